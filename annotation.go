@@ -0,0 +1,131 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+annotation.go
+Implementation of the output of reports as workflow commands of GitHub
+Actions. Each involved site of a report is printed as an error or warning
+annotation, so that the findings are shown inline on pull requests, if the
+tests are run with the detector enabled.
+*/
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OutputFormat describes in which format the reports are printed
+type OutputFormat int
+
+const (
+	// OutputText prints the reports as text to stderr
+	OutputText OutputFormat = iota
+	// OutputGitHub additionally prints the reports as annotations of
+	// GitHub Actions to stdout
+	OutputGitHub
+	// OutputJSON prints each report as one JSON object per line to stderr
+	// instead of the text, see ReadReports
+	OutputJSON
+)
+
+// escape the message of a workflow command
+//  Args:
+//   s (string): message
+//  Returns:
+//   (string): escaped message
+func escapeAnnotationData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+// escape a property of a workflow command
+//  Args:
+//   s (string): property value
+//  Returns:
+//   (string): escaped property value
+func escapeAnnotationProperty(s string) string {
+	s = escapeAnnotationData(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	return strings.ReplaceAll(s, ",", "%2C")
+}
+
+// get the path of a file relative to the workspace, so that GitHub can match
+// it with the files of the repository
+//  Args:
+//   file (string): absolute path of the file
+//  Returns:
+//   (string): path relative to the workspace, file if it is not in the
+//    workspace
+func workspacePath(file string) string {
+	root := os.Getenv("GITHUB_WORKSPACE")
+	if root == "" {
+		var err error
+		if root, err = os.Getwd(); err != nil {
+			return file
+		}
+	}
+	rel, err := filepath.Rel(root, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return file
+	}
+	return filepath.ToSlash(rel)
+}
+
+// print a report as annotations, one for each involved site
+//  Args:
+//   w (io.Writer): writer of the workflow commands
+//   r (Report): report to print
+//  Returns:
+//   nil
+func writeAnnotations(w io.Writer, r Report) {
+	level := "error"
+	if r.Type == ReportWarning {
+		level = "warning"
+	}
+
+	message := r.Title
+	if r.ID != "" {
+		message += " (Cycle ID: " + r.ID + ")"
+	}
+	message += ". See the log for the complete report."
+
+	if len(r.Sites) == 0 {
+		fmt.Fprintf(w, "::%s title=%s::%s\n", level,
+			escapeAnnotationProperty(r.Title), escapeAnnotationData(message))
+		return
+	}
+
+	for i, site := range r.Sites {
+		fmt.Fprintf(w, "::%s file=%s,line=%d,title=%s::%s\n", level,
+			escapeAnnotationProperty(workspacePath(site.File)), site.Line,
+			escapeAnnotationProperty(r.Title),
+			escapeAnnotationData(fmt.Sprint(message, " Site ", i+1, " of ",
+				len(r.Sites), ".")))
+	}
+}
@@ -0,0 +1,199 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+barrier.go
+This file implements a reusable barrier for a fixed number of routines.
+Routines waiting at the barrier are registered in the wait-for graph, so that
+deadlocks in which routines wait at the barrier, while a missing routine is
+blocked by a lock held by one of the waiting routines, are found by the
+periodical detection.
+*/
+
+import (
+	"sync"
+
+	"github.com/ErikKassubek/Deadlock-Go/internal/waitfor"
+)
+
+// type to implement a reusable barrier
+type Barrier struct {
+	// number of routines which have to arrive, before the barrier is released
+	n int
+	// lock to prevent concurrent access to the barrier
+	lock *sync.Mutex
+	// condition to wait for the release of the barrier
+	cond *sync.Cond
+	// number of the current round of the barrier
+	generation uint64
+	// indexes of the routines which arrived in the current round
+	arrived map[int]struct{}
+	// number of routines which arrived in the current round
+	count int
+	// indexes of all routines which ever waited at the barrier
+	participants map[int]struct{}
+	// info about the creation of the barrier
+	context []callerInfo
+	// detector the barrier belongs to
+	detector *Detector
+}
+
+// create a new barrier for n routines
+//  Args:
+//   n (int): number of routines which have to arrive, before the barrier is released
+//  Returns:
+//   (*Barrier): the created barrier
+func NewBarrier(n int) *Barrier {
+	return newBarrier(defaultDetector, n, 2)
+}
+
+// create a new barrier and save the caller information of the creation
+//  Args:
+//   d (*Detector): detector the barrier belongs to
+//   n (int): number of routines which have to arrive, before the barrier is released
+//   skip (int): number of stack frames between the user code and the
+//    callerSite call
+//  Returns:
+//   (*Barrier): the created barrier
+func newBarrier(d *Detector, n int, skip int) *Barrier {
+	// initialize detector if necessary
+	if !d.initialized {
+		d.initialize()
+	}
+
+	if n <= 0 {
+		panic("The number of routines of a barrier must be positive.")
+	}
+
+	b := Barrier{
+		n:            n,
+		lock:         &sync.Mutex{},
+		arrived:      make(map[int]struct{}),
+		participants: make(map[int]struct{}),
+		detector:     d,
+	}
+	b.cond = sync.NewCond(b.lock)
+
+	// save the position of the NewBarrier call
+	_, file, line, _ := callerSite(skip)
+	b.context = append(b.context, newInfo(file, line, "", true, false, ""))
+
+	return &b
+}
+
+// ============ GETTER ============
+
+// getter for context
+//  Returns:
+//   (*[]callerInfo): caller info of the barrier
+func (b *Barrier) getContext() *[]callerInfo {
+	return &b.context
+}
+
+// getter for the name of the resource type
+//  Returns:
+//   (string): name of the resource type
+func (b *Barrier) getResourceName() string {
+	return "Barrier"
+}
+
+// get the routines a routine waiting at the barrier waits for. These are
+// all known participants which have not arrived in the current round. If not
+// all participants of the barrier are known yet, the barrier can also be
+// released by a routine, which has not waited at it before, and the
+// participants are only inferred blockers
+//  Args:
+//   routineIndex (int): index of the waiting routine
+//   read (bool): not used for barriers
+//  Returns:
+//   (waitfor.Blockers): the known participants which have not arrived
+func (b *Barrier) getBlockers(routineIndex int, read bool) waitfor.Blockers {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	res := make([]int, 0)
+	for index := range b.participants {
+		if _, ok := b.arrived[index]; !ok {
+			res = append(res, index)
+		}
+	}
+	if len(b.participants) < b.n {
+		return waitfor.Blockers{Inferred: res, Unknown: true}
+	}
+	return waitfor.Blockers{Known: res}
+}
+
+// ============ FUNCTIONS ============
+
+// Wait blocks until n routines have called Wait. Afterwards the barrier
+// can be used again.
+//  Returns:
+//   nil
+func (b *Barrier) Wait() {
+	d := b.detector
+	index := -1
+	if d.opts.Activated && d.opts.PeriodicDetection {
+		// create new routine, if not initialized
+		index = d.registerRoutine()
+	}
+
+	b.lock.Lock()
+
+	b.count++
+	if index != -1 {
+		b.arrived[index] = struct{}{}
+		b.participants[index] = struct{}{}
+	}
+
+	// the last routine releases the barrier
+	if b.count == b.n {
+		b.count = 0
+		b.arrived = make(map[int]struct{})
+		b.generation++
+		b.cond.Broadcast()
+		b.lock.Unlock()
+		return
+	}
+
+	generation := b.generation
+	b.lock.Unlock()
+
+	// register the wait in the wait-for graph. b.lock is not held, because
+	// the detection locks b while the wait-for graph is locked
+	if index != -1 {
+		_, file, line, _ := callerSite(1)
+		d.startWaiting(index, b, false, newInfo(file, line, "", false, false, ""))
+	}
+
+	b.lock.Lock()
+	for generation == b.generation {
+		b.cond.Wait()
+	}
+	b.lock.Unlock()
+
+	if index != -1 {
+		d.stopWaiting(index)
+	}
+}
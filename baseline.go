@@ -0,0 +1,195 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+baseline.go
+This file implements the comparison of the found cycles with a committed
+baseline. The baseline file contains the identifiers of the known cycles, one
+per line. Reports of cycles in the baseline are suppressed, so that only new
+cycles are reported, and if FailOnNewCycles is set, the program is
+terminated with a nonzero exit code after a comprehensive detection, which
+found new cycles. If the baseline file does not exist, it is created with
+the cycles of the first comprehensive detection. The schema version of the
+baseline is written in the comment "# schema <version>".
+*/
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// exit code of the program, if the comprehensive detection found cycles,
+// which are not in the baseline
+const newCyclesExitCode = 3
+
+// type to compare the found cycles with the baseline
+type baseline struct {
+	// identifiers of the cycles in the baseline
+	known map[string]bool
+	// true if the baseline file does not exist yet
+	missing bool
+	// titles of the cycles found by the running comprehensive detection,
+	// which are not in the baseline, by their identifiers
+	found map[string]string
+}
+
+// read the baseline file. Lines are split at the first space, the part
+// before is the identifier of a cycle. Empty lines and lines starting with
+// # are ignored, except for the schema version
+//  Args:
+//   path (string): path of the baseline file
+//  Returns:
+//   (*baseline): the baseline
+func readBaseline(path string) *baseline {
+	b := &baseline{known: make(map[string]bool), found: make(map[string]string)}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		b.missing = true
+		return b
+	}
+	if err != nil {
+		panic(fmt.Sprint("Could not read the baseline file ", path, ": ", err))
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if version := strings.TrimPrefix(line, "# schema "); version != line {
+			v, err := strconv.Atoi(strings.TrimSpace(version))
+			if err == nil {
+				err = CheckSchemaVersion(v)
+			}
+			if err != nil {
+				panic(fmt.Sprint("Could not read the baseline file ", path, ": ", err))
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		b.known[strings.Fields(line)[0]] = true
+	}
+	if err := scanner.Err(); err != nil {
+		panic(fmt.Sprint("Could not read the baseline file ", path, ": ", err))
+	}
+	return b
+}
+
+// check if a report is suppressed by the baseline. Cycles, which are not
+// in the baseline, are saved for the verdict of the comprehensive detection
+//  Args:
+//   r (Report): the report
+//  Returns:
+//   (bool): true if the cycle of the report is in the baseline
+func (d *Detector) inBaseline(r Report) bool {
+	if d.opts.BaselineFile == "" || r.ID == "" {
+		return false
+	}
+
+	d.baselineLock.Lock()
+	defer d.baselineLock.Unlock()
+
+	if d.baseline == nil {
+		d.baseline = readBaseline(d.opts.BaselineFile)
+	}
+	if d.baseline.known[r.ID] {
+		return true
+	}
+	d.baseline.found[r.ID] = r.Title
+	return false
+}
+
+// finish the comparison with the baseline after a comprehensive detection.
+// If the baseline file does not exist, it is created with the found cycles.
+// Otherwise the program is terminated, if new cycles were found and
+// FailOnNewCycles is set
+//  Returns:
+//   nil
+func (d *Detector) finishBaseline() {
+	if d.opts.BaselineFile == "" {
+		return
+	}
+
+	d.baselineLock.Lock()
+	if d.baseline == nil {
+		d.baseline = readBaseline(d.opts.BaselineFile)
+	}
+	b := d.baseline
+	found := b.found
+	b.found = make(map[string]string)
+	missing := b.missing
+	if missing {
+		// the created baseline is used for the following detections
+		for id := range found {
+			b.known[id] = true
+		}
+		b.missing = false
+	}
+	d.baselineLock.Unlock()
+
+	if missing {
+		d.writeBaseline(found)
+		return
+	}
+
+	if len(found) == 0 || !d.opts.FailOnNewCycles {
+		return
+	}
+	fmt.Fprint(os.Stderr, "deadlock: cycles, which are not in the baseline ",
+		d.opts.BaselineFile, ": ", len(found), "\n")
+	if d.exit && exitSupported {
+		os.Exit(newCyclesExitCode)
+	}
+}
+
+// create the baseline file
+//  Args:
+//   found (map[string]string): titles of the cycles by their identifiers
+//  Returns:
+//   nil
+func (d *Detector) writeBaseline(found map[string]string) {
+	ids := make([]string, 0, len(found))
+	for id := range found {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var out strings.Builder
+	out.WriteString("# known cycles of the deadlock detector\n")
+	fmt.Fprintln(&out, "# schema", SchemaVersion)
+	for _, id := range ids {
+		fmt.Fprintln(&out, id, "#", found[id])
+	}
+	if err := os.WriteFile(d.opts.BaselineFile, []byte(out.String()), 0644); err != nil {
+		panic(fmt.Sprint("Could not write the baseline file ", d.opts.BaselineFile,
+			": ", err))
+	}
+}
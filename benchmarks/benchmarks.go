@@ -0,0 +1,259 @@
+package benchmarks
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: benchmarks
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+benchmarks.go
+This package generates synthetic lock workloads and measures the overhead of
+the detector on the lock operations and the time of the comprehensive
+detection, so that performance regressions in the hot path can be measured.
+A workload runs the same sequence of acquisitions once with the locks of the
+sync package and once with the locks of a detector. The nested locks are
+always acquired in the same order, so that the workloads never block
+forever. The command cmd/deadlock-bench runs workloads from the command line.
+*/
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	deadlock "github.com/ErikKassubek/Deadlock-Go"
+)
+
+// Workload describes a synthetic lock workload. The time of the comprehensive
+// detection grows exponentially with the number of dependencies, large
+// workloads should therefore limit the search with MaxSearchTime
+type Workload struct {
+	// number of routines, which run concurrently
+	Goroutines int
+	// number of locks
+	Locks int
+	// number of locks, which are held at the same time
+	Depth int
+	// probability between 0 and 1, that an acquisition uses one of the hot
+	// locks, which are shared by all routines, instead of a random lock
+	Contention float64
+	// number of nested acquisitions of each routine
+	Iterations int
+	// seed of the random sequence of acquisitions
+	Seed int64
+}
+
+// Result contains the measurements of a workload
+type Result struct {
+	// duration of the workload with the locks of the sync package
+	Baseline time.Duration
+	// duration of the workload with the locks of the detector
+	Instrumented time.Duration
+	// duration of the comprehensive detection after the workload
+	Detection time.Duration
+	// number of reports of the detector
+	Reports int
+	// true if the comprehensive detection was cut short by the limits of
+	// the search
+	Truncated bool
+}
+
+// DefaultWorkload returns a small workload
+//  Returns:
+//   (Workload): the workload
+func DefaultWorkload() Workload {
+	return Workload{
+		Goroutines: 4,
+		Locks:      16,
+		Depth:      2,
+		Contention: 0.2,
+		Iterations: 100,
+		Seed:       1,
+	}
+}
+
+// Overhead returns the factor, by which the detector slows down the lock
+// operations of the workload
+//  Returns:
+//   (float64): ratio of the instrumented and the baseline duration
+func (r Result) Overhead() float64 {
+	if r.Baseline == 0 {
+		return 0
+	}
+	return float64(r.Instrumented) / float64(r.Baseline)
+}
+
+// String returns a one-line summary of the result
+//  Returns:
+//   (string): the summary
+func (r Result) String() string {
+	truncated := ""
+	if r.Truncated {
+		truncated = " (truncated)"
+	}
+	return fmt.Sprintf("baseline %v, instrumented %v (%.2fx), detection %v%s, %d reports",
+		r.Baseline, r.Instrumented, r.Overhead(), r.Detection, truncated, r.Reports)
+}
+
+// check if the workload is valid
+//  Returns:
+//   (error): nil if the workload is valid, an error describing the first
+//    invalid field otherwise
+func (w Workload) validate() error {
+	switch {
+	case w.Goroutines <= 0:
+		return fmt.Errorf("benchmarks: Goroutines must be positive, got %d", w.Goroutines)
+	case w.Locks <= 0:
+		return fmt.Errorf("benchmarks: Locks must be positive, got %d", w.Locks)
+	case w.Depth <= 0 || w.Depth > w.Locks:
+		return fmt.Errorf("benchmarks: Depth must be between 1 and Locks, got %d", w.Depth)
+	case w.Contention < 0 || w.Contention > 1:
+		return fmt.Errorf("benchmarks: Contention must be between 0 and 1, got %v",
+			w.Contention)
+	case w.Iterations < 0:
+		return fmt.Errorf("benchmarks: Iterations must not be negative, got %d",
+			w.Iterations)
+	}
+	return nil
+}
+
+// create the sequences of acquisitions of the routines. Each nesting is
+// sorted by the indexes of the locks, so that all routines use the same
+// lock order
+//  Returns:
+//   ([][][]int): indexes of the locks of each nesting of each routine
+func (w Workload) sequences() [][][]int {
+	rng := rand.New(rand.NewSource(w.Seed))
+	hot := w.Depth
+
+	res := make([][][]int, w.Goroutines)
+	for g := range res {
+		res[g] = make([][]int, w.Iterations)
+		for i := range res[g] {
+			chosen := make(map[int]bool)
+			for len(chosen) < w.Depth {
+				if rng.Float64() < w.Contention {
+					chosen[rng.Intn(hot)] = true
+				} else {
+					chosen[rng.Intn(w.Locks)] = true
+				}
+			}
+			nesting := make([]int, 0, w.Depth)
+			for l := 0; l < w.Locks; l++ {
+				if chosen[l] {
+					nesting = append(nesting, l)
+				}
+			}
+			res[g][i] = nesting
+		}
+	}
+	return res
+}
+
+// run the acquisitions of all routines concurrently
+//  Args:
+//   seqs ([][][]int): acquisitions of the routines
+//   locks ([]sync.Locker): the locks
+//  Returns:
+//   (time.Duration): duration until all routines have finished
+func runSequences(seqs [][][]int, locks []sync.Locker) time.Duration {
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for _, seq := range seqs {
+		wg.Add(1)
+		go func(seq [][]int) {
+			defer wg.Done()
+			<-start
+			for _, nesting := range seq {
+				for _, l := range nesting {
+					locks[l].Lock()
+				}
+				for i := len(nesting) - 1; i >= 0; i-- {
+					locks[nesting[i]].Unlock()
+				}
+			}
+		}(seq)
+	}
+
+	begin := time.Now()
+	close(start)
+	wg.Wait()
+	return time.Since(begin)
+}
+
+// Run runs a workload with the locks of the sync package and with the locks
+// of a new detector with the options opts, and measures the durations.
+// The periodical detection is disabled, so that it does not influence the
+// measurement of the lock operations
+//  Args:
+//   w (Workload): the workload
+//   opts (deadlock.Options): options of the detector, the sizes of the lock
+//    trees are increased if they are too small for the workload
+//  Returns:
+//   (Result): the measurements
+//   (error): error if the workload or the options are invalid
+func Run(w Workload, opts deadlock.Options) (Result, error) {
+	if err := w.validate(); err != nil {
+		return Result{}, err
+	}
+
+	opts.PeriodicDetection = false
+	if opts.MaxRoutines < w.Goroutines+1 {
+		opts.MaxRoutines = w.Goroutines + 1
+	}
+	if opts.MaxDependencies < w.Iterations*w.Depth {
+		opts.MaxDependencies = w.Iterations * w.Depth
+	}
+	if opts.MaxNumberOfDependentLocks < w.Depth {
+		opts.MaxNumberOfDependentLocks = w.Depth
+	}
+
+	res := Result{}
+	d := deadlock.NewDetector(func(r deadlock.Report) {
+		if r.Truncation != nil {
+			res.Truncated = true
+			return
+		}
+		res.Reports++
+	})
+	if err := d.Configure(opts); err != nil {
+		return Result{}, err
+	}
+
+	seqs := w.sequences()
+
+	baseline := make([]sync.Locker, w.Locks)
+	instrumented := make([]sync.Locker, w.Locks)
+	for i := range baseline {
+		baseline[i] = &sync.Mutex{}
+		instrumented[i] = d.NewLock()
+	}
+
+	res.Baseline = runSequences(seqs, baseline)
+	res.Instrumented = runSequences(seqs, instrumented)
+
+	begin := time.Now()
+	d.FindPotentialDeadlocks()
+	res.Detection = time.Since(begin)
+
+	return res, nil
+}
@@ -0,0 +1,93 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+budget.go
+Implementation of the budget of the periodical detection. With a budget,
+the periodical detection may only use the given fraction of one CPU. Each
+interval of the periodical detection adds the budget of the interval to a
+credit, each pass subtracts the time it took. If the credit is used up,
+the following passes are skipped, until the credit is positive again. The
+time of a pass is measured as wall time, which is at least the CPU time of
+the detection, so the budget is kept even if the pass is blocked.
+*/
+
+import "time"
+
+// budget of the periodical detection
+type detectionBudget struct {
+	// remaining time, which can be used by the detection
+	credit time.Duration
+	// time, which is added to the credit in each interval
+	perInterval time.Duration
+}
+
+// create a new budget
+//  Args:
+//   interval (time.Duration): interval of the periodical detection
+//   fraction (float64): fraction of one CPU, which can be used by the
+//    detection
+//  Returns:
+//   (*detectionBudget): the budget, nil if the detection is not limited
+func newDetectionBudget(interval time.Duration, fraction float64) *detectionBudget {
+	if fraction <= 0 {
+		return nil
+	}
+
+	perInterval := time.Duration(float64(interval) * fraction)
+	return &detectionBudget{
+		credit:      perInterval,
+		perInterval: perInterval,
+	}
+}
+
+// add the budget of a new interval and check if a pass can be run.
+// The credit is not accumulated over more than one interval, so that
+// phases without detection do not allow long phases with expensive passes
+//  Returns:
+//   (bool): true if the pass can be run, false if it must be skipped
+func (b *detectionBudget) allow() bool {
+	if b == nil {
+		return true
+	}
+
+	b.credit += b.perInterval
+	if b.credit > b.perInterval {
+		b.credit = b.perInterval
+	}
+	return b.credit > 0
+}
+
+// subtract the time of a pass from the credit
+//  Args:
+//   cost (time.Duration): time of the pass
+//  Returns:
+//   nil
+func (b *detectionBudget) spend(cost time.Duration) {
+	if b == nil {
+		return
+	}
+	b.credit -= cost
+}
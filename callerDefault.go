@@ -0,0 +1,144 @@
+//go:build !deadlock_embedded
+
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+callerDefault.go
+This file implements the collection of the sites of lock operations for all
+builds except the embedded mode. The sites are resolved to file, line and
+function with the symbol information of the runtime. Resolved sites are
+cached by the program counters of the call stack, so that a site, which is
+used again, is not resolved again.
+*/
+
+import (
+	"runtime"
+	"sync"
+)
+
+// maximum number of call stacks in the cache of the resolved sites. Call
+// stacks, which are used after the cache is full, are resolved on each use
+const maxCachedFrames = 1 << 14
+
+// program counters of a call stack, which identify the resolved site
+type framesKey [32]uintptr
+
+// resolved site of a call stack
+type userSite struct {
+	// first frame outside of the deadlock packages and the sync package
+	frame runtime.Frame
+	// true if the call was made by the Wait of a condition variable
+	condWait bool
+}
+
+// cache of the resolved sites
+var frameCache = struct {
+	sync.Mutex
+	m map[framesKey]userSite
+}{m: make(map[framesKey]userSite)}
+
+// false, the binary is not built in the embedded mode
+const embeddedMode = false
+
+// default sizes of the preallocated structures. The holding sets grow
+// dynamically
+const (
+	defaultMaxDependencies           = 4096
+	defaultMaxNumberOfDependentLocks = 0
+	defaultMaxRoutines               = 1024
+	defaultMaxCallStackSize          = 2048
+	defaultHoldingSetBound           = 1024
+)
+
+// get the program counter, file and line of a caller
+//  Args:
+//   skip (int): number of stack frames to ascend, 0 is the caller of callerSite
+//  Returns:
+//   (uintptr): program counter of the caller
+//   (string): file of the caller
+//   (int): line of the caller
+//   (bool): false if the caller could not be found
+func callerSite(skip int) (uintptr, string, int, bool) {
+	return runtime.Caller(skip + 1)
+}
+
+// get the first frame outside of the deadlock packages and the sync package
+//  Returns:
+//   (runtime.Frame): frame of the caller
+func userFrame() runtime.Frame {
+	frame, _ := userFrameCond()
+	return frame
+}
+
+// get the first frame outside of the deadlock packages and the sync package
+// and whether the call was made by the Wait of a condition variable, which
+// reacquires its lock
+//  Returns:
+//   (runtime.Frame): frame of the caller
+//   (bool): true if the call was made by sync.Cond.Wait
+func userFrameCond() (runtime.Frame, bool) {
+	var pc framesKey
+	n := runtime.Callers(2, pc[:])
+
+	frameCache.Lock()
+	site, ok := frameCache.m[pc]
+	frameCache.Unlock()
+	if ok {
+		return site.frame, site.condWait
+	}
+
+	frames := runtime.CallersFrames(pc[:n])
+	for {
+		frame, more := frames.Next()
+		site.frame = frame
+		if frame.Function == condWaitFunction {
+			site.condWait = true
+		}
+		if !isDetectorFrame(frame.Function) || !more {
+			break
+		}
+	}
+
+	frameCache.Lock()
+	if len(frameCache.m) < maxCachedFrames {
+		frameCache.m[pc] = site
+	}
+	frameCache.Unlock()
+	return site.frame, site.condWait
+}
+
+// get the full name of the function of a program counter
+//  Args:
+//   pc (uintptr): program counter
+//  Returns:
+//   (string): name of the function, empty if it is unknown
+func funcName(pc uintptr) string {
+	f := runtime.FuncForPC(pc)
+	if f == nil {
+		return ""
+	}
+	return f.Name()
+}
@@ -0,0 +1,134 @@
+//go:build deadlock_embedded
+
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+callerEmbedded.go
+This file implements the collection of the sites of lock operations for the
+embedded mode (build tag deadlock_embedded). On memory-constrained targets
+the sites are not resolved to files and lines. A site is identified by the
+offset of its program counter to the function siteAnchor, which is the same in
+every run of the binary. Reports show the site as "pc <offset>", and the
+offsets are resolved offline with the symbol table of the binary:
+
+	deadlock-overlay -symbols ./app < report.txt
+*/
+
+import (
+	"reflect"
+	"runtime"
+)
+
+// true, the binary is built in the embedded mode
+const embeddedMode = true
+
+// default sizes of the preallocated structures
+const (
+	defaultMaxDependencies           = 256
+	defaultMaxNumberOfDependentLocks = 16
+	defaultMaxRoutines               = 64
+	defaultMaxCallStackSize          = 0
+	defaultHoldingSetBound           = 0
+)
+
+// file of all sites in the embedded mode
+const siteFile = "pc"
+
+// function to which the offsets of the sites are relative. The tool looks up
+// the function in the symbol table to resolve the offsets
+func siteAnchor() {}
+
+// program counter to which the offsets of the sites are relative
+var siteBase = reflect.ValueOf(siteAnchor).Pointer()
+
+// get the offset of a program counter to siteBase
+//  Args:
+//   pc (uintptr): program counter
+//  Returns:
+//   (int): the offset
+func siteOffset(pc uintptr) int {
+	return int(int64(pc) - int64(siteBase))
+}
+
+// get the site of a caller. The program counter is not resolved
+//  Args:
+//   skip (int): number of stack frames to ascend, 0 is the caller of callerSite
+//  Returns:
+//   (uintptr): program counter of the caller
+//   (string): siteFile
+//   (int): offset of the program counter of the caller
+//   (bool): false if the caller could not be found
+func callerSite(skip int) (uintptr, string, int, bool) {
+	var pc [1]uintptr
+	if runtime.Callers(skip+2, pc[:]) == 0 {
+		return 0, "", 0, false
+	}
+	// the program counter is the return address, the call is one byte before
+	return pc[0] - 1, siteFile, siteOffset(pc[0] - 1), true
+}
+
+// get the site of the first frame outside of the deadlock packages and the
+// sync package. Only the names of the functions are looked up, the file and
+// line are not resolved
+//  Returns:
+//   (runtime.Frame): frame of the caller with the offset as line
+func userFrame() runtime.Frame {
+	frame, _ := userFrameCond()
+	return frame
+}
+
+// get the first frame outside of the deadlock packages and the sync package
+// and whether the call was made by the Wait of a condition variable
+//  Returns:
+//   (runtime.Frame): frame of the caller
+//   (bool): true if the call was made by sync.Cond.Wait
+func userFrameCond() (runtime.Frame, bool) {
+	var pc [16]uintptr
+	n := runtime.Callers(2, pc[:])
+	frame := runtime.Frame{}
+	condWait := false
+	for i := 0; i < n; i++ {
+		frame = runtime.Frame{PC: pc[i] - 1, File: siteFile,
+			Line: siteOffset(pc[i] - 1)}
+		f := runtime.FuncForPC(frame.PC)
+		if f != nil && f.Name() == condWaitFunction {
+			condWait = true
+		}
+		if f == nil || !isDetectorFrame(f.Name()) {
+			break
+		}
+	}
+	return frame, condWait
+}
+
+// function names are not collected in the embedded mode
+//  Args:
+//   pc (uintptr): program counter
+//  Returns:
+//   (string): empty string
+func funcName(pc uintptr) string {
+	return ""
+}
@@ -25,34 +25,227 @@ Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
 
 /*
 callerInfo.go
-Implementation of a struct to save the caller info of locks
+Implementation of a struct to save the caller info of locks.
+The same sites are recorded again and again in long runs. The file, line,
+function and call stack of a site are therefore interned: each distinct
+site is stored once in the site table, and a caller info only refers to
+its record.
 */
 
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/petermattis/goid"
+)
+
 // Type to save info about caller.
 // A caller is an instance where a lock was created or locked.
 type callerInfo struct {
+	// interned site of the call, nil for an unknown site
+	site *siteInfo
+	// true: create, false: lock
+	create bool
+	// true if the lock was acquired as an upgrade of a reader lock
+	upgrade bool
+	// true if the lock was acquired during the initialization of the packages
+	initPhase bool
+	// true if the lock was reacquired by the Wait of a condition variable
+	condWait bool
+	// time of the call, relative to the creation of the detector
+	timestamp time.Duration
+}
+
+// site of a call, which is shared by all callers with the same site
+type siteInfo struct {
 	// name of the file with full path
 	file string
 	// number of the line, in which the lock is created or locked
 	line int
-	// true: create, false: lock
-	create bool
+	// full name of the function in which the call happened
+	function string
 	// string to save the call stack
 	callStacks string
 }
 
+// table of the interned sites
+var sites = struct {
+	sync.Mutex
+	m map[siteInfo]*siteInfo
+}{m: make(map[siteInfo]*siteInfo)}
+
+// get the interned record of a site
+//  Args:
+//   s (siteInfo): the site
+//  Returns:
+//   (*siteInfo): the record of the site in the site table
+func internSite(s siteInfo) *siteInfo {
+	sites.Lock()
+	defer sites.Unlock()
+
+	if interned, ok := sites.m[s]; ok {
+		return interned
+	}
+	interned := &s
+	sites.m[s] = interned
+	return interned
+}
+
+// getter for the file of the site
+//  Returns:
+//   (string): name of the file with full path, empty if it is unknown
+func (c callerInfo) file() string {
+	if c.site == nil {
+		return ""
+	}
+	return c.site.file
+}
+
+// getter for the line of the site
+//  Returns:
+//   (int): line of the call, 0 if it is unknown
+func (c callerInfo) line() int {
+	if c.site == nil {
+		return 0
+	}
+	return c.site.line
+}
+
+// getter for the function of the site
+//  Returns:
+//   (string): full name of the function, empty if it is unknown
+func (c callerInfo) function() string {
+	if c.site == nil {
+		return ""
+	}
+	return c.site.function
+}
+
+// getter for the call stack of the site
+//  Returns:
+//   (string): the call stack, empty if it was not collected
+func (c callerInfo) callStacks() string {
+	if c.site == nil {
+		return ""
+	}
+	return c.site.callStacks
+}
+
 // newInfo creates and returns a new callerInfo
 //  Args:
 //   file (string): name of the file
 //   line (int): line in the file where the call happened
+//   function (string): full name of the function in which the call
+//    happened, empty if it is unknown
 //   create (bool): set to true if the call was a lock creation or false, if it was a lock acquiring
+//   upgrade (bool): set to true if the call was an upgrade of a reader lock
+//   callStack (string): call stack of the call
 //  Returns:
 //   callerInfo: the created callerInfo
-func newInfo(file string, line int, create bool, callStack string) callerInfo {
+func newInfo(file string, line int, function string, create bool, upgrade bool,
+	callStack string) callerInfo {
 	return callerInfo{
-		file:       file,
-		line:       line,
-		create:     create,
-		callStacks: callStack,
+		site: internSite(siteInfo{
+			file:       file,
+			line:       line,
+			function:   function,
+			callStacks: callStack,
+		}),
+		create:  create,
+		upgrade: upgrade,
+	}
+}
+
+// get the file and line of the first caller outside of the deadlock packages
+// and the sync package
+//  Returns:
+//   (string): file of the caller
+//   (int): line of the caller
+func userCaller() (string, int) {
+	frame := userFrame()
+	return frame.File, frame.Line
+}
+
+// full name of the function of a condition variable, which releases and
+// reacquires its lock
+const condWaitFunction = "sync.(*Cond).Wait"
+
+// check if a function belongs to the deadlock packages or the sync package
+//  Args:
+//   function (string): full name of the function
+//  Returns:
+//   (bool): true if the function belongs to the detector, false otherwise
+func isDetectorFrame(function string) bool {
+	return strings.HasPrefix(function, "github.com/ErikKassubek/Deadlock-Go") ||
+		strings.HasPrefix(function, "sync.")
+}
+
+// check if a frame is removed from the collected call stacks
+//  Args:
+//   function (string): full name of the function of the frame
+//  Returns:
+//   (bool): true if the frame is removed, false otherwise
+func (d *Detector) isTrimmedFrame(function string) bool {
+	if d.opts.TrimCallStack && (isDetectorFrame(function) ||
+		strings.HasPrefix(function, "runtime.")) {
+		return true
+	}
+	for _, prefix := range d.opts.TrimmedPackages {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// collect the call stack of the current routine. The stack contains at most
+// CallStackDepth frames and MaxCallStackSize bytes. Frames of the detector,
+// the runtime and the trimmed packages are removed
+//  Returns:
+//   (string): the call stack
+func (d *Detector) callStack() string {
+	return d.formatStack(goid.Get(), stackPCs())
+}
+
+// format the call stack of a routine like the call stack of callStack
+//  Args:
+//   id (int64): id of the goroutine
+//   pc ([]uintptr): program counters of the call stack
+//  Returns:
+//   (string): the call stack, empty if it has no program counters
+func (d *Detector) formatStack(id int64, pc []uintptr) string {
+	if len(pc) == 0 {
+		return ""
+	}
+
+	// the trimmed frames are not counted for the depth
+	frames := runtime.CallersFrames(pc)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "goroutine %d [running]:\n", id)
+	count := 0
+	for {
+		frame, more := frames.Next()
+		if !d.isTrimmedFrame(frame.Function) {
+			if d.opts.CallStackDepth > 0 && count >= d.opts.CallStackDepth {
+				break
+			}
+			fmt.Fprintf(&b, "%s(...)\n\t%s:%d\n", frame.Function, frame.File,
+				frame.Line)
+			count++
+		}
+		if !more {
+			break
+		}
+	}
+	b.WriteString("\n")
+
+	res := b.String()
+	if len(res) > d.opts.MaxCallStackSize {
+		res = res[:d.opts.MaxCallStackSize]
 	}
+	return res
 }
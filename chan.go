@@ -0,0 +1,322 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+chan.go
+This file implements a channel, whose blocking operations are included in
+the periodical detection. A routine, which is blocked in Send, can only be
+released by a routine receiving from the channel, and a routine blocked in
+Recv only by a routine sending on or closing the channel. The routines,
+which used the other side of the channel before, are therefore inferred as
+the blockers of a blocked routine in the wait-for graph. Another routine can
+still use the other side, so a cycle over these routines is only reported as
+a potential deadlock and the program is not terminated. If no routine used
+the other side yet and the blocked routine holds a lock, all other blocked
+routines can be the blocker, so that a routine on the other side, which
+waits for the lock, is found before its first operation on the channel.
+Without a held lock, such a cycle is not possible and the routine is not
+considered as blocked by unknown routines, e.g. if it waits for the first
+value of a producer, which was not started yet. A routine, which holds a
+lock while it is blocked on the channel, and a routine on the other side of
+the channel, which waits for the lock, build such a cycle in the wait-for
+graph. The operations only register a wait, if they would block, so that the
+communication on channels, which is not blocked, is not slowed down by the
+wait-for graph.
+*/
+
+import (
+	"sync"
+
+	"github.com/ErikKassubek/Deadlock-Go/internal/waitfor"
+)
+
+// type to implement a channel with elements of type T
+type Chan[T any] struct {
+	// channel for the actual communication
+	ch chan T
+	// lock to prevent concurrent access to senders and receivers
+	lock *sync.Mutex
+	// indexes of all routines which ever sent on or closed the channel
+	senders map[int]struct{}
+	// indexes of all routines which ever received from the channel
+	receivers map[int]struct{}
+	// true for the blocked routines, which hold a lock while they are
+	// blocked on the channel
+	holding map[int]bool
+	// info about the creation of the channel
+	context []callerInfo
+	// detector the channel belongs to
+	detector *Detector
+}
+
+// create a new channel of the default detector
+//  Args:
+//   size (int): buffer size of the channel, 0 for an unbuffered channel
+//  Returns:
+//   (*Chan[T]): the created channel
+func NewChan[T any](size int) *Chan[T] {
+	return newChan[T](defaultDetector, size, 2)
+}
+
+// create a new channel, which is checked by a detector. Methods can not
+// have type parameters, therefore this is not a method of the detector
+//  Args:
+//   d (*Detector): detector the channel belongs to
+//   size (int): buffer size of the channel, 0 for an unbuffered channel
+//  Returns:
+//   (*Chan[T]): the created channel
+func NewChanOf[T any](d *Detector, size int) *Chan[T] {
+	return newChan[T](d, size, 2)
+}
+
+// create a new channel and save the caller information of the creation
+//  Args:
+//   d (*Detector): detector the channel belongs to
+//   size (int): buffer size of the channel, 0 for an unbuffered channel
+//   skip (int): number of stack frames between the user code and the
+//    callerSite call
+//  Returns:
+//   (*Chan[T]): the created channel
+func newChan[T any](d *Detector, size int, skip int) *Chan[T] {
+	// initialize detector if necessary
+	if !d.initialized {
+		d.initialize()
+	}
+
+	c := Chan[T]{
+		ch:        make(chan T, size),
+		lock:      &sync.Mutex{},
+		senders:   make(map[int]struct{}),
+		receivers: make(map[int]struct{}),
+		holding:   make(map[int]bool),
+		detector:  d,
+	}
+
+	// save the position of the NewChan call
+	_, file, line, _ := callerSite(skip)
+	c.context = append(c.context, newInfo(file, line, "", true, false, ""))
+
+	return &c
+}
+
+// ============ GETTER ============
+
+// getter for context
+//  Returns:
+//   (*[]callerInfo): caller info of the channel
+func (c *Chan[T]) getContext() *[]callerInfo {
+	return &c.context
+}
+
+// getter for the name of the resource type
+//  Returns:
+//   (string): name of the resource type
+func (c *Chan[T]) getResourceName() string {
+	return "Chan"
+}
+
+// get the routines a routine blocked on the channel waits for. These are the
+// routines, which used the other side of the channel before. Any other
+// routine can also use the other side, they are therefore only inferred
+// blockers
+//  Args:
+//   routineIndex (int): index of the blocked routine
+//   read (bool): true if the routine is blocked in Recv, false if it is
+//    blocked in Send
+//  Returns:
+//   (waitfor.Blockers): the routines, which used the other side, as inferred
+//    blockers, unknown if no routine used the other side yet and the blocked
+//    routine holds a lock
+func (c *Chan[T]) getBlockers(routineIndex int, read bool) waitfor.Blockers {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	other := c.receivers
+	if read {
+		other = c.senders
+	}
+	res := make([]int, 0, len(other))
+	for index := range other {
+		if index != routineIndex {
+			res = append(res, index)
+		}
+	}
+	return waitfor.Blockers{Inferred: res, Unknown: len(res) == 0 && c.holding[routineIndex]}
+}
+
+// C returns the underlying channel, e.g. for a select statement. Operations
+// on the underlying channel are not checked
+//  Returns:
+//   (chan T): the underlying channel
+func (c *Chan[T]) C() chan T {
+	return c.ch
+}
+
+// Len returns the number of elements in the buffer of the channel
+//  Returns:
+//   (int): number of buffered elements
+func (c *Chan[T]) Len() int {
+	return len(c.ch)
+}
+
+// Cap returns the buffer size of the channel
+//  Returns:
+//   (int): buffer size
+func (c *Chan[T]) Cap() int {
+	return cap(c.ch)
+}
+
+// ============ FUNCTIONS ============
+
+// get the index of the calling routine and save it as a user of one side of
+// the channel
+//  Args:
+//   recv (bool): true if the routine receives, false if it sends or closes
+//  Returns:
+//   (int): index of the routine, -1 if the routine is not registered
+func (c *Chan[T]) register(recv bool) int {
+	d := c.detector
+	if !d.opts.Activated || !d.opts.PeriodicDetection {
+		return -1
+	}
+	index := d.registerRoutine()
+	if index == -1 {
+		return -1
+	}
+
+	c.lock.Lock()
+	if recv {
+		c.receivers[index] = struct{}{}
+	} else {
+		c.senders[index] = struct{}{}
+	}
+	c.lock.Unlock()
+	return index
+}
+
+// register that the calling routine is blocked on the channel. c.lock is not
+// held, while the wait is added to the wait-for graph, because the detection
+// locks c while the wait-for graph is locked
+//  Args:
+//   index (int): index of the routine
+//   recv (bool): true if the routine is blocked in Recv, false for Send
+//  Returns:
+//   nil
+func (c *Chan[T]) startWaiting(index int, recv bool) {
+	d := c.detector
+	r := &d.routines[index]
+	holding := r.holdingCount > 0 || len(r.lightHolding) > 0
+
+	c.lock.Lock()
+	c.holding[index] = holding
+	c.lock.Unlock()
+
+	frame := userFrame()
+	d.startWaiting(index, c, recv, newInfo(frame.File, frame.Line, frame.Function,
+		false, false, ""))
+}
+
+// register that the calling routine is no longer blocked on the channel
+//  Args:
+//   index (int): index of the routine
+//  Returns:
+//   nil
+func (c *Chan[T]) stopWaiting(index int) {
+	c.detector.stopWaiting(index)
+
+	c.lock.Lock()
+	delete(c.holding, index)
+	c.lock.Unlock()
+}
+
+// Send sends v on the channel. If the send blocks, the routine is registered
+// in the wait-for graph until the value was sent
+//  Args:
+//   v (T): value to send
+//  Returns:
+//   nil
+func (c *Chan[T]) Send(v T) {
+	index := c.register(false)
+	if index == -1 {
+		c.ch <- v
+		return
+	}
+
+	// the routine is not blocked, if the value can be sent immediately
+	select {
+	case c.ch <- v:
+		return
+	default:
+	}
+
+	c.startWaiting(index, false)
+	c.ch <- v
+	c.stopWaiting(index)
+}
+
+// Recv receives a value from the channel. If the receive blocks, the routine
+// is registered in the wait-for graph until a value was received
+//  Returns:
+//   (T): the received value, the zero value if the channel is closed
+func (c *Chan[T]) Recv() T {
+	v, _ := c.RecvOK()
+	return v
+}
+
+// RecvOK receives a value from the channel and reports whether the value was
+// sent or the channel is closed. If the receive blocks, the routine is
+// registered in the wait-for graph until a value was received
+//  Returns:
+//   (T): the received value, the zero value if the channel is closed
+//   (bool): false if the channel is closed and empty
+func (c *Chan[T]) RecvOK() (T, bool) {
+	index := c.register(true)
+	if index == -1 {
+		v, ok := <-c.ch
+		return v, ok
+	}
+
+	// the routine is not blocked, if a value can be received immediately
+	select {
+	case v, ok := <-c.ch:
+		return v, ok
+	default:
+	}
+
+	c.startWaiting(index, true)
+	v, ok := <-c.ch
+	c.stopWaiting(index)
+	return v, ok
+}
+
+// Close closes the channel. The closing routine releases the routines
+// blocked in Recv and is therefore saved like a sending routine
+//  Returns:
+//   nil
+func (c *Chan[T]) Close() {
+	c.register(false)
+	close(c.ch)
+}
+
@@ -0,0 +1,71 @@
+package main
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: main
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+main.go
+Command deadlock-bench runs a synthetic lock workload (see package
+benchmarks) with the default options of the detector and prints the
+overhead of the lock operations and the duration of the comprehensive
+detection:
+
+	deadlock-bench -goroutines 8 -locks 32 -depth 3 -contention 0.5
+*/
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	deadlock "github.com/ErikKassubek/Deadlock-Go"
+	"github.com/ErikKassubek/Deadlock-Go/benchmarks"
+)
+
+func main() {
+	w := benchmarks.DefaultWorkload()
+	flag.IntVar(&w.Goroutines, "goroutines", w.Goroutines, "number of routines")
+	flag.IntVar(&w.Locks, "locks", w.Locks, "number of locks")
+	flag.IntVar(&w.Depth, "depth", w.Depth, "number of locks held at the same time")
+	flag.Float64Var(&w.Contention, "contention", w.Contention,
+		"probability that an acquisition uses a lock shared by all routines")
+	flag.IntVar(&w.Iterations, "iterations", w.Iterations,
+		"number of nested acquisitions of each routine")
+	flag.Int64Var(&w.Seed, "seed", w.Seed, "seed of the acquisitions")
+	runs := flag.Int("runs", 1, "number of runs of the workload")
+	maxSearchTime := flag.Duration("max-search-time", 10*time.Second,
+		"time after which the comprehensive detection is stopped, 0 for no limit")
+	flag.Parse()
+
+	opts := deadlock.DefaultOptions()
+	opts.MaxSearchTime = *maxSearchTime
+	for i := 0; i < *runs; i++ {
+		res, err := benchmarks.Run(w, opts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "deadlock-bench:", err)
+			os.Exit(2)
+		}
+		fmt.Println(res)
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: main
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+main.go
+Command deadlock-broker combines the dependencies between distributed locks
+of multiple cooperating processes, e.g. processes sharing file locks, and
+reports cycles, which contain dependencies of different processes. The
+processes publish their dependencies with the option LockGraphSocket of the
+detector:
+
+	deadlock-broker -socket /tmp/deadlock.sock
+
+Cycles in the dependencies of a single process are reported by the detector
+of the process itself.
+*/
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+
+	deadlock "github.com/ErikKassubek/Deadlock-Go"
+)
+
+// graph of the dependencies between the distributed locks of all processes
+type lockGraph struct {
+	// dependencies by the names of the held and the acquired lock, with one
+	// dependency per process
+	edges map[string]map[string]map[int]deadlock.LockGraphEdge
+	// keys of the cycles, which were already reported
+	reported map[string]bool
+	// writer for the reports
+	out io.Writer
+	// lock to prevent concurrent access to the graph
+	lock sync.Mutex
+}
+
+// create a new graph
+//  Args:
+//   out (io.Writer): writer for the reports
+//  Returns:
+//   (*lockGraph): the graph
+func newLockGraph(out io.Writer) *lockGraph {
+	return &lockGraph{
+		edges:    make(map[string]map[string]map[int]deadlock.LockGraphEdge),
+		reported: make(map[string]bool),
+		out:      out,
+	}
+}
+
+// add a dependency to the graph and report a cycle, which is closed by it
+//  Args:
+//   e (deadlock.LockGraphEdge): the dependency
+//  Returns:
+//   nil
+func (g *lockGraph) add(e deadlock.LockGraphEdge) {
+	if e.From == e.To {
+		return
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.edges[e.From] == nil {
+		g.edges[e.From] = make(map[string]map[int]deadlock.LockGraphEdge)
+	}
+	if g.edges[e.From][e.To] == nil {
+		g.edges[e.From][e.To] = make(map[int]deadlock.LockGraphEdge)
+	}
+	if _, ok := g.edges[e.From][e.To][e.Process]; ok {
+		return
+	}
+	g.edges[e.From][e.To][e.Process] = e
+
+	// a new cycle must contain the new dependency
+	path := g.path(e.To, e.From)
+	if path == nil {
+		return
+	}
+	cycle := append([]string{e.From}, path...)
+	if !g.crossProcess(cycle) {
+		return
+	}
+
+	key := cycleKey(cycle)
+	if g.reported[key] {
+		return
+	}
+	g.reported[key] = true
+	g.report(cycle)
+}
+
+// search the shortest path between two locks with a breadth-first search
+//  Args:
+//   from (string): name of the first lock
+//   to (string): name of the last lock
+//  Returns:
+//   ([]string): names of the locks on the path, from and to included, nil
+//    if no path exists
+func (g *lockGraph) path(from string, to string) []string {
+	prev := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == to {
+			path := []string{}
+			for l := to; l != ""; l = prev[l] {
+				path = append([]string{l}, path...)
+			}
+			return path
+		}
+		for next := range g.edges[current] {
+			if _, ok := prev[next]; !ok {
+				prev[next] = current
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// check if the dependencies of a cycle can be taken from at least two
+// processes
+//  Args:
+//   cycle ([]string): names of the locks of the cycle, the first and the
+//    last lock are equal
+//  Returns:
+//   (bool): true if the cycle contains dependencies of different processes
+func (g *lockGraph) crossProcess(cycle []string) bool {
+	processes := make(map[int]bool)
+	for i := 0; i < len(cycle)-1; i++ {
+		for process := range g.edges[cycle[i]][cycle[i+1]] {
+			processes[process] = true
+		}
+	}
+	return len(processes) > 1
+}
+
+// get a key of a cycle, which does not depend on its first lock
+//  Args:
+//   cycle ([]string): names of the locks of the cycle, the first and the
+//    last lock are equal
+//  Returns:
+//   (string): key of the cycle
+func cycleKey(cycle []string) string {
+	locks := cycle[:len(cycle)-1]
+	start := 0
+	for i, l := range locks {
+		if l < locks[start] {
+			start = i
+		}
+	}
+	return strings.Join(append(append([]string{}, locks[start:]...), locks[:start]...), "\x00")
+}
+
+// report a cycle
+//  Args:
+//   cycle ([]string): names of the locks of the cycle, the first and the
+//    last lock are equal
+//  Returns:
+//   nil
+func (g *lockGraph) report(cycle []string) {
+	fmt.Fprintln(g.out, "POTENTIAL DEADLOCK (CROSS-PROCESS)")
+	fmt.Fprintln(g.out, "")
+	fmt.Fprintln(g.out, "Dependencies involved in potential deadlock:")
+	fmt.Fprintln(g.out, "")
+	for i := 0; i < len(cycle)-1; i++ {
+		fmt.Fprintln(g.out, cycle[i], "->", cycle[i+1])
+
+		edges := g.edges[cycle[i]][cycle[i+1]]
+		processes := make([]int, 0, len(edges))
+		for process := range edges {
+			processes = append(processes, process)
+		}
+		sort.Ints(processes)
+		for _, process := range processes {
+			site := edges[process].Site
+			fmt.Fprintln(g.out, "  process", process, "acquired at:", site.File, site.Line)
+		}
+	}
+	fmt.Fprintln(g.out, "")
+}
+
+// read the dependencies of a process
+//  Args:
+//   conn (net.Conn): connection to the process
+//  Returns:
+//   nil
+func (g *lockGraph) serve(conn net.Conn) {
+	defer conn.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	for {
+		var e deadlock.LockGraphEdge
+		if err := dec.Decode(&e); err != nil {
+			if err != io.EOF {
+				fmt.Fprintln(os.Stderr, "deadlock-broker:", err)
+			}
+			return
+		}
+		if err := deadlock.CheckSchemaVersion(e.Schema); err != nil {
+			fmt.Fprintln(os.Stderr, "deadlock-broker:", err)
+			return
+		}
+		g.add(e)
+	}
+}
+
+func main() {
+	socket := flag.String("socket", "/tmp/deadlock-broker.sock", "path of the unix socket")
+	flag.Parse()
+
+	// remove the socket of a previous run
+	os.Remove(*socket)
+	listener, err := net.Listen("unix", *socket)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "deadlock-broker:", err)
+		os.Exit(1)
+	}
+
+	// remove the socket, when the broker is stopped
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		os.Remove(*socket)
+		os.Exit(0)
+	}()
+
+	g := newLockGraph(os.Stdout)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "deadlock-broker:", err)
+			os.Exit(1)
+		}
+		go g.serve(conn)
+	}
+}
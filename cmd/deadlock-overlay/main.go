@@ -0,0 +1,445 @@
+package main
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: main
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+main.go
+Command deadlock-overlay creates an overlay for the go command, which replaces
+the imports of "sync" in a module with the sync-compatible package of the
+deadlock detector. The module is not changed, the overlay is only used by
+builds with the -overlay flag, e.g. a debug build:
+
+	deadlock-overlay -module . -deadlock ../Deadlock-Go -deps
+	go build -overlay .deadlock-overlay/overlay.json ./...
+
+With -deps, the imports in all dependencies of the module are replaced too,
+so that the whole dependency tree is checked by the detector. The main
+functions of the module start the comprehensive detection at their end.
+
+With -symbols, the sites in a report of a binary built in the embedded mode
+are resolved with the binary or with a symbol map written with -symbol-map
+(see symbols.go).
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// path of the deadlock module
+const deadlockModule = "github.com/ErikKassubek/Deadlock-Go"
+
+// import path of the sync-compatible package
+const shimPackage = deadlockModule + "/sync"
+
+// type to implement the overlay file of the go command
+type overlay struct {
+	Replace map[string]string
+}
+
+// information about a package, returned by go list
+type listPackage struct {
+	Dir          string
+	ImportPath   string
+	Name         string
+	Standard     bool
+	GoFiles      []string
+	CgoFiles     []string
+	TestGoFiles  []string
+	XTestGoFiles []string
+	Module       *struct {
+		Path string
+		Main bool
+	}
+}
+
+func main() {
+	module := flag.String("module", ".", "directory of the module to instrument")
+	out := flag.String("out", "", "output directory, default: <module>/.deadlock-overlay")
+	deadlockDir := flag.String("deadlock", "", "directory of a local copy of the deadlock module")
+	deps := flag.Bool("deps", false, "also instrument the dependencies of the module")
+	symbols := flag.String("symbols", "", "resolve the sites of a report on stdin "+
+		"with a binary built in the embedded mode or with its symbol map")
+	symbolMap := flag.String("symbol-map", "", "write the symbol map of a binary "+
+		"built in the embedded mode to stdout")
+	flag.Parse()
+
+	if *symbolMap != "" {
+		if err := writeSymbolMap(*symbolMap, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "deadlock-overlay:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *symbols != "" {
+		if err := resolveReport(*symbols, os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "deadlock-overlay:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *deadlockDir == "" {
+		fmt.Fprintln(os.Stderr, "deadlock-overlay: -deadlock is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *out == "" {
+		*out = filepath.Join(*module, ".deadlock-overlay")
+	}
+
+	if err := run(*module, *out, *deadlockDir, *deps); err != nil {
+		fmt.Fprintln(os.Stderr, "deadlock-overlay:", err)
+		os.Exit(1)
+	}
+}
+
+// create the overlay
+//  Args:
+//   module (string): directory of the module to instrument
+//   out (string): output directory
+//   deadlockDir (string): directory of a local copy of the deadlock module
+//   deps (bool): if true, the dependencies are also instrumented
+//  Returns:
+//   (error): error if the overlay could not be created
+func run(module string, out string, deadlockDir string, deps bool) error {
+	module, err := filepath.Abs(module)
+	if err != nil {
+		return err
+	}
+	out, err = filepath.Abs(out)
+	if err != nil {
+		return err
+	}
+	deadlockDir, err = filepath.Abs(deadlockDir)
+	if err != nil {
+		return err
+	}
+
+	pkgs, err := listPackages(module, deps)
+	if err != nil {
+		return err
+	}
+
+	ov := overlay{Replace: make(map[string]string)}
+
+	// replace the imports of sync in all files of the packages
+	for _, pkg := range pkgs {
+		if pkg.Standard || strings.HasPrefix(pkg.ImportPath, deadlockModule) {
+			continue
+		}
+
+		files := append(append([]string{}, pkg.GoFiles...), pkg.CgoFiles...)
+		mainModule := pkg.Module != nil && pkg.Module.Main
+		if mainModule {
+			files = append(append(files, pkg.TestGoFiles...), pkg.XTestGoFiles...)
+		}
+
+		for _, file := range files {
+			path := filepath.Join(pkg.Dir, file)
+			src, changed, err := rewriteFile(path, mainModule && pkg.Name == "main")
+			if err != nil {
+				return err
+			}
+			if !changed {
+				continue
+			}
+			target := filepath.Join(out, "files", strings.TrimPrefix(
+				path, filepath.VolumeName(path)))
+			if err := writeFile(target, src); err != nil {
+				return err
+			}
+			ov.Replace[path] = target
+		}
+	}
+
+	// add the deadlock module to go.mod and go.sum of the module
+	goMod, err := createGoMod(module, deadlockDir)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(out, "go.mod"), goMod); err != nil {
+		return err
+	}
+	ov.Replace[filepath.Join(module, "go.mod")] = filepath.Join(out, "go.mod")
+
+	goSum, err := createGoSum(module, deadlockDir)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(out, "go.sum"), goSum); err != nil {
+		return err
+	}
+	ov.Replace[filepath.Join(module, "go.sum")] = filepath.Join(out, "go.sum")
+
+	data, err := json.MarshalIndent(ov, "", "\t")
+	if err != nil {
+		return err
+	}
+	overlayFile := filepath.Join(out, "overlay.json")
+	if err := writeFile(overlayFile, data); err != nil {
+		return err
+	}
+
+	fmt.Println("Instrumented", len(ov.Replace)-2, "files. Build with:")
+	fmt.Println("  go build -overlay", overlayFile, "./...")
+	return nil
+}
+
+// get the packages of the module and, if deps is set, their dependencies
+//  Args:
+//   module (string): directory of the module
+//   deps (bool): if true, the dependencies are also listed
+//  Returns:
+//   ([]listPackage): the packages
+//   (error): error if go list failed
+func listPackages(module string, deps bool) ([]listPackage, error) {
+	args := []string{"list", "-json"}
+	if deps {
+		args = append(args, "-deps")
+	}
+	args = append(args, "./...")
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = module
+	cmd.Stderr = os.Stderr
+	data, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list failed: %v", err)
+	}
+
+	res := make([]listPackage, 0)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var pkg listPackage
+		if err := dec.Decode(&pkg); err != nil {
+			return nil, err
+		}
+		res = append(res, pkg)
+	}
+	return res, nil
+}
+
+// replace the import of sync in a file with the sync-compatible package
+//  Args:
+//   path (string): path of the file
+//   isMain (bool): true if the file belongs to a main package of the module.
+//    In this case the comprehensive detection is added to the main function
+//  Returns:
+//   ([]byte): the rewritten file
+//   (bool): true if the file was changed, false otherwise
+//   (error): error if the file could not be read or parsed
+func rewriteFile(path string, isMain bool) ([]byte, bool, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, false, err
+	}
+
+	changed := false
+	for _, imp := range f.Imports {
+		if imp.Path.Value == strconv.Quote("sync") {
+			imp.Path.Value = strconv.Quote(shimPackage)
+			changed = true
+		}
+	}
+	if isMain && addComprehensiveDetection(f) {
+		changed = true
+	}
+	if !changed {
+		return nil, false, nil
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), true, nil
+}
+
+// start the comprehensive detection at the end of the main function
+//  Args:
+//   f (*ast.File): file to change
+//  Returns:
+//   (bool): true if the file contains the main function, false otherwise
+func addComprehensiveDetection(f *ast.File) bool {
+	var mainFunc *ast.FuncDecl
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil &&
+			fn.Name.Name == "main" && fn.Body != nil {
+			mainFunc = fn
+		}
+	}
+	if mainFunc == nil {
+		return false
+	}
+
+	// import the deadlock module with a name, which does not collide with
+	// the names in the file
+	name := "deadlockoverlay"
+	spec := &ast.ImportSpec{
+		Name: ast.NewIdent(name),
+		Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(deadlockModule)},
+	}
+	f.Decls = append([]ast.Decl{&ast.GenDecl{
+		Tok:   token.IMPORT,
+		Specs: []ast.Spec{spec},
+	}}, f.Decls...)
+	f.Imports = append(f.Imports, spec)
+
+	// defer deadlockoverlay.FindPotentialDeadlocks()
+	call := &ast.DeferStmt{Call: &ast.CallExpr{
+		Fun: &ast.SelectorExpr{
+			X:   ast.NewIdent(name),
+			Sel: ast.NewIdent("FindPotentialDeadlocks"),
+		},
+	}}
+	mainFunc.Body.List = append([]ast.Stmt{call}, mainFunc.Body.List...)
+	return true
+}
+
+// create the go.mod of the module with a requirement on the deadlock module,
+// which is replaced by the local copy
+//  Args:
+//   module (string): directory of the module
+//   deadlockDir (string): directory of the local copy of the deadlock module
+//  Returns:
+//   ([]byte): content of the go.mod
+//   (error): error if a go.mod could not be read
+func createGoMod(module string, deadlockDir string) ([]byte, error) {
+	goMod, err := os.ReadFile(filepath.Join(module, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+	deadlockGoMod, err := os.ReadFile(filepath.Join(deadlockDir, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(goMod)
+	buf.WriteString("\n// added by deadlock-overlay\n")
+	if !bytes.Contains(goMod, []byte(deadlockModule+" ")) {
+		fmt.Fprintf(&buf, "require %s v0.0.0\n", deadlockModule)
+	}
+
+	// the requirements of the deadlock module must be listed in the module
+	for _, req := range requirements(deadlockGoMod) {
+		mod := strings.Fields(req)[0]
+		if !bytes.Contains(goMod, []byte(mod+" ")) {
+			fmt.Fprintf(&buf, "require %s // indirect\n", req)
+		}
+	}
+	fmt.Fprintf(&buf, "replace %s => %s\n", deadlockModule, deadlockDir)
+
+	return buf.Bytes(), nil
+}
+
+// get the requirements of a go.mod
+//  Args:
+//   goMod ([]byte): content of the go.mod
+//  Returns:
+//   ([]string): requirements in the form "path version"
+func requirements(goMod []byte) []string {
+	res := make([]string, 0)
+	inBlock := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(goMod))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "require (":
+			inBlock = true
+		case inBlock && line == ")":
+			inBlock = false
+		case inBlock && line != "":
+			res = append(res, line)
+		case strings.HasPrefix(line, "require "):
+			res = append(res, strings.TrimSpace(strings.TrimPrefix(line, "require ")))
+		}
+	}
+	return res
+}
+
+// create the go.sum of the module with the checksums of the requirements of
+// the deadlock module
+//  Args:
+//   module (string): directory of the module
+//   deadlockDir (string): directory of the local copy of the deadlock module
+//  Returns:
+//   ([]byte): content of the go.sum
+//   (error): error if a go.sum could not be read
+func createGoSum(module string, deadlockDir string) ([]byte, error) {
+	lines := make(map[string]struct{})
+	var buf bytes.Buffer
+
+	for _, dir := range []string{module, deadlockDir} {
+		data, err := os.ReadFile(filepath.Join(dir, "go.sum"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if _, ok := lines[line]; ok || line == "" {
+				continue
+			}
+			lines[line] = struct{}{}
+			buf.WriteString(line + "\n")
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// write a file and create the directories of the path
+//  Args:
+//   path (string): path of the file
+//   data ([]byte): content of the file
+//  Returns:
+//   (error): error if the file could not be written
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
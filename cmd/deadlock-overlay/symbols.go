@@ -0,0 +1,368 @@
+package main
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: main
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+symbols.go
+Resolve the sites of reports of binaries, which were built in the embedded
+mode of the detector (build tag deadlock_embedded). In this mode, the
+detector does not resolve the sites on the target, but reports them as
+"pc <offset>", the offset of the program counter to the function siteAnchor.
+The offsets are resolved with the symbol table of the binary, which is kept
+even if the binary is stripped:
+
+	deadlock-overlay -symbols ./app < report.txt
+
+If the binary is not available where the report is analyzed, a symbol map
+can be written with the binary at build time and used instead of the binary.
+The map contains the ranges of the offsets in the functions outside the
+standard library with their files and lines:
+
+	deadlock-overlay -symbol-map ./app > app.symbols
+	deadlock-overlay -symbols app.symbols < report.txt
+*/
+
+import (
+	"bufio"
+	"debug/elf"
+	"debug/gosym"
+	"debug/macho"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// function to which the offsets of the sites are relative
+const siteAnchor = deadlockModule + ".siteAnchor"
+
+// first line of a symbol map
+const symbolMapHeader = "# deadlock symbol map"
+
+// site in a report of the embedded mode, e.g. "pc 1234" or "pc:-56"
+var sitePattern = regexp.MustCompile(`\bpc([ :])(-?[0-9]+)\b`)
+
+// resolver for the offsets of the sites
+type symbolResolver interface {
+	// get the file and line of an offset, false if the offset is unknown
+	resolve(offset int64) (string, int, bool)
+}
+
+// resolver with the symbol table of the binary
+type tableResolver struct {
+	table  *gosym.Table
+	anchor uint64
+}
+
+// resolve an offset with the symbol table
+//  Args:
+//   offset (int64): offset of the program counter to siteAnchor
+//  Returns:
+//   (string): file of the offset
+//   (int): line of the offset
+//   (bool): false if the offset is not in a function of the binary
+func (r *tableResolver) resolve(offset int64) (string, int, bool) {
+	file, line, fn := r.table.PCToLine(uint64(int64(r.anchor) + offset))
+	return file, line, fn != nil
+}
+
+// range of offsets with the same file and line in a symbol map
+type symbolRange struct {
+	start, end int64
+	file       string
+	line       int
+}
+
+// resolver with a symbol map
+type mapResolver []symbolRange
+
+// resolve an offset with the symbol map
+//  Args:
+//   offset (int64): offset of the program counter to siteAnchor
+//  Returns:
+//   (string): file of the offset
+//   (int): line of the offset
+//   (bool): false if the offset is not in a range of the map
+func (r mapResolver) resolve(offset int64) (string, int, bool) {
+	i := sort.Search(len(r), func(i int) bool { return r[i].end > offset })
+	if i == len(r) || r[i].start > offset {
+		return "", 0, false
+	}
+	return r[i].file, r[i].line, true
+}
+
+// replace the sites in a report by their files and lines
+//  Args:
+//   symbols (string): path of the binary which created the report or of
+//    a symbol map of the binary
+//   in (io.Reader): the report
+//   out (io.Writer): writer for the resolved report
+//  Returns:
+//   (error): error if the symbols or the report could not be read
+func resolveReport(symbols string, in io.Reader, out io.Writer) error {
+	resolver, err := loadResolver(symbols)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := sitePattern.ReplaceAllStringFunc(scanner.Text(), func(site string) string {
+			match := sitePattern.FindStringSubmatch(site)
+			offset, err := strconv.ParseInt(match[2], 10, 64)
+			if err != nil {
+				return site
+			}
+			file, l, ok := resolver.resolve(offset)
+			if !ok {
+				return site
+			}
+			return fmt.Sprint(file, match[1], l)
+		})
+		if _, err := fmt.Fprintln(out, line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// load the resolver for a binary or a symbol map
+//  Args:
+//   symbols (string): path of the binary or the symbol map
+//  Returns:
+//   (symbolResolver): the resolver
+//   (error): error if the file could not be read
+func loadResolver(symbols string) (symbolResolver, error) {
+	if isSymbolMap(symbols) {
+		return readSymbolMap(symbols)
+	}
+
+	table, anchor, err := readAnchoredTable(symbols)
+	if err != nil {
+		return nil, err
+	}
+	return &tableResolver{table: table, anchor: anchor.Entry}, nil
+}
+
+// read the symbol table of a binary built in the embedded mode
+//  Args:
+//   binary (string): path of the binary
+//  Returns:
+//   (*gosym.Table): the symbol table
+//   (*gosym.Func): the function siteAnchor
+//   (error): error if the binary could not be read or was not built in
+//    the embedded mode
+func readAnchoredTable(binary string) (*gosym.Table, *gosym.Func, error) {
+	table, err := readSymbolTable(binary)
+	if err != nil {
+		return nil, nil, err
+	}
+	anchor := table.LookupFunc(siteAnchor)
+	if anchor == nil {
+		return nil, nil, fmt.Errorf("%s was not built with the embedded mode of the detector",
+			binary)
+	}
+	return table, anchor, nil
+}
+
+// check if a file is a symbol map
+//  Args:
+//   path (string): path of the file
+//  Returns:
+//   (bool): true if the file starts with the header of a symbol map
+func isSymbolMap(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, len(symbolMapHeader))
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+	return string(header) == symbolMapHeader
+}
+
+// write the symbol map of a binary built in the embedded mode. The map
+// contains a line "<start> <end> <file>:<line>" for each range of offsets
+// with the same file and line in the functions outside the standard library
+//  Args:
+//   binary (string): path of the binary
+//   out (io.Writer): writer for the map
+//  Returns:
+//   (error): error if the binary could not be read
+func writeSymbolMap(binary string, out io.Writer) error {
+	table, anchor, err := readAnchoredTable(binary)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(out)
+	fmt.Fprintln(w, symbolMapHeader)
+	for i := range table.Funcs {
+		fn := &table.Funcs[i]
+		if isStdFunc(fn.Name) {
+			continue
+		}
+
+		var current symbolRange
+		for pc := fn.Entry; pc < fn.End; pc++ {
+			file, line, _ := table.PCToLine(pc)
+			offset := int64(pc) - int64(anchor.Entry)
+			if pc != fn.Entry && file == current.file && line == current.line {
+				current.end = offset + 1
+				continue
+			}
+			writeSymbolRange(w, current)
+			current = symbolRange{start: offset, end: offset + 1, file: file, line: line}
+		}
+		writeSymbolRange(w, current)
+	}
+	return w.Flush()
+}
+
+// write a range of a symbol map
+//  Args:
+//   w (io.Writer): writer for the map
+//   r (symbolRange): the range, ignored if it is empty
+//  Returns:
+//   nil
+func writeSymbolRange(w io.Writer, r symbolRange) {
+	if r.end > r.start && r.file != "" {
+		fmt.Fprintf(w, "%d %d %s:%d\n", r.start, r.end, r.file, r.line)
+	}
+}
+
+// check if a function belongs to the standard library or the runtime. The
+// first element of the import path of other packages contains a dot,
+// except for the main package
+//  Args:
+//   name (string): full name of the function
+//  Returns:
+//   (bool): true if the function belongs to the standard library
+func isStdFunc(name string) bool {
+	if strings.HasPrefix(name, "main.") {
+		return false
+	}
+	i := strings.Index(name, "/")
+	if i < 0 {
+		return true
+	}
+	return !strings.Contains(name[:i], ".")
+}
+
+// read a symbol map written by writeSymbolMap
+//  Args:
+//   path (string): path of the map
+//  Returns:
+//   (mapResolver): the ranges of the map sorted by their offsets
+//   (error): error if the map could not be read
+func readSymbolMap(path string) (mapResolver, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ranges mapResolver
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		text := scanner.Text()
+		if n == 1 || text == "" {
+			continue
+		}
+		fields := strings.SplitN(text, " ", 3)
+		sep := -1
+		if len(fields) == 3 {
+			sep = strings.LastIndex(fields[2], ":")
+		}
+		if sep < 0 {
+			return nil, fmt.Errorf("%s:%d: invalid range %q", path, n, text)
+		}
+		var r symbolRange
+		var errs [3]error
+		r.start, errs[0] = strconv.ParseInt(fields[0], 10, 64)
+		r.end, errs[1] = strconv.ParseInt(fields[1], 10, 64)
+		r.line, errs[2] = strconv.Atoi(fields[2][sep+1:])
+		for _, err := range errs {
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+			}
+		}
+		r.file = fields[2][:sep]
+		ranges = append(ranges, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+	return ranges, nil
+}
+
+// read the symbol table of an ELF or Mach-O binary from its pclntab
+//  Args:
+//   binary (string): path of the binary
+//  Returns:
+//   (*gosym.Table): the symbol table
+//   (error): error if the binary could not be read
+func readSymbolTable(binary string) (*gosym.Table, error) {
+	var pclntab []byte
+	var text uint64
+
+	if f, err := elf.Open(binary); err == nil {
+		defer f.Close()
+		for _, s := range f.Sections {
+			switch {
+			case s.Name == ".text":
+				text = s.Addr
+			case strings.HasSuffix(s.Name, ".gopclntab"):
+				if pclntab, err = s.Data(); err != nil {
+					return nil, err
+				}
+			}
+		}
+	} else if f, err := macho.Open(binary); err == nil {
+		defer f.Close()
+		if s := f.Section("__text"); s != nil {
+			text = s.Addr
+		}
+		if s := f.Section("__gopclntab"); s != nil {
+			if pclntab, err = s.Data(); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		return nil, fmt.Errorf("%s is neither an ELF nor a Mach-O binary", binary)
+	}
+
+	if pclntab == nil {
+		return nil, fmt.Errorf("%s does not contain a Go symbol table", binary)
+	}
+	return gosym.NewTable(nil, gosym.NewLineTable(pclntab, text))
+}
@@ -0,0 +1,266 @@
+package main
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: main
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+graph.go
+Implementation of the lock graph of the lock order and the delta file. The
+nodes of the graph are the locks, an edge from a lock a to a lock b means
+that a routine acquired b while it held a. The graph is printed as text,
+as HTML table or as DOT graph.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+
+	deadlock "github.com/ErikKassubek/Deadlock-Go"
+)
+
+// lock in the lock graph
+type graphLock struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Tags string `json:"tags,omitempty"`
+}
+
+// edge in the lock graph
+type graphEdge struct {
+	From       string               `json:"from"`
+	To         string               `json:"to"`
+	Sites      []string             `json:"sites"`
+	Routines   int                  `json:"routines"`
+	Goroutines []deadlock.Goroutine `json:"goroutines,omitempty"`
+	Count      int                  `json:"count"`
+	Concurrent bool                 `json:"concurrent"`
+}
+
+// get the identities of the first goroutines of the edge, as in the lock
+// order written by the detector
+//  Returns:
+//   ([]string): identities of the first goroutines
+func (e graphEdge) GoroutineLabels() []string {
+	const shown = 3
+	res := make([]string, 0, shown+1)
+	for i, g := range e.Goroutines {
+		if i == shown {
+			res = append(res, fmt.Sprint("and ", len(e.Goroutines)-shown, " more goroutines"))
+			break
+		}
+		res = append(res, g.String())
+	}
+	return res
+}
+
+// lock graph, the JSON format of the lock order
+type lockGraph struct {
+	Schema int         `json:"schema"`
+	Locks  []graphLock `json:"locks"`
+	Edges  []graphEdge `json:"edges"`
+}
+
+// read the lock order in the JSON format
+//  Args:
+//   data ([]byte): the lock order
+//  Returns:
+//   (*lockGraph): the lock graph
+//   (error): error if the lock order could not be read or was written with
+//    an unsupported schema version
+func readLockOrder(data []byte) (*lockGraph, error) {
+	g := &lockGraph{}
+	if err := json.Unmarshal(data, g); err != nil {
+		return nil, err
+	}
+	if err := deadlock.CheckSchemaVersion(g.Schema); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// rebuild the lock graph from the records of a delta file. The locks are
+// named by their creation sites
+//  Args:
+//   deltas ([]deadlock.Delta): records of the delta file
+//  Returns:
+//   (*lockGraph): the lock graph
+func deltaGraph(deltas []deadlock.Delta) *lockGraph {
+	g := &lockGraph{}
+	names := make(map[int]string)
+	edges := make(map[[2]string]*graphEdge)
+	routines := make(map[[2]string]map[int]bool)
+
+	// name of a lock, whose record is missing
+	name := func(id int) string {
+		if n, ok := names[id]; ok {
+			return n
+		}
+		return fmt.Sprint("lock ", id)
+	}
+
+	for _, record := range deltas {
+		switch record.Kind {
+		case deadlock.DeltaLock:
+			names[record.Lock] = record.Site
+			g.Locks = append(g.Locks, graphLock{Name: record.Site, Type: record.Type})
+		case deadlock.DeltaDependency:
+			to := name(record.Lock)
+			for _, held := range record.Held {
+				key := [2]string{name(held), to}
+				edge, ok := edges[key]
+				if !ok {
+					edge = &graphEdge{From: key[0], To: key[1]}
+					edges[key] = edge
+					routines[key] = make(map[int]bool)
+				}
+				if !containsString(edge.Sites, record.Acquired) {
+					edge.Sites = append(edge.Sites, record.Acquired)
+				}
+				if !routines[key][record.Routine] && record.Goroutine != nil {
+					edge.Goroutines = append(edge.Goroutines, *record.Goroutine)
+				}
+				routines[key][record.Routine] = true
+				edge.Routines = len(routines[key])
+				edge.Count++
+			}
+		}
+	}
+
+	for _, edge := range edges {
+		sort.Strings(edge.Sites)
+		g.Edges = append(g.Edges, *edge)
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+	return g
+}
+
+// check if a list of strings contains a string
+//  Args:
+//   list ([]string): the list
+//   s (string): the string
+//  Returns:
+//   (bool): true if s is in list
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// print the lock graph as text with one edge per line
+//  Args:
+//   w (io.Writer): writer to write to
+//  Returns:
+//   (error): error if the graph could not be written
+func (g *lockGraph) writeText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Locks: %d, edges: %d\n\n", len(g.Locks), len(g.Edges))
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "%s -> %s (%d acquisitions, %d routines", edge.From,
+			edge.To, edge.Count, edge.Routines)
+		if edge.Concurrent {
+			fmt.Fprint(&b, ", concurrent")
+		}
+		fmt.Fprintln(&b, ")")
+		for _, site := range edge.Sites {
+			fmt.Fprintln(&b, "\tacquired at", site)
+		}
+		for _, g := range edge.GoroutineLabels() {
+			fmt.Fprintln(&b, "\tby", g)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// print the lock graph as DOT graph
+//  Args:
+//   w (io.Writer): writer to write to
+//  Returns:
+//   (error): error if the graph could not be written
+func (g *lockGraph) writeDOT(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintln(&b, "digraph locks {")
+	fmt.Fprintln(&b, "\tnode [shape=box];")
+	for _, l := range g.Locks {
+		label := l.Name
+		if l.Type != "" {
+			label = l.Type + "\n" + label
+		}
+		if l.Tags != "" {
+			label += "\n" + l.Tags
+		}
+		fmt.Fprintf(&b, "\t%q [label=%q];\n", l.Name, label)
+	}
+	for _, edge := range g.Edges {
+		style := ""
+		if edge.Concurrent {
+			style = ", style=bold"
+		}
+		label := append([]string{fmt.Sprint(edge.Count)}, edge.GoroutineLabels()...)
+		fmt.Fprintf(&b, "\t%q -> %q [label=%q%s];\n", edge.From, edge.To,
+			strings.Join(label, "\n"), style)
+	}
+	fmt.Fprintln(&b, "}")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// template of the HTML page of the lock graph
+var graphTemplate = template.Must(template.New("graph").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Lock graph</title>
+` + pageStyle + `
+</head>
+<body>
+<h1>Lock graph</h1>
+<p>{{len .Locks}} locks, {{len .Edges}} edges</p>
+<table>
+<tr><th>From</th><th>To</th><th>Sites</th><th>Goroutines</th><th>Acquisitions</th><th>Concurrent</th></tr>
+{{range .Edges}}<tr><td><code>{{.From}}</code></td><td><code>{{.To}}</code></td><td>{{range .Sites}}<code>{{.}}</code><br>{{end}}</td><td>{{range .GoroutineLabels}}{{.}}<br>{{else}}{{.Routines}}{{end}}</td><td>{{.Count}}</td><td>{{if .Concurrent}}yes{{else}}no{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// print the lock graph as HTML page
+//  Args:
+//   w (io.Writer): writer to write to
+//  Returns:
+//   (error): error if the page could not be written
+func (g *lockGraph) writeHTML(w io.Writer) error {
+	return graphTemplate.Execute(w, g)
+}
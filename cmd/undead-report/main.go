@@ -0,0 +1,250 @@
+package main
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: main
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+main.go
+Command undead-report renders the machine outputs of the detector offline,
+so that the program only has to emit the cheap machine formats. It reads
+
+	- the reports printed with OutputJSON (one JSON object per line)
+	- the lock order written with WriteLockOrder in the JSON format
+	- the summary written with SummaryJSON, e.g. the summary file
+	- the delta file
+
+from a file or stdin and prints them as text, HTML, DOT or SARIF:
+
+	go test ./... 2> reports.json
+	undead-report -format sarif reports.json > deadlock.sarif
+	undead-report -format dot lockorder.json | dot -Tsvg > lockorder.svg
+
+The kind of the input is detected from its first JSON object, if it is not
+set with -input. Inputs written with an older schema version of the detector
+are accepted down to deadlock.MinSchemaVersion. Not every format is available for every input, e.g. a
+summary can not be rendered as a graph.
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	deadlock "github.com/ErikKassubek/Deadlock-Go"
+)
+
+// kinds of the inputs
+const (
+	inputAuto    = "auto"
+	inputReports = "reports"
+	inputOrder   = "order"
+	inputSummary = "summary"
+	inputDelta   = "delta"
+)
+
+// output formats
+const (
+	formatText  = "text"
+	formatHTML  = "html"
+	formatDOT   = "dot"
+	formatSARIF = "sarif"
+)
+
+// content of an input, exactly one of the fields is set
+type input struct {
+	// reports printed with OutputJSON
+	reports []deadlock.Report
+	// lock order or lock graph rebuilt from a delta file
+	graph *lockGraph
+	// summary of a detection
+	summary *deadlock.Summary
+}
+
+func main() {
+	format := flag.String("format", formatText, "output format: text, html, dot or sarif")
+	kind := flag.String("input", inputAuto, "kind of the input: auto, reports, "+
+		"order, summary or delta")
+	root := flag.String("root", "", "directory to which the paths in SARIF are "+
+		"relative, default: working directory")
+	flag.Parse()
+
+	if flag.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "undead-report: at most one input file can be given")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	r := io.Reader(os.Stdin)
+	if flag.NArg() == 1 {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "undead-report:", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	in, err := readInput(r, *kind)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "undead-report:", err)
+		os.Exit(1)
+	}
+
+	if err := render(os.Stdout, in, *format, *root); err != nil {
+		fmt.Fprintln(os.Stderr, "undead-report:", err)
+		os.Exit(1)
+	}
+}
+
+// read an input
+//  Args:
+//   r (io.Reader): reader of the input
+//   kind (string): kind of the input, inputAuto to detect it
+//  Returns:
+//   (input): content of the input
+//   (error): error if the input could not be read
+func readInput(r io.Reader, kind string) (input, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return input{}, err
+	}
+
+	if kind == inputAuto {
+		if kind, err = detectInput(data); err != nil {
+			return input{}, err
+		}
+	}
+
+	switch kind {
+	case inputReports:
+		reports, err := deadlock.ReadReports(bytes.NewReader(data))
+		return input{reports: reports}, err
+	case inputOrder:
+		g, err := readLockOrder(data)
+		return input{graph: g}, err
+	case inputSummary:
+		s, err := deadlock.ReadSummary(bytes.NewReader(data))
+		if err != nil {
+			return input{}, err
+		}
+		return input{summary: &s}, nil
+	case inputDelta:
+		deltas, err := deadlock.ReadDeltas(bytes.NewReader(data))
+		if err != nil {
+			return input{}, err
+		}
+		return input{graph: deltaGraph(deltas)}, nil
+	}
+	return input{}, fmt.Errorf("unknown kind of input %q", kind)
+}
+
+// detect the kind of an input from the fields of its first JSON object.
+// Lines before the first object, e.g. other output on stderr, are skipped
+//  Args:
+//   data ([]byte): the input
+//  Returns:
+//   (string): kind of the input
+//   (error): error if the kind could not be detected
+func detectInput(data []byte) (string, error) {
+	start := 0
+	for start < len(data) {
+		end := bytes.IndexByte(data[start:], '\n')
+		if end == -1 {
+			end = len(data) - start
+		}
+		if line := bytes.TrimSpace(data[start : start+end]); len(line) > 0 && line[0] == '{' {
+			break
+		}
+		start += end + 1
+	}
+	if start >= len(data) {
+		return "", fmt.Errorf("the input contains no JSON object")
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(bytes.NewReader(data[start:])).Decode(&fields); err != nil {
+		return "", err
+	}
+
+	switch {
+	case fields["edges"] != nil:
+		return inputOrder, nil
+	case fields["unique_dependencies"] != nil:
+		return inputSummary, nil
+	case fields["kind"] != nil:
+		return inputDelta, nil
+	case fields["title"] != nil:
+		return inputReports, nil
+	}
+	return "", fmt.Errorf("unknown kind of input, set it with -input")
+}
+
+// render an input
+//  Args:
+//   w (io.Writer): writer of the output
+//   in (input): the input
+//   format (string): output format
+//   root (string): directory to which the paths in SARIF are relative
+//  Returns:
+//   (error): error if the input could not be rendered in the format
+func render(w io.Writer, in input, format string, root string) error {
+	switch {
+	case in.reports != nil:
+		switch format {
+		case formatText:
+			return writeReportsText(w, in.reports)
+		case formatHTML:
+			return writeReportsHTML(w, in.reports)
+		case formatDOT:
+			return writeReportsDOT(w, in.reports)
+		case formatSARIF:
+			return writeSARIF(w, in.reports, root)
+		}
+	case in.graph != nil:
+		switch format {
+		case formatText:
+			return in.graph.writeText(w)
+		case formatHTML:
+			return in.graph.writeHTML(w)
+		case formatDOT:
+			return in.graph.writeDOT(w)
+		case formatSARIF:
+			return fmt.Errorf("a lock graph contains no findings, it can not " +
+				"be rendered as SARIF")
+		}
+	case in.summary != nil:
+		switch format {
+		case formatText:
+			return in.summary.Write(w, deadlock.SummaryText)
+		case formatHTML:
+			return writeSummaryHTML(w, *in.summary)
+		case formatDOT, formatSARIF:
+			return fmt.Errorf("a summary can not be rendered as %s", format)
+		}
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
@@ -0,0 +1,228 @@
+package main
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: main
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+report.go
+Implementation of the output of the reports and the summary as text, HTML
+and DOT. The DOT graph contains the cycles of the reports: the nodes are
+the locks, identified by their creation sites, and the edges are the
+acquisitions of the cycles.
+*/
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	deadlock "github.com/ErikKassubek/Deadlock-Go"
+)
+
+// style of the HTML pages
+const pageStyle = `<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+pre { background: #f6f6f6; padding: 1em; overflow-x: auto; }
+.deadlock { color: #b00; }
+.potential { color: #b60; }
+.warning { color: #077; }
+</style>`
+
+// format a site as file:line
+//  Args:
+//   site (deadlock.Site): the site
+//  Returns:
+//   (string): the formatted site
+func siteString(site deadlock.Site) string {
+	return fmt.Sprintf("%s:%d", site.File, site.Line)
+}
+
+// print the reports as text. Reports without text are printed with their
+// title and sites
+//  Args:
+//   w (io.Writer): writer to write to
+//   reports ([]deadlock.Report): the reports
+//  Returns:
+//   (error): error if the reports could not be written
+func writeReportsText(w io.Writer, reports []deadlock.Report) error {
+	var b strings.Builder
+	for _, r := range reports {
+		if r.Text != "" {
+			fmt.Fprint(&b, strings.TrimRight(r.Text, "\n"), "\n\n")
+			continue
+		}
+		fmt.Fprint(&b, r.Title)
+		if r.ID != "" {
+			fmt.Fprint(&b, " (Cycle ID: ", r.ID, ")")
+		}
+		fmt.Fprintln(&b)
+		for _, site := range r.Sites {
+			fmt.Fprintln(&b, "\t"+siteString(site))
+		}
+		for _, g := range r.Goroutines {
+			fmt.Fprintln(&b, "\t"+g.String())
+		}
+		if r.Released != nil {
+			fmt.Fprintln(&b, "\tlast released at "+siteString(*r.Released))
+		}
+		fmt.Fprintln(&b)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// template of the HTML page of the reports
+var reportsTemplate = template.Must(template.New("reports").Funcs(template.FuncMap{
+	"class": func(t deadlock.ReportType) string {
+		switch t {
+		case deadlock.ReportDeadlock:
+			return "deadlock"
+		case deadlock.ReportPotentialDeadlock:
+			return "potential"
+		}
+		return "warning"
+	},
+	"site": siteString,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Deadlock reports</title>
+` + pageStyle + `
+</head>
+<body>
+<h1>Deadlock reports</h1>
+<p>{{len .}} reports</p>
+{{range .}}<section>
+<h2 class="{{class .Type}}">{{.Title}}</h2>
+{{if .ID}}<p>Cycle ID: <code>{{.ID}}</code></p>
+{{end}}{{if .Sites}}<ul>
+{{range .Sites}}<li><code>{{site .}}</code>{{if .Function}} in <code>{{.Function}}</code>{{end}}</li>
+{{end}}</ul>
+{{end}}{{if .Goroutines}}<p>Goroutines:</p><ul>
+{{range .Goroutines}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}{{with .Released}}<p>Last released at <code>{{site .}}</code></p>
+{{end}}{{if .Text}}<pre>{{.Text}}</pre>
+{{end}}</section>
+{{end}}</body>
+</html>
+`))
+
+// print the reports as HTML page
+//  Args:
+//   w (io.Writer): writer to write to
+//   reports ([]deadlock.Report): the reports
+//  Returns:
+//   (error): error if the page could not be written
+func writeReportsHTML(w io.Writer, reports []deadlock.Report) error {
+	return reportsTemplate.Execute(w, reports)
+}
+
+// print the cycles of the reports as DOT graph. In a cycle, the i-th site
+// is the acquisition of the i-th lock, while the previous lock was held.
+// Reports without a cycle are not contained in the graph, reports of the
+// same cycle are only contained once
+//  Args:
+//   w (io.Writer): writer to write to
+//   reports ([]deadlock.Report): the reports
+//  Returns:
+//   (error): error if the graph could not be written
+func writeReportsDOT(w io.Writer, reports []deadlock.Report) error {
+	var b strings.Builder
+	fmt.Fprintln(&b, "digraph cycles {")
+	fmt.Fprintln(&b, "\tnode [shape=box];")
+	nodes := make(map[string]bool)
+	cycles := make(map[string]bool)
+	for _, r := range reports {
+		if r.ID == "" || len(r.Locks) < 2 || len(r.Locks) != len(r.Sites) || cycles[r.ID] {
+			continue
+		}
+		cycles[r.ID] = true
+		color := "orange"
+		if r.Type == deadlock.ReportDeadlock {
+			color = "red"
+		}
+		tooltip := []string{r.ID}
+		for _, g := range r.Goroutines {
+			tooltip = append(tooltip, g.String())
+		}
+		for i, l := range r.Locks {
+			name := siteString(l.Site)
+			if !nodes[name] {
+				nodes[name] = true
+				fmt.Fprintf(&b, "\t%q;\n", name)
+			}
+			prev := siteString(r.Locks[(i+len(r.Locks)-1)%len(r.Locks)].Site)
+			fmt.Fprintf(&b, "\t%q -> %q [label=%q, color=%s, tooltip=%q];\n", prev,
+				name, siteString(r.Sites[i]), color, strings.Join(tooltip, "\n"))
+		}
+	}
+	fmt.Fprintln(&b, "}")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// template of the HTML page of the summary
+var summaryTemplate = template.Must(template.New("summary").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Detection summary</title>
+` + pageStyle + `
+</head>
+<body>
+<h1>Detection summary</h1>
+<table>
+<tr><th>Routines analysed</th><td>{{.Routines}}</td></tr>
+<tr><th>Lock tree epoch</th><td>{{.Epoch}}</td></tr>
+<tr><th>Unique dependencies</th><td>{{.UniqueDependencies}}</td></tr>
+<tr><th>Dependencies pruned</th><td>{{.DependenciesPruned}}</td></tr>
+<tr><th>Chains explored</th><td>{{.ChainsExplored}}</td></tr>
+<tr><th>Pruned by gate locks</th><td>{{.Pruning.GateLock}}</td></tr>
+<tr><th>Pruned by rw-lock rules</th><td>{{.Pruning.RWLock}}</td></tr>
+<tr><th>Pruned by program order</th><td>{{.Pruning.HappensBefore}}</td></tr>
+<tr><th>Pruned as duplicates</th><td>{{.Pruning.Dedup}}</td></tr>
+<tr><th>Pruned by the baseline</th><td>{{.Pruning.Baseline}}</td></tr>
+<tr><th>Cycles found</th><td>{{.Cycles}}</td></tr>
+<tr><th>Unique reports</th><td>{{.Reports}}</td></tr>
+<tr><th>Truncated</th><td>{{if .Truncation}}{{.Truncation}}{{else}}no{{end}}</td></tr>
+<tr><th>Duration</th><td>{{.Duration}}</td></tr>
+{{range .Phases}}<tr><th>Duration of {{.Phase}}</th><td>{{.Duration}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// print the summary as HTML page
+//  Args:
+//   w (io.Writer): writer to write to
+//   s (deadlock.Summary): the summary
+//  Returns:
+//   (error): error if the page could not be written
+func writeSummaryHTML(w io.Writer, s deadlock.Summary) error {
+	return summaryTemplate.Execute(w, s)
+}
@@ -0,0 +1,201 @@
+package main
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: main
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+sarif.go
+Implementation of the output of the reports in the Static Analysis Results
+Interchange Format (SARIF) 2.1.0, so that the findings can be uploaded to
+code scanning services. Each kind of report is a rule, each report a result
+with the involved sites as locations. The cycle ID is the fingerprint of a
+result, so that the same cycle is matched across runs.
+*/
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	deadlock "github.com/ErikKassubek/Deadlock-Go"
+)
+
+// version and schema of the written SARIF
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// text of a SARIF message
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+// rule of the tool
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+// region of a location
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// file of a location
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// location of a result in a file
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	// nil if the line is unknown
+	Region *sarifRegion `json:"region,omitempty"`
+}
+
+// location of a result
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+// finding of the tool
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+}
+
+// analysis tool
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+// tool of a run
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+// run of the tool
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+// SARIF log
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+// get the id of the rule of a report from its title, e.g.
+// "deadlock-double-locking" for "DEADLOCK (DOUBLE LOCKING)"
+//  Args:
+//   title (string): title of the report
+//  Returns:
+//   (string): id of the rule
+func ruleID(title string) string {
+	fields := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+	})
+	return strings.Join(fields, "-")
+}
+
+// get the path of a file relative to the root directory, so that it matches
+// the files of the repository
+//  Args:
+//   file (string): absolute path of the file
+//   root (string): root directory, the working directory if empty
+//  Returns:
+//   (string): path relative to root, file if it is not in root
+func sarifPath(file string, root string) string {
+	if root == "" {
+		var err error
+		if root, err = os.Getwd(); err != nil {
+			return filepath.ToSlash(file)
+		}
+	}
+	rel, err := filepath.Rel(root, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(file)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// print the reports as SARIF log
+//  Args:
+//   w (io.Writer): writer to write to
+//   reports ([]deadlock.Report): the reports
+//   root (string): directory to which the paths are relative
+//  Returns:
+//   (error): error if the log could not be written
+func writeSARIF(w io.Writer, reports []deadlock.Report, root string) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "undead-report",
+			InformationURI: "https://github.com/ErikKassubek/Deadlock-Go",
+			Rules:          make([]sarifRule, 0),
+		}},
+		Results: make([]sarifResult, 0, len(reports)),
+	}
+
+	rules := make(map[string]bool)
+	for _, r := range reports {
+		id := ruleID(r.Title)
+		if !rules[id] {
+			rules[id] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules,
+				sarifRule{ID: id, ShortDescription: sarifMessage{Text: r.Title}})
+		}
+
+		result := sarifResult{RuleID: id, Level: "error", Message: sarifMessage{Text: r.Error()}}
+		if r.Type == deadlock.ReportWarning {
+			result.Level = "warning"
+		}
+		if r.ID != "" {
+			result.PartialFingerprints = map[string]string{"cycleId/v1": r.ID}
+		}
+		for _, site := range r.Sites {
+			l := sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: sarifPath(site.File, root)},
+			}
+			if site.Line > 0 {
+				l.Region = &sarifRegion{StartLine: site.Line}
+			}
+			result.Locations = append(result.Locations, sarifLocation{PhysicalLocation: l})
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion,
+		Runs: []sarifRun{run}})
+}
@@ -0,0 +1,144 @@
+package main
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: main
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+main.go
+Command undead-selftest runs the scenarios of package scenarios with their
+known results against the detector it was built with, e.g. lock inversions
+which must be reported and gate-locked pairs which must not, and prints for
+each scenario whether the detector found what was expected. The command
+should be built
+with the same version, build tags and flags as the program, whose clean
+result is trusted:
+
+	go run -tags deadlock_embedded github.com/ErikKassubek/Deadlock-Go/cmd/undead-selftest
+
+Each scenario uses its own detector with the default options, except for a
+shorter interval of the periodical detection. The exit status is 1, if the
+result of a scenario differs from the expected result.
+*/
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	deadlock "github.com/ErikKassubek/Deadlock-Go"
+	"github.com/ErikKassubek/Deadlock-Go/scenarios"
+)
+
+// import path of the detector
+const deadlockModule = "github.com/ErikKassubek/Deadlock-Go"
+
+func main() {
+	run := flag.String("run", "", "run only the scenarios matching the regular expression")
+	periodic := flag.Duration("periodic", 100*time.Millisecond,
+		"interval of the periodical detection")
+	timeout := flag.Duration("timeout", 5*time.Second,
+		"time after which a scenario with an actual deadlock is considered undetected")
+	verbose := flag.Bool("v", false, "print the titles of the reports of each scenario")
+	flag.Parse()
+
+	filter, err := regexp.Compile(*run)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "undead-selftest:", err)
+		os.Exit(2)
+	}
+
+	opts := deadlock.DefaultOptions()
+	opts.PeriodicDetectionTime = *periodic
+
+	fmt.Println(buildDescription())
+	passed, total := 0, 0
+	for _, s := range scenarios.All() {
+		if !filter.MatchString(s.Name) {
+			continue
+		}
+		total++
+
+		res, err := scenarios.Run(s, opts, *timeout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "undead-selftest:", err)
+			os.Exit(2)
+		}
+
+		status := "FAIL"
+		if res.Passed() {
+			status = "PASS"
+			passed++
+		}
+		note := ""
+		if s.FalsePositive {
+			note = " (false positive)"
+		}
+		fmt.Printf("%s  %-20s expected %s%s, got %s\n", status, s.Name, s.Expect, note,
+			res.Got)
+		if *verbose {
+			fmt.Println("      ", s.Description)
+			for _, r := range res.Reports {
+				fmt.Println("      ", r.Title)
+			}
+		}
+	}
+
+	fmt.Printf("%d of %d scenarios passed\n", passed, total)
+	if passed != total {
+		os.Exit(1)
+	}
+}
+
+// describe the build of the detector, which is checked by the self-test
+//  Returns:
+//   (string): version of the detector and of Go and the build tags
+func buildDescription() string {
+	version, tags := "unknown", ""
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Path == deadlockModule {
+			version = info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == deadlockModule {
+				version = dep.Version
+				if dep.Replace != nil {
+					version += " => " + dep.Replace.Path
+				}
+			}
+		}
+		for _, setting := range info.Settings {
+			if setting.Key == "-tags" {
+				tags = setting.Value
+			}
+		}
+	}
+	if tags == "" {
+		tags = "none"
+	}
+	return fmt.Sprintf("%s %s, %s, build tags: %s", deadlockModule, version,
+		runtime.Version(), tags)
+}
@@ -0,0 +1,384 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+cond.go
+This file implements a condition variable, which can be used as a drop-in
+replacement for sync.Cond. Routines waiting in Wait are registered in the
+wait-for graph. A waiting routine can only be woken by a routine, which
+signals the condition variable. The routines, which called Signal or
+Broadcast before, are therefore inferred as the blockers of a waiting
+routine, so that cycles in which the signaling routines are blocked by a
+lock held by a waiting routine, or are themselves blocked in a cycle, are
+found by the periodical detection. Another routine can still signal the
+condition variable, such a cycle is therefore only reported as a warning
+and the program is not terminated. A signal, which is sent while no routine waits, is
+lost. The last lost signal is saved and shown in the reports, because a
+routine waiting forever after a lost signal is a common bug.
+The locker of the condition variable is released and reacquired by the
+Wait of sync.Cond, so that the reacquisition of a lock of the detector is
+marked in the reports as with sync.Cond.
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/ErikKassubek/Deadlock-Go/internal/waitfor"
+)
+
+// type to implement a condition variable
+type Cond struct {
+	// L is held while observing or changing the condition
+	L sync.Locker
+	// condition variable, which releases and reacquires L
+	cond *sync.Cond
+	// lock to prevent concurrent access to the routines and signals
+	lock *sync.Mutex
+	// number of routines currently waiting, including routines which are
+	// not registered
+	waiters int
+	// indexes of the routines currently waiting
+	waiting map[int]struct{}
+	// indexes of all routines which ever signaled the condition variable
+	signalers map[int]struct{}
+	// caller info of the last signal, which was sent while no routine waited
+	missed *callerInfo
+	// info about the creation of the condition variable
+	context []callerInfo
+	// detector the condition variable belongs to
+	detector *Detector
+	// initializes a condition variable, which was not created with NewCond,
+	// at its first use
+	lazy sync.Once
+}
+
+// create a new condition variable with locker l
+//  Args:
+//   l (sync.Locker): locker of the condition variable
+//  Returns:
+//   (*Cond): the created condition variable
+func NewCond(l sync.Locker) *Cond {
+	return newCond(defaultDetector, l, 2)
+}
+
+// create a new condition variable and save the caller information of the
+// creation
+//  Args:
+//   d (*Detector): detector the condition variable belongs to
+//   l (sync.Locker): locker of the condition variable
+//   skip (int): number of stack frames between the user code and the
+//    callerSite call
+//  Returns:
+//   (*Cond): the created condition variable
+func newCond(d *Detector, l sync.Locker, skip int) *Cond {
+	// initialize detector if necessary
+	if !d.initialized {
+		d.initialize()
+	}
+
+	c := Cond{L: l}
+
+	// save the position of the NewCond call
+	_, file, line, _ := callerSite(skip)
+	c.lazy.Do(func() {
+		c.init(d, newInfo(file, line, "", true, false, ""))
+	})
+
+	return &c
+}
+
+// initialize the condition variable
+//  Args:
+//   d (*Detector): detector the condition variable belongs to
+//   info (callerInfo): caller info of the creation
+//  Returns:
+//   nil
+func (c *Cond) init(d *Detector, info callerInfo) {
+	c.cond = sync.NewCond(condLocker{c})
+	c.lock = &sync.Mutex{}
+	c.waiting = make(map[int]struct{})
+	c.signalers = make(map[int]struct{})
+	c.detector = d
+	c.context = append(c.context, info)
+}
+
+// initialize a condition variable, which was declared as a zero value
+// instead of being created with NewCond. The first use of the condition
+// variable is saved as its creation
+//  Returns:
+//   nil
+func (c *Cond) lazyInit() {
+	c.lazy.Do(func() {
+		if !defaultDetector.initialized {
+			defaultDetector.initialize()
+		}
+
+		frame := userFrame()
+		c.init(defaultDetector, newInfo(frame.File, frame.Line, frame.Function,
+			true, false, ""))
+	})
+}
+
+// type to release and reacquire the current locker of a condition variable,
+// so that L can be set after the creation as with sync.Cond
+type condLocker struct {
+	c *Cond
+}
+
+// acquire the locker of the condition variable
+//  Returns:
+//   nil
+func (l condLocker) Lock() {
+	l.c.L.Lock()
+}
+
+// release the locker of the condition variable
+//  Returns:
+//   nil
+func (l condLocker) Unlock() {
+	l.c.L.Unlock()
+}
+
+// ============ GETTER ============
+
+// getter for context
+//  Returns:
+//   (*[]callerInfo): caller info of the condition variable
+func (c *Cond) getContext() *[]callerInfo {
+	return &c.context
+}
+
+// getter for the name of the resource type
+//  Returns:
+//   (string): name of the resource type
+func (c *Cond) getResourceName() string {
+	return "Cond"
+}
+
+// get the routines a routine waiting for the condition variable waits for.
+// These are all routines which signaled the condition variable before,
+// except the waiting routine. Any other routine can also signal the
+// condition variable, they are therefore only inferred blockers. If no other
+// routine has signaled the condition variable yet, the wait is not
+// considered as blocked by any routine
+//  Args:
+//   routineIndex (int): index of the waiting routine
+//   read (bool): not used for condition variables
+//  Returns:
+//   (waitfor.Blockers): the routines which signaled the condition variable
+//    as inferred blockers
+func (c *Cond) getBlockers(routineIndex int, read bool) waitfor.Blockers {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	res := make([]int, 0, len(c.signalers))
+	for index := range c.signalers {
+		if index != routineIndex {
+			res = append(res, index)
+		}
+	}
+	return waitfor.Blockers{Inferred: res}
+}
+
+// ============ FUNCTIONS ============
+
+// Wait atomically unlocks c.L and suspends the routine. After the routine is
+// woken by Signal or Broadcast, Wait locks c.L before it returns. As with
+// sync.Cond, Wait must be called while c.L is held and the condition must be
+// checked in a loop
+//  Returns:
+//   nil
+func (c *Cond) Wait() {
+	c.lazyInit()
+
+	d := c.detector
+	index := -1
+	if d.opts.Activated && d.opts.PeriodicDetection {
+		// create new routine, if not initialized
+		index = d.registerRoutine()
+	}
+
+	c.lock.Lock()
+	c.waiters++
+	if index != -1 {
+		c.waiting[index] = struct{}{}
+	}
+	c.lock.Unlock()
+
+	// register the wait in the wait-for graph. c.lock is not held, because
+	// the detection locks c while the wait-for graph is locked. The
+	// reacquisition of L replaces the wait by the wait for the lock
+	if index != -1 {
+		frame := userFrame()
+		d.startWaiting(index, c, false, newInfo(frame.File, frame.Line,
+			frame.Function, false, false, ""))
+	}
+
+	c.cond.Wait()
+
+	if index != -1 {
+		d.stopWaiting(index)
+	}
+
+	c.lock.Lock()
+	c.waiters--
+	delete(c.waiting, index)
+	c.lock.Unlock()
+}
+
+// Signal wakes one routine waiting on c, if there is any. It is allowed but
+// not required for the caller to hold c.L during the call
+//  Returns:
+//   nil
+func (c *Cond) Signal() {
+	c.lazyInit()
+	c.signal()
+	c.cond.Signal()
+}
+
+// Broadcast wakes all routines waiting on c. It is allowed but not required
+// for the caller to hold c.L during the call
+//  Returns:
+//   nil
+func (c *Cond) Broadcast() {
+	c.lazyInit()
+	c.signal()
+	c.cond.Broadcast()
+}
+
+// save the signaling routine as a possible blocker of the waiting routines
+// and save the signal if no routine is waiting
+//  Returns:
+//   nil
+func (c *Cond) signal() {
+	d := c.detector
+	if !d.opts.Activated {
+		return
+	}
+
+	index := -1
+	if d.opts.PeriodicDetection {
+		index = d.registerRoutine()
+	}
+
+	c.lock.Lock()
+	if index != -1 {
+		c.signalers[index] = struct{}{}
+	}
+	if c.waiters == 0 {
+		frame := userFrame()
+		info := newInfo(frame.File, frame.Line, frame.Function, false, false, "")
+		c.missed = &info
+	}
+	c.lock.Unlock()
+}
+
+// write the last signal of the condition variable, which was sent while no
+// routine was waiting
+//  Args:
+//   out (*bytes.Buffer): buffer to write to
+//  Returns:
+//   nil
+func (c *Cond) writeMissedSignal(out *bytes.Buffer) {
+	c.lock.Lock()
+	missed := c.missed
+	c.lock.Unlock()
+
+	if missed == nil {
+		return
+	}
+	fmt.Fprintln(out, "Last signal, which was sent while no routine was waiting:")
+	fmt.Fprintln(out, missed.file(), missed.line())
+}
+
+// get the routines waiting for a condition variable, which can only be woken
+// by routines in a deadlock, i.e. all routines which signaled the condition
+// variable are in the cycle or are themselves such waiting routines
+//  Args:
+//   cycle ([]int): indexes of the routines in the deadlock
+//  Returns:
+//   ([]int): indexes of the waiting routines
+//   ([]waitState): waits of the waiting routines
+func (d *Detector) condWaitersBlockedBy(cycle []int) ([]int, []waitState) {
+	blocked := make(map[int]bool, len(cycle))
+	for _, index := range cycle {
+		blocked[index] = true
+	}
+
+	// collect the routines waiting for a condition variable with their
+	// signaling routines
+	type condWait struct {
+		index     int
+		state     waitState
+		signalers []int
+	}
+	waits := make([]condWait, 0)
+	d.waitGraph.Range(false, func(index int, w *waitfor.Want) {
+		state := w.Data.(*waitState)
+		c, ok := state.resource.(*Cond)
+		if !ok || blocked[index] {
+			return
+		}
+		signalers := c.getBlockers(index, false).Inferred
+		if len(signalers) != 0 {
+			waits = append(waits, condWait{index: index, state: *state, signalers: signalers})
+		}
+	})
+
+	// add the waiting routines until no more routine is found, because a
+	// routine can be blocked by a routine, which is blocked by the cycle
+	indexes := make([]int, 0)
+	states := make([]waitState, 0)
+	for changed := true; changed; {
+		changed = false
+		for _, w := range waits {
+			if blocked[w.index] || !allBlocked(w.signalers, blocked) {
+				continue
+			}
+			blocked[w.index] = true
+			indexes = append(indexes, w.index)
+			states = append(states, w.state)
+			changed = true
+		}
+	}
+	return indexes, states
+}
+
+// check if all routines are blocked
+//  Args:
+//   indexes ([]int): indexes of the routines
+//   blocked (map[int]bool): blocked routines
+//  Returns:
+//   (bool): true if all routines are blocked
+func allBlocked(indexes []int, blocked map[int]bool) bool {
+	for _, index := range indexes {
+		if !blocked[index] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,153 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+console.go
+Implementation of the console output of the reports. The title of a report
+is colored according to its severity. Colors are only used, if the output is
+a terminal, unless the color mode is set explicitly. The verbosity decides
+whether the complete reports, summaries or only the titles are printed.
+*/
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Verbosity describes how detailed the reports are printed to the console
+type Verbosity int
+
+const (
+	// VerbosityQuiet prints only one line with the title of each report
+	VerbosityQuiet Verbosity = iota
+	// VerbositySummary prints the title and the first section of each
+	// report, e.g. the locks involved in a deadlock
+	VerbositySummary
+	// VerbosityFull prints the complete reports
+	VerbosityFull
+	// VerbosityDebug prints the complete reports and the quality metrics of
+	// each comprehensive detection, i.e. the number of candidates rejected
+	// by each rule of the detection
+	VerbosityDebug
+)
+
+// ColorMode describes whether the console output is colored
+type ColorMode int
+
+const (
+	// ColorAuto colors the output, if it is a terminal
+	ColorAuto ColorMode = iota
+	// ColorAlways always colors the output
+	ColorAlways
+	// ColorNever never colors the output
+	ColorNever
+)
+
+// get the color of the title of a report
+//  Args:
+//   reportType (ReportType): kind of the report
+//  Returns:
+//   (string): color format of the title
+func severityColor(reportType ReportType) string {
+	switch reportType {
+	case ReportDeadlock:
+		return red
+	case ReportPotentialDeadlock:
+		return yellow
+	default:
+		return blue
+	}
+}
+
+// check if the output to a writer is colored
+//  Args:
+//   w (io.Writer): writer of the output
+//  Returns:
+//   (bool): true if the output is colored, false otherwise
+func (d *Detector) useColor(w io.Writer) bool {
+	switch d.opts.Color {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	// see https://no-color.org
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// print a report to the console
+//  Args:
+//   w (io.Writer): writer of the console
+//   r (Report): report without the text
+//   body (string): colored text of the report without the title
+//  Returns:
+//   nil
+func (d *Detector) printReport(w io.Writer, r Report, body string) {
+	var b strings.Builder
+
+	switch d.opts.Verbosity {
+	case VerbosityQuiet:
+		fmt.Fprintf(&b, severityColor(r.Type), r.Title)
+		if r.ID != "" {
+			fmt.Fprint(&b, " (Cycle ID: ", r.ID, ")")
+		}
+		fmt.Fprintln(&b)
+	case VerbositySummary:
+		fmt.Fprintf(&b, severityColor(r.Type), r.Title+"\n\n")
+		// the sections of a report start with a purple header, the summary
+		// contains everything before the second header
+		header := strings.Split(purple, "%s")[0]
+		if first := strings.Index(body, header); first != -1 {
+			if second := strings.Index(body[first+1:], header); second != -1 {
+				body = body[:first+1+second]
+			}
+		}
+		fmt.Fprint(&b, strings.TrimRight(body, "\n"), "\n\n")
+	default:
+		fmt.Fprintf(&b, severityColor(r.Type), r.Title+"\n\n")
+		fmt.Fprint(&b, body)
+	}
+
+	text := b.String()
+	if !d.useColor(w) {
+		text = removeColors(text)
+	}
+	fmt.Fprint(w, text)
+}
@@ -0,0 +1,169 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+convoy.go
+This file implements the detection of lock convoys. A lock convoy occurs,
+if a lock has persistently long queues of waiting routines, while the
+routine which releases the lock reacquires it directly afterwards. The
+waiting routines are then starved, which can look like a deadlock.
+*/
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/petermattis/goid"
+)
+
+// number of acquisitions after which a lock is checked for a convoy
+const convoyWindow = 100
+
+// maximum time between release and acquisition of a lock by the same
+// routine, such that the acquisition counts as immediate reacquisition
+const convoyReacquireTime = time.Millisecond
+
+// minimum ratio of immediate reacquisitions in a window for a convoy
+const convoyReacquireRatio = 0.5
+
+// maximum number of saved sites for waiting routines and reacquisitions
+const convoyMaxSites = 10
+
+// type to save information about the queue of a lock
+type convoyInfo struct {
+	// lock to prevent concurrent access to the convoy info
+	lock sync.Mutex
+	// number of routines currently waiting for the lock
+	waiting int
+	// id of the routine which released the lock last
+	lastReleaser int64
+	// time of the last release
+	lastRelease time.Time
+	// number of acquisitions in the current window
+	acquisitions int
+	// sum of the queue lengths at the acquisitions in the current window
+	waitingSum int
+	// number of immediate reacquisitions in the current window
+	reacquisitions int
+	// sites of the immediate reacquisitions
+	reacquisitionSites map[string]callerInfo
+	// sites of the waiting routines
+	waitingSites map[string]callerInfo
+	// true if a convoy on the lock was already reported
+	reported bool
+}
+
+// create a new convoyInfo
+//  Returns:
+//   (*convoyInfo): the created convoyInfo
+func newConvoyInfo() *convoyInfo {
+	return &convoyInfo{
+		lastReleaser:       -1,
+		reacquisitionSites: make(map[string]callerInfo),
+		waitingSites:       make(map[string]callerInfo),
+	}
+}
+
+// register that a routine starts to wait for a lock
+//  Args:
+//   m (mutexInt): lock the routine waits for
+//  Returns:
+//   (callerInfo): caller info of the acquisition
+func startConvoyWait(m mutexInt) callerInfo {
+	c := m.getConvoyInfo()
+
+	_, file, line, _ := callerSite(3)
+	caller := newInfo(file, line, "", false, false, "")
+
+	c.lock.Lock()
+	c.waiting++
+	if c.waiting > 1 && len(c.waitingSites) < convoyMaxSites {
+		c.waitingSites[fmt.Sprint(file, ":", line)] = caller
+	}
+	c.lock.Unlock()
+
+	return caller
+}
+
+// register that a routine has acquired a lock and check for a convoy
+// at the end of each window
+//  Args:
+//   m (mutexInt): lock which was acquired
+//   caller (callerInfo): caller info of the acquisition
+//  Returns:
+//   nil
+func endConvoyWait(m mutexInt, caller callerInfo) {
+	c := m.getConvoyInfo()
+	id := goid.Get()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.waiting--
+	c.acquisitions++
+	c.waitingSum += c.waiting
+
+	// the lock was reacquired by the last releaser while other routines wait
+	if c.waiting > 0 && c.lastReleaser == id &&
+		time.Since(c.lastRelease) <= convoyReacquireTime {
+		c.reacquisitions++
+		if len(c.reacquisitionSites) < convoyMaxSites {
+			c.reacquisitionSites[fmt.Sprint(caller.file(), ":", caller.line())] = caller
+		}
+	}
+
+	if c.acquisitions < convoyWindow {
+		return
+	}
+
+	// check the window for a convoy
+	d := m.getDetector()
+	if !c.reported &&
+		c.waitingSum >= d.opts.ConvoyMinWaiting*c.acquisitions &&
+		float64(c.reacquisitions) >= convoyReacquireRatio*float64(c.acquisitions) {
+		c.reported = true
+		d.reportConvoy(m, c)
+	}
+
+	// start a new window
+	c.acquisitions = 0
+	c.waitingSum = 0
+	c.reacquisitions = 0
+}
+
+// register that a routine has released a lock
+//  Args:
+//   m (mutexInt): lock which was released
+//  Returns:
+//   nil
+func releaseConvoy(m mutexInt) {
+	c := m.getConvoyInfo()
+
+	c.lock.Lock()
+	c.lastReleaser = goid.Get()
+	c.lastRelease = time.Now()
+	c.lock.Unlock()
+}
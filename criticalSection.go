@@ -0,0 +1,271 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+criticalSection.go
+Implementation of the inventory of the critical sections. With
+CriticalSections, each release of a lock is paired with the acquisition of
+the lock by the releasing routine. For each lock, the pairs of acquisition
+and release sites are counted together with the durations, for which the
+lock was held. An acquisition site, whose critical sections end at different
+release sites, or a release by a routine, which did not acquire the lock,
+often points to a path on which the lock is not released, e.g. a return
+before the Unlock, which precedes a double locking or a deadlock. The
+inventory is written as Markdown tables or as JSON.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CriticalSectionFormat describes the format of the exported critical
+// sections
+type CriticalSectionFormat int
+
+const (
+	// CriticalSectionMarkdown writes the critical sections as Markdown tables
+	CriticalSectionMarkdown CriticalSectionFormat = iota
+	// CriticalSectionJSON writes the critical sections as a JSON object
+	CriticalSectionJSON
+)
+
+// key of the critical sections of a lock, which were started and ended at
+// the same sites
+type criticalSectionKey struct {
+	// the lock
+	mu mutexInt
+	// acquisition site, empty if the lock was released by a routine, which
+	// did not acquire it
+	acquired string
+	// release site
+	released string
+}
+
+// critical sections of a lock with the same acquisition and release site
+type criticalSection struct {
+	// number of critical sections
+	count int
+	// sum of the durations, for which the lock was held
+	total time.Duration
+	// longest duration, for which the lock was held
+	max time.Duration
+}
+
+// pair the release of a lock with the acquisition of the lock by the
+// releasing routine. Must be called before the routine is removed from the
+// holders of the lock
+//  Args:
+//   m (mutexInt): the released lock
+//   index (int): index of the routine, -1 if the routine is not registered
+//  Returns:
+//   nil
+func (d *Detector) recordCriticalSection(m mutexInt, index int) {
+	if !d.opts.CriticalSections {
+		return
+	}
+
+	frame := userFrame()
+	key := criticalSectionKey{
+		mu:       m,
+		released: fmt.Sprint(workspacePath(frame.File), ":", frame.Line),
+	}
+	var hold time.Duration
+
+	m.getIsLockedRoutineIndexLock().Lock()
+	if acquired, ok := (*m.getHolders())[index]; ok {
+		key.acquired = fmt.Sprint(workspacePath(acquired.file()), ":", acquired.line())
+		hold = time.Since(d.start) - acquired.timestamp
+	}
+	m.getIsLockedRoutineIndexLock().Unlock()
+
+	d.criticalSectionsLock.Lock()
+	section, ok := d.criticalSections[key]
+	if !ok {
+		section = &criticalSection{}
+		d.criticalSections[key] = section
+	}
+	section.count++
+	section.total += hold
+	if hold > section.max {
+		section.max = hold
+	}
+	d.criticalSectionsLock.Unlock()
+}
+
+// critical sections of a lock with the same acquisition and release site in
+// the export
+type criticalSectionEntry struct {
+	Lock     string        `json:"lock"`
+	Type     string        `json:"type"`
+	Acquired string        `json:"acquired,omitempty"`
+	Released string        `json:"released"`
+	Count    int           `json:"count"`
+	Mean     time.Duration `json:"mean_ns"`
+	Max      time.Duration `json:"max_ns"`
+	// true if the critical sections started at Acquired end at multiple
+	// release sites
+	Divergent bool `json:"divergent"`
+}
+
+// WriteCriticalSections writes the critical sections recorded by the default
+// detector (see Detector.WriteCriticalSections)
+//  Args:
+//   w (io.Writer): writer to write to
+//   format (CriticalSectionFormat): CriticalSectionMarkdown or
+//    CriticalSectionJSON
+//  Returns:
+//   (error): error if the critical sections could not be written
+func WriteCriticalSections(w io.Writer, format CriticalSectionFormat) error {
+	return defaultDetector.WriteCriticalSections(w, format)
+}
+
+// WriteCriticalSections writes the critical sections recorded by the
+// detector. Each entry pairs an acquisition site of a lock with a release
+// site, how often the lock was held between them and for how long. The
+// critical sections are only recorded with the option CriticalSections
+//  Args:
+//   w (io.Writer): writer to write to
+//   format (CriticalSectionFormat): CriticalSectionMarkdown or
+//    CriticalSectionJSON
+//  Returns:
+//   (error): error if the critical sections could not be written
+func (d *Detector) WriteCriticalSections(w io.Writer, format CriticalSectionFormat) error {
+	entries := d.criticalSectionEntries()
+
+	switch format {
+	case CriticalSectionMarkdown:
+		return writeCriticalSectionsMarkdown(w, entries)
+	case CriticalSectionJSON:
+		return writeCriticalSectionsJSON(w, entries)
+	}
+	return fmt.Errorf("deadlock: unknown critical section format %d", format)
+}
+
+// collect the critical sections of all locks
+//  Returns:
+//   ([]criticalSectionEntry): critical sections sorted by lock, acquisition
+//    and release site
+func (d *Detector) criticalSectionEntries() []criticalSectionEntry {
+	d.criticalSectionsLock.Lock()
+	sections := make(map[criticalSectionKey]criticalSection, len(d.criticalSections))
+	for key, section := range d.criticalSections {
+		sections[key] = *section
+	}
+	d.criticalSectionsLock.Unlock()
+
+	locks := make(map[mutexInt]bool)
+	for key := range sections {
+		locks[key.mu] = true
+	}
+	names := siteNames(locks)
+
+	// count the release sites of each acquisition site
+	releases := make(map[[2]string]int)
+	for key := range sections {
+		if key.acquired != "" {
+			releases[[2]string{names[key.mu], key.acquired}]++
+		}
+	}
+
+	entries := make([]criticalSectionEntry, 0, len(sections))
+	for key, section := range sections {
+		entries = append(entries, criticalSectionEntry{
+			Lock:      names[key.mu],
+			Type:      key.mu.getResourceName(),
+			Acquired:  key.acquired,
+			Released:  key.released,
+			Count:     section.count,
+			Mean:      section.total / time.Duration(section.count),
+			Max:       section.max,
+			Divergent: releases[[2]string{names[key.mu], key.acquired}] > 1,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Lock != entries[j].Lock {
+			return entries[i].Lock < entries[j].Lock
+		}
+		if entries[i].Acquired != entries[j].Acquired {
+			return entries[i].Acquired < entries[j].Acquired
+		}
+		return entries[i].Released < entries[j].Released
+	})
+	return entries
+}
+
+// write the critical sections as Markdown tables
+//  Args:
+//   w (io.Writer): writer to write to
+//   entries ([]criticalSectionEntry): critical sections
+//  Returns:
+//   (error): error if the tables could not be written
+func writeCriticalSectionsMarkdown(w io.Writer, entries []criticalSectionEntry) error {
+	var b strings.Builder
+	b.WriteString("# Critical sections\n\n")
+	b.WriteString("Each row pairs an acquisition of a lock with the release of the " +
+		"lock by the same routine. Acquisitions, which are released at multiple " +
+		"sites, are marked with *. Releases by a routine, which did not acquire " +
+		"the lock, have an unknown acquisition site.\n\n")
+	b.WriteString("| Lock | Type | Acquired at | Released at | Count | Mean hold | Max hold |\n")
+	b.WriteString("|---|---|---|---|---|---|---|\n")
+	for _, e := range entries {
+		// the hold durations are not known without the acquisition
+		acquired, mean, max := "unknown", "unknown", "unknown"
+		if e.Acquired != "" {
+			acquired, mean, max = "`"+e.Acquired+"`", e.Mean.String(), e.Max.String()
+		}
+		if e.Divergent {
+			acquired += " *"
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s | `%s` | %d | %s | %s |\n", e.Lock, e.Type,
+			acquired, e.Released, e.Count, mean, max)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// write the critical sections as a JSON object
+//  Args:
+//   w (io.Writer): writer to write to
+//   entries ([]criticalSectionEntry): critical sections
+//  Returns:
+//   (error): error if the object could not be written
+func writeCriticalSectionsJSON(w io.Writer, entries []criticalSectionEntry) error {
+	var inventory struct {
+		Schema   int                    `json:"schema"`
+		Sections []criticalSectionEntry `json:"sections"`
+	}
+	inventory.Schema = SchemaVersion
+	inventory.Sections = entries
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(inventory)
+}
@@ -0,0 +1,104 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+cycleID.go
+Implementation of stable identifiers for cycles. The identifier of a cycle
+is a hash of the normalized sites of the cycle. A site is normalized to the
+function it is in, so that the identifier does not change if code is moved
+inside of a file or between files. Since the sites are sorted before they
+are hashed, the identifier does not depend on the routine, with which the
+detection of the cycle started. The identifier can therefore be used to
+reference a cycle across runs of the program.
+*/
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// get the normalized site of a call
+//  Args:
+//   c (callerInfo): caller info of the call
+//  Returns:
+//   (string): function of the call, the name of the file if the function is
+//    unknown
+func normalizedSite(c callerInfo) string {
+	if c.function() != "" {
+		return c.function()
+	}
+	return filepath.Base(c.file())
+}
+
+// compute the identifier of a cycle
+//  Args:
+//   sites ([]string): normalized sites of the cycle
+//  Returns:
+//   (string): identifier of the cycle
+func cycleID(sites []string) string {
+	sorted := append([]string{}, sites...)
+	sort.Strings(sorted)
+
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(sorted, "\n")))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// compute the identifier of a cycle of dependencies
+//  Args:
+//   deps ([]*dependency): dependencies in the cycle
+//  Returns:
+//   (string): identifier of the cycle
+func dependencyCycleID(deps []*dependency) string {
+	sites := make([]string, 0, len(deps))
+	for _, dep := range deps {
+		mode := "w"
+		if dep.read {
+			mode = "r"
+		}
+		context := *dep.mu.getContext()
+		sites = append(sites, fmt.Sprint(mode, " ", normalizedSite(dep.caller),
+			" ", normalizedSite(context[0])))
+	}
+	return cycleID(sites)
+}
+
+// compute the identifier of a wait-for cycle
+//  Args:
+//   states ([]waitState): waits of the routines in the cycle
+//  Returns:
+//   (string): identifier of the cycle
+func waitForCycleID(states []waitState) string {
+	sites := make([]string, 0, len(states))
+	for _, state := range states {
+		context := *state.resource.getContext()
+		sites = append(sites, fmt.Sprint(state.resource.getResourceName(), " ",
+			normalizedSite(state.caller), " ", normalizedSite(context[0])))
+	}
+	return cycleID(sites)
+}
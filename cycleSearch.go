@@ -0,0 +1,163 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+cycleSearch.go
+Implementation of the alternative search for cycles with the algorithm of
+Johnson (internal/analyze). Instead of building chains of dependencies, the
+elementary cycles of the deduplicated lock-order graph are enumerated. Each
+cycle of the graph is found exactly once, the dependencies of its edges are
+then combined into the cycles of dependencies, which are checked with the
+same conditions as the chains of the depth-first search. On dense graphs,
+in which many chains do not close a cycle, this is faster than the
+depth-first search.
+*/
+
+import (
+	"github.com/ErikKassubek/Deadlock-Go/internal/analyze"
+	"github.com/ErikKassubek/Deadlock-Go/internal/graph"
+)
+
+// CycleSearchAlgorithm describes how the comprehensive detection searches
+// for cycles in the lock trees
+type CycleSearchAlgorithm int
+
+const (
+	// CycleSearchChains searches for cycles with a depth-first search over
+	// the chains of dependencies
+	CycleSearchChains CycleSearchAlgorithm = iota
+	// CycleSearchJohnson enumerates the elementary cycles of the lock-order
+	// graph with the algorithm of Johnson
+	CycleSearchJohnson
+)
+
+// search the cycles in the dependencies of the search with the algorithm
+// of Johnson
+//  Returns:
+//   nil
+func (d *Detector) detectJohnson() {
+	g := newLockOrderGraph(d.searchDependencies)
+	johnson := analyze.Johnson{
+		Visit: func(length int) bool {
+			return d.progress.explore(length + 1)
+		},
+		Extend: d.progress.extend,
+	}
+
+	used := make([]bool, d.snapshot.routines())
+	johnson.Cycles(g, func(cycle []int) bool {
+		stack := newDepStack()
+		d.johnsonDependencies(g, cycle, &stack, used)
+		return !d.progress.stopped()
+	})
+
+	if d.progress.stopped() {
+		return
+	}
+	for i := 0; i < d.snapshot.routines(); i++ {
+		d.progress.routineDone()
+	}
+}
+
+// combine the dependencies of the edges of a cycle of the lock-order graph
+// into cycles of dependencies and report them. The dependency of an edge
+// is the dependency, whose lock is the end of the edge, so that each
+// dependency is linked to the dependency of the next edge
+//  Args:
+//   g (*graph.Graph): the lock-order graph
+//   cycle ([]int): indexes of the edges of the cycle, whose dependencies are
+//    not on the stack yet
+//   stack (*depStack): dependencies of the previous edges of the cycle
+//   used ([]bool): true for the routines of the dependencies on the stack
+//  Returns:
+//   nil
+func (d *Detector) johnsonDependencies(g *graph.Graph, cycle []int,
+	stack *depStack, used []bool) {
+	edge := g.Edges()[cycle[0]]
+	for _, ref := range edge.Deps {
+		if d.progress.stopped() {
+			return
+		}
+		dep := d.searchDependencies[ref.Routine][ref.Index]
+		if used[ref.Routine] {
+			d.progress.prune(pruneHappensBefore, 1)
+			continue
+		}
+		if stack.size > 0 {
+			rule := d.chainRule(stack, dep, ref.Routine, false)
+			d.progress.prune(rule, 1)
+			if rule != pruneNone {
+				continue
+			}
+		}
+
+		if len(cycle) == 1 {
+			rule := d.cycleChainRule(stack, dep, ref.Routine, false)
+			d.progress.prune(rule, 1)
+			if rule == pruneNone {
+				stack.push(dep, ref.Routine)
+				if !d.hasChord(stack, false) {
+					rotated := rotateToFirstRoutine(stack)
+					d.recordFoundCycle(&rotated)
+					d.reportDeadlock(&rotated)
+				} else {
+					d.progress.prune(pruneDedup, 1)
+				}
+				stack.pop()
+			}
+			continue
+		}
+
+		stack.push(dep, ref.Routine)
+		used[ref.Routine] = true
+		d.johnsonDependencies(g, cycle[1:], stack, used)
+		used[ref.Routine] = false
+		stack.pop()
+	}
+}
+
+// rotate a cycle, so that it starts with the dependency of the routine with
+// the smallest index, like the cycles of the depth-first search
+//  Args:
+//   stack (*depStack): stack which represents the cycle
+//  Returns:
+//   (depStack): the rotated cycle
+func rotateToFirstRoutine(stack *depStack) depStack {
+	elements := make([]*stackElement, 0, stack.size)
+	first := 0
+	for cl := stack.stack.next; cl != nil; cl = cl.next {
+		if len(elements) > 0 && cl.index < elements[first].index {
+			first = len(elements)
+		}
+		elements = append(elements, cl)
+	}
+
+	res := newDepStack()
+	for i := range elements {
+		e := elements[(first+i)%len(elements)]
+		res.push(e.depEntry, e.index)
+	}
+	return res
+}
@@ -0,0 +1,203 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+declaredLockOrder.go
+Implementation of the check of the declared lock order. The declared order
+consists of chains of lock patterns. A lock must not be acquired while a
+lock, which matches a later pattern of the same chain, is held. Every
+acquisition, which contradicts the order, is reported separately from the
+cycle detection, so that a convention is already enforced when only one
+direction of an edge was observed. Each contradicting acquisition site is
+only reported once.
+
+The declared order can be read from a file with one chain per line, in
+which the patterns are separated by "->". Empty lines and lines starting
+with "#" are ignored:
+
+	# accounts before transactions before the log
+	bank/account.go -> bank/transaction.go:31 -> log/writer.go
+*/
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// creation site of a lock
+type lockSiteKey struct {
+	file string
+	line int
+}
+
+// check if a chain of the declared lock order is valid
+//  Args:
+//   chain ([]string): chain of lock patterns
+//  Returns:
+//   (error): nil if the chain is valid, an error describing the problem
+//    otherwise
+func validateLockOrderChain(chain []string) error {
+	if len(chain) < 2 {
+		return fmt.Errorf("deadlock: a chain of the LockOrder must contain at "+
+			"least two patterns, got %q", chain)
+	}
+	for _, pattern := range chain {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("deadlock: empty pattern in LockOrder chain %q", chain)
+		}
+	}
+	return nil
+}
+
+// ReadLockOrder reads a declared lock order from a file
+//  Args:
+//   path (string): path of the file
+//  Returns:
+//   ([][]string): chains of the lock order
+//   (error): error if the file could not be read or is invalid
+func ReadLockOrder(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	chains := make([][]string, 0)
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		chain := strings.Split(line, "->")
+		for i := range chain {
+			chain[i] = strings.TrimSpace(chain[i])
+		}
+		if err := validateLockOrderChain(chain); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+		chains = append(chains, chain)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return chains, nil
+}
+
+// check if the creation site of a lock matches a pattern
+//  Args:
+//   site (lockSiteKey): creation site of the lock
+//   pattern (string): file:line or file
+//  Returns:
+//   (bool): true if the site matches the pattern, false otherwise
+func matchLockPattern(site lockSiteKey, pattern string) bool {
+	file := pattern
+	line := 0
+	if i := strings.LastIndex(pattern, ":"); i >= 0 {
+		if n, err := strconv.Atoi(pattern[i+1:]); err == nil {
+			file, line = pattern[:i], n
+		}
+	}
+	if line != 0 && line != site.line {
+		return false
+	}
+
+	siteFile := "/" + filepath.ToSlash(site.file)
+	return strings.HasSuffix(siteFile, "/"+strings.TrimPrefix(file, "/"))
+}
+
+// get the positions of a lock in the chains of the declared lock order
+//  Args:
+//   m (mutexInt): lock
+//  Returns:
+//   ([]int): position of the first matching pattern for each chain, -1 if
+//    no pattern of the chain matches the lock
+func (d *Detector) lockOrderPositions(m mutexInt) []int {
+	context := *m.getContext()
+	site := lockSiteKey{context[0].file(), context[0].line()}
+
+	d.lockOrderLock.Lock()
+	defer d.lockOrderLock.Unlock()
+	if positions, ok := d.lockOrderLevels[site]; ok {
+		return positions
+	}
+
+	positions := make([]int, len(d.opts.LockOrder))
+	for i, chain := range d.opts.LockOrder {
+		positions[i] = -1
+		for j, pattern := range chain {
+			if matchLockPattern(site, pattern) {
+				positions[i] = j
+				break
+			}
+		}
+	}
+	d.lockOrderLevels[site] = positions
+	return positions
+}
+
+// report all held locks, which must not be held while the new lock of the
+// routine is acquired
+//  Args:
+//   hc (int): position of the new lock in the holding set
+//  Returns:
+//   nil
+func (r *routine) checkLockOrder(hc int) {
+	d := r.detector
+	if len(d.opts.LockOrder) == 0 || hc == 0 {
+		return
+	}
+
+	m := r.holdingSet[hc]
+	acquired := d.lockOrderPositions(m)
+	for i := 0; i < hc; i++ {
+		held := d.lockOrderPositions(r.holdingSet[i])
+		for c, chain := range d.opts.LockOrder {
+			if acquired[c] < 0 || held[c] <= acquired[c] {
+				continue
+			}
+
+			// report each contradicting acquisition only once
+			key := fmt.Sprint(r.holdingCallers[i].file(), ":", r.holdingCallers[i].line(),
+				",", r.holdingCallers[hc].file(), ":", r.holdingCallers[hc].line(), ",", c)
+			d.lockOrderLock.Lock()
+			reported := d.reportedLockOrders[key]
+			d.reportedLockOrders[key] = true
+			d.lockOrderLock.Unlock()
+
+			if !reported {
+				d.reportLockOrderViolation(chain, r.holdingSet[i], r.holdingCallers[i],
+					m, r.holdingCallers[hc])
+			}
+		}
+	}
+}
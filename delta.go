@@ -0,0 +1,279 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+delta.go
+Implementation of the periodic persistence of the lock trees for long
+running processes, whose lock trees are analyzed by another process (e.g.
+a collector of an agent). Instead of the full lock trees, only the new
+unique dependencies are appended to a file in each interval, one JSON
+object per line. A lock is written once, before the first dependency,
+which contains it, and is afterwards referenced by its id. The collector
+maintains the complete current graph by applying the records in the order
+of the file (see ReadDeltas).
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// kinds of the delta records
+const (
+	// DeltaLock is the record of a lock
+	DeltaLock = "lock"
+	// DeltaDependency is the record of a dependency
+	DeltaDependency = "dependency"
+)
+
+// Delta is a record of the delta file, either a lock or a dependency
+type Delta struct {
+	// schema version of the record
+	Schema int `json:"schema"`
+	// DeltaLock or DeltaDependency
+	Kind string `json:"kind"`
+	// id of the lock, for a dependency the id of the acquired lock
+	Lock int `json:"lock"`
+	// creation site of the lock, empty for a dependency
+	Site string `json:"site,omitempty"`
+	// resource type of the lock, empty for a dependency
+	Type string `json:"type,omitempty"`
+	// index of the routine of the dependency, which distinguishes the
+	// routines within one delta file
+	Routine int `json:"routine,omitempty"`
+	// goroutine of the dependency, nil for a lock
+	Goroutine *Goroutine `json:"goroutine,omitempty"`
+	// true if the lock of the dependency was acquired as a reader lock
+	Read bool `json:"read,omitempty"`
+	// ids of the locks, which were held, when the lock of the dependency was
+	// acquired
+	Held []int `json:"held,omitempty"`
+	// true for the held locks, which were held as reader locks
+	HeldRead []bool `json:"held_read,omitempty"`
+	// site of the acquisition, which created the dependency
+	Acquired string `json:"acquired,omitempty"`
+	// time of the first acquisition, which created the dependency, nil for
+	// a lock
+	Time *time.Time `json:"time,omitempty"`
+}
+
+// type to append the new dependencies to the delta file
+type deltaWriter struct {
+	// path of the delta file
+	path string
+	// records, which were not written yet
+	pending []Delta
+	// ids of the locks, which were already written
+	lockIDs map[mutexInt]int
+	// lock to prevent concurrent access to pending and lockIDs
+	lock sync.Mutex
+	// closed to stop the periodic writing
+	stop chan struct{}
+	// closed after the last write
+	done chan struct{}
+}
+
+// create a new writer and start to write the records periodically in the
+// background
+//  Args:
+//   path (string): path of the delta file
+//   interval (time.Duration): time between two writes
+//  Returns:
+//   (*deltaWriter): the writer
+func newDeltaWriter(path string, interval time.Duration) *deltaWriter {
+	w := &deltaWriter{
+		path:    path,
+		lockIDs: make(map[mutexInt]int),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.run(interval)
+	return w
+}
+
+// get the id of a lock and add the record of the lock, if it was not
+// written yet. Must be called while lock is held
+//  Args:
+//   m (mutexInt): the lock
+//  Returns:
+//   (int): id of the lock
+func (w *deltaWriter) lockID(m mutexInt) int {
+	if id, ok := w.lockIDs[m]; ok {
+		return id
+	}
+	id := len(w.lockIDs) + 1
+	w.lockIDs[m] = id
+	w.pending = append(w.pending, Delta{
+		Schema: SchemaVersion,
+		Kind:   DeltaLock,
+		Lock:   id,
+		Site:   lockSite(m),
+		Type:   m.getResourceName(),
+	})
+	return id
+}
+
+// add the record of a new dependency
+//  Args:
+//   dep (*dependency): the dependency
+//   index (int): index of the routine of the dependency
+//   g (Goroutine): goroutine of the routine
+//   start (time.Time): start of the detector
+//  Returns:
+//   nil
+func (w *deltaWriter) add(dep *dependency, index int, g Goroutine, start time.Time) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	held := make([]int, dep.holdingCount)
+	for i := 0; i < dep.holdingCount; i++ {
+		held[i] = w.lockID(dep.holdingSet[i])
+	}
+	first := start.Add(dep.first)
+	record := Delta{
+		Schema:    SchemaVersion,
+		Kind:      DeltaDependency,
+		Lock:      w.lockID(dep.mu),
+		Routine:   index,
+		Goroutine: &g,
+		Read:      dep.read,
+		Held:      held,
+		HeldRead:  append([]bool{}, dep.holdingRead[:dep.holdingCount]...),
+		Time:      &first,
+	}
+	if dep.caller.file() != "" {
+		record.Acquired = fmt.Sprint(workspacePath(dep.caller.file()), ":",
+			dep.caller.line())
+	}
+	w.pending = append(w.pending, record)
+}
+
+// append the pending records to the delta file. If the file can not be
+// written, the records are written with the next write
+//  Returns:
+//   (error): error if the file could not be written
+func (w *deltaWriter) flush() error {
+	w.lock.Lock()
+	pending := w.pending
+	w.pending = nil
+	w.lock.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err == nil {
+		enc := json.NewEncoder(f)
+		for i, record := range pending {
+			if err = enc.Encode(record); err != nil {
+				pending = pending[i:]
+				break
+			}
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		w.lock.Lock()
+		w.pending = append(pending, w.pending...)
+		w.lock.Unlock()
+		return fmt.Errorf("deadlock: could not write the delta file: %w", err)
+	}
+	return nil
+}
+
+// write the pending records periodically until the writer is closed
+//  Args:
+//   interval (time.Duration): time between two writes
+//  Returns:
+//   nil
+func (w *deltaWriter) run(interval time.Duration) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.flush()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// stop the periodic writing and write the pending records
+//  Returns:
+//   (error): error if the file could not be written
+func (w *deltaWriter) close() error {
+	close(w.stop)
+	<-w.done
+	return w.flush()
+}
+
+// add a new dependency to the delta file, if the deltas are written
+//  Args:
+//   dep (*dependency): the new dependency
+//   index (int): index of the routine of the dependency
+//  Returns:
+//   nil
+func (d *Detector) recordDelta(dep *dependency, index int) {
+	if d.deltas == nil {
+		return
+	}
+	d.deltas.add(dep, index, d.goroutine(index), d.start)
+}
+
+// ReadDeltas reads the records of a delta file
+//  Args:
+//   r (io.Reader): reader of the delta file
+//  Returns:
+//   ([]Delta): the records in the order of the file
+//   (error): error if the file could not be read or a record was written
+//    with an unsupported schema version, a partly written last record is
+//    ignored
+func ReadDeltas(r io.Reader) ([]Delta, error) {
+	res := make([]Delta, 0)
+	dec := json.NewDecoder(r)
+	for {
+		var record Delta
+		err := dec.Decode(&record)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return res, nil
+		}
+		if err != nil {
+			return res, err
+		}
+		if err := CheckSchemaVersion(record.Schema); err != nil {
+			return res, err
+		}
+		res = append(res, record)
+	}
+}
@@ -31,31 +31,54 @@ These are all the locks which were hold by the same routine, when l was
 acquired.
 */
 
+import (
+	"sync/atomic"
+	"time"
+)
+
 // Type to implement a dependency
 // A dependency represents a set of edges in a lock tree
 // It consist of a lock l and a list of all locks, on which l depends
 // i.e. all lock which were already locked by the same routine, when
 // l was acquired.
 type dependency struct {
-	mu           mutexInt   // lock
-	holdingSet   []mutexInt // locks which where locked while mu was acquired
-	holdingCount int        // on how many locks does mu depend
+	// the fields accessed atomically are first, so that they are 64-bit
+	// aligned on 32-bit platforms
+	last         int64         // time of the last acquisition which created the dependency, accessed atomically
+	count        int64         // number of acquisitions which created the dependency, accessed atomically
+	mu           mutexInt      // lock
+	read         bool          // true if mu was acquired as a reader lock
+	holdingSet   []mutexInt    // locks which where locked while mu was acquired
+	holdingRead  []bool        // true for the locks in holdingSet which were held as reader locks
+	holdingCount int           // on how many locks does mu depend
+	upgrade      bool          // true if mu was acquired as an upgrade of a reader lock
+	initPhase    bool          // true if mu was acquired during the initialization of the packages
+	caller       callerInfo    // caller info of the acquisition which created the dependency
+	first        time.Duration // time of the first acquisition which created the dependency
+	example      *edgeExample  // first acquisition which created the dependency, nil without EdgeExamples
 }
 
 // newDependency creates and returns a new dependency object
 //  Args:
 //   mu (mutexInt): lock of the dependency
+//   read (bool): true if lock was acquired as a reader lock
 //   currentLocks ([]mutexInt): list of locks mu depends on
+//   currentRead ([]bool): true for the locks in currentLocks which are held
+//    as reader locks
 //   numberOfLocks (int): number of locks lock depends on
+//   upgrade (bool): true if lock was acquired as an upgrade of a reader lock
 //  Returns:
 //   (dependency) : the created dependency
-func newDependency(lock mutexInt, currentLocks []mutexInt,
-	numberOfLocks int) dependency {
+func newDependency(lock mutexInt, read bool, currentLocks []mutexInt,
+	currentRead []bool, numberOfLocks int, upgrade bool) dependency {
 	// create dependency
 	d := dependency{
 		mu:           lock,
+		read:         read,
 		holdingCount: numberOfLocks,
-		holdingSet:   make([]mutexInt, opts.maxNumberOfDependentLocks),
+		upgrade:      upgrade,
+		holdingSet:   make([]mutexInt, len(currentLocks)),
+		holdingRead:  make([]bool, numberOfLocks),
 	}
 
 	// copy currentLocks into d.holding set
@@ -63,6 +86,9 @@ func newDependency(lock mutexInt, currentLocks []mutexInt,
 		d.holdingSet = append(d.holdingSet, currentLocks[i])
 	}
 
+	// copy the reader status of the held locks
+	copy(d.holdingRead, currentRead)
+
 	return d
 }
 
@@ -83,3 +109,29 @@ func (d *dependency) update(lock mutexInt, hs *[]mutexInt, numberOfLocks int) {
 	// set new holdingCount
 	d.holdingCount = numberOfLocks
 }
+
+// renew the time of the last acquisition of an existing dependency. The
+// dependency can already be read by an analysis, the time and the number of
+// acquisitions are therefore changed atomically
+//  Args:
+//   now (time.Duration): time of the acquisition
+//  Returns:
+//   nil
+func (d *dependency) observe(now time.Duration) {
+	atomic.StoreInt64(&d.last, int64(now))
+	atomic.AddInt64(&d.count, 1)
+}
+
+// get the time of the last acquisition which created the dependency
+//  Returns:
+//   (time.Duration): time of the last acquisition
+func (d *dependency) lastSeen() time.Duration {
+	return time.Duration(atomic.LoadInt64(&d.last))
+}
+
+// get the number of acquisitions which created the dependency
+//  Returns:
+//   (int): number of acquisitions
+func (d *dependency) observed() int {
+	return int(atomic.LoadInt64(&d.count))
+}
@@ -0,0 +1,103 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+detectionRun.go
+Implementation of the serialization of the comprehensive detection. The
+detection can be started at the same time from different routines, e.g. by
+a signal handler and at the end of main, or by a deadlock found by the
+periodical detection while the program terminates. The detection uses
+state of the detector, like the progress and the collected reports, which
+must not be shared by two runs. Only one detection therefore runs at the
+same time. A call, which is made while a detection is running, does not start
+a second run, which would report the same cycles again, but waits for the
+running detection and returns its summary.
+*/
+
+import "github.com/petermattis/goid"
+
+// running comprehensive detection
+type detectionRun struct {
+	// id of the goroutine, which runs the detection
+	routine int64
+	// closed after the detection has finished
+	done chan struct{}
+	// summary of the detection, set before done is closed
+	summary Summary
+}
+
+// start a new run of the comprehensive detection or join the running one
+//  Returns:
+//   (*detectionRun): the running detection or the new run
+//   (bool): true if a detection was already running, false if the caller
+//    must run the detection and finish the run afterwards
+func (d *Detector) joinDetectionRun() (*detectionRun, bool) {
+	d.detectionRunLock.Lock()
+	defer d.detectionRunLock.Unlock()
+
+	if d.detectionRun != nil {
+		return d.detectionRun, true
+	}
+	d.detectionRun = &detectionRun{
+		routine: goid.Get(),
+		done:    make(chan struct{}),
+	}
+	return d.detectionRun, false
+}
+
+// finish a run of the comprehensive detection and release the routines,
+// which wait for it
+//  Args:
+//   run (*detectionRun): the finished run
+//  Returns:
+//   nil
+func (d *Detector) finishDetectionRun(run *detectionRun) {
+	d.detectionRunLock.Lock()
+	d.detectionRun = nil
+	d.detectionRunLock.Unlock()
+	close(run.done)
+}
+
+// wait until a running detection has finished. A detection, which is
+// started again by the routine that runs it, e.g. from a report sink,
+// returns immediately
+//  Args:
+//   cancel (<-chan struct{}): closed to stop waiting, nil if the wait can
+//    not be canceled
+//  Returns:
+//   (Summary): summary of the detection, the zero value if the wait was
+//    canceled or the detection is started again by its own routine
+func (run *detectionRun) wait(cancel <-chan struct{}) Summary {
+	if run.routine == goid.Get() {
+		return Summary{}
+	}
+
+	select {
+	case <-run.done:
+		return run.summary
+	case <-cancel:
+		return Summary{}
+	}
+}
@@ -26,20 +26,15 @@ Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
 /*
 detector.go
 This file contains all the functionality to detect circles in the lock-trees
-and therefor actual or potential deadlocks. It implements the periodical
-detection during the runtime of the program as well as the comprehensive
-detection after the program has finished.
-The periodical detection searches for actual deadlocks and can stop the
-program if it is in a deadlock situation.
+and therefor potential deadlocks. It implements the comprehensive detection
+after the program has finished. Actual deadlocks are found during the runtime
+of the program by the periodical detection with the wait-for graph (see
+waitFor.go).
 The comprehensive detection should run as soon as the actual program has finished.
 It is based on iGoodLock and reports potential deadlocks in the code.
 */
 
-import (
-	"fmt"
-	"os"
-	"runtime"
-)
+import "fmt"
 
 // ================ Comprehensive Detection ================
 
@@ -52,34 +47,133 @@ import (
 // it as a defer statement at the beginning of the main function of the
 // program.
 //  Returns:
-//   nil
-func FindPotentialDeadlocks() {
+//   (Summary): summary of the detection
+func FindPotentialDeadlocks() Summary {
+	return defaultDetector.FindPotentialDeadlocks()
+}
+
+// FindPotentialDeadlocks runs the comprehensive detection for the locks of
+// the detector (see FindPotentialDeadlocks)
+//  Returns:
+//   (Summary): summary of the detection
+func (d *Detector) FindPotentialDeadlocks() Summary {
+	return d.findPotentialDeadlocks(nil)
+}
+
+// run the comprehensive detection. Only one detection runs at the same
+// time, a call during a running detection waits for it and returns its
+// summary
+//  Args:
+//   cancel (<-chan struct{}): closed to cut the detection short, nil if
+//    the detection can not be canceled
+//  Returns:
+//   (Summary): summary of the detection, the zero value if the
+//    comprehensive detection is disabled
+func (d *Detector) findPotentialDeadlocks(cancel <-chan struct{}) Summary {
 	// check if comprehensive detection is disabled, and if do abort deadlock
 	//detection
-	if !opts.comprehensiveDetection {
-		return
+	if !d.opts.ComprehensiveDetection {
+		return Summary{}
+	}
+
+	run, running := d.joinDetectionRun()
+	if running {
+		return run.wait(cancel)
 	}
+	defer d.finishDetectionRun(run)
 
-	// only run detector if at least two routines were running during the
+	run.summary = d.runDetection(cancel)
+	return run.summary
+}
+
+// run the phases of the comprehensive detection
+//  Args:
+//   cancel (<-chan struct{}): closed to cut the detection short, nil if
+//    the detection can not be canceled
+//  Returns:
+//   (Summary): summary of the detection
+func (d *Detector) runDetection(cancel <-chan struct{}) (summary Summary) {
+	// all phases analyse the same state of the lock trees, while the
+	// program can still acquire locks
+	d.snapshot = d.takeSnapshot()
+
+	// track the progress and the limits of the detection. If a limit cut
+	// the search short, the result is reported as incomplete
+	d.progress = d.newProgressTracker(cancel)
+	d.startSummary()
+	d.startReportOrder()
+	defer func() {
+		d.finishReportOrder()
+		truncation := d.progress.truncation()
+		if truncation != nil {
+			d.reportTruncation(*truncation)
+		}
+		reports := d.reportSummary()
+		d.progress.finish()
+		summary = d.newSummary(d.progress, reports, truncation)
+		d.progress = nil
+		d.writeSummaryFile(summary)
+		d.reportMetrics(summary)
+		d.finishBaseline()
+		d.snapshot = nil
+	}()
+
+	// search for cycles in the lock order of the init functions. The init
+	// functions run in one routine, the cycles can therefore not be found by
+	// the normal detection
+	d.progress.phase("init order")
+	d.detectInitOrder()
+
+	// only run detector if enough routines were running during the
 	// execution of the program
-	if numberRoutines > 1 {
-		// abort check if the lock trees contain less than 2 unique dependencies
-		if !isNumberDependenciesGreaterEqualTwo() {
+	if d.snapshot.routines() >= d.opts.MinRoutines {
+		// search for upgrades of the same rw-lock in different routines
+		d.progress.phase("upgrades")
+		d.detectConcurrentUpgrades()
+
+		// search for writers, which can be queued between the acquisitions
+		// of recursive reader locks
+		if d.opts.WriterQueuingDetection {
+			d.progress.phase("recursive reader locks")
+			d.detectRecursiveRLocks()
+		}
+
+		// abort check if the lock trees contain not enough unique dependencies
+		if !d.hasUniqueDependencies(d.opts.MinDependencies) {
 			return
 		}
 
 		// start the detection of potential deadlocks
-		detect()
+		d.progress.phase("cycles")
+		d.detect()
+		d.checkReference()
 	}
+	return
 }
 
-// isNumberDependenciesGreaterEqualTwo counts the number of unique dependencies in
-// all and checks if it is greater or equal two lock trees.
+// hasUniqueDependencies counts the number of unique dependencies in all
+// lock trees and checks if it is greater or equal than min.
 // It is not necessary to run comprehensive detection if less then
 // two unique dependencies exists.
+//  Args:
+//   min (int): minimum number of unique dependencies
+//  Returns:
+//   (bool) : true, if number of unique dependencies is greater or equal than min,
+//    false otherwise
+func (d *Detector) hasUniqueDependencies(min int) bool {
+	if min <= 0 {
+		return true
+	}
+	return d.countUniqueDependencies(min) >= min
+}
+
+// count the unique dependencies in all lock trees
+//  Args:
+//   limit (int): number of unique dependencies, after which the counting
+//    is stopped, 0 to count all dependencies
 //  Returns:
-//   (bool) : true, if number of unique dependencies is greater or equal than 2,false otherwise
-func isNumberDependenciesGreaterEqualTwo() bool {
+//   (int): number of unique dependencies, at most limit if limit is positive
+func (d *Detector) countUniqueDependencies(limit int) int {
 	// number of already found unique dependencies
 	depCount := 0
 
@@ -90,12 +184,9 @@ func isNumberDependenciesGreaterEqualTwo() bool {
 	dependencyMap := make(map[string]struct{})
 
 	// parse all routines
-	for i := 0; i < numberRoutines; i++ {
-		current := routines[i]
-
-		// parse routine i
-		for j := 0; j < current.depCount; j++ {
-			dep := current.dependencies[j]
+	for _, deps := range d.snapshot.dependencies {
+		// parse the routine
+		for _, dep := range deps {
 
 			// get the dependency string and store it in dependencySting
 			getDependencyString(&dependencyString, dep)
@@ -107,15 +198,14 @@ func isNumberDependenciesGreaterEqualTwo() bool {
 				depCount++
 			}
 
-			// if more than two unique dep have been found return true
-			if depCount == 2 {
-				return true
+			// stop if enough unique dep have been found
+			if limit > 0 && depCount >= limit {
+				return depCount
 			}
 		}
 	}
 
-	// return false if depCount never reached 2
-	return false
+	return depCount
 }
 
 // getDependencyString calculates the dependency string for a given
@@ -139,7 +229,7 @@ func getDependencyString(str *string, dep *dependency) {
 // detect runs the detection for loops in the lock trees
 //  Returns:
 //   nil
-func detect() {
+func (d *Detector) detect() {
 	// visiting gets set to index of the routine on which the search for circles is started
 	var visiting int
 
@@ -153,18 +243,33 @@ func detect() {
 	// of the search.
 	// They can also be temporarily ignored, if a dependency of this routine
 	// is already in the path which is currently explored
-	isTraversed := make([]bool, numberRoutines)
+	isTraversed := make([]bool, d.snapshot.routines())
+
+	// remove the dependencies, which can not be part of a cycle
+	var pruned int
+	d.searchDependencies, pruned = d.pruneDependencies()
+	d.progress.pruned(pruned)
+	defer func() {
+		d.searchDependencies = nil
+	}()
+
+	if d.opts.CycleSearch == CycleSearchJohnson {
+		d.detectJohnson()
+		return
+	}
 
 	// traverse all routines as starting routine for the loop search
-	for i := 0; i < numberRoutines; i++ {
-		routine := routines[i]
-
+	for i := 0; i < d.snapshot.routines(); i++ {
 		visiting = i
 
 		// traverse all dependencies of the given routine as starting routine
 		// for potential paths
-		for j := 0; j < routine.depCount; j++ {
-			dep := routine.dependencies[j]
+		for _, dep := range d.searchDependencies[i] {
+			// abort the search if the time limit was reached
+			if d.progress.stopped() {
+				return
+			}
+
 			isTraversed[i] = true
 
 			// push the dependency on the stack as first element of the currently
@@ -172,11 +277,12 @@ func detect() {
 			stack.push(dep, i)
 
 			// start the depth-first search to find potential circular paths
-			dfs(&stack, visiting, &isTraversed)
+			d.dfs(&stack, visiting, &isTraversed)
 
 			// remove dep from the stack
 			stack.pop()
 		}
+		d.progress.routineDone()
 	}
 }
 
@@ -191,36 +297,52 @@ func detect() {
 //    (either as starting routine or as a routine which already has a dep in the current path)
 //  Returns:
 //   nil
-func dfs(stack *depStack, visiting int, isTraversed *([]bool)) {
+func (d *Detector) dfs(stack *depStack, visiting int, isTraversed *([]bool)) {
 	// Traverse through all routines to find the potential next step in the path.
 	// Routines with index <= visiting have already been used as starting routine
 	// and therefore don't have to been considered again.
-	for i := visiting + 1; i < numberRoutines; i++ {
-		routine := routines[i]
-
-		// continue if the routine has already been traversed
+	for i := visiting + 1; i < d.snapshot.routines(); i++ {
+		// continue if the routine has already been traversed. The routine
+		// has a dependency in the path, its other dependencies are ordered
+		// with it by the program order
 		if (*isTraversed)[i] {
+			d.progress.prune(pruneHappensBefore, len(d.searchDependencies[i]))
 			continue
 		}
 
 		// go through all dependencies of the current routine
-		for j := 0; j < routine.depCount; j++ {
-			dep := routine.dependencies[j]
+		for _, dep := range d.searchDependencies[i] {
 			// check if adding dep to the stack would still be a valid path
-			if isChain(stack, dep, i) {
+			rule := d.chainRule(stack, dep, i, false)
+			d.progress.prune(rule, 1)
+			if rule == pruneNone {
+				// abort the search if the time limit was reached
+				if !d.progress.explore(stack.size + 1) {
+					return
+				}
+
 				// check if adding dep to the stack would lead to a cycle
-				if isCycleChain(stack, dep, i) {
-					// report the found potential deadlock
-					stack.push(dep, j)
-					reportDeadlock(stack)
+				rule := d.cycleChainRule(stack, dep, i, false)
+				d.progress.prune(rule, 1)
+				if rule == pruneNone {
+					// report the found potential deadlock, if it does not contain
+					// a shorter cycle, which is reported by itself
+					stack.push(dep, i)
+					if !d.hasChord(stack, false) {
+						d.recordFoundCycle(stack)
+						d.reportDeadlock(stack)
+					} else {
+						d.progress.prune(pruneDedup, 1)
+					}
 					stack.pop()
-				} else { // the path is not a cycle yet
-					// add dep to the current path
+				} else if d.progress.extend(stack.size + 1) {
+					// the path is not a cycle yet and the depth limit allows
+					// to extend it further. Add dep to the current path
 					stack.push(dep, i)
 					(*isTraversed)[i] = true
 
 					// call dfs recursively to traverse the path further
-					dfs(stack, visiting, isTraversed)
+					d.dfs(stack, visiting, isTraversed)
 
 					// dep did not lead to a cycle in the lock trees.
 					// It is removed to explore different paths
@@ -232,185 +354,68 @@ func dfs(stack *depStack, visiting int, isTraversed *([]bool)) {
 	}
 }
 
-// ================ Periodical Detection ================
-
-// periodicalDetection is the main function to start the periodical detection.
-// It is called periodically to detect if the program is in a local deadlock
-// state i.e. a state in which only a subset of the running routines are in
-// a deadlock position.
-//  The program will be terminated if such a situation occurs and the comprehensive
-//  detection will automatically be started.
-//  If the program is in a total deadlock, i.e. no routine is running anymore,
-//  it is normally automatically terminated my the go-runtime deadlock detection.
-//  In this case the comprehensive detection can not be started.
-// To detect such local deadlocks, the detector uses the dependency of each
-// routine, which was last added to the routine and searches for circles in
-// this set of dependencies.
-//  Args:
-//   lastHolding (*[]mutexInt): list of the dependencies which were considered
-//    in the last run
+// detectConcurrentUpgrades searches for upgrades of the same rw-lock in
+// different routines. If two routines hold the reader lock at the same time
+// and both try to upgrade it, each of them waits for the other to release
+// the reader lock. Those upgrades can only run concurrently, if they
+// are not protected by a common gate lock.
 //  Returns:
 //   nil
-func periodicalDetection(lastHolding *[]mutexInt) {
-	// only check if at least two routines are currently running
-	if runtime.NumGoroutine() < 2 {
-		return
-	}
-
-	// A stack is used to represent the currently explored path in the lock trees.
-	// A dependency is added to the path by pushing it on top of the stack.
-
-	// the detection is only run if the number of routines which hold at least two
-	// locks is at least 2 and the situation has changed since the late periodical check
-	nrThreadsHoldingLocks := 0
-	sthNew := false
-
-	// traverse all routines
-	for index, r := range routines {
-		// check if the routine holds at least two lock and the last added dependency
-		// has changed since the last check
-		holds := r.holdingCount - 1
-		if holds >= 0 && (*lastHolding)[index] != r.holdingSet[holds] {
-			(*lastHolding)[index] = r.holdingSet[holds]
-			sthNew = true
-			if holds > 0 {
-				nrThreadsHoldingLocks++
+func (d *Detector) detectConcurrentUpgrades() {
+	// every lock is only reported once
+	reported := make(map[mutexInt]struct{})
+
+	trees := d.snapshot.dependencies
+	for i := range trees {
+		for _, dep := range trees[i] {
+			if !dep.upgrade {
+				continue
+			}
+			if _, ok := reported[dep.mu]; ok {
+				continue
 			}
-		} else if holds < 0 && (*lastHolding)[index] != nil {
-			(*lastHolding)[index] = nil
-			sthNew = true
-		}
-	}
-
-	// abort the detection if nothing has changed or not enough routines hold locks
-	if !sthNew || nrThreadsHoldingLocks <= 1 {
-		return
-	}
-
-	// run the detection
-	detectionPeriodical(lastHolding)
-}
-
-// detectPeriodical starts the search for local deadlocks.
-// It uses depth-first search to search for cyclic chains in the set of
-// dependencies which contain the dependencies which were last added to each
-// routine
-// 	Args:
-//   lastHolding (*[]mutexInt): list with dependencies
-//  Returns:
-//   nil
-func detectionPeriodical(lastHolding *[]mutexInt) {
-	// A stack is used to represent the currently explored path in the lock trees.
-	// A dependency is added to the path by pushing it on top of the stack.
-	stack := newDepStack()
-
-	// every dependency can only be used once in the path
-	isTraversed := make([]bool, opts.maxRoutines)
-
-	// traverse all routines as starting routine
-	for index, r := range routines {
-		// routines with an index >= routinesIndex have not been used in the program
-		if index >= numberRoutines {
-			break
-		}
 
-		// continue if the routine has not acquired a dependency
-		if r.curDep == nil {
-			continue
+			// search for an upgrade of the same lock in another routine
+		search:
+			for k := i + 1; k < len(trees); k++ {
+				for _, other := range trees[k] {
+					if !other.upgrade || other.mu != dep.mu {
+						continue
+					}
+					if haveGateLock(dep, other) {
+						d.progress.prune(pruneGateLock, 1)
+						continue
+					}
+					reported[dep.mu] = struct{}{}
+					d.reportPotentialDeadlockUpgrade(dep.mu)
+					break search
+				}
+			}
 		}
-
-		isTraversed[index] = true
-
-		// add the dependency as first dependency of the path to the stack and
-		// start the recursive search for a cyclic path
-		stack.push(r.curDep, index)
-		dfsPeriodical(&stack, index, isTraversed, lastHolding)
-
-		// if no cycle is found with this dependency it is removed from the path
-		stack.pop()
-		r.curDep = nil
 	}
 }
 
-// dfsPeriodical runs the recursive depth-first search.
-// Only paths which build a valid chain are explored.
-// After a new dependency is added to the currently explored path, it is checked,
-// if the path forms a circle.
+// haveGateLock checks if the holding sets of two dependencies contain the
+// same lock, which was not acquired as a reader lock in both of them.
+// Such a lock works as a gate lock and prevents the two dependencies
+// from being created concurrently.
 //  Args:
-//   stack (*depStack): stack witch represent the currently explored path
-//   visiting int: index of the routine of the first element in the currently explored path
-//   isTraversed (*([]bool)): list which stores which routines have already been traversed
-//    (either as starting routine or as a routine which already has a dep in the current path)
-//   lastHolding (*[]mutexInt): list with dependencies
+//   dep1 (*dependency): first dependency
+//   dep2 (*dependency): second dependency
 //  Returns:
-//   nil
-func dfsPeriodical(stack *depStack, visiting int, isTraversed []bool,
-	lastHolding *[]mutexInt) {
-	// Traverse through all routines to find the potential next step in the path.
-	// Routines with index <= visiting have already been used as starting routine
-	// and therefore don't have to been considered again.
-	for i := visiting + 1; i < numberRoutines; i++ {
-		r := routines[i]
-
-		// continue if the routine has no current dependency or has already be traversed
-		if r.curDep == nil || isTraversed[i] {
-			continue
-		}
-
-		dep := r.curDep
-
-		// check if adding dep to the current path would lead to a valid dependency
-		// chain
-		if !isChain(stack, dep, i) {
-			continue
-		}
-
-		// check if adding dep to the curring path would lead to a cyclic dependency
-		// chain. This would indicate a deadlock.
-		if isCycleChain(stack, dep, i) {
-			stack.push(dep, i)
-
-			// check if the last added dependency in on of the routines in the path
-			// has changed since the beginning of the detection. In this case, the
-			// program will assume it was a false alarm and will not terminate the
-			// program
-			sthNew := false
-
-			// traverse alle routines in the current dependency chain
-			for cl := stack.stack.next; cl != nil; cl = cl.next {
-				routineInChain := routines[cl.index]
-
-				// check if the last added dependency has changed
-				holds := routineInChain.holdingCount - 1
-				if (holds >= 0 &&
-					(*lastHolding)[cl.index] != routineInChain.holdingSet[holds]) ||
-					(holds < 0 && (*lastHolding)[cl.index] != nil) {
-					sthNew = true
-					break
-				}
-			}
-
-			// if nothing has changed the program assumes a deadlock.
-			// Therefore it reports the deadlock, starts the comprehensive detection
-			// to search for other possible deadlocks and terminates the program.
-			if !sthNew {
-				reportDeadlockPeriodical()
-				FindPotentialDeadlocks()
-				os.Exit(2)
+//   (bool): true if the dependencies have a gate lock, false otherwise
+func haveGateLock(dep1 *dependency, dep2 *dependency) bool {
+	for i := 0; i < dep1.holdingCount; i++ {
+		for j := 0; j < dep2.holdingCount; j++ {
+			lock1 := dep1.holdingSet[i]
+			lock2 := dep2.holdingSet[j]
+			if mutexHaveEqualLock(lock1, lock2) &&
+				!(dep1.holdingRead[i] && dep2.holdingRead[j]) {
+				return true
 			}
-			stack.pop()
-		} else {
-			// if the chain is not a cycle, the dependency is added to the current
-			// path and the search is continued recursively
-			isTraversed[numberRoutines] = true
-			stack.push(dep, numberRoutines)
-			dfsPeriodical(stack, visiting, isTraversed, lastHolding)
-
-			// if no cycle has been found with dep, it is removed from the path
-			stack.pop()
-			isTraversed[numberRoutines] = false
 		}
 	}
+	return false
 }
 
 // ================ Checks for chains and Cycles ================
@@ -428,30 +433,39 @@ func dfsPeriodical(stack *depStack, visiting int, isTraversed []bool,
 //   dep (*dependency): dependency for which it should be checked if it can be
 //    added to the path
 //   routineIndex (int): index of the routine the dependency is from
+//   pending (bool): if true, only writers which are currently waiting are
+//    considered as queued writers (see isLink)
 //  Returns:
 //   (bool): true if dep can be added to the current path, false otherwise
-func isChain(stack *depStack, dep *dependency, routineIndex int) bool {
+func (d *Detector) isChain(stack *depStack, dep *dependency, routineIndex int, pending bool) bool {
+	return d.chainRule(stack, dep, routineIndex, pending) == pruneNone
+}
+
+// chainRule checks if adding dep to the current path is still a valid path
+// (see isChain) and returns the rule, which prevents it
+//  Args:
+//   stack (*depStack): stack representing the current path
+//   dep (*dependency): dependency for which it should be checked if it can be
+//    added to the path
+//   routineIndex (int): index of the routine the dependency is from
+//   pending (bool): if true, only writers which are currently waiting are
+//    considered as queued writers (see isLink)
+//  Returns:
+//   (pruneRule): pruneNone if dep can be added to the current path,
+//    otherwise the rule which prevents it
+func (d *Detector) chainRule(stack *depStack, dep *dependency, routineIndex int,
+	pending bool) pruneRule {
 	// the mutex of the depEntry at the top of the stack mut be in the
 	// holding set of dep
-	found := false
-	for i := 0; i < dep.holdingCount; i++ {
-		mutexInHs := dep.holdingSet[i]
-		if mutexHaveEqualLock(mutexInHs, stack.top.depEntry.mu) {
-			// if mutexInHs is read, the mutex at the top of the stack can not also be read
-			if !(mutexInHs.getRLock(routineIndex) && stack.top.depEntry.mu.getRLock(stack.top.index)) {
-				found = true
-				break
-			}
-		}
-	}
-	if !found {
-		return false
+	if rule := d.linkRule(stack.top.depEntry, stack.top.index, dep, routineIndex,
+		pending); rule != pruneNone {
+		return rule
 	}
 
 	for c := stack.stack.next; c != nil; c = c.next {
 		// no two dependencies in the stack can be equal
 		if c.depEntry == dep {
-			return false
+			return pruneNoChain
 		}
 
 		// If two holding sets contain the same mutex they both have to be rLock
@@ -461,15 +475,15 @@ func isChain(stack *depStack, dep *dependency, routineIndex int) bool {
 				lockInDepHs := dep.holdingSet[i]
 				lockInCHoldingSet := c.depEntry.holdingSet[j]
 				if mutexHaveEqualLock(lockInDepHs, lockInCHoldingSet) {
-					if !(lockInCHoldingSet.getRLock(c.index) && lockInDepHs.getRLock(routineIndex)) {
-						return false
+					if !(c.depEntry.holdingRead[j] && dep.holdingRead[i]) {
+						return pruneGateLock
 					}
 				}
 			}
 		}
 	}
 
-	return true
+	return pruneNone
 }
 
 // isCycleCain checks if adding a dependency dep to the current path represented
@@ -485,25 +499,180 @@ func isChain(stack *depStack, dep *dependency, routineIndex int) bool {
 //  dep (*dependency): dependency for which it should be checked if adding dep
 //   to the path would lead to a cyclic path
 //  routineIndex (int): index of the routine from which dep originated
+//  pending (bool): if true, only writers which are currently waiting are
+//   considered as queued writers (see isLink)
 // Returns:
 //  (bool): true if dep can be added to the current path to create a valid cyclic
 //   chain, false if the path is no cycle, or it contains RW-lock with which
 //   the cycle does not indicate a deadlock
-func isCycleChain(dStack *depStack, dep *dependency, routineIndex int) bool {
+func (d *Detector) isCycleChain(dStack *depStack, dep *dependency, routineIndex int,
+	pending bool) bool {
+	return d.cycleChainRule(dStack, dep, routineIndex, pending) == pruneNone
+}
+
+// cycleChainRule checks if adding dep to the current path would lead to a
+// cyclic chain (see isCycleChain) and returns the rule, which prevents it
+// Args:
+//  stack (*depStack): stack representing the current path
+//  dep (*dependency): dependency for which it should be checked if adding dep
+//   to the path would lead to a cyclic path
+//  routineIndex (int): index of the routine from which dep originated
+//  pending (bool): if true, only writers which are currently waiting are
+//   considered as queued writers (see isLink)
+// Returns:
+//  (pruneRule): pruneNone if adding dep creates a valid cyclic chain,
+//   pruneNoChain if the path is no cycle, otherwise the rule which prevents
+//   the cycle
+func (d *Detector) cycleChainRule(dStack *depStack, dep *dependency, routineIndex int,
+	pending bool) pruneRule {
 	// the mutex dep must be in the holding set of the depEntry at the bottom of
 	// the stack
-	found := false
-	for i := 0; i < dStack.stack.next.depEntry.holdingCount; i++ {
-		mutexInHs := dStack.stack.next.depEntry.holdingSet[i]
-		if mutexHaveEqualLock(mutexInHs, dep.mu) {
-			// if mutexInHs is read, the mutex at the top of the stack can not also be read
-			if !(mutexInHs.getRLock(dStack.stack.index) && dep.mu.getRLock(routineIndex)) {
-				found = true
-				break
+	return d.linkRule(dep, routineIndex, dStack.stack.next.depEntry,
+		dStack.stack.next.index, pending)
+}
+
+// hasChord checks if a cyclic path contains a link between two dependencies,
+// which are not adjacent in the path. Such a chord splits the cycle, so that
+// the dependencies between the two ends of the chord together with the
+// chord build a shorter cycle. The shorter cycle is a valid chain, because
+// every part of a valid chain is valid, and is therefore found and reported
+// by the search itself.
+//  Args:
+//   stack (*depStack): stack representing the cycle
+//   pending (bool): if true, only writers which are currently waiting are
+//    considered as queued writers (see isLink)
+//  Returns:
+//   (bool): true if the cycle contains a chord, false if it is minimal
+func (d *Detector) hasChord(stack *depStack, pending bool) bool {
+	for prev := stack.stack.next; prev != nil; prev = prev.next {
+		// the successor of prev in the cycle is linked to prev by the path
+		succ := prev.next
+		if succ == nil {
+			succ = stack.stack.next
+		}
+
+		for next := stack.stack.next; next != nil; next = next.next {
+			if next == prev || next == succ {
+				continue
+			}
+			if found, _ := d.isLink(prev.depEntry, prev.index, next.depEntry,
+				next.index, pending); found {
+				return true
 			}
 		}
 	}
-	return found
+	return false
+}
+
+// isLink checks if the lock of dependency prev is in the holding set of
+// dependency next, meaning the routine of prev can be forced to wait for the
+// routine of next.
+//  If both locks are reader locks, the routine of prev only has to wait, if a
+//  writer of the rw-lock is queued by another routine. Go's rw-locks prefer
+//  writers, so a queued writer blocks new readers, while itself waiting
+//  for the release of the reader lock held by the routine of next.
+// Args:
+//  prev (*dependency): dependency whose lock is acquired
+//  prevIndex (int): index of the routine of prev
+//  next (*dependency): dependency whose holding set is checked
+//  nextIndex (int): index of the routine of next
+//  pending (bool): if true, only writers which are currently waiting for the
+//   lock are considered, otherwise all routines, which have ever acquired the
+//   writer lock
+// Returns:
+//  (bool): true if the routine of prev can be forced to wait for the routine of next
+//  (mutexInt): rw-lock on which a writer must be queued to force the wait,
+//   nil if no queued writer is necessary
+func (d *Detector) isLink(prev *dependency, prevIndex int, next *dependency, nextIndex int,
+	pending bool) (bool, mutexInt) {
+	var queued mutexInt
+	for i := 0; i < next.holdingCount; i++ {
+		mutexInHs := next.holdingSet[i]
+		if !mutexHaveEqualLock(mutexInHs, prev.mu) {
+			continue
+		}
+
+		// if mutexInHs is read, the mutex of prev can not also be read
+		if !(next.holdingRead[i] && prev.read) {
+			return true, nil
+		}
+
+		// both are read, check if a writer of another routine can be queued.
+		// For rw-locks which allow recursive reader locks, writers are not
+		// queued between the reader locks of a recursive acquisition
+		if mutexInHs.allowsRecursiveRLock() && isRecursiveRLock(prev) {
+			continue
+		}
+		if d.opts.WriterQueuingDetection && queued == nil &&
+			mutexInHs.hasWriter(pending, prevIndex, nextIndex) {
+			queued = mutexInHs
+		}
+	}
+	return queued != nil, queued
+}
+
+// linkRule checks if the routine of prev can be forced to wait for the
+// routine of next (see isLink) and returns the rule, which prevents it
+// Args:
+//  prev (*dependency): dependency whose lock is acquired
+//  prevIndex (int): index of the routine of prev
+//  next (*dependency): dependency whose holding set is checked
+//  nextIndex (int): index of the routine of next
+//  pending (bool): if true, only waiting writers are considered (see isLink)
+// Returns:
+//  (pruneRule): pruneNone if the dependencies are linked, pruneRWLock if the
+//   lock of prev is only held as reader lock in next, pruneNoChain otherwise
+func (d *Detector) linkRule(prev *dependency, prevIndex int, next *dependency, nextIndex int,
+	pending bool) pruneRule {
+	if found, _ := d.isLink(prev, prevIndex, next, nextIndex, pending); found {
+		return pruneNone
+	}
+	for i := 0; i < next.holdingCount; i++ {
+		if mutexHaveEqualLock(next.holdingSet[i], prev.mu) {
+			return pruneRWLock
+		}
+	}
+	return pruneNoChain
+}
+
+// isRecursiveRLock checks if the lock of a dependency was acquired as a
+// reader lock, while the routine already held the reader lock
+//  Args:
+//   dep (*dependency): dependency to check
+//  Returns:
+//   (bool): true if the dependency is a recursive reader lock
+func isRecursiveRLock(dep *dependency) bool {
+	if !dep.read {
+		return false
+	}
+	for i := 0; i < dep.holdingCount; i++ {
+		if dep.holdingRead[i] && mutexHaveEqualLock(dep.holdingSet[i], dep.mu) {
+			return true
+		}
+	}
+	return false
+}
+
+// queuedWriterLocks returns the rw-locks in a cyclic path, on which a writer
+// must be queued, so that the cycle can lead to a deadlock
+//  Args:
+//   stack (*depStack): stack representing the cycle
+//  Returns:
+//   ([]mutexInt): rw-locks on which a writer must be queued
+func (d *Detector) queuedWriterLocks(stack *depStack) []mutexInt {
+	res := make([]mutexInt, 0)
+	for cl := stack.stack.next; cl != nil; cl = cl.next {
+		// the last dependency in the path is linked to the first dependency
+		next := cl.next
+		if next == nil {
+			next = stack.stack.next
+		}
+		if _, queued := d.isLink(cl.depEntry, cl.index, next.depEntry, next.index,
+			false); queued != nil {
+			res = append(res, queued)
+		}
+	}
+	return res
 }
 
 func mutexHaveEqualLock(m1, m2 mutexInt) bool {
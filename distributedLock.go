@@ -0,0 +1,180 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+distributedLock.go
+This file implements helpers to model locks outside of the program, e.g.
+Postgres advisory locks or Redis locks, as locks of the detector. The
+acquisitions and releases of these locks are registered by the program,
+after they were done. The locks are added to the lock trees like other
+locks, so that cycles between distributed locks and cycles between
+distributed locks and locks of the program are found by the detection.
+Acquisitions with a timeout can not block forever and are therefore
+registered like try-locks, which do not create dependencies on the locks
+held by the routine.
+*/
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// type to model a lock outside of the program
+type DistributedLock struct {
+	// lock, which represents the distributed lock in the lock trees
+	m *Mutex
+	// name of the lock
+	name string
+}
+
+// get the model of a distributed lock of the default detector. The same
+// name always returns the same lock
+//  Args:
+//   name (string): name of the lock, e.g. "orders:42"
+//  Returns:
+//   (*DistributedLock): the lock
+func NewDistributedLock(name string) *DistributedLock {
+	return newDistributedLock(defaultDetector, name, 2)
+}
+
+// get the model of a Postgres advisory lock of the default detector
+//  Args:
+//   key (int64): key of the advisory lock
+//  Returns:
+//   (*DistributedLock): the lock
+func AdvisoryLock(key int64) *DistributedLock {
+	return newDistributedLock(defaultDetector, fmt.Sprint("postgres advisory lock ", key), 2)
+}
+
+// get the model of a Redis lock of the default detector
+//  Args:
+//   key (string): key of the lock
+//  Returns:
+//   (*DistributedLock): the lock
+func RedisLock(key string) *DistributedLock {
+	return newDistributedLock(defaultDetector, "redis lock "+key, 2)
+}
+
+// get the model of a lock of a file, which is shared by multiple processes,
+// e.g. with flock, of the default detector
+//  Args:
+//   path (string): path of the file
+//  Returns:
+//   (*DistributedLock): the lock
+func FileLock(path string) *DistributedLock {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return newDistributedLock(defaultDetector, "file lock "+path, 2)
+}
+
+// get the model of a distributed lock and create it, if it does not exist
+// yet. The creation of the lock is saved as the first call for its name
+//  Args:
+//   d (*Detector): detector the lock belongs to
+//   name (string): name of the lock
+//   skip (int): number of stack frames between the user code and the
+//    callerSite call
+//  Returns:
+//   (*DistributedLock): the lock
+func newDistributedLock(d *Detector, name string, skip int) *DistributedLock {
+	d.distributedLocksLock.Lock()
+	defer d.distributedLocksLock.Unlock()
+
+	if l, ok := d.distributedLocks[name]; ok {
+		return l
+	}
+
+	l := &DistributedLock{m: newLock(d, skip+1), name: name}
+	l.m.model = name
+	l.m.SetTag("resource", name)
+	d.distributedLocks[name] = l
+	return l
+}
+
+// getter for the name of the lock
+//  Returns:
+//   (string): name of the lock
+func (l *DistributedLock) Name() string {
+	return l.name
+}
+
+// SetTag sets the tag key of the lock to value
+//  Args:
+//   key (string): key of the tag
+//   value (string): value of the tag
+//  Returns:
+//   nil
+func (l *DistributedLock) SetTag(key string, value string) {
+	l.m.SetTag(key, value)
+}
+
+// Acquired registers that the routine has acquired the lock without a
+// timeout
+//  Returns:
+//   nil
+func (l *DistributedLock) Acquired() {
+	lockInt(l.m, false, false)
+}
+
+// AcquiredWithTimeout registers that the routine has acquired the lock
+// with a timeout. Because the acquisition could not have blocked forever,
+// it does not create dependencies on the locks held by the routine
+//  Returns:
+//   nil
+func (l *DistributedLock) AcquiredWithTimeout() {
+	tryLockInt(l.m, false)
+}
+
+// Released registers that the routine has released the lock
+//  Returns:
+//   nil
+func (l *DistributedLock) Released() {
+	if l.m.getDetector().opts.Activated {
+		unlockInt(l.m)
+	}
+}
+
+// check if a lock only models a lock outside of the program, whose
+// underlying lock must not be acquired
+//  Args:
+//   m (mutexInt): lock
+//  Returns:
+//   (bool): true if m models a distributed lock
+func isModeled(m mutexInt) bool {
+	return modelName(m) != ""
+}
+
+// get the name of the distributed lock, which is modeled by a lock
+//  Args:
+//   m (mutexInt): lock
+//  Returns:
+//   (string): name of the distributed lock, empty if m is not a model
+func modelName(m mutexInt) string {
+	if mu, ok := m.(*Mutex); ok {
+		return mu.model
+	}
+	return ""
+}
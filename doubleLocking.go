@@ -0,0 +1,108 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+doubleLocking.go
+Implementation of the double locking policies of the locks. By default, a
+routine which locks a lock it already holds is reported as a deadlock and
+the program is terminated. Designs which intentionally take locks
+recursively can disable the check or downgrade it to a warning for
+specific locks.
+Recursive reader locks of a rw-mutex are not a double locking, but can still
+lead to a deadlock: if a writer of another routine starts to wait for the
+rw-mutex between the two acquisitions, the second acquisition waits for the
+writer, which itself waits for the release of the first acquisition. This
+is reported as a potential deadlock, if another routine uses the writer lock
+without a common gate lock (see recursiveRLock.go), unless recursive reader
+locks are allowed for the rw-mutex.
+*/
+
+// DoubleLockingPolicy describes how double locking of a lock is handled
+type DoubleLockingPolicy int
+
+const (
+	// DoubleLockingReport reports double locking as a deadlock and
+	// terminates the program, if CheckDoubleLocking is enabled
+	DoubleLockingReport DoubleLockingPolicy = iota
+	// DoubleLockingWarn reports double locking as a warning. The program is
+	// not terminated, the lock operation still blocks
+	DoubleLockingWarn
+	// DoubleLockingIgnore disables the check of double locking for the lock
+	DoubleLockingIgnore
+)
+
+// SetDoubleLockingPolicy sets how double locking of m is handled. It must be
+// called before m is used
+//  Args:
+//   policy (DoubleLockingPolicy): policy for m
+//  Returns:
+//   nil
+func (m *Mutex) SetDoubleLockingPolicy(policy DoubleLockingPolicy) {
+	m.doubleLocking = policy
+}
+
+// SetDoubleLockingPolicy sets how double locking of m is handled. It must be
+// called before m is used
+//  Args:
+//   policy (DoubleLockingPolicy): policy for m
+//  Returns:
+//   nil
+func (m *RWMutex) SetDoubleLockingPolicy(policy DoubleLockingPolicy) {
+	m.doubleLocking = policy
+}
+
+// AllowRecursiveRLock disables the report of recursive reader locks of m.
+// It must be called before m is used
+//  Returns:
+//   nil
+func (m *RWMutex) AllowRecursiveRLock() {
+	m.recursiveRLock = true
+}
+
+// getter for the double locking policy
+//  Returns:
+//   (DoubleLockingPolicy): policy of the lock
+func (m *Mutex) getDoubleLockingPolicy() DoubleLockingPolicy {
+	return m.doubleLocking
+}
+
+// getter for the double locking policy
+//  Returns:
+//   (DoubleLockingPolicy): policy of the lock
+func (m *RWMutex) getDoubleLockingPolicy() DoubleLockingPolicy {
+	return m.doubleLocking
+}
+
+// empty getter, needed for mutexInt
+func (m *Mutex) allowsRecursiveRLock() bool {
+	return false
+}
+
+// getter for recursiveRLock
+//  Returns:
+//   (bool): true if recursive reader locks of the lock are not reported
+func (m *RWMutex) allowsRecursiveRLock() bool {
+	return m.recursiveRLock
+}
@@ -0,0 +1,190 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+edgeExample.go
+This file implements the observed examples of the edges of a cycle. An edge
+of a cycle in the lock trees means, that a routine acquired a lock y while
+it held a lock x. With EdgeExamples, a routine saves the call stack of each
+acquisition while the lock is held. The first acquisition, which creates a
+dependency, saves the goroutine, the held acquisitions and its own call
+stack as example of the dependency. The report of a potential deadlock
+contains for each edge the example of the dependency, which created it, so
+that the execution path of the edge can be found and not only its sites.
+The call stacks are only saved as program counters and resolved, if the
+example is reported.
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// EdgeExample is an observed acquisition of a lock, while another lock was
+// held, which created an edge of a cycle
+type EdgeExample struct {
+	// goroutine, which acquired the locks
+	Goroutine Goroutine `json:"goroutine"`
+	// acquisition site of the held lock
+	Held Site `json:"held"`
+	// time of the acquisition of the held lock since the start of the
+	// detector
+	HeldAt time.Duration `json:"held_at_ns"`
+	// call stack of the acquisition of the held lock
+	HeldStack string `json:"held_stack,omitempty"`
+	// acquisition site of the lock, which was acquired while the other lock
+	// was held
+	Acquired Site `json:"acquired"`
+	// time of the acquisition since the start of the detector
+	AcquiredAt time.Duration `json:"acquired_at_ns"`
+	// call stack of the acquisition
+	AcquiredStack string `json:"acquired_stack,omitempty"`
+}
+
+// observed example of the first acquisition, which created a dependency
+type edgeExample struct {
+	// index of the routine of the acquisition
+	routine int
+	// acquisitions of the locks in the holding set of the dependency
+	held []callerInfo
+	// call stacks of the acquisitions in held
+	heldStacks [][]uintptr
+	// call stack of the acquisition, which created the dependency
+	stack []uintptr
+}
+
+// get the program counters of the call stack of the current routine
+//  Returns:
+//   ([]uintptr): the program counters, nil in the embedded mode
+func stackPCs() []uintptr {
+	// call stacks are not resolved in the embedded mode
+	if embeddedMode {
+		return nil
+	}
+
+	pc := make([]uintptr, 32)
+	n := runtime.Callers(3, pc)
+	for n == len(pc) {
+		pc = make([]uintptr, 2*len(pc))
+		n = runtime.Callers(3, pc)
+	}
+	return pc[:n]
+}
+
+// save the time and the call stack of the acquisition of the lock at
+// position hc of the holding set, if edge examples are collected
+//  Args:
+//   hc (int): position of the lock in the holding set
+//   info (*callerInfo): caller info of the acquisition
+//  Returns:
+//   nil
+func (r *routine) saveHoldingStack(hc int, info *callerInfo) {
+	if r.holdingStacks == nil {
+		return
+	}
+	info.timestamp = time.Since(r.detector.start)
+	r.holdingStacks[hc] = stackPCs()
+}
+
+// create the example of a new dependency from the current acquisition
+//  Args:
+//   hc (int): number of locks in the holding set of the dependency
+//  Returns:
+//   (*edgeExample): the example, nil if edge examples are not collected
+func (r *routine) newEdgeExample(hc int) *edgeExample {
+	if r.holdingStacks == nil {
+		return nil
+	}
+	return &edgeExample{
+		routine:    r.index,
+		held:       append([]callerInfo{}, r.holdingCallers[:hc]...),
+		heldStacks: append([][]uintptr{}, r.holdingStacks[:hc]...),
+		stack:      stackPCs(),
+	}
+}
+
+// get the examples of the edges of a cycle. The edge between two
+// consecutive dependencies of the cycle is created by the second one,
+// which acquired its lock while holding the lock of the first one
+//  Args:
+//   deps ([]*dependency): dependencies of the cycle
+//  Returns:
+//   ([]EdgeExample): example of each edge, nil if the dependencies have no
+//    examples
+func (d *Detector) edgeExamples(deps []*dependency) []EdgeExample {
+	res := make([]EdgeExample, 0, len(deps))
+	for i, prev := range deps {
+		next := deps[(i+1)%len(deps)]
+		ex := next.example
+		if ex == nil {
+			return nil
+		}
+		g := d.goroutine(ex.routine)
+		for j := 0; j < next.holdingCount && j < len(ex.held); j++ {
+			if !mutexHaveEqualLock(next.holdingSet[j], prev.mu) {
+				continue
+			}
+			res = append(res, EdgeExample{
+				Goroutine:     g,
+				Held:          newSite(ex.held[j]),
+				HeldAt:        ex.held[j].timestamp,
+				HeldStack:     d.formatStack(g.ID, ex.heldStacks[j]),
+				Acquired:      newSite(next.caller),
+				AcquiredAt:    next.caller.timestamp,
+				AcquiredStack: d.formatStack(g.ID, ex.stack),
+			})
+			break
+		}
+	}
+	return res
+}
+
+// write the examples of the edges of a cycle
+//  Args:
+//   out (*bytes.Buffer): buffer to write to
+//   examples ([]EdgeExample): example of each edge
+//  Returns:
+//   nil
+func writeEdgeExamples(out *bytes.Buffer, examples []EdgeExample) {
+	if len(examples) == 0 {
+		return
+	}
+
+	fmt.Fprintf(out, purple, "Observed examples of the edges of the potential deadlock:\n\n")
+	for _, ex := range examples {
+		fmt.Fprintf(out, blue, fmt.Sprint(ex.Goroutine.sentence(), " acquired ",
+			ex.Acquired.File, ":", ex.Acquired.Line, " while holding ",
+			ex.Held.File, ":", ex.Held.Line))
+		fmt.Fprintf(out, "\n")
+		fmt.Fprintln(out, "Held lock acquired", ex.HeldAt.Round(time.Microsecond),
+			"after the start of the detector:")
+		fmt.Fprint(out, ex.HeldStack)
+		fmt.Fprintln(out, "Lock acquired", ex.AcquiredAt.Round(time.Microsecond),
+			"after the start of the detector:")
+		fmt.Fprint(out, ex.AcquiredStack)
+	}
+}
@@ -0,0 +1,107 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+errorTracker.go
+Implementation of an adapter, which converts reports into error events for
+error trackers like Sentry. The events of the same cycle get the same
+fingerprint, so that a tracker groups them into one issue, even if the cycle
+is found in different runs of the program.
+*/
+
+// ErrorEvent is a report converted into an event of an error tracker
+type ErrorEvent struct {
+	// short description of the event, e.g. "POTENTIAL DEADLOCK (Cycle ID: ...)"
+	Message string
+	// severity of the event: "error" for deadlocks, "warning" for potential
+	// deadlocks and "info" for warnings
+	Level string
+	// values to group events of the same cycle
+	Fingerprint []string
+	// involved sites, used as frames of the stack trace of the event
+	Frames []Site
+	// additional key-value information, e.g. the cycle ID
+	Tags map[string]string
+	// complete text of the report
+	Text string
+}
+
+// NewErrorEvent converts a report into an error event
+//  Args:
+//   r (Report): report to convert
+//  Returns:
+//   (ErrorEvent): the created error event
+func NewErrorEvent(r Report) ErrorEvent {
+	e := ErrorEvent{
+		Message: r.Title,
+		Level:   "info",
+		Frames:  r.Sites,
+		Tags: map[string]string{
+			"deadlock.type": r.Type.String(),
+		},
+		Text: r.Text,
+	}
+
+	switch r.Type {
+	case ReportDeadlock:
+		e.Level = "error"
+	case ReportPotentialDeadlock:
+		e.Level = "warning"
+	}
+
+	// reports of cycles are grouped by their ID, other reports by their title
+	// and the first involved site
+	if r.ID != "" {
+		e.Message += " (Cycle ID: " + r.ID + ")"
+		e.Tags["deadlock.cycle_id"] = r.ID
+		e.Fingerprint = []string{"deadlock", r.ID}
+	} else if len(r.Sites) != 0 {
+		e.Fingerprint = []string{"deadlock", r.Title,
+			normalizedSite(newInfo(r.Sites[0].File, 0, r.Sites[0].Function,
+				false, false, ""))}
+	} else {
+		e.Fingerprint = []string{"deadlock", r.Title}
+	}
+
+	// the tags of the involved locks
+	for key, value := range r.LockTags() {
+		e.Tags["lock."+key] = value
+	}
+
+	return e
+}
+
+// NewErrorTrackerSink creates a sink, which converts the reports into error
+// events and passes them to capture, e.g. a function which sends them to
+// an error tracker
+//  Args:
+//   capture (func(ErrorEvent)): function which receives the events
+//  Returns:
+//   (ReportSink): the created sink
+func NewErrorTrackerSink(capture func(ErrorEvent)) ReportSink {
+	return func(r Report) {
+		capture(NewErrorEvent(r))
+	}
+}
@@ -0,0 +1,123 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+escalation.go
+Implementation of the escalation after a confirmed deadlock. Before the
+program is terminated, the response escalates in stages, each after its
+own delay: the deadlock is reported, the callback of the escalation policy
+is invoked with the timeout of the shutdown hooks, the shutdown hooks
+are run, SIGQUIT is sent to the process, so
+that the runtime writes its own dump of all goroutines, and finally the
+program is terminated. The stages are only run by detectors, which
+terminate the program.
+*/
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// EscalationPolicy describes the stages, which are run after a deadlock
+// was reported and before the program is terminated
+type EscalationPolicy struct {
+	// function, which receives the report of the deadlock, nil if no
+	// function is invoked
+	Callback func(Report)
+	// time between the report and the invocation of Callback
+	CallbackDelay time.Duration
+	// if true, SIGQUIT is sent to the process after the shutdown hooks. The
+	// runtime then prints the stacks of all goroutines and exits, unless the
+	// program handles the signal
+	QuitSignal bool
+	// time between the shutdown hooks and SIGQUIT
+	QuitSignalDelay time.Duration
+	// time between the last stage and the termination of the program
+	ExitDelay time.Duration
+}
+
+// check if the delays of the policy are valid
+//  Returns:
+//   (error): nil if the policy is valid, an error describing the invalid
+//    delay otherwise
+func (p EscalationPolicy) validate() error {
+	delays := []struct {
+		name  string
+		delay time.Duration
+	}{
+		{"CallbackDelay", p.CallbackDelay},
+		{"QuitSignalDelay", p.QuitSignalDelay},
+		{"ExitDelay", p.ExitDelay},
+	}
+	for _, d := range delays {
+		if d.delay < 0 {
+			return fmt.Errorf("deadlock: Escalation.%s must not be negative, got %v",
+				d.name, d.delay)
+		}
+	}
+	return nil
+}
+
+// save the last reported deadlock for the callback of the escalation
+//  Args:
+//   r (Report): report of the deadlock
+//   text (string): text of the report with colors
+//  Returns:
+//   nil
+func (d *Detector) saveDeadlockReport(r Report, text string) {
+	r.Text = removeColors(r.Title + "\n\n" + text)
+	d.deadlockReportLock.Lock()
+	d.deadlockReport = r
+	d.deadlockReportLock.Unlock()
+}
+
+// run the stages of the escalation policy and terminate the program
+//  Returns:
+//   nil
+func (d *Detector) escalate() {
+	p := d.opts.Escalation
+
+	if p.Callback != nil {
+		time.Sleep(p.CallbackDelay)
+		d.deadlockReportLock.Lock()
+		r := d.deadlockReport
+		d.deadlockReportLock.Unlock()
+		d.runHooks("escalation callback", []func(){func() { p.Callback(r) }})
+	}
+
+	d.printQuietSummary()
+	d.runShutdownHooks()
+
+	if p.QuitSignal {
+		time.Sleep(p.QuitSignalDelay)
+		if err := sendQuitSignal(); err != nil {
+			fmt.Fprintln(os.Stderr, "deadlock: could not send SIGQUIT:", err)
+		}
+	}
+
+	time.Sleep(p.ExitDelay)
+	os.Exit(2)
+}
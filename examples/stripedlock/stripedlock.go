@@ -0,0 +1,130 @@
+package stripedlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: stripedlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+stripedlock.go
+This package contains an example of a lock type of another package, which
+registers its underlying locks with the detector (deadlock.LockerProvider).
+A striped lock protects many keys with a fixed number of locks (stripes).
+The stripe of a key is selected by the hash of the key. The stripes are
+locks of the sync package, the acquisitions and releases of the stripes are
+registered with the detector, so that two routines, which lock the stripes
+of two keys in different orders, are reported as a potential deadlock.
+The detector provides its own striped lock (deadlock.StripedMutex), this
+package only shows, how a lock type of another package is registered.
+*/
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	deadlock "github.com/ErikKassubek/Deadlock-Go"
+)
+
+// Mutex is a striped lock
+type Mutex struct {
+	// the stripes
+	stripes []sync.Mutex
+	// registration of the stripes with the detector
+	locks *deadlock.ProvidedLocks
+}
+
+// create a new striped lock, which is registered with the default detector
+//  Args:
+//   n (int): number of stripes, must be positive
+//  Returns:
+//   (*Mutex): the created lock
+func New(n int) *Mutex {
+	if n <= 0 {
+		panic("stripedlock: number of stripes must be positive")
+	}
+	m := &Mutex{stripes: make([]sync.Mutex, n)}
+	m.locks = deadlock.RegisterLockerProvider(m)
+	return m
+}
+
+// get the name of the lock type
+//  Returns:
+//   (string): name of the lock type
+func (m *Mutex) LockerName() string {
+	return "stripedlock.Mutex"
+}
+
+// get the name of a stripe
+//  Args:
+//   id (interface{}): index of the stripe
+//  Returns:
+//   (string): name of the stripe
+func (m *Mutex) LockName(id interface{}) string {
+	return fmt.Sprint("stripe ", id)
+}
+
+// get the stripe of a key
+//  Args:
+//   key (string): the key
+//  Returns:
+//   (int): index of the stripe
+func (m *Mutex) stripe(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(m.stripes)))
+}
+
+// Lock locks the stripe of a key
+//  Args:
+//   key (string): the key
+//  Returns:
+//   nil
+func (m *Mutex) Lock(key string) {
+	i := m.stripe(key)
+	m.stripes[i].Lock()
+	m.locks.Acquired(i)
+}
+
+// TryLock tries to lock the stripe of a key
+//  Args:
+//   key (string): the key
+//  Returns:
+//   (bool): true if locking was successful, false otherwise
+func (m *Mutex) TryLock(key string) bool {
+	i := m.stripe(key)
+	if !m.stripes[i].TryLock() {
+		return false
+	}
+	m.locks.TryAcquired(i)
+	return true
+}
+
+// Unlock unlocks the stripe of a key
+//  Args:
+//   key (string): the key
+//  Returns:
+//   nil
+func (m *Mutex) Unlock(key string) {
+	i := m.stripe(key)
+	m.locks.Released(i)
+	m.stripes[i].Unlock()
+}
@@ -0,0 +1,288 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+expectation.go
+Implementation of expectations about held locks, which are carried by a
+context. A layer, which calls into another layer, often relies on a locking
+contract, e.g. that a callback is not called while the lock of the caller
+is held, or that a function is only called with the lock of its data. The
+contract is attached to the context of the operation with Expect or
+ExpectHeld and checked with CheckExpectations, when the operation starts,
+against the locks held by the calling routine:
+
+	ctx = deadlock.Expect(ctx, m)
+	...
+	func (s *Store) Get(ctx context.Context, key string) {
+		deadlock.CheckExpectations(ctx)
+		...
+	}
+
+A violated expectation is reported as a warning with the site of the
+expectation and the site of the check. Each combination of both sites is
+only reported once. A routine, which blocks on a lock it must not hold,
+would deadlock with itself or with the routine, which relies on the
+contract, so the violation is reported even if no deadlock occurs in this
+run.
+*/
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// key of the expectations in a context
+type expectationsKey struct{}
+
+// expectation about a lock at the start of an operation
+type expectation struct {
+	// lock of the expectation
+	mu mutexInt
+	// true if the lock must be held, false if it must not be held
+	held bool
+	// site where the expectation was added to the context
+	site Site
+}
+
+// Expect returns a copy of ctx, which carries the expectation, that the
+// lock is not held by the routine, which starts an operation with the
+// context. The expectation is checked by CheckExpectations
+//  Args:
+//   ctx (context.Context): parent context
+//   l (sync.Locker): lock of the detector, which must not be held
+//  Returns:
+//   (context.Context): the context with the expectation
+func Expect(ctx context.Context, l sync.Locker) context.Context {
+	return expect(ctx, l, false)
+}
+
+// ExpectHeld returns a copy of ctx, which carries the expectation, that the
+// lock is held by the routine, which starts an operation with the context.
+// The expectation is checked by CheckExpectations
+//  Args:
+//   ctx (context.Context): parent context
+//   l (sync.Locker): lock of the detector, which must be held
+//  Returns:
+//   (context.Context): the context with the expectation
+func ExpectHeld(ctx context.Context, l sync.Locker) context.Context {
+	return expect(ctx, l, true)
+}
+
+// add an expectation to a context. The expectations of the parent context
+// are kept. The reader locker of a rw-lock is checked as the rw-lock. A
+// locker, which is not a lock of the detector, can not be checked, it is
+// reported as a warning and the context is returned unchanged
+//  Args:
+//   ctx (context.Context): parent context
+//   l (sync.Locker): lock of the expectation
+//   held (bool): true if the lock must be held, false if it must not be held
+//  Returns:
+//   (context.Context): the context with the expectation
+func expect(ctx context.Context, l sync.Locker, held bool) context.Context {
+	frame := userFrame()
+	site := Site{File: frame.File, Line: frame.Line, Function: frame.Function}
+
+	if r, ok := l.(*rlocker); ok {
+		l = (*RWMutex)(r)
+	}
+	m, ok := l.(mutexInt)
+	if !ok {
+		defaultDetector.reportUnsupportedExpectation(l, site)
+		return ctx
+	}
+
+	// a lock declared as a zero value may not have been used yet
+	m.lazyInit()
+	if !m.getDetector().opts.Activated {
+		return ctx
+	}
+
+	parent, _ := ctx.Value(expectationsKey{}).([]expectation)
+	expectations := make([]expectation, len(parent), len(parent)+1)
+	copy(expectations, parent)
+	expectations = append(expectations, expectation{
+		mu:   m,
+		held: held,
+		site: site,
+	})
+	return context.WithValue(ctx, expectationsKey{}, expectations)
+}
+
+// CheckExpectations checks the expectations of the context against the
+// locks held by the calling routine. It is called at the start of the
+// operation, which uses the context. Violated expectations are reported as
+// warnings
+//  Args:
+//   ctx (context.Context): context of the operation
+//  Returns:
+//   nil
+func CheckExpectations(ctx context.Context) {
+	expectations, _ := ctx.Value(expectationsKey{}).([]expectation)
+	if len(expectations) == 0 {
+		return
+	}
+
+	frame := userFrame()
+	site := Site{File: frame.File, Line: frame.Line, Function: frame.Function}
+	for _, e := range expectations {
+		e.mu.getDetector().checkExpectation(e, site)
+	}
+}
+
+// check an expectation against the locks held by the calling routine
+//  Args:
+//   e (expectation): the expectation
+//   site (Site): site of the check
+//  Returns:
+//   nil
+func (d *Detector) checkExpectation(e expectation, site Site) {
+	// the held locks are only known, if the routines are registered
+	if !d.opts.Activated || e.mu.detectionDisabled() || d.inReport() ||
+		(!d.opts.PeriodicDetection && !d.opts.ComprehensiveDetection &&
+			len(d.opts.LockOrder) == 0 && d.opts.LockGraphSocket == "") {
+		return
+	}
+
+	// a routine, which never acquired a lock, is not registered
+	held := false
+	var acquired *Site
+	if index := d.getRoutineIndex(); index != -1 {
+		e.mu.getIsLockedRoutineIndexLock().Lock()
+		held = (*e.mu.getIsLockedRoutineIndex())[index] > 0
+		if caller, ok := (*e.mu.getHolders())[index]; ok && held {
+			s := newSite(caller)
+			acquired = &s
+		}
+		e.mu.getIsLockedRoutineIndexLock().Unlock()
+	}
+	if held == e.held {
+		return
+	}
+
+	// report each violated expectation only once for each check site
+	key := fmt.Sprint(e.site.File, ":", e.site.Line, ",", site.File, ":",
+		site.Line, ",", e.held)
+	d.reportedExpectationsLock.Lock()
+	reported := d.reportedExpectations[key]
+	d.reportedExpectations[key] = true
+	d.reportedExpectationsLock.Unlock()
+
+	if !reported {
+		d.reportExpectation(e, site, acquired)
+	}
+}
+
+// report a violated expectation
+//  Args:
+//   e (expectation): the violated expectation
+//   site (Site): site of the check
+//   acquired (*Site): acquisition of the lock, if it is held and the
+//    acquisition is known, nil otherwise
+//  Returns:
+//   nil
+func (d *Detector) reportExpectation(e expectation, site Site, acquired *Site) {
+	out := &bytes.Buffer{}
+
+	fmt.Fprintf(out, purple, "Expectation:\n\n")
+	if context := *e.mu.getContext(); len(context) != 0 {
+		fmt.Fprintln(out, e.site.File, e.site.Line,
+			fmt.Sprint("(lock created at ", context[0].file(), ":", context[0].line(), ")"))
+	} else {
+		fmt.Fprintln(out, e.site.File, e.site.Line)
+	}
+	fmt.Fprintln(out, "")
+
+	fmt.Fprintf(out, purple, "Start of the operation:\n\n")
+	fmt.Fprintln(out, site.File, site.Line, site.Function)
+	fmt.Fprintln(out, "")
+
+	sites := []Site{e.site, site}
+	if e.held {
+		fmt.Fprintln(out, "The lock must be held, when the operation starts, but the")
+		fmt.Fprintln(out, "routine does not hold it.")
+	} else {
+		if acquired != nil {
+			fmt.Fprintf(out, purple, "Acquisition of the lock:\n\n")
+			fmt.Fprintln(out, acquired.File, acquired.Line)
+			fmt.Fprintln(out, "")
+			sites = append(sites, *acquired)
+		}
+		fmt.Fprintln(out, "The lock must not be held, when the operation starts, but the")
+		fmt.Fprintln(out, "routine holds it.")
+	}
+	fmt.Fprintf(out, "\n\n")
+
+	locks := lockInfos(e.mu)
+	d.report(Report{
+		Type:  ReportWarning,
+		Title: "VIOLATED LOCK EXPECTATION",
+		Sites: sites,
+		Locks: locks,
+		err: &ExpectationError{
+			Lock:        locks[0],
+			Expectation: e.site,
+			Check:       site,
+			Held:        e.held,
+		},
+	}, out)
+}
+
+// report an expectation about a locker, which is not a lock of the detector
+// and can therefore not be checked. Each site is only reported once
+//  Args:
+//   l (sync.Locker): the locker
+//   site (Site): site where the expectation was added to the context
+//  Returns:
+//   nil
+func (d *Detector) reportUnsupportedExpectation(l sync.Locker, site Site) {
+	if !d.opts.Activated || d.inReport() {
+		return
+	}
+
+	key := fmt.Sprint(site.File, ":", site.Line, ",unsupported")
+	d.reportedExpectationsLock.Lock()
+	reported := d.reportedExpectations[key]
+	d.reportedExpectations[key] = true
+	d.reportedExpectationsLock.Unlock()
+	if reported {
+		return
+	}
+
+	out := &bytes.Buffer{}
+	fmt.Fprintf(out, purple, "Expectation:\n\n")
+	fmt.Fprintln(out, site.File, site.Line, site.Function)
+	fmt.Fprintln(out, "")
+	fmt.Fprintf(out, "The locker of type %T is not a lock of the detector, the\n", l)
+	fmt.Fprintln(out, "expectation is not checked.")
+	fmt.Fprintf(out, "\n\n")
+
+	d.report(Report{
+		Type:  ReportWarning,
+		Title: "UNCHECKED LOCK EXPECTATION",
+		Sites: []Site{site},
+	}, out)
+}
@@ -0,0 +1,292 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+external.go
+This file implements the registration of locks, which are acquired in C code
+(e.g. with cgo). The detector cannot observe these locks, therefore the
+routines must register when they start to acquire, acquire and release them.
+The routines waiting for an external lock are added to the wait-for graph of
+the periodical detection, so that deadlocks between locks in Go and locks in
+C are found. Only routines of the Go program can be registered, C threads
+which are not called from Go are not known to the detector.
+*/
+
+import (
+	"fmt"
+
+	"github.com/ErikKassubek/Deadlock-Go/internal/waitfor"
+)
+
+// type to implement a lock, which is acquired in C code
+type externalLock struct {
+	// handle of the lock, e.g. the address of the C mutex
+	handle uintptr
+	// number of acquisitions of the routines holding the lock, the key is
+	// the index of the routine. Protected by externalLocksLock of the detector
+	holders map[int]int
+	// indexes of the routines which are acquiring the lock. Protected by
+	// externalLocksLock of the detector
+	waiters map[int]struct{}
+	// info about the first registration of the lock
+	context []callerInfo
+	// detector the lock belongs to
+	detector *Detector
+}
+
+// ============ GETTER ============
+
+// getter for context
+//  Returns:
+//   (*[]callerInfo): caller info of the first registration of the lock
+func (e *externalLock) getContext() *[]callerInfo {
+	return &e.context
+}
+
+// getter for the name of the resource type
+//  Returns:
+//   (string): name of the resource type with the handle of the lock
+func (e *externalLock) getResourceName() string {
+	return fmt.Sprintf("external lock %#x", e.handle)
+}
+
+// get the routines a routine acquiring the lock waits for. These are the
+// routines holding the lock. Whether C locks are recursive is not known,
+// therefore the waiting routine itself is not considered as blocking
+//  Args:
+//   routineIndex (int): index of the waiting routine
+//   read (bool): not used for external locks
+//  Returns:
+//   (waitfor.Blockers): the routines which hold the lock, only the
+//    registered holders are considered
+func (e *externalLock) getBlockers(routineIndex int, read bool) waitfor.Blockers {
+	e.detector.externalLocksLock.Lock()
+	defer e.detector.externalLocksLock.Unlock()
+
+	res := make([]int, 0, len(e.holders))
+	for index := range e.holders {
+		if index != routineIndex {
+			res = append(res, index)
+		}
+	}
+	return waitfor.Blockers{Known: res}
+}
+
+// ============ FUNCTIONS ============
+
+// ExternalAcquire registers with the default detector, that the routine
+// starts to acquire the lock handle in C code. It must be called before the
+// call of the C code, which can block, and followed by ExternalAcquired
+// after the lock was acquired
+//  Args:
+//   handle (uintptr): handle of the lock, e.g. the address of the C mutex
+//  Returns:
+//   nil
+func ExternalAcquire(handle uintptr) {
+	defaultDetector.externalAcquire(handle, 2)
+}
+
+// ExternalAcquired registers with the default detector, that the routine
+// has acquired the lock handle in C code. Locks, which were acquired
+// without blocking (e.g. by a try-lock) can be registered with
+// ExternalAcquired without ExternalAcquire
+//  Args:
+//   handle (uintptr): handle of the lock, e.g. the address of the C mutex
+//  Returns:
+//   nil
+func ExternalAcquired(handle uintptr) {
+	defaultDetector.externalAcquired(handle, 2)
+}
+
+// ExternalRelease registers with the default detector, that the lock handle
+// was released in C code
+//  Args:
+//   handle (uintptr): handle of the lock, e.g. the address of the C mutex
+//  Returns:
+//   nil
+func ExternalRelease(handle uintptr) {
+	defaultDetector.externalRelease(handle)
+}
+
+// ExternalAcquire registers with the detector, that the routine starts to
+// acquire the lock handle in C code (see ExternalAcquire)
+//  Args:
+//   handle (uintptr): handle of the lock, e.g. the address of the C mutex
+//  Returns:
+//   nil
+func (d *Detector) ExternalAcquire(handle uintptr) {
+	d.externalAcquire(handle, 2)
+}
+
+// ExternalAcquired registers with the detector, that the routine has
+// acquired the lock handle in C code (see ExternalAcquired)
+//  Args:
+//   handle (uintptr): handle of the lock, e.g. the address of the C mutex
+//  Returns:
+//   nil
+func (d *Detector) ExternalAcquired(handle uintptr) {
+	d.externalAcquired(handle, 2)
+}
+
+// ExternalRelease registers with the detector, that the lock handle was
+// released in C code
+//  Args:
+//   handle (uintptr): handle of the lock, e.g. the address of the C mutex
+//  Returns:
+//   nil
+func (d *Detector) ExternalRelease(handle uintptr) {
+	d.externalRelease(handle)
+}
+
+// get the index of the routine for the registration of an external lock
+//  Returns:
+//   (int): index of the routine, -1 if external locks are not recorded
+func (d *Detector) externalRoutine() int {
+	// initialize detector if necessary
+	if !d.initialized {
+		d.initialize()
+	}
+
+	if !d.opts.Activated || !d.opts.PeriodicDetection {
+		return -1
+	}
+	return d.registerRoutine()
+}
+
+// get the external lock with a handle and create it, if it is not known
+// yet. Must be called while externalLocksLock is held.
+//  Args:
+//   handle (uintptr): handle of the lock
+//   skip (int): number of stack frames between the user code and the
+//    callerSite call
+//  Returns:
+//   (*externalLock): the lock
+func (d *Detector) getExternalLock(handle uintptr, skip int) *externalLock {
+	e, ok := d.externalLocks[handle]
+	if !ok {
+		e = &externalLock{
+			handle:   handle,
+			holders:  make(map[int]int),
+			waiters:  make(map[int]struct{}),
+			detector: d,
+		}
+		_, file, line, _ := callerSite(skip)
+		e.context = append(e.context, newInfo(file, line, "", true, false, ""))
+		d.externalLocks[handle] = e
+	}
+	return e
+}
+
+// remove an external lock, which is neither held nor acquired by a
+// routine. Must be called while externalLocksLock is held.
+//  Args:
+//   e (*externalLock): the lock
+//  Returns:
+//   nil
+func (d *Detector) removeUnusedExternalLock(e *externalLock) {
+	if len(e.holders) == 0 && len(e.waiters) == 0 {
+		delete(d.externalLocks, e.handle)
+	}
+}
+
+// register that the routine starts to acquire an external lock
+//  Args:
+//   handle (uintptr): handle of the lock
+//   skip (int): number of stack frames between the user code and the
+//    callerSite call
+//  Returns:
+//   nil
+func (d *Detector) externalAcquire(handle uintptr, skip int) {
+	index := d.externalRoutine()
+	if index == -1 {
+		return
+	}
+
+	d.externalLocksLock.Lock()
+	e := d.getExternalLock(handle, skip+1)
+	e.waiters[index] = struct{}{}
+	d.externalLocksLock.Unlock()
+
+	// register the wait in the wait-for graph. externalLocksLock is not held,
+	// because the detection locks it while the wait-for graph is locked
+	_, file, line, _ := callerSite(skip)
+	d.startWaiting(index, e, false, newInfo(file, line, "", false, false, ""))
+}
+
+// register that the routine has acquired an external lock
+//  Args:
+//   handle (uintptr): handle of the lock
+//   skip (int): number of stack frames between the user code and the
+//    callerSite call
+//  Returns:
+//   nil
+func (d *Detector) externalAcquired(handle uintptr, skip int) {
+	index := d.externalRoutine()
+	if index == -1 {
+		return
+	}
+
+	d.externalLocksLock.Lock()
+	e := d.getExternalLock(handle, skip+1)
+	_, waiting := e.waiters[index]
+	delete(e.waiters, index)
+	e.holders[index]++
+	d.externalLocksLock.Unlock()
+
+	if waiting {
+		d.stopWaiting(index)
+	}
+}
+
+// register that an external lock was released. If the routine does not
+// hold the lock, it was released on behalf of its holders, e.g. by a C
+// thread, and the lock is no longer held by any routine
+//  Args:
+//   handle (uintptr): handle of the lock
+//  Returns:
+//   nil
+func (d *Detector) externalRelease(handle uintptr) {
+	index := d.externalRoutine()
+	if index == -1 {
+		return
+	}
+
+	d.externalLocksLock.Lock()
+	defer d.externalLocksLock.Unlock()
+
+	e, ok := d.externalLocks[handle]
+	if !ok {
+		return
+	}
+	if _, ok := e.holders[index]; ok {
+		e.holders[index]--
+		if e.holders[index] == 0 {
+			delete(e.holders, index)
+		}
+	} else {
+		e.holders = make(map[int]int)
+	}
+	d.removeUnusedExternalLock(e)
+}
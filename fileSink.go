@@ -0,0 +1,295 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+fileSink.go
+Implementation of a report sink, which writes the reports to a file. The file
+can be limited in size. If a report would exceed the size, the file is
+rotated: the current file is renamed to <path>.1, older files are renamed
+to <path>.2, <path>.3 and so on, and only the configured number of old files
+is kept. This prevents a noisy run from filling the disk of a long-lived host.
+The file can be compressed with gzip. Every write is flushed, so that the
+file can be decompressed even if the program is terminated without closing
+it. The size limit applies to the compressed size.
+*/
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileSinkOptions controls the size and rotation of the file of a FileSink
+type FileSinkOptions struct {
+	// maximum size of the file in bytes, 0 for no limit
+	MaxSize int64
+	// number of rotated files which are kept, 0 to delete the file on rotation
+	MaxBackups int
+	// if true, the file is compressed with gzip. Files with the suffix .gz
+	// are always compressed
+	Compress bool
+}
+
+// FileSink writes reports to a file
+type FileSink struct {
+	// file the reports are written to
+	file *rotatingFile
+}
+
+// NewFileSink creates a sink, which appends the reports to a file
+//  Args:
+//   path (string): path of the file
+//   opts (FileSinkOptions): size and rotation of the file
+//  Returns:
+//   (*FileSink): the created sink, use its Report method as ReportSink
+//   (error): error if the file could not be opened or the options are invalid
+func NewFileSink(path string, opts FileSinkOptions) (*FileSink, error) {
+	if opts.MaxSize < 0 || opts.MaxBackups < 0 {
+		return nil, fmt.Errorf("deadlock: MaxSize and MaxBackups must not be negative")
+	}
+
+	compress := opts.Compress || strings.HasSuffix(path, ".gz")
+	file, err := newRotatingFile(path, opts.MaxSize, opts.MaxBackups, compress)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: file}, nil
+}
+
+// Report writes a report to the file. It can be used as ReportSink
+//  Args:
+//   r (Report): report to write
+//  Returns:
+//   nil
+func (s *FileSink) Report(r Report) {
+	text := fmt.Sprintf("=== %s ===\n%s\n", time.Now().Format(time.RFC3339), r.Text)
+	if _, err := s.file.Write([]byte(text)); err != nil {
+		fmt.Fprintln(os.Stderr, "deadlock: could not write report:", err)
+		fmt.Fprint(os.Stderr, r.Text)
+	}
+}
+
+// Close closes the file of the sink
+//  Returns:
+//   (error): error if the file could not be closed
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// type to implement a file with a size limit, which is rotated if the limit
+// is exceeded
+type rotatingFile struct {
+	// path of the file
+	path string
+	// maximum size of the file in bytes, 0 for no limit
+	maxSize int64
+	// number of rotated files which are kept
+	maxBackups int
+	// if true, the file is compressed with gzip
+	compress bool
+	// current file
+	file *os.File
+	// gzip writer on top of file, nil if the file is not compressed
+	gz *gzip.Writer
+	// current size of the file
+	size int64
+	// lock to prevent concurrent writes
+	lock sync.Mutex
+}
+
+// open a file with a size limit
+//  Args:
+//   path (string): path of the file
+//   maxSize (int64): maximum size of the file in bytes, 0 for no limit
+//   maxBackups (int): number of rotated files which are kept
+//   compress (bool): if true, the file is compressed with gzip
+//  Returns:
+//   (*rotatingFile): the opened file
+//   (error): error if the file could not be opened
+func newRotatingFile(path string, maxSize int64, maxBackups int,
+	compress bool) (*rotatingFile, error) {
+	f := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+		compress:   compress,
+	}
+	if err := f.open(os.O_APPEND); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open the file at the path
+//  Args:
+//   flag (int): os.O_APPEND to continue an existing file, os.O_TRUNC
+//    to start a new one
+//  Returns:
+//   (error): error if the file could not be opened
+func (f *rotatingFile) open(flag int) error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|flag, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+
+	// appending to an existing compressed file adds a new gzip member, which
+	// is decompressed together with the previous ones
+	if f.compress {
+		f.gz = gzip.NewWriter(countingWriter{f})
+	}
+	return nil
+}
+
+// writer, which writes to the file and counts the written bytes
+type countingWriter struct {
+	f *rotatingFile
+}
+
+// write to the file and add the number of written bytes to its size
+//  Args:
+//   p ([]byte): data to write
+//  Returns:
+//   (int): number of written bytes
+//   (error): error if the data could not be written
+func (w countingWriter) Write(p []byte) (int, error) {
+	n, err := w.f.file.Write(p)
+	w.f.size += int64(n)
+	return n, err
+}
+
+// write to the file. If the data would exceed the size limit, the file is
+// rotated first. Data, which is larger than the limit on its own, is written
+// to a new file
+//  Args:
+//   p ([]byte): data to write
+//  Returns:
+//   (int): number of written bytes
+//   (error): error if the data could not be written
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	if f.gz == nil {
+		return countingWriter{f}.Write(p)
+	}
+
+	n, err := f.gz.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, f.gz.Flush()
+}
+
+// close the gzip writer and the current file
+//  Returns:
+//   (error): error if the file could not be closed
+func (f *rotatingFile) closeFile() error {
+	var err error
+	if f.gz != nil {
+		err = f.gz.Close()
+		f.gz = nil
+	}
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	f.file = nil
+	return err
+}
+
+// rotate the file: shift the old files, rename the current file to <path>.1
+// and start a new file
+//  Returns:
+//   (error): error if the files could not be renamed or opened
+func (f *rotatingFile) rotate() error {
+	if err := f.closeFile(); err != nil {
+		return err
+	}
+
+	if f.maxBackups == 0 {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		// the oldest file is overwritten by the rename
+		for i := f.maxBackups - 1; i >= 1; i-- {
+			err := os.Rename(backupPath(f.path, i), backupPath(f.path, i+1))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(f.path, backupPath(f.path, 1)); err != nil {
+			return err
+		}
+	}
+
+	return f.open(os.O_TRUNC)
+}
+
+// close the file
+//  Returns:
+//   (error): error if the file could not be closed
+func (f *rotatingFile) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	return f.closeFile()
+}
+
+// get the path of a rotated file. The suffix .gz is kept at the end, so that
+// the rotated files are still recognized as compressed
+//  Args:
+//   path (string): path of the current file
+//   n (int): number of the rotation, 1 for the newest rotated file
+//  Returns:
+//   (string): path of the rotated file
+func backupPath(path string, n int) string {
+	if strings.HasSuffix(path, ".gz") {
+		return fmt.Sprint(strings.TrimSuffix(path, ".gz"), ".", n, ".gz")
+	}
+	return fmt.Sprint(path, ".", n)
+}
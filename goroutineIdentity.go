@@ -0,0 +1,303 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+goroutineIdentity.go
+This file implements the identity of the goroutines in the outputs of the
+detector. The index of a routine in the detector is meaningless to users,
+the outputs therefore show the id of the goroutine, as in the call stacks of
+a panic, its name set with SetGoroutineName, the site of the go statement,
+which created it, and the ids of its ancestors. The creator of a goroutine is
+read from its call stack, when the goroutine is registered by the detector.
+The ancestors are followed through the registered goroutines and end at the
+first ancestor, which is not registered by the detector, because the call
+stacks of other goroutines are not read.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/petermattis/goid"
+)
+
+// Goroutine identifies a goroutine in the outputs of the detector
+type Goroutine struct {
+	// id of the goroutine, as in the call stacks of a panic
+	ID int64 `json:"id"`
+	// name set with SetGoroutineName, empty if no name was set
+	Name string `json:"name,omitempty"`
+	// site of the go statement, which created the goroutine, nil for the
+	// main goroutine and in the embedded mode
+	Created *Site `json:"created,omitempty"`
+	// ids of the ancestors of the goroutine, starting with the goroutine,
+	// which created it, as far as they are registered by the detector
+	Ancestors []int64 `json:"ancestors,omitempty"`
+}
+
+// names of the goroutines set with SetGoroutineName, by goroutine id
+var goroutineNames = make(map[int64]string)
+
+// lock to prevent concurrent access to goroutineNames
+var goroutineNamesLock sync.Mutex
+
+// SetGoroutineName sets the name of the calling goroutine, which is shown in
+// all outputs of all detectors, e.g. "worker 3". The name of a goroutine is
+// kept after it has finished, it should therefore be removed with an empty
+// name by short-lived goroutines
+//  Args:
+//   name (string): name of the goroutine, empty to remove the name
+//  Returns:
+//   nil
+func SetGoroutineName(name string) {
+	id := goid.Get()
+
+	goroutineNamesLock.Lock()
+	defer goroutineNamesLock.Unlock()
+	if name == "" {
+		delete(goroutineNames, id)
+	} else {
+		goroutineNames[id] = name
+	}
+}
+
+// get the name of a goroutine
+//  Args:
+//   id (int64): id of the goroutine
+//  Returns:
+//   (string): name of the goroutine, empty if no name was set
+func goroutineName(id int64) string {
+	goroutineNamesLock.Lock()
+	defer goroutineNamesLock.Unlock()
+	return goroutineNames[id]
+}
+
+// String returns the identity of the goroutine, e.g.
+// `goroutine 12 "worker" (created at /app/main.go:30, ancestors 7 <- 1)`
+//  Returns:
+//   (string): the identity
+func (g Goroutine) String() string {
+	s := fmt.Sprint("goroutine ", g.ID)
+	if g.Name != "" {
+		s += fmt.Sprintf(" %q", g.Name)
+	}
+
+	details := make([]string, 0, 2)
+	if g.Created != nil {
+		details = append(details, fmt.Sprint("created at ", g.Created.File, ":",
+			g.Created.Line))
+	}
+	if len(g.Ancestors) != 0 {
+		ids := make([]string, 0, len(g.Ancestors))
+		for _, id := range g.Ancestors {
+			ids = append(ids, fmt.Sprint(id))
+		}
+		details = append(details, "ancestors "+strings.Join(ids, " <- "))
+	}
+	if len(details) != 0 {
+		s += " (" + strings.Join(details, ", ") + ")"
+	}
+	return s
+}
+
+// UnmarshalJSON reads the identity of a goroutine. Outputs of schema version
+// 2 contain only the id of the goroutine as a number
+//  Args:
+//   data ([]byte): JSON object or number
+//  Returns:
+//   (error): error if the data is neither an identity nor an id
+func (g *Goroutine) UnmarshalJSON(data []byte) error {
+	var id int64
+	if err := json.Unmarshal(data, &id); err == nil {
+		*g = Goroutine{ID: id}
+		return nil
+	}
+
+	// alias without the method to prevent a recursion
+	type goroutine Goroutine
+	return json.Unmarshal(data, (*goroutine)(g))
+}
+
+// get the identity of the goroutine as the start of a sentence
+//  Returns:
+//   (string): the identity, starting with an upper case letter
+func (g Goroutine) sentence() string {
+	s := g.String()
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// get the identity of the calling goroutine without its name. Only the
+// parent is added to the ancestors, the other ancestors are added, when the
+// identity is shown
+//  Returns:
+//   (Goroutine): the identity
+func currentGoroutine() Goroutine {
+	g := Goroutine{ID: goid.Get()}
+
+	parent, created, ok := goroutineCreator()
+	if !ok {
+		return g
+	}
+	if !embeddedMode {
+		g.Created = created
+	}
+	g.Ancestors = []int64{parent}
+	return g
+}
+
+// get the creator of the calling goroutine from its call stack. The stack
+// ends with the go statement, e.g.
+//
+//	created by main.main in goroutine 1
+//		/app/main.go:30 +0x25
+//
+//  Returns:
+//   (int64): id of the goroutine, which created the calling goroutine
+//   (*Site): site of the go statement
+//   (bool): false for the main goroutine, or if the creator is unknown
+func goroutineCreator() (int64, *Site, bool) {
+	stack := string(currentStack())
+
+	start := strings.LastIndex(stack, "\ncreated by ")
+	if start == -1 {
+		return 0, nil, false
+	}
+	lines := strings.SplitN(stack[start+1:], "\n", 3)
+	if len(lines) < 2 {
+		return 0, nil, false
+	}
+
+	// first line: created by <function> in goroutine <id>
+	creator := strings.TrimPrefix(lines[0], "created by ")
+	sep := strings.LastIndex(creator, " in goroutine ")
+	if sep == -1 {
+		return 0, nil, false
+	}
+	parent, err := strconv.ParseInt(creator[sep+len(" in goroutine "):], 10, 64)
+	if err != nil {
+		return 0, nil, false
+	}
+
+	// second line: <file>:<line> +<offset>
+	site := strings.TrimSpace(lines[1])
+	if i := strings.LastIndex(site, " +"); i != -1 {
+		site = site[:i]
+	}
+	colon := strings.LastIndex(site, ":")
+	if colon == -1 {
+		return parent, nil, true
+	}
+	line, err := strconv.Atoi(site[colon+1:])
+	if err != nil {
+		return parent, nil, true
+	}
+	return parent, &Site{File: site[:colon], Line: line, Function: creator[:sep]}, true
+}
+
+// replace the site of the go statement of a routine, e.g. if the routine
+// was created by the detector on behalf of the program
+//  Args:
+//   index (int): index of the routine
+//   created (Site): site, at which the program created the routine
+//  Returns:
+//   nil
+func (d *Detector) setGoroutineCreated(index int, created Site) {
+	if embeddedMode {
+		return
+	}
+
+	d.createRoutineLock.Lock()
+	if index >= 0 && index < d.numberRoutines {
+		d.routines[index].identity.Created = &created
+	}
+	d.createRoutineLock.Unlock()
+}
+
+// get the identity of a routine
+//  Args:
+//   index (int): index of the routine
+//  Returns:
+//   (Goroutine): the identity, the zero value if the routine does not exist
+func (d *Detector) goroutine(index int) Goroutine {
+	d.createRoutineLock.Lock()
+	if index < 0 || index >= d.numberRoutines {
+		d.createRoutineLock.Unlock()
+		return Goroutine{}
+	}
+	g := d.routines[index].identity
+
+	// follow the parents through the registered routines. The number of
+	// steps is bounded, in case the runtime reused a goroutine id
+	if len(g.Ancestors) != 0 {
+		g.Ancestors = []int64{g.Ancestors[0]}
+		for len(g.Ancestors) <= d.numberRoutines {
+			parent, ok := d.mapIndex[g.Ancestors[len(g.Ancestors)-1]]
+			if !ok || len(d.routines[parent].identity.Ancestors) == 0 {
+				break
+			}
+			g.Ancestors = append(g.Ancestors, d.routines[parent].identity.Ancestors[0])
+		}
+	}
+	d.createRoutineLock.Unlock()
+
+	g.Name = goroutineName(g.ID)
+	return g
+}
+
+// get the identities of routines, each routine only once
+//  Args:
+//   indexes ([]int): indexes of the routines
+//  Returns:
+//   ([]Goroutine): the identities in the order of the first occurrence of
+//    the routines, nil if there are no routines
+func (d *Detector) goroutines(indexes []int) []Goroutine {
+	var res []Goroutine
+	seen := make(map[int]bool, len(indexes))
+	for _, index := range indexes {
+		if seen[index] || index < 0 {
+			continue
+		}
+		seen[index] = true
+		res = append(res, d.goroutine(index))
+	}
+	return res
+}
+
+// get the call stack of the calling goroutine
+//  Returns:
+//   ([]byte): the call stack in the format of runtime.Stack
+func currentStack() []byte {
+	buf := make([]byte, 4096)
+	n := runtime.Stack(buf, false)
+	for n == len(buf) {
+		buf = make([]byte, 2*len(buf))
+		n = runtime.Stack(buf, false)
+	}
+	return buf[:n]
+}
@@ -0,0 +1,280 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+guard.go
+Implementation of guards, which detect locks that are still held when the
+function which acquired them returns, e.g. because the release was not
+deferred and an early return skips it. A guard is created at the beginning
+of the function and checked by a deferred call:
+
+	defer deadlock.NewGuard().Check()
+
+Every lock acquired by the routine between the creation and the check of
+the guard, including the acquisitions in called functions, and not released
+before the check is reported as an abandoned lock. Locks, which are
+released by another routine before the check, are not reported. Locks
+whose release is intentionally left to the caller or to another routine
+can be excluded with Transfer.
+*/
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/petermattis/goid"
+)
+
+// Guard checks that a function releases the locks it acquires
+type Guard struct {
+	// detector the guard belongs to
+	detector *Detector
+	// goroutine id of the routine, which created the guard
+	id int64
+	// site of the creation of the guard
+	site Site
+	// acquisitions of the routine since the creation, which were not
+	// released yet. Protected by detector.guardsLock
+	acquired []guardedLock
+	// guard of the calling function, nil if there is none
+	parent *Guard
+	// set to true after the guard was checked
+	checked bool
+	// method and path of the request, if the guard delimits the scope of a
+	// HTTP handler, empty otherwise
+	request string
+}
+
+// acquisition of a lock registered by a guard
+type guardedLock struct {
+	// acquired lock
+	mu mutexInt
+	// site of the acquisition
+	site Site
+	// true if the lock is released outside of the guarded function
+	transferred bool
+}
+
+// NewGuard creates a guard for the calling function. Check must be
+// called by the same routine before the function returns, normally with
+// defer NewGuard().Check()
+//  Returns:
+//   (*Guard): the created guard
+func NewGuard() *Guard {
+	return newGuard(defaultDetector)
+}
+
+// create a guard for the calling function and register it as the
+// innermost guard of the routine
+//  Args:
+//   d (*Detector): detector whose locks are guarded
+//  Returns:
+//   (*Guard): the created guard
+func newGuard(d *Detector) *Guard {
+	frame := userFrame()
+	return newGuardAt(d, Site{File: frame.File, Line: frame.Line, Function: frame.Function})
+}
+
+// create a guard for a scope of the calling routine and register it as the
+// innermost guard of the routine
+//  Args:
+//   d (*Detector): detector whose locks are guarded
+//   site (Site): site of the guarded scope, e.g. the guarded function
+//  Returns:
+//   (*Guard): the created guard
+func newGuardAt(d *Detector, site Site) *Guard {
+	g := &Guard{
+		detector: d,
+		id:       goid.Get(),
+		site:     site,
+	}
+	if !d.opts.Activated {
+		g.checked = true
+		return g
+	}
+
+	d.guardsLock.Lock()
+	g.parent = d.guards[g.id]
+	d.guards[g.id] = g
+	d.guardsLock.Unlock()
+	atomic.AddInt32(&d.numberGuards, 1)
+	return g
+}
+
+// Transfer excludes the current acquisitions of a lock from the check of
+// the guard, because the lock is released outside of the guarded function
+//  Args:
+//   l (sync.Locker): lock of the detector, which was acquired in the
+//    guarded function
+//  Returns:
+//   nil
+func (g *Guard) Transfer(l sync.Locker) {
+	m, ok := l.(mutexInt)
+	if !ok {
+		panic(fmt.Sprintf("Transfer of %T, which is not a lock of the detector", l))
+	}
+	d := g.detector
+	d.guardsLock.Lock()
+	defer d.guardsLock.Unlock()
+	for i := range g.acquired {
+		if sameLock(g.acquired[i].mu, m) {
+			g.acquired[i].transferred = true
+		}
+	}
+}
+
+// Check reports all locks, which were acquired since the creation of the
+// guard and are still held. It must be called by the routine, which
+// created the guard
+//  Returns:
+//   nil
+func (g *Guard) Check() {
+	if g.checked {
+		return
+	}
+	d := g.detector
+
+	if goid.Get() != g.id {
+		panic("Check of a guard in a routine which did not create it")
+	}
+
+	d.guardsLock.Lock()
+	if d.guards[g.id] != g {
+		d.guardsLock.Unlock()
+		panic("Check of a guard before the check of the guards created after it")
+	}
+	if g.parent == nil {
+		delete(d.guards, g.id)
+	} else {
+		d.guards[g.id] = g.parent
+	}
+	g.checked = true
+
+	// report each acquisition site in a guarded function only once
+	abandoned := make([]guardedLock, 0)
+	for _, l := range g.acquired {
+		key := fmt.Sprint(g.site.File, ":", g.site.Line, ",", l.site.File, ":", l.site.Line)
+		if l.transferred || d.reportedAbandonedLocks[key] {
+			continue
+		}
+		d.reportedAbandonedLocks[key] = true
+		abandoned = append(abandoned, l)
+	}
+	d.guardsLock.Unlock()
+	atomic.AddInt32(&d.numberGuards, -1)
+
+	// releases after the shutdown of the detector are not registered
+	if atomic.LoadInt32(&d.shutdown) != 0 {
+		return
+	}
+
+	for _, l := range abandoned {
+		d.reportAbandonedLock(g, l)
+	}
+}
+
+// register an acquisition in the innermost guard of the calling routine
+//  Args:
+//   m (mutexInt): acquired lock
+//  Returns:
+//   nil
+func (d *Detector) guardAcquired(m mutexInt) {
+	if atomic.LoadInt32(&d.numberGuards) == 0 {
+		return
+	}
+	id := goid.Get()
+
+	d.guardsLock.Lock()
+	defer d.guardsLock.Unlock()
+	g := d.guards[id]
+	if g == nil {
+		return
+	}
+	frame := userFrame()
+	g.acquired = append(g.acquired, guardedLock{
+		mu:   m,
+		site: Site{File: frame.File, Line: frame.Line, Function: frame.Function},
+	})
+}
+
+// remove the last acquisition of a released lock from the guards. The
+// guards of the calling routine are searched first. If the lock was
+// acquired in a guarded function of another routine, the release by this
+// routine is a transfer of the lock
+//  Args:
+//   m (mutexInt): released lock
+//  Returns:
+//   nil
+func (d *Detector) guardReleased(m mutexInt) {
+	if atomic.LoadInt32(&d.numberGuards) == 0 {
+		return
+	}
+	id := goid.Get()
+
+	d.guardsLock.Lock()
+	defer d.guardsLock.Unlock()
+	for g := d.guards[id]; g != nil; g = g.parent {
+		if g.release(m) {
+			return
+		}
+	}
+	for other, g := range d.guards {
+		if other == id {
+			continue
+		}
+		for ; g != nil; g = g.parent {
+			if g.release(m) {
+				return
+			}
+		}
+	}
+}
+
+// remove the last acquisition of a lock from the guard
+//  Args:
+//   m (mutexInt): released lock
+//  Returns:
+//   (bool): true if the guard contained an acquisition of m
+func (g *Guard) release(m mutexInt) bool {
+	for i := len(g.acquired) - 1; i >= 0; i-- {
+		if sameLock(g.acquired[i].mu, m) {
+			g.acquired = append(g.acquired[:i], g.acquired[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// check if two locks are the same lock. An upgradable rw-mutex is the
+// same lock as its underlying rw-mutex
+//  Args:
+//   a (mutexInt): first lock
+//   b (mutexInt): second lock
+//  Returns:
+//   (bool): true if a and b are the same lock
+func sameLock(a mutexInt, b mutexInt) bool {
+	return a.getMemoryPosition() == b.getMemoryPosition()
+}
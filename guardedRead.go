@@ -0,0 +1,85 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+guardedRead.go
+Implementation of the recording of guarded reads. Most reader locks of a
+program guard a short read of shared data and are acquired, while the
+routine holds no other lock. Such an acquisition can not create a
+dependency, so the lock is not added to the holding set. It is held on the
+lightweight path of the routine (hotLock.go) instead, while the event, the
+wait-for graph and the single level caller information are recorded as
+before. If the routine acquires another lock while it holds the reader lock,
+the reader lock is promoted into the holding set, so that the new dependency
+is complete.
+Resolving the site of a single level lock is the most expensive part of the
+recording. The innermost program counters of a guarded read, whose site was
+already collected, are therefore remembered, so that a known site is
+recognized without resolving the frames.
+*/
+
+import (
+	"runtime"
+	"time"
+)
+
+// innermost program counters of the call stack of a guarded read. Equal
+// program counters always belong to the same site
+type guardedReadSite [8]uintptr
+
+// acquire a reader lock, which is not an upgrade, on the lightweight path if
+// the routine holds no other locks. Locks, for which the acquisition sites
+// are saved for the lock depth or the declared lock order, are always added
+// to the holding set
+//  Args:
+//   m (mutexInt): acquired reader lock
+//   initPhase (bool): true if m is acquired during the initialization of the
+//    packages
+//  Returns:
+//   (bool): true if m was acquired on the lightweight path
+func (r *routine) acquireGuardedRead(m mutexInt, initPhase bool) bool {
+	if r.holdingCount > 0 || len(r.lightHolding) > 0 || r.holdingCallers != nil {
+		return false
+	}
+
+	// the reader status is needed by the wait-for graph and the check for
+	// double locking
+	m.setRLock(r.index, true)
+
+	if r.detector.opts.CollectSingleLevelLockStack {
+		var site guardedReadSite
+		runtime.Callers(2, site[:])
+		if _, ok := r.guardedReadSites[site]; !ok {
+			if r.isNewSingleLevelLock() {
+				r.saveLockCaller(m, false, initPhase, time.Since(r.detector.start))
+			}
+			r.guardedReadSites[site] = struct{}{}
+		}
+	}
+
+	r.lightHolding = append(r.lightHolding,
+		lightHeldLock{mu: m, read: true, recorded: true})
+	return true
+}
@@ -0,0 +1,93 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+history.go
+Implementation of the history of the routines, which stores the last lock
+events of a routine in a ring buffer (internal/record). The events of the routines involved in a deadlock are added to the
+report, to show what these routines did immediately before they blocked.
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/ErikKassubek/Deadlock-Go/internal/record"
+)
+
+// save a lock event of a routine in its history
+//  Args:
+//   index (int): index of the routine
+//   m (mutexInt): lock of the event
+//   kind (string): kind of the event
+//  Returns:
+//   nil
+func (d *Detector) recordEvent(index int, m mutexInt, kind string) {
+	if d.opts.EventHistorySize <= 0 {
+		return
+	}
+
+	file, line := userCaller()
+	d.routines[index].history.Record(record.Event{
+		Kind: kind,
+		Lock: m,
+		File: file,
+		Line: line,
+		Time: time.Since(d.start),
+	})
+}
+
+// write the last lock events of the routines involved in a deadlock
+//  Args:
+//   out (*bytes.Buffer): buffer to write to
+//   indexes ([]int): indexes of the involved routines
+//  Returns:
+//   nil
+func (d *Detector) writeHistory(out *bytes.Buffer, indexes []int) {
+	if d.opts.EventHistorySize <= 0 {
+		return
+	}
+
+	fmt.Fprintf(out, purple, "\nLast lock events of the routines involved in deadlock:\n\n")
+
+	written := make(map[int]bool)
+	for _, index := range indexes {
+		if written[index] || index < 0 || index >= d.numberRoutines {
+			continue
+		}
+		written[index] = true
+
+		fmt.Fprintf(out, blue, fmt.Sprint(d.goroutine(index).sentence(), ":"))
+		fmt.Fprintf(out, "\n")
+		for _, e := range d.routines[index].history.Events() {
+			context := *e.Lock.(mutexInt).getContext()
+			fmt.Fprintln(out, e.Time.Round(time.Microsecond), e.Kind,
+				fmt.Sprint(e.File, ":", e.Line), fmt.Sprint("(lock created at ",
+					context[0].file(), ":", context[0].line(), ")"))
+		}
+		fmt.Fprintln(out, "")
+	}
+}
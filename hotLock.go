@@ -0,0 +1,230 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+hotLock.go
+Implementation of the opt-out of single locks from the detection. Locks,
+which are acquired extremely often, e.g. a mutex which protects a counter,
+can bypass the recording completely, while the other locks of the program
+are still checked. The operations of such a lock only lock and unlock the
+underlying lock, so the lock never appears in the lock trees, the wait-for
+graph or any report.
+With HotLockRate, hot locks are detected automatically. A lock, which is
+acquired more often per second than the rate and never together with other
+locks, is acquired on a lightweight path: it is neither added to the holding
+set nor to the wait-for graph, and no event or caller is recorded. The
+routine only remembers that it holds the lock. If the routine acquires
+another lock while it holds the hot lock, or the hot lock while it holds
+other locks, the lock is nested. The held hot locks are then moved into the
+holding set, so that the new dependency is complete, and the lock is never
+downgraded again. Guarded reads (guardedRead.go) are held in the same list
+of lightweight locks and promoted in the same way.
+*/
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// information about the acquisitions of a lock for the detection of hot
+// locks. All fields are accessed atomically
+type hotLockInfo struct {
+	// 1 if the lock is acquired on the lightweight path
+	hot int32
+	// 1 if the lock was acquired together with other locks
+	nested int32
+	// number of acquisitions in the current window
+	count int32
+	// start of the current window as time since the start of the detector
+	windowStart int64
+}
+
+// lock acquired on the lightweight path
+type lightHeldLock struct {
+	// acquired lock
+	mu mutexInt
+	// true if the lock is held as a reader lock
+	read bool
+	// true if the events of the lock are recorded in the history
+	recorded bool
+}
+
+// mark the lock as nested, so that it is acquired on the normal path
+//  Returns:
+//   nil
+func (h *hotLockInfo) markNested() {
+	if atomic.LoadInt32(&h.nested) == 0 {
+		atomic.StoreInt32(&h.nested, 1)
+	}
+	if atomic.LoadInt32(&h.hot) != 0 {
+		atomic.StoreInt32(&h.hot, 0)
+	}
+}
+
+// count an acquisition of a lock, which was not nested, and downgrade the
+// lock, if the rate of the acquisitions in the last window reached the
+// threshold
+//  Args:
+//   d (*Detector): detector of the lock
+//  Returns:
+//   nil
+func (h *hotLockInfo) countAcquisition(d *Detector) {
+	if atomic.LoadInt32(&h.nested) != 0 {
+		return
+	}
+	n := atomic.AddInt32(&h.count, 1)
+
+	now := int64(time.Since(d.start))
+	start := atomic.LoadInt64(&h.windowStart)
+	elapsed := time.Duration(now - start)
+	if elapsed < time.Second || !atomic.CompareAndSwapInt64(&h.windowStart, start, now) {
+		return
+	}
+	atomic.StoreInt32(&h.count, 0)
+	rate := float64(n) / elapsed.Seconds()
+	if rate >= float64(d.opts.HotLockRate) && atomic.LoadInt32(&h.nested) == 0 {
+		atomic.StoreInt32(&h.hot, 1)
+	}
+}
+
+// check the nesting of an acquisition and acquire hot locks on the
+// lightweight path. Must be called by the routine after the promotion of
+// its lightweight locks and before m is recorded
+//  Args:
+//   r (*routine): routine, which acquires m
+//   m (mutexInt): acquired lock
+//   rLock (bool): true if m is acquired as a reader lock
+//   upgrade (bool): true if m is acquired as an upgrade of a reader lock
+//  Returns:
+//   (bool): true if m was acquired on the lightweight path and must not
+//    be recorded
+func (d *Detector) acquireHotLock(r *routine, m mutexInt, rLock bool, upgrade bool) bool {
+	if d.opts.HotLockRate <= 0 {
+		return false
+	}
+
+	info := m.getHotLockInfo()
+	if r.holdingCount > 0 || upgrade {
+		info.markNested()
+		for i := 0; i < r.holdingCount; i++ {
+			r.holdingSet[i].getHotLockInfo().markNested()
+		}
+		return false
+	}
+
+	if atomic.LoadInt32(&info.hot) == 0 {
+		info.countAcquisition(d)
+		return false
+	}
+
+	r.lightHolding = append(r.lightHolding, lightHeldLock{mu: m, read: rLock})
+	m.getIsLockedRoutineIndexLock().Lock()
+	(*m.getIsLockedRoutineIndex())[r.index] += 1
+	m.getIsLockedRoutineIndexLock().Unlock()
+	return true
+}
+
+// move the locks, which the routine holds on the lightweight path, into the
+// holding set, because the routine acquires another lock. Must be called
+// by the routine before the new lock is recorded, so that its dependency
+// contains the held locks
+//  Returns:
+//   nil
+func (r *routine) promoteLightLocks() {
+	if len(r.lightHolding) == 0 {
+		return
+	}
+	for _, held := range r.lightHolding {
+		held.mu.getHotLockInfo().markNested()
+		r.updateTryLock(held.mu, held.read)
+	}
+	r.lightHolding = r.lightHolding[:0]
+}
+
+// remove a lock, which was acquired on the lightweight path, from the
+// locks held by the routine
+//  Args:
+//   m (mutexInt): released lock
+//  Returns:
+//   (lightHeldLock): the removed acquisition
+//   (bool): true if m was acquired on the lightweight path
+func (r *routine) releaseLightLock(m mutexInt) (lightHeldLock, bool) {
+	for i := len(r.lightHolding) - 1; i >= 0; i-- {
+		if r.lightHolding[i].mu == m {
+			held := r.lightHolding[i]
+			r.lightHolding = append(r.lightHolding[:i], r.lightHolding[i+1:]...)
+			return held, true
+		}
+	}
+	return lightHeldLock{}, false
+}
+
+// DisableDetection excludes m from the detection. It must be called before
+// m is used
+//  Returns:
+//   nil
+func (m *Mutex) DisableDetection() {
+	m.lazyInit()
+	m.disabled = true
+}
+
+// DisableDetection excludes m from the detection. It must be called before
+// m is used
+//  Returns:
+//   nil
+func (m *RWMutex) DisableDetection() {
+	m.lazyInit()
+	m.disabled = true
+}
+
+// check if the lock is excluded from the detection
+//  Returns:
+//   (bool): true if the operations of the lock are not recorded
+func (m *Mutex) detectionDisabled() bool {
+	return m.disabled
+}
+
+// check if the lock is excluded from the detection
+//  Returns:
+//   (bool): true if the operations of the lock are not recorded
+func (m *RWMutex) detectionDisabled() bool {
+	return m.disabled
+}
+
+// getter for the information about the acquisitions for the detection of
+// hot locks
+//  Returns:
+//   (*hotLockInfo): information about the acquisitions
+func (m *Mutex) getHotLockInfo() *hotLockInfo {
+	return &m.hotLock
+}
+
+// getter for the information about the acquisitions for the detection of
+// hot locks
+//  Returns:
+//   (*hotLockInfo): information about the acquisitions
+func (m *RWMutex) getHotLockInfo() *hotLockInfo {
+	return &m.hotLock
+}
@@ -0,0 +1,102 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+httpMiddleware.go
+Implementation of a middleware for net/http, which delimits the scope of a
+handler. A handler of a chain of middlewares runs in the routine of the
+request and calls the next handler of the chain. Each handler wrapped with
+Middleware is checked by a guard (guard.go): all locks acquired while the
+handler runs, including the handlers it calls, must be released before it
+returns. If the outermost handler of the chain is wrapped, the locks of the
+whole chain are checked at the end of the request. If the handlers of the
+chain are wrapped individually, a lock acquired in one middleware and
+released by a middleware, which called it, after the next handler returned,
+is reported for the handler, which acquired it. A lock acquired before the
+call of the next handler and released by a later handler of the chain is
+only reported, if the chain ends before the release, e.g. because a
+middleware between them rejected the request. The reports contain the
+handler and the request.
+*/
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+)
+
+// Middleware returns a handler, which calls next and reports the locks of
+// the default detector, which were acquired while next ran and are still
+// held, when it returns (see Detector.Middleware)
+//  Args:
+//   next (http.Handler): handler to check
+//  Returns:
+//   (http.Handler): the checked handler
+func Middleware(next http.Handler) http.Handler {
+	return defaultDetector.Middleware(next)
+}
+
+// Middleware returns a handler, which calls next and reports the locks of
+// the detector, which were acquired while next ran and are still held, when
+// it returns. The locks are reported as abandoned locks with the definition
+// of next and the method and path of the request
+//  Args:
+//   next (http.Handler): handler to check
+//  Returns:
+//   (http.Handler): the checked handler
+func (d *Detector) Middleware(next http.Handler) http.Handler {
+	site := handlerSite(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g := newGuardAt(d, site)
+		g.request = r.Method + " " + r.URL.Path
+		defer g.Check()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// get the site of the definition of a handler, i.e. of the function of a
+// http.HandlerFunc or of the ServeHTTP method of other handlers
+//  Args:
+//   h (http.Handler): handler
+//  Returns:
+//   (Site): site of the handler, only with the type as function, if the
+//    definition is not known
+func handlerSite(h http.Handler) Site {
+	var pc uintptr
+	if f, ok := h.(http.HandlerFunc); ok {
+		pc = reflect.ValueOf(f).Pointer()
+	} else if m, ok := reflect.TypeOf(h).MethodByName("ServeHTTP"); ok {
+		pc = m.Func.Pointer()
+	}
+
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return Site{Function: fmt.Sprintf("%T", h)}
+	}
+	file, line := fn.FileLine(fn.Entry())
+	return Site{File: file, Line: line, Function: fn.Name()}
+}
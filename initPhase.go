@@ -0,0 +1,170 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+initPhase.go
+This file implements the handling of locks, which are used in the init
+functions of packages. These locks are used before the main function, and
+therefore before the detector can be configured and initialized. Dependencies
+created in the init phase are marked, so that cycles in the lock order
+of the init functions of different packages can be detected.
+*/
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/petermattis/goid"
+)
+
+// set to true, if the init phase is over. It is only read and written by
+// the main routine
+var initPhaseOver = false
+
+// check if the current routine runs an init function of a package
+//  Returns:
+//   (bool): true if the call is made from an init function, false otherwise
+func inInitPhase() bool {
+	// init functions are only executed by the main routine
+	if initPhaseOver || goid.Get() != 1 {
+		return false
+	}
+
+	// search the call stack for the initialization of packages by the runtime
+	pc := make([]uintptr, 64)
+	n := runtime.Callers(2, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	for {
+		frame, more := frames.Next()
+		if strings.HasPrefix(frame.Function, "runtime.doInit") {
+			return true
+		}
+		if !more {
+			break
+		}
+	}
+
+	// the main routine has left the init phase and will not return to it
+	initPhaseOver = true
+	return false
+}
+
+// edge in the lock graph of the init phase
+type initEdge struct {
+	// dependency which created the edge
+	dep *dependency
+	// target of the edge
+	to mutexInt
+}
+
+// detectInitOrder searches for cycles in the lock order of dependencies,
+// which were created in the init phase. A cycle is reported, if the
+// acquisitions of the locks in the cycle were made by at least two
+// different packages.
+//  Returns:
+//   nil
+func (d *Detector) detectInitOrder() {
+	// create the lock graph of the init phase
+	graph := make(map[uintptr][]initEdge)
+	for _, deps := range d.snapshot.dependencies {
+		for _, dep := range deps {
+			if !dep.initPhase {
+				continue
+			}
+			for k := 0; k < dep.holdingCount; k++ {
+				// two reader locks can not block each other
+				if dep.read && dep.holdingRead[k] {
+					continue
+				}
+				from := dep.holdingSet[k].getMemoryPosition()
+				graph[from] = append(graph[from], initEdge{dep: dep, to: dep.mu})
+			}
+		}
+	}
+
+	if len(graph) == 0 {
+		return
+	}
+
+	// search for cycles, starting at each lock. To report each cycle only
+	// once, only cycles in which the start has the smallest memory position
+	// are considered
+	path := make([]*dependency, 0)
+	onPath := make(map[uintptr]bool)
+	for start := range graph {
+		onPath[start] = true
+		d.dfsInitOrder(graph, start, start, &path, onPath)
+		onPath[start] = false
+	}
+}
+
+// dfsInitOrder runs a depth-first search in the lock graph of the init phase
+//  Args:
+//   graph (map[uintptr][]initEdge): lock graph of the init phase
+//   start (uintptr): memory position of the lock at which the search started
+//   current (uintptr): memory position of the current lock
+//   path (*[]*dependency): dependencies of the edges in the current path
+//   onPath (map[uintptr]bool): locks in the current path
+//  Returns:
+//   nil
+func (d *Detector) dfsInitOrder(graph map[uintptr][]initEdge, start uintptr, current uintptr,
+	path *[]*dependency, onPath map[uintptr]bool) {
+	for _, edge := range graph[current] {
+		next := edge.to.getMemoryPosition()
+		if next < start {
+			continue
+		}
+
+		*path = append(*path, edge.dep)
+		if next == start {
+			if isCrossPackage(*path) {
+				cycle := make([]*dependency, len(*path))
+				copy(cycle, *path)
+				d.reportPotentialDeadlockInitOrder(cycle)
+			}
+		} else if !onPath[next] {
+			onPath[next] = true
+			d.dfsInitOrder(graph, start, next, path, onPath)
+			onPath[next] = false
+		}
+		*path = (*path)[:len(*path)-1]
+	}
+}
+
+// check if the acquisitions of a cycle were made by at least two packages
+//  Args:
+//   cycle ([]*dependency): dependencies of the edges of the cycle
+//  Returns:
+//   (bool): true if the cycle was created by different packages
+func isCrossPackage(cycle []*dependency) bool {
+	pkg := filepath.Dir(cycle[0].caller.file())
+	for _, dep := range cycle[1:] {
+		if filepath.Dir(dep.caller.file()) != pkg {
+			return true
+		}
+	}
+	return false
+}
@@ -33,37 +33,83 @@ import (
 	"time"
 )
 
-// global variable to check whether the detector was already initialized
-var initialized = false
-
 // initialize initializes the deadlock detector.
 // This starts the periodical detection.
 //  Returns:
 //   nil
-func initialize() {
-	initialized = true
+func (d *Detector) initialize() {
+	// During the initialization of the packages, the options can not be set
+	// yet. The initialization is therefore postponed until the first lock
+	// operation after the init phase. Locks used in the init phase are
+	// recorded in the default sized routines.
+	if inInitPhase() {
+		return
+	}
+
+	d.initializeLock.Lock()
+	defer d.initializeLock.Unlock()
+	if d.initialized {
+		return
+	}
 
-	// reinitialize routines to set size
-	routines = make([]routine, opts.maxRoutines)
+	d.initialized = true
+
+	// reinitialize routines to set size. Routines which were created during
+	// the init phase are kept
+	d.createRoutineLock.Lock()
+	size := d.opts.MaxRoutines
+	if size < d.numberRoutines {
+		size = d.numberRoutines
+	}
+	initRoutines := d.routines[:d.numberRoutines]
+	d.routines = make([]routine, size)
+	copy(d.routines, initRoutines)
+	d.createRoutineLock.Unlock()
+
+	// publish the dependencies between distributed locks to the broker
+	if d.opts.LockGraphSocket != "" {
+		d.publisher = newLockGraphPublisher(d.opts.LockGraphSocket)
+		d.OnShutdown(d.publisher.close)
+	}
+
+	// append the new dependencies to the delta file
+	if d.opts.DeltaFile != "" {
+		d.deltas = newDeltaWriter(d.opts.DeltaFile, d.opts.DeltaInterval)
+		d.OnShutdown(d.deltas.close)
+	}
 
 	// return if periodical detection is disabled
-	if !opts.periodicDetection {
+	if !d.opts.PeriodicDetection {
 		return
 	}
 
-	// go routine to run the periodical detection in the background
-	go func() {
-		// timer to send a signals at equal intervals
-		timer := time.NewTicker(opts.periodicDetectionTime)
-
-		// initialize lashHolding. This slice stores the dependencies which were
-		// considered in the last detection round, so that the detection only takes
-		// place, if the situation has changed
-		lastHolding := make([]mutexInt, opts.maxRoutines)
-
-		// run the periodical detection if a timer signal is received
-		for range timer.C {
-			periodicalDetection(&lastHolding)
-		}
-	}()
+	// run the periodical detection in the background
+	d.periodicDone = make(chan struct{})
+	d.startPeriodicalDetection()
+}
+
+// run one pass of the periodical detection. Passes are skipped, if they use
+// more than the budget or the detector is paused
+//  Args:
+//   budget (*detectionBudget): budget of the periodical detection, nil if
+//    the time is not limited
+//  Returns:
+//   nil
+func (d *Detector) periodicalPass(budget *detectionBudget) {
+	if d.isPaused() || !budget.allow() {
+		return
+	}
+	start := time.Now()
+
+	d.periodicalWaitForDetection()
+
+	if d.opts.StarvationDetection {
+		d.checkStarvation()
+	}
+
+	if d.opts.LongWaitThreshold > 0 {
+		d.checkLongWaits()
+	}
+
+	budget.spend(time.Since(start))
 }
@@ -0,0 +1,171 @@
+package analyze
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: analyze
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+analyze.go
+This package contains the analyses of the lock-order graph (internal/graph).
+An Analyzer finds the edges of a graph, which can be part of a cycle. The
+detector only searches for cycles in the dependencies of these edges, all
+other dependencies are removed before the search. An edge between two locks
+can only be part of a cycle, if both locks are in the same strongly
+connected component of the graph, which is computed by the SCC analyzer
+with the algorithm of Tarjan.
+*/
+
+import "github.com/ErikKassubek/Deadlock-Go/internal/graph"
+
+// Analyzer finds the edges of a graph, which can be part of a cycle
+type Analyzer interface {
+	// get for each edge of g, whether it can be part of a cycle. An edge,
+	// for which false is returned, must not be part of any cycle of g
+	CycleEdges(g *graph.Graph) []bool
+}
+
+// SCC is an Analyzer, which returns the edges inside the strongly connected
+// components of the graph
+type SCC struct{}
+
+// get the edges, which are inside a strongly connected component. An edge
+// from a node to itself is always returned
+//  Args:
+//   g (*graph.Graph): the graph
+//  Returns:
+//   ([]bool): true for the edges inside a strongly connected component
+func (SCC) CycleEdges(g *graph.Graph) []bool {
+	component := StronglyConnected(g)
+	edges := g.Edges()
+	res := make([]bool, len(edges))
+	for i, e := range edges {
+		res[i] = component[e.From] == component[e.To]
+	}
+	return res
+}
+
+// state of the algorithm of Tarjan
+type tarjan struct {
+	// the graph
+	g *graph.Graph
+	// nodes, which are part of the analyzed subgraph, nil for all nodes
+	allowed func(n int) bool
+	// order in which the nodes were visited, -1 if not visited yet
+	index []int
+	// smallest index of a node on the stack, which is reachable from a node
+	low []int
+	// nodes, whose component is not known yet
+	stack []int
+	// true for the nodes on the stack
+	onStack []bool
+	// component of each node
+	component []int
+	// number of visited nodes
+	visited int
+	// number of found components
+	components int
+}
+
+// compute the strongly connected components of a graph
+//  Args:
+//   g (*graph.Graph): the graph
+//  Returns:
+//   ([]int): component of each node. Two nodes are in the same component,
+//    if they have the same value
+func StronglyConnected(g *graph.Graph) []int {
+	return stronglyConnected(g, nil)
+}
+
+// compute the strongly connected components of the subgraph of some nodes
+//  Args:
+//   g (*graph.Graph): the graph
+//   allowed (func(int) bool): nodes of the subgraph, nil for all nodes
+//  Returns:
+//   ([]int): component of each node, -1 for the nodes, which are not in
+//    the subgraph
+func stronglyConnected(g *graph.Graph, allowed func(n int) bool) []int {
+	n := g.NumNodes()
+	t := tarjan{
+		g:         g,
+		allowed:   allowed,
+		index:     make([]int, n),
+		low:       make([]int, n),
+		onStack:   make([]bool, n),
+		component: make([]int, n),
+	}
+	for i := range t.index {
+		t.index[i] = -1
+	}
+
+	for i := 0; i < n; i++ {
+		if t.allowed != nil && !t.allowed(i) {
+			t.component[i] = -1
+		} else if t.index[i] == -1 {
+			t.visit(i)
+		}
+	}
+	return t.component
+}
+
+// visit a node and all nodes reachable from it, which were not visited yet
+//  Args:
+//   v (int): the node
+//  Returns:
+//   nil
+func (t *tarjan) visit(v int) {
+	t.index[v] = t.visited
+	t.low[v] = t.visited
+	t.visited++
+	t.stack = append(t.stack, v)
+	t.onStack[v] = true
+
+	edges := t.g.Edges()
+	for _, e := range t.g.Out(v) {
+		w := edges[e].To
+		if t.allowed != nil && !t.allowed(w) {
+			continue
+		}
+		if t.index[w] == -1 {
+			t.visit(w)
+			if t.low[w] < t.low[v] {
+				t.low[v] = t.low[w]
+			}
+		} else if t.onStack[w] && t.index[w] < t.low[v] {
+			t.low[v] = t.index[w]
+		}
+	}
+
+	// v is the root of a component, all nodes above v on the stack belong
+	// to the component
+	if t.low[v] == t.index[v] {
+		for {
+			w := t.stack[len(t.stack)-1]
+			t.stack = t.stack[:len(t.stack)-1]
+			t.onStack[w] = false
+			t.component[w] = t.components
+			if w == v {
+				break
+			}
+		}
+		t.components++
+	}
+}
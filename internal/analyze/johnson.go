@@ -0,0 +1,211 @@
+package analyze
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: analyze
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+johnson.go
+Implementation of the enumeration of the elementary cycles of a graph with
+the algorithm of Johnson. The cycles are searched from each node s in the
+strongly connected component of s in the subgraph of the nodes, which are
+not smaller than s, so that each cycle is only found from its smallest
+node. A node, from which no cycle back to s was found, stays blocked until
+one of its successors is unblocked, so that each elementary cycle is found
+exactly once and the time between two found cycles is linear in the size
+of the graph. Edges from a node to itself are not part of the cycles.
+*/
+
+import "github.com/ErikKassubek/Deadlock-Go/internal/graph"
+
+// Enumerator is an Analyzer, which can also enumerate the cycles of a graph
+type Enumerator interface {
+	Analyzer
+	// call found for each elementary cycle of g with the indexes of its
+	// edges in the order of the cycle. The enumeration is stopped, if found
+	// returns false
+	Cycles(g *graph.Graph, found func(cycle []int) bool)
+}
+
+// Johnson is an Enumerator, which uses the algorithm of Johnson
+type Johnson struct {
+	// called before a path of the given number of edges is extended by an
+	// edge. If it returns false, the search is stopped. Nil to never stop
+	Visit func(length int) bool
+	// called before a path of the given number of edges is extended to
+	// this number by an edge, which does not close a cycle. It is not called
+	// for the first edge of a path. If it returns false, the path is not
+	// extended. Nil for no limit
+	Extend func(length int) bool
+}
+
+// get the edges, which can be part of a cycle (see SCC)
+//  Args:
+//   g (*graph.Graph): the graph
+//  Returns:
+//   ([]bool): true for the edges inside a strongly connected component
+func (Johnson) CycleEdges(g *graph.Graph) []bool {
+	return SCC{}.CycleEdges(g)
+}
+
+// state of the search for the cycles of one start node
+type johnsonSearch struct {
+	// options of the search
+	j Johnson
+	// the graph
+	g *graph.Graph
+	// start node of the cycles
+	start int
+	// component of each node, the search only uses the nodes in the
+	// component of start
+	component []int
+	// true for the nodes, which can not reach start at the moment
+	blocked []bool
+	// nodes, which are unblocked, if the node is unblocked
+	b []map[int]bool
+	// edges of the current path
+	path []int
+	// callback for the found cycles
+	found func(cycle []int) bool
+	// true if the search was stopped
+	stopped bool
+}
+
+// enumerate the elementary cycles of a graph
+//  Args:
+//   g (*graph.Graph): the graph
+//   found (func([]int) bool): called for each cycle with the indexes of its
+//    edges, the slice is only valid during the call. If it returns false,
+//    the enumeration is stopped
+//  Returns:
+//   nil
+func (j Johnson) Cycles(g *graph.Graph, found func(cycle []int) bool) {
+	n := g.NumNodes()
+	for s := 0; s < n; s++ {
+		start := s
+		component := stronglyConnected(g, func(v int) bool { return v >= start })
+
+		search := johnsonSearch{
+			j:         j,
+			g:         g,
+			start:     s,
+			component: component,
+			blocked:   make([]bool, n),
+			b:         make([]map[int]bool, n),
+			found:     found,
+		}
+		search.circuit(s)
+		if search.stopped {
+			return
+		}
+	}
+}
+
+// check if an edge is part of the subgraph of the search
+//  Args:
+//   e (graph.Edge): the edge
+//  Returns:
+//   (bool): true if the edge connects two different nodes in the component
+//    of the start node
+func (s *johnsonSearch) inComponent(e graph.Edge) bool {
+	c := s.component[s.start]
+	return e.From != e.To && s.component[e.From] == c && s.component[e.To] == c
+}
+
+// search the cycles through the start node, which extend the current path
+// by a node
+//  Args:
+//   v (int): last node of the current path
+//  Returns:
+//   (bool): true if a cycle was found from v, or the search could not be
+//    continued at v, so that v must not stay blocked
+func (s *johnsonSearch) circuit(v int) bool {
+	res := false
+	s.blocked[v] = true
+
+	edges := s.g.Edges()
+	for _, e := range s.g.Out(v) {
+		if !s.inComponent(edges[e]) {
+			continue
+		}
+		if s.j.Visit != nil && !s.j.Visit(len(s.path)) {
+			s.stopped = true
+			return true
+		}
+
+		w := edges[e].To
+		if w == s.start {
+			s.path = append(s.path, e)
+			if !s.found(s.path) {
+				s.stopped = true
+			}
+			s.path = s.path[:len(s.path)-1]
+			res = true
+		} else if !s.blocked[w] {
+			if len(s.path) > 0 && s.j.Extend != nil && !s.j.Extend(len(s.path)+1) {
+				// the cycles through w are not searched, w must be searched
+				// again from other paths
+				res = true
+				continue
+			}
+			s.path = append(s.path, e)
+			if s.circuit(w) {
+				res = true
+			}
+			s.path = s.path[:len(s.path)-1]
+		}
+		if s.stopped {
+			return true
+		}
+	}
+
+	if res {
+		s.unblock(v)
+	} else {
+		for _, e := range s.g.Out(v) {
+			if !s.inComponent(edges[e]) {
+				continue
+			}
+			w := edges[e].To
+			if s.b[w] == nil {
+				s.b[w] = make(map[int]bool)
+			}
+			s.b[w][v] = true
+		}
+	}
+	return res
+}
+
+// unblock a node and all nodes, which were blocked because of it
+//  Args:
+//   v (int): the node
+//  Returns:
+//   nil
+func (s *johnsonSearch) unblock(v int) {
+	s.blocked[v] = false
+	for w := range s.b[v] {
+		delete(s.b[v], w)
+		if s.blocked[w] {
+			s.unblock(w)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package graph
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: graph
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+graph.go
+This package implements the deduplicated lock-order graph. The nodes of the
+graph are the locks, an edge from a lock a to a lock b means, that a routine
+acquired b while it held a. All dependencies, which create the same edge,
+are combined in this edge, so that the analyses work on the locks instead of
+on the acquisitions. The dependencies are only referenced by the index of
+their routine and their position in the dependencies of the routine, so
+that the graph does not depend on the types of the detector.
+*/
+
+// Dep references a dependency, which created an edge
+type Dep struct {
+	// index of the routine of the dependency
+	Routine int
+	// position of the dependency in the dependencies of the routine
+	Index int
+}
+
+// Edge is an edge of the graph
+type Edge struct {
+	// node of the lock, which was held
+	From int
+	// node of the lock, which was acquired
+	To int
+	// dependencies, which created the edge
+	Deps []Dep
+}
+
+// Graph is a directed graph of the locks. The zero value is not usable,
+// graphs are created with New
+type Graph struct {
+	// key of each node
+	keys []interface{}
+	// node of each key
+	nodes map[interface{}]int
+	// edges of the graph
+	edges []Edge
+	// edge of each pair of nodes
+	edgeOf map[[2]int]int
+	// indexes of the outgoing edges of each node
+	out [][]int
+}
+
+// create a new empty graph
+//  Returns:
+//   (*Graph): the created graph
+func New() *Graph {
+	return &Graph{
+		nodes:  make(map[interface{}]int),
+		edgeOf: make(map[[2]int]int),
+	}
+}
+
+// get the node of a lock. The node is created, if the lock has no node yet
+//  Args:
+//   key (interface{}): key of the lock, equal for two equal locks
+//  Returns:
+//   (int): the node of the lock
+func (g *Graph) Node(key interface{}) int {
+	if n, ok := g.nodes[key]; ok {
+		return n
+	}
+	n := len(g.keys)
+	g.nodes[key] = n
+	g.keys = append(g.keys, key)
+	g.out = append(g.out, nil)
+	return n
+}
+
+// add a dependency to the edge between two locks. The edge is created, if
+// the locks have no edge yet. The edges of a dependency must be added one
+// after another
+//  Args:
+//   from (interface{}): key of the lock, which was held
+//   to (interface{}): key of the lock, which was acquired
+//   dep (Dep): dependency, which created the edge
+//  Returns:
+//   (int): index of the edge
+func (g *Graph) AddEdge(from interface{}, to interface{}, dep Dep) int {
+	f, t := g.Node(from), g.Node(to)
+	e, ok := g.edgeOf[[2]int{f, t}]
+	if !ok {
+		e = len(g.edges)
+		g.edges = append(g.edges, Edge{From: f, To: t})
+		g.edgeOf[[2]int{f, t}] = e
+		g.out[f] = append(g.out[f], e)
+	}
+	// a dependency, which holds a lock more than once, is only added once
+	if deps := g.edges[e].Deps; len(deps) == 0 || deps[len(deps)-1] != dep {
+		g.edges[e].Deps = append(deps, dep)
+	}
+	return e
+}
+
+// get the number of nodes
+//  Returns:
+//   (int): number of nodes
+func (g *Graph) NumNodes() int {
+	return len(g.keys)
+}
+
+// get the key of a node
+//  Args:
+//   n (int): the node
+//  Returns:
+//   (interface{}): the key of the lock of the node
+func (g *Graph) Key(n int) interface{} {
+	return g.keys[n]
+}
+
+// get the edges. The index of an edge in the result is its index in the
+// graph. The result must not be changed
+//  Returns:
+//   ([]Edge): the edges of the graph
+func (g *Graph) Edges() []Edge {
+	return g.edges
+}
+
+// get the outgoing edges of a node. The result must not be changed
+//  Args:
+//   n (int): the node
+//  Returns:
+//   ([]int): indexes of the edges, which start at n
+func (g *Graph) Out(n int) []int {
+	return g.out[n]
+}
@@ -0,0 +1,120 @@
+package record
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: record
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+record.go
+This package contains the types to record the lock events of the routines,
+independent of the locks of the detector. A Recorder saves the events of one
+routine, so that the detector can add them to a report. The Ring keeps the
+last events of a routine in a ring buffer. Other recorders, e.g. recorders
+which write the events to a trace file, implement the same interface and
+can be developed and tested without the detector.
+*/
+
+import (
+	"sync"
+	"time"
+)
+
+// Event is a lock event of a routine
+type Event struct {
+	// kind of the event, e.g. Lock or Unlock
+	Kind string
+	// lock of the event. The recorder does not use the lock, it is only
+	// returned with the event
+	Lock interface{}
+	// file of the event
+	File string
+	// line of the event
+	Line int
+	// time of the event, relative to the creation of the detector
+	Time time.Duration
+}
+
+// Recorder saves the lock events of a routine. The methods can be called
+// concurrently
+type Recorder interface {
+	// save an event
+	Record(e Event)
+	// get the saved events in the order in which they happened
+	Events() []Event
+}
+
+// Ring is a Recorder, which keeps the last events in a ring buffer
+type Ring struct {
+	// saved events
+	events []Event
+	// position of the next event in events
+	next int
+	// true if events was filled at least once
+	full bool
+	// lock to prevent concurrent access to the events
+	lock sync.Mutex
+}
+
+// create a new ring buffer
+//  Args:
+//   size (int): maximum number of saved events, must be positive
+//  Returns:
+//   (*Ring): the created ring buffer
+func NewRing(size int) *Ring {
+	if size <= 0 {
+		panic("record: size of the ring buffer must be positive")
+	}
+	return &Ring{
+		events: make([]Event, size),
+	}
+}
+
+// add an event to the ring buffer. If the buffer is full, the oldest event
+// is overwritten
+//  Args:
+//   e (Event): event to add
+//  Returns:
+//   nil
+func (r *Ring) Record(e Event) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.events[r.next] = e
+	r.next++
+	if r.next == len(r.events) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// get the saved events
+//  Returns:
+//   ([]Event): events in the order in which they happened
+func (r *Ring) Events() []Event {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.full {
+		return append([]Event{}, r.events[:r.next]...)
+	}
+	return append(append([]Event{}, r.events[r.next:]...), r.events[:r.next]...)
+}
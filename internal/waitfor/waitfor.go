@@ -0,0 +1,478 @@
+package waitfor
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: waitfor
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+waitfor.go
+This package implements the wait-for graph of the periodical detection. The
+nodes of the graph are the routines and the resources they synchronize on.
+A hold edge from a resource to a routine means, that the routine currently
+holds the resource, a want edge from a routine to a resource means, that the
+routine is blocked by the resource. Both kinds of edges are maintained
+online by the operations on the resources, the detection only has to search
+for a cycle in the current graph. The hold edges are stored in the nodes of
+the resources, so that holding and releasing a resource does not contend on
+the graph. Resources without a node, e.g. resources whose holders are not
+known, provide the routines they are blocked by when the graph is searched.
+These routines can also be inferred, e.g. from earlier uses of the resource,
+if the routine, which will release the want, is not known. A cycle over an
+inferred edge can be broken by a routine outside of the cycle and is
+therefore never confirmed as a deadlock.
+Routines are only referenced by their index, so that the graph does not
+depend on the types of the detector.
+*/
+
+import (
+	"sort"
+	"sync"
+)
+
+// Node is the node of a resource, which stores the routines holding it.
+// A resource can be held exclusively or shared by multiple routines. The
+// zero value is not usable, nodes are created with NewNode
+type Node struct {
+	// lock to prevent concurrent access to the holds
+	lock sync.Mutex
+	// number of exclusive holds of each routine
+	exclusive map[int]int
+	// number of shared holds of each routine
+	shared map[int]int
+}
+
+// create a new node without holders
+//  Returns:
+//   (*Node): the created node
+func NewNode() *Node {
+	return &Node{
+		exclusive: make(map[int]int),
+		shared:    make(map[int]int),
+	}
+}
+
+// add a hold edge from the node to a routine
+//  Args:
+//   routine (int): index of the routine, which acquired the resource
+//   shared (bool): true if the resource is held shared, e.g. as a reader lock
+//  Returns:
+//   nil
+func (n *Node) Hold(routine int, shared bool) {
+	n.lock.Lock()
+	if shared {
+		n.shared[routine]++
+	} else {
+		n.exclusive[routine]++
+	}
+	n.lock.Unlock()
+}
+
+// remove a hold edge from the node to a routine. An exclusive hold is
+// released before a shared hold. If the routine does not hold the resource,
+// it releases the resource for another routine, which is possible for Go
+// locks. In this case, the hold of the routine with the smallest index is
+// removed
+//  Args:
+//   routine (int): index of the routine, which released the resource
+//  Returns:
+//   nil
+func (n *Node) Release(routine int) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.exclusive[routine] == 0 && n.shared[routine] == 0 {
+		holders := append(sortedKeys(n.exclusive), sortedKeys(n.shared)...)
+		if len(holders) == 0 {
+			return
+		}
+		routine = holders[0]
+	}
+
+	if n.exclusive[routine] > 0 {
+		decrease(n.exclusive, routine)
+	} else {
+		decrease(n.shared, routine)
+	}
+}
+
+// change an exclusive hold of a routine into a shared hold
+//  Args:
+//   routine (int): index of the routine, which downgraded the resource
+//  Returns:
+//   nil
+func (n *Node) Downgrade(routine int) {
+	n.lock.Lock()
+	if n.exclusive[routine] > 0 {
+		decrease(n.exclusive, routine)
+		n.shared[routine]++
+	}
+	n.lock.Unlock()
+}
+
+// get the routines, which block a routine waiting for the resource. A
+// routine waiting for a shared hold is only blocked by exclusive holders
+//  Args:
+//   routine (int): index of the waiting routine, it is never returned
+//   shared (bool): true if the routine waits for a shared hold
+//  Returns:
+//   ([]int): indexes of the blocking routines, sorted
+func (n *Node) Holders(routine int, shared bool) []int {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	res := make([]int, 0)
+	for _, index := range sortedKeys(n.exclusive) {
+		if index != routine {
+			res = append(res, index)
+		}
+	}
+	if !shared {
+		for _, index := range sortedKeys(n.shared) {
+			if index != routine && n.exclusive[index] == 0 {
+				res = append(res, index)
+			}
+		}
+		sort.Ints(res)
+	}
+	return res
+}
+
+// Want is a want edge from a routine to the resource it is blocked by
+type Want struct {
+	// the resource, wants for the same resource must have equal resources
+	Resource interface{}
+	// node of the resource, nil if the holders of the resource are not
+	// stored in the graph
+	Node *Node
+	// true if the routine waits for a shared hold of the resource
+	Shared bool
+	// number of the want, increased with every new want in the graph, so
+	// that a new want of a routine can be distinguished from an old one
+	Seq uint64
+	// information of the detector about the want
+	Data interface{}
+}
+
+// Blockers are the routines, which block a want for a resource without a
+// node
+type Blockers struct {
+	// routines, which block the want until they use the resource
+	Known []int
+	// routines, which are only inferred to block the want, e.g. because they
+	// used the resource before. The want can also be released by another
+	// routine
+	Inferred []int
+	// if true, the want can be released by routines, which are not known.
+	// All routines waiting for other resources are then inferred blockers
+	Unknown bool
+}
+
+// Graph is the wait-for graph. The zero value is not usable, graphs are
+// created with New
+type Graph struct {
+	// lock to prevent concurrent access to the wants
+	lock sync.Mutex
+	// number of wants so far
+	seq uint64
+	// current want of each blocked routine
+	wants map[int]*Want
+	// routines and sequence numbers of the wants of the last cycle found by
+	// Check
+	last map[int]uint64
+	// true if the last cycle was already confirmed
+	confirmed bool
+}
+
+// create a new wait-for graph without wants
+//  Returns:
+//   (*Graph): the created graph
+func New() *Graph {
+	return &Graph{wants: make(map[int]*Want)}
+}
+
+// add a want edge from a routine to a resource. A previous want of the
+// routine is replaced
+//  Args:
+//   routine (int): index of the blocked routine
+//   resource (interface{}): resource the routine is blocked by
+//   node (*Node): node of the resource, nil if the holders are not stored
+//   shared (bool): true if the routine waits for a shared hold
+//   data (interface{}): information of the detector about the want
+//  Returns:
+//   nil
+func (g *Graph) Want(routine int, resource interface{}, node *Node, shared bool,
+	data interface{}) {
+	g.lock.Lock()
+	g.seq++
+	g.wants[routine] = &Want{
+		Resource: resource,
+		Node:     node,
+		Shared:   shared,
+		Seq:      g.seq,
+		Data:     data,
+	}
+	g.lock.Unlock()
+}
+
+// remove the want edge of a routine, after it is no longer blocked
+//  Args:
+//   routine (int): index of the routine
+//  Returns:
+//   nil
+func (g *Graph) Done(routine int) {
+	g.lock.Lock()
+	delete(g.wants, routine)
+	g.lock.Unlock()
+}
+
+// remove all want edges and forget the last cycle
+//  Returns:
+//   nil
+func (g *Graph) Reset() {
+	g.lock.Lock()
+	g.wants = make(map[int]*Want)
+	g.last = nil
+	g.confirmed = false
+	g.lock.Unlock()
+}
+
+// call a function for each want edge in the order of the routines and
+// forget the last cycle, if restart is set, so that a cycle must be found
+// again by two checks. The graph is locked while the function is called,
+// the function can therefore change the data of the wants, but must not
+// use the graph
+//  Args:
+//   restart (bool): if true, the last cycle is forgotten
+//   f (func(int, *Want)): function called with the index of each blocked
+//    routine and its want
+//  Returns:
+//   nil
+func (g *Graph) Range(restart bool, f func(routine int, w *Want)) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if restart {
+		g.last = nil
+		g.confirmed = false
+	}
+	for _, routine := range sortedKeys(g.wants) {
+		f(routine, g.wants[routine])
+	}
+}
+
+// search for a cycle in the graph. A cycle is confirmed, if the previous
+// check found the same cycle with the same wants, i.e. the routines of the
+// cycle were blocked by the same waits during the time between the checks.
+// A cycle is only confirmed once. A routine waiting for a resource without a
+// node is blocked by the routines returned by blockers. A cycle without
+// inferred blockers is preferred. A cycle with an inferred blocker is only a
+// possible deadlock, because another routine can release the wait
+//  Args:
+//   blockers (func(int, *Want) Blockers): get the routines blocking a want
+//    for a resource without a node. It is called while the graph is locked
+//    and must not use the graph
+//  Returns:
+//   ([]int): indexes of the routines in the cycle, nil if no cycle exists
+//   ([]Want): wants of the routines in the cycle
+//   (bool): true if the cycle is confirmed
+//   (bool): true if the cycle contains an inferred blocker
+func (g *Graph) Check(blockers func(routine int, w *Want) Blockers) ([]int, []Want, bool, bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	cycle, inferred := g.findCycle(blockers)
+
+	// check if the cycle has not changed since the last check
+	same := cycle != nil && len(cycle) == len(g.last)
+	current := make(map[int]uint64)
+	wants := make([]Want, 0, len(cycle))
+	for _, routine := range cycle {
+		w := g.wants[routine]
+		current[routine] = w.Seq
+		if s, ok := g.last[routine]; !ok || s != w.Seq {
+			same = false
+		}
+		wants = append(wants, *w)
+	}
+	confirmed := same && !g.confirmed
+	g.confirmed = same
+	g.last = nil
+	if cycle != nil {
+		g.last = current
+	}
+	return cycle, wants, confirmed, inferred
+}
+
+// get the blockers of each blocked routine and search for a cycle. A cycle
+// over the known blockers is searched first, a cycle over the inferred
+// blockers only if no such cycle exists. Must be called while the graph is
+// locked
+//  Args:
+//   blockers (func(int, *Want) Blockers): blockers of wants for resources
+//    without a node
+//  Returns:
+//   ([]int): indexes of the routines in the cycle, nil if no cycle exists
+//   (bool): true if the cycle contains an inferred blocker
+func (g *Graph) findCycle(blockers func(routine int, w *Want) Blockers) ([]int, bool) {
+	routines := sortedKeys(g.wants)
+
+	// build the edges between the routines
+	known := make(map[int][]int)
+	all := make(map[int][]int)
+	for _, routine := range routines {
+		b := g.blockers(routine, routines, blockers)
+		known[routine] = b.Known
+		all[routine] = append(append([]int{}, b.Known...), b.Inferred...)
+	}
+
+	if cycle := searchCycle(routines, known); cycle != nil {
+		return cycle, false
+	}
+	if cycle := searchCycle(routines, all); cycle != nil {
+		return cycle, true
+	}
+	return nil, false
+}
+
+// search for a cycle with a depth-first search
+//  Args:
+//   routines ([]int): indexes of all blocked routines, sorted
+//   edges (map[int][]int): routines each blocked routine waits for
+//  Returns:
+//   ([]int): indexes of the routines in the cycle, nil if no cycle exists
+func searchCycle(routines []int, edges map[int][]int) []int {
+	onPath := make(map[int]bool)
+	done := make(map[int]bool)
+	path := make([]int, 0)
+
+	var dfs func(routine int) []int
+	dfs = func(routine int) []int {
+		onPath[routine] = true
+		path = append(path, routine)
+
+		for _, next := range edges[routine] {
+			if onPath[next] {
+				// extract the cycle from the path
+				for i, p := range path {
+					if p == next {
+						return append([]int{}, path[i:]...)
+					}
+				}
+				continue
+			}
+			if done[next] {
+				continue
+			}
+			if _, ok := edges[next]; !ok {
+				// the routine is not blocked
+				continue
+			}
+			if cycle := dfs(next); cycle != nil {
+				return cycle
+			}
+		}
+
+		onPath[routine] = false
+		path = path[:len(path)-1]
+		done[routine] = true
+		return nil
+	}
+
+	for _, routine := range routines {
+		if done[routine] {
+			continue
+		}
+		if cycle := dfs(routine); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// get the routines a blocked routine waits for. A routine waiting for a
+// shared hold of a node is also blocked by the routines, which started to
+// wait for an exclusive hold of the node before it, like readers of Go's
+// rw-mutexes are blocked by waiting writers. Must be called while the graph
+// is locked
+//  Args:
+//   routine (int): index of the blocked routine
+//   routines ([]int): indexes of all blocked routines, sorted
+//   blockers (func(int, *Want) Blockers): blockers of wants for resources
+//    without a node
+//  Returns:
+//   (Blockers): the blocking routines
+func (g *Graph) blockers(routine int, routines []int,
+	blockers func(routine int, w *Want) Blockers) Blockers {
+	w := g.wants[routine]
+
+	if w.Node == nil {
+		res := blockers(routine, w)
+		if res.Unknown {
+			// all other blocked routines can be the unknown blockers
+			for _, other := range routines {
+				if other != routine && g.wants[other].Resource != w.Resource {
+					res.Inferred = append(res.Inferred, other)
+				}
+			}
+		}
+		return res
+	}
+
+	res := w.Node.Holders(routine, w.Shared)
+	if w.Shared {
+		for _, other := range routines {
+			o := g.wants[other]
+			if other != routine && o.Node == w.Node && !o.Shared && o.Seq < w.Seq {
+				res = append(res, other)
+			}
+		}
+	}
+	return Blockers{Known: res}
+}
+
+// get the keys of a map of routines in increasing order
+//  Args:
+//   m (map[int]V): map with the indexes of routines as keys
+//  Returns:
+//   ([]int): the sorted keys
+func sortedKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// decrease the number of holds of a routine and remove the routine if it
+// holds no more
+//  Args:
+//   m (map[int]int): number of holds of each routine
+//   routine (int): index of the routine
+//  Returns:
+//   nil
+func decrease(m map[int]int, routine int) {
+	m[routine]--
+	if m[routine] <= 0 {
+		delete(m, routine)
+	}
+}
@@ -0,0 +1,162 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+keyedMutex.go
+Implementation of a map of locks with one lock per key, e.g. to lock
+entities by their ids. The lock of a key is created at the first Lock of
+the key and is a lock of the detector, so that two routines, which lock the
+keys of two entities in different orders, are reported as a potential
+deadlock. The lock of a key is removed, when it is neither held nor
+acquired by a routine, so that the map does not grow with the number of
+keys, which were ever used. Locks, which are part of a dependency in the
+lock trees, are kept, because the dependency refers to the lock and a new
+lock for the same key would not be linked to it.
+*/
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Type to implement a map of locks with one lock per key. The zero value
+// is an empty map of the default detector
+type KeyedMutex[K comparable] struct {
+	// detector the locks belong to, nil for the default detector
+	detector *Detector
+	// lock of each key, which is used or part of the lock trees
+	locks map[K]*keyedLock
+	// lock to prevent concurrent access to locks
+	lock sync.Mutex
+}
+
+// lock of a key of a KeyedMutex
+type keyedLock struct {
+	// the lock
+	m *Mutex
+	// number of routines, which hold the lock or want to acquire it
+	refs int
+}
+
+// create a new map of locks of the default detector
+//  Returns:
+//   (*KeyedMutex[K]): the created map
+func NewKeyedMutex[K comparable]() *KeyedMutex[K] {
+	return &KeyedMutex[K]{}
+}
+
+// create a new map of locks, which are checked by a detector
+//  Args:
+//   d (*Detector): detector the locks belong to
+//  Returns:
+//   (*KeyedMutex[K]): the created map
+func NewDetectorKeyedMutex[K comparable](d *Detector) *KeyedMutex[K] {
+	return &KeyedMutex[K]{detector: d}
+}
+
+// get the lock of a key and create it, if it does not exist yet. The
+// creation of the lock is saved as the first Lock of the key
+//  Args:
+//   key (K): the key
+//  Returns:
+//   (*keyedLock): the lock of the key, whose references were incremented
+func (k *KeyedMutex[K]) acquire(key K) *keyedLock {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	if k.locks == nil {
+		k.locks = make(map[K]*keyedLock)
+	}
+
+	l, ok := k.locks[key]
+	if !ok {
+		d := k.detector
+		if d == nil {
+			d = defaultDetector
+		}
+		l = &keyedLock{m: newLock(d, 3)}
+		l.m.SetTag("key", fmt.Sprint(key))
+		k.locks[key] = l
+	}
+	l.refs++
+	return l
+}
+
+// decrement the references of the lock of a key and remove the lock, if it
+// is not used anymore and is not part of the lock trees
+//  Args:
+//   key (K): the key
+//   l (*keyedLock): the lock of the key
+//  Returns:
+//   nil
+func (k *KeyedMutex[K]) release(key K, l *keyedLock) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	l.refs--
+	if l.refs == 0 && !l.m.isInLockTree() {
+		delete(k.locks, key)
+	}
+}
+
+// Lock locks the lock of a key
+//  Args:
+//   key (K): the key
+//  Returns:
+//   nil
+func (k *KeyedMutex[K]) Lock(key K) {
+	k.acquire(key).m.Lock()
+}
+
+// TryLock tries to lock the lock of a key
+//  Args:
+//   key (K): the key
+//  Returns:
+//   (bool): true if locking was successful, false otherwise
+func (k *KeyedMutex[K]) TryLock(key K) bool {
+	l := k.acquire(key)
+	if l.m.TryLock() {
+		return true
+	}
+	k.release(key, l)
+	return false
+}
+
+// Unlock unlocks the lock of a key
+//  Args:
+//   key (K): the key
+//  Returns:
+//   nil
+func (k *KeyedMutex[K]) Unlock(key K) {
+	k.lock.Lock()
+	l, ok := k.locks[key]
+	k.lock.Unlock()
+	if !ok {
+		panic(fmt.Sprint("deadlock: Unlock of the key ", key, ", which is not locked"))
+	}
+
+	l.m.Unlock()
+	k.release(key, l)
+}
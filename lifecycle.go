@@ -0,0 +1,106 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+lifecycle.go
+This file implements the pause of the periodical detection, e.g. while a
+mobile app is in the background. A suspended app does not run, therefore
+waits would seem to last as long as the app was suspended. While the detector
+is paused, no periodical detection is run, and when it is resumed, all
+current waits are restarted, so that no long waits, starvation or deadlocks
+are derived from the time in the background.
+*/
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/ErikKassubek/Deadlock-Go/internal/waitfor"
+)
+
+// Pause pauses the periodical detection of the default detector
+//  Returns:
+//   nil
+func Pause() {
+	defaultDetector.Pause()
+}
+
+// Resume resumes the periodical detection of the default detector
+//  Returns:
+//   nil
+func Resume() {
+	defaultDetector.Resume()
+}
+
+// Pause pauses the periodical detection of the detector, until Resume is
+// called. The lock operations are still recorded
+//  Returns:
+//   nil
+func (d *Detector) Pause() {
+	atomic.StoreInt32(&d.paused, 1)
+}
+
+// Resume resumes the periodical detection of the detector. The current waits
+// are restarted, so that the time while the detector was paused is not
+// counted
+//  Returns:
+//   nil
+func (d *Detector) Resume() {
+	if atomic.LoadInt32(&d.paused) == 0 {
+		return
+	}
+
+	now := time.Now()
+
+	// restart the waits of the wait-for graph. A cycle must be found again
+	// by two passes to be confirmed
+	d.waitGraph.Range(true, func(index int, w *waitfor.Want) {
+		w.Data.(*waitState).start = now
+	})
+
+	// restart the waits for the starvation detection
+	d.waitingRWLocksLock.Lock()
+	infos := make([]*starvationInfo, 0, len(d.waitingRWLocks))
+	for s := range d.waitingRWLocks {
+		infos = append(infos, s)
+	}
+	d.waitingRWLocksLock.Unlock()
+	for _, s := range infos {
+		s.lock.Lock()
+		for w := range s.waiting {
+			w.start = now
+		}
+		s.lock.Unlock()
+	}
+
+	atomic.StoreInt32(&d.paused, 0)
+}
+
+// check if the detector is paused
+//  Returns:
+//   (bool): true if the periodical detection is paused, false otherwise
+func (d *Detector) isPaused() bool {
+	return atomic.LoadInt32(&d.paused) == 1
+}
@@ -0,0 +1,87 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+lockDepth.go
+Implementation of the check of the lock depth. If a routine holds more locks
+at the same time than the configured maximum, a warning with the acquisition
+sites of the held locks is reported. Deep nestings of locks often lead to
+cycles in the lock order, and the check is cheap, because the held locks of
+each routine are already known. Each nesting is only reported once.
+*/
+
+import (
+	"fmt"
+	"strings"
+)
+
+// save the acquisition site of a newly held lock, if the acquisition sites
+// are needed for the check of the lock depth or the declared lock order
+//  Args:
+//   hc (int): position of the new lock in the holding set
+//  Returns:
+//   nil
+func (r *routine) saveHoldingCaller(hc int) {
+	if r.holdingCallers == nil {
+		return
+	}
+
+	frame := userFrame()
+	info := newInfo(frame.File, frame.Line, frame.Function, false, false, "")
+	r.saveHoldingStack(hc, &info)
+	r.holdingCallers[hc] = info
+}
+
+// report a warning if the routine holds more locks than allowed
+//  Args:
+//   hc (int): position of the new lock in the holding set
+//  Returns:
+//   nil
+func (r *routine) checkLockDepth(hc int) {
+	d := r.detector
+	if d.opts.MaxLockDepth <= 0 {
+		return
+	}
+
+	if hc+1 <= d.opts.MaxLockDepth {
+		return
+	}
+
+	// report each nesting only once
+	sites := make([]string, 0, hc+1)
+	for _, c := range r.holdingCallers[:hc+1] {
+		sites = append(sites, fmt.Sprint(c.file(), ":", c.line()))
+	}
+	key := strings.Join(sites, ",")
+
+	d.reportedLockDepthsLock.Lock()
+	reported := d.reportedLockDepths[key]
+	d.reportedLockDepths[key] = true
+	d.reportedLockDepthsLock.Unlock()
+
+	if !reported {
+		d.reportLockDepth(r.holdingSet[:hc+1], r.holdingCallers[:hc+1])
+	}
+}
@@ -0,0 +1,189 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+lockGraph.go
+This file implements the publication of the dependencies between distributed
+locks, e.g. file locks shared by multiple processes, to a broker
+(cmd/deadlock-broker) over a unix socket. Each process only knows its own
+dependencies, the broker combines the dependencies of all processes and
+reports cycles, which contain dependencies of different processes. Only
+dependencies between locks modeled with DistributedLock are published,
+because only they have the same identity in all processes.
+*/
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"sync"
+)
+
+// number of dependencies, which can wait for their publication
+const lockGraphBufferSize = 1024
+
+// LockGraphEdge is a dependency between two distributed locks, which is
+// published to the broker. The routine acquired To while it held From
+type LockGraphEdge struct {
+	// schema version of the edge
+	Schema int
+	// process id of the process, in which the dependency was created
+	Process int
+	// name of the lock, which was held
+	From string
+	// name of the lock, which was acquired
+	To string
+	// site of the acquisition of To
+	Site Site
+}
+
+// type to publish the dependencies between distributed locks to the broker
+type lockGraphPublisher struct {
+	// path of the unix socket of the broker
+	socket string
+	// dependencies, which were not sent yet
+	edges chan LockGraphEdge
+	// names of the locks of the dependencies, which were already published
+	published map[[2]string]bool
+	// lock to prevent concurrent access to published
+	lock sync.Mutex
+	// closed when all dependencies were sent
+	done chan struct{}
+}
+
+// create a new publisher and start to send the dependencies in the
+// background
+//  Args:
+//   socket (string): path of the unix socket of the broker
+//  Returns:
+//   (*lockGraphPublisher): the publisher
+func newLockGraphPublisher(socket string) *lockGraphPublisher {
+	p := &lockGraphPublisher{
+		socket:    socket,
+		edges:     make(chan LockGraphEdge, lockGraphBufferSize),
+		published: make(map[[2]string]bool),
+		done:      make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// publish a dependency, if it was not published before. If too many
+// dependencies wait for their publication, the dependency is dropped and
+// published with its next acquisition
+//  Args:
+//   e (LockGraphEdge): the dependency
+//  Returns:
+//   nil
+func (p *lockGraphPublisher) publish(e LockGraphEdge) {
+	key := [2]string{e.From, e.To}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.published[key] {
+		return
+	}
+
+	select {
+	case p.edges <- e:
+		p.published[key] = true
+	default:
+	}
+}
+
+// send the dependencies to the broker. If the broker can not be reached,
+// the connection is retried with the next dependency and all dependencies
+// are sent again
+//  Returns:
+//   nil
+func (p *lockGraphPublisher) run() {
+	defer close(p.done)
+
+	var conn net.Conn
+	sent := make([]LockGraphEdge, 0)
+	for e := range p.edges {
+		sent = append(sent, e)
+
+		pending := []LockGraphEdge{e}
+		if conn == nil {
+			c, err := net.Dial("unix", p.socket)
+			if err != nil {
+				continue
+			}
+			conn = c
+			pending = sent
+		}
+
+		enc := json.NewEncoder(conn)
+		for _, edge := range pending {
+			if err := enc.Encode(edge); err != nil {
+				conn.Close()
+				conn = nil
+				break
+			}
+		}
+	}
+
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+// stop the publisher after all waiting dependencies were sent
+//  Returns:
+//   (error): nil
+func (p *lockGraphPublisher) close() error {
+	p.lock.Lock()
+	close(p.edges)
+	p.lock.Unlock()
+
+	<-p.done
+	return nil
+}
+
+// publish the dependencies of a new dependency of a distributed lock on
+// the distributed locks held by the routine
+//  Args:
+//   dep (*dependency): the new dependency
+//  Returns:
+//   nil
+func (d *Detector) publishDependency(dep *dependency) {
+	if d.publisher == nil || !isModeled(dep.mu) {
+		return
+	}
+
+	for i := 0; i < dep.holdingCount; i++ {
+		if !isModeled(dep.holdingSet[i]) {
+			continue
+		}
+		d.publisher.publish(LockGraphEdge{
+			Schema:  SchemaVersion,
+			Process: os.Getpid(),
+			From:    modelName(dep.holdingSet[i]),
+			To:      modelName(dep.mu),
+			Site:    newSite(dep.caller),
+		})
+	}
+}
@@ -0,0 +1,538 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+lockOrder.go
+Implementation of the export of the observed lock order. Each dependency in
+the lock trees of the routines is an edge from a held lock to the lock which
+was acquired while it was held. The edges of all routines are combined and
+written as a DOT graph, as Markdown tables or as JSON, so that teams get a
+reference of the locking order, which is generated from the real behavior of
+the program. The tags of the locks are added to the locks of the graph and
+the tables.
+Each edge is annotated with whether its locks were observed concurrently,
+i.e. whether another routine used one of the locks while the edge was
+created, so that external tools can filter edges of phases of the program,
+which never overlap. The detector does not record a happens-before relation,
+the annotation is based on the times of the first and last acquisitions of
+the dependencies.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LockOrderFormat describes the format of the exported lock order
+type LockOrderFormat int
+
+const (
+	// LockOrderDOT writes the lock order as a graph in the DOT language
+	LockOrderDOT LockOrderFormat = iota
+	// LockOrderMarkdown writes the lock order as Markdown tables
+	LockOrderMarkdown
+	// LockOrderJSON writes the lock order as a JSON object
+	LockOrderJSON
+)
+
+// lock of the lock order
+type lockOrderNode struct {
+	// name of the resource type of the lock
+	resource string
+	// formatted tags of the lock, empty if the lock has no tags
+	tags string
+}
+
+// edge of the lock order
+type lockOrderEdge struct {
+	// name of the lock, which was held
+	from string
+	// name of the lock, which was acquired
+	to string
+	// acquisition sites of to while from was held
+	sites map[string]bool
+	// indexes of the routines, which created the edge
+	routines map[int]bool
+	// identities of the routines, which created the edge, ordered by their
+	// indexes
+	goroutines []Goroutine
+	// number of acquisitions, which created the edge
+	count int
+	// wall clock times of the first and last acquisition, which created
+	// the edge
+	first time.Time
+	last  time.Time
+	// times since the start of the detector of the first and last
+	// acquisition, which created the edge, by routine
+	spans map[int]lockOrderSpan
+	// true if another routine used one of the locks of the edge, while the
+	// edge was created
+	concurrent bool
+}
+
+// times since the start of the detector of the first and last use of a
+// lock or an edge by a routine
+type lockOrderSpan struct {
+	first time.Duration
+	last  time.Duration
+}
+
+// extend the span by the times of a dependency
+//  Args:
+//   dep (*dependency): dependency to add
+//  Returns:
+//   nil
+func (s *lockOrderSpan) add(dep *dependency) {
+	if s.first == 0 || dep.first < s.first {
+		s.first = dep.first
+	}
+	if last := dep.lastSeen(); last > s.last {
+		s.last = last
+	}
+}
+
+// check if two spans overlap
+//  Args:
+//   o (lockOrderSpan): other span
+//  Returns:
+//   (bool): true if the spans overlap
+func (s lockOrderSpan) overlaps(o lockOrderSpan) bool {
+	return s.first <= o.last && o.first <= s.last
+}
+
+// WriteLockOrder writes the lock order observed by the default detector
+//  Args:
+//   w (io.Writer): writer to write to
+//   format (LockOrderFormat): LockOrderDOT, LockOrderMarkdown or LockOrderJSON
+//  Returns:
+//   (error): error if the lock order could not be written
+func WriteLockOrder(w io.Writer, format LockOrderFormat) error {
+	return defaultDetector.WriteLockOrder(w, format)
+}
+
+// WriteLockOrder writes the lock order observed by the detector
+//  Args:
+//   w (io.Writer): writer to write to
+//   format (LockOrderFormat): LockOrderDOT, LockOrderMarkdown or LockOrderJSON
+//  Returns:
+//   (error): error if the lock order could not be written
+func (d *Detector) WriteLockOrder(w io.Writer, format LockOrderFormat) error {
+	locks, edges := d.lockOrder()
+
+	switch format {
+	case LockOrderDOT:
+		return writeLockOrderDOT(w, locks, edges)
+	case LockOrderMarkdown:
+		return writeLockOrderMarkdown(w, locks, edges)
+	case LockOrderJSON:
+		return writeLockOrderJSON(w, locks, edges)
+	}
+	return fmt.Errorf("deadlock: unknown lock order format %d", format)
+}
+
+// get the creation site of a lock
+//  Args:
+//   m (mutexInt): lock
+//  Returns:
+//   (string): creation site of the lock
+func lockSite(m mutexInt) string {
+	context := *m.getContext()
+	return fmt.Sprint(workspacePath(context[0].file()), ":", context[0].line())
+}
+
+// get the names of the locks in the lock trees of all routines
+//  Args:
+//   trees ([][]*dependency): dependencies of each routine
+//  Returns:
+//   (map[mutexInt]string): names of the locks
+func lockNames(trees [][]*dependency) map[mutexInt]string {
+	seen := make(map[mutexInt]bool)
+	for _, deps := range trees {
+		for _, dep := range deps {
+			if dep.mu != nil {
+				seen[dep.mu] = true
+			}
+			for k := 0; k < dep.holdingCount; k++ {
+				if dep.holdingSet[k] != nil {
+					seen[dep.holdingSet[k]] = true
+				}
+			}
+		}
+	}
+	return siteNames(seen)
+}
+
+// name locks by their creation sites. If multiple locks were created at the
+// same site, their names are numbered in the order of their memory positions
+//  Args:
+//   locks (map[mutexInt]bool): locks to name
+//  Returns:
+//   (map[mutexInt]string): names of the locks
+func siteNames(locks map[mutexInt]bool) map[mutexInt]string {
+	sites := make(map[string][]mutexInt)
+	for m := range locks {
+		site := lockSite(m)
+		sites[site] = append(sites[site], m)
+	}
+
+	names := make(map[mutexInt]string, len(locks))
+	for site, locks := range sites {
+		if len(locks) == 1 {
+			names[locks[0]] = site
+			continue
+		}
+		sort.Slice(locks, func(i, j int) bool {
+			return locks[i].getMemoryPosition() < locks[j].getMemoryPosition()
+		})
+		for i, m := range locks {
+			names[m] = fmt.Sprint(site, "#", i+1)
+		}
+	}
+	return names
+}
+
+// collect the edges of the lock order from the lock trees of all routines
+//  Returns:
+//   (map[string]lockOrderNode): names of the locks in the lock order, mapped
+//    to their resource names and tags
+//   ([]*lockOrderEdge): edges sorted by their locks
+func (d *Detector) lockOrder() (map[string]lockOrderNode, []*lockOrderEdge) {
+	trees := d.takeSnapshot().dependencies
+	names := lockNames(trees)
+	locks := make(map[string]lockOrderNode, len(names))
+	for m, name := range names {
+		locks[name] = lockOrderNode{
+			resource: m.getResourceName(),
+			tags:     formatTags(copyTags(m)),
+		}
+	}
+	edges := make(map[[2]string]*lockOrderEdge)
+
+	// spans in which the routines used the locks, by lock and routine
+	uses := make(map[string]map[int]lockOrderSpan)
+	use := func(name string, index int, dep *dependency) {
+		if uses[name] == nil {
+			uses[name] = make(map[int]lockOrderSpan)
+		}
+		span := uses[name][index]
+		span.add(dep)
+		uses[name][index] = span
+	}
+
+	for i, deps := range trees {
+		for _, dep := range deps {
+			to := names[dep.mu]
+			use(to, i, dep)
+
+			for k := 0; k < dep.holdingCount; k++ {
+				held := dep.holdingSet[k]
+				if held == nil || held == dep.mu {
+					continue
+				}
+				from := names[held]
+				use(from, i, dep)
+
+				edge, ok := edges[[2]string{from, to}]
+				if !ok {
+					edge = &lockOrderEdge{
+						from:     from,
+						to:       to,
+						sites:    make(map[string]bool),
+						routines: make(map[int]bool),
+						spans:    make(map[int]lockOrderSpan),
+					}
+					edges[[2]string{from, to}] = edge
+				}
+				if dep.caller.file() != "" {
+					edge.sites[fmt.Sprint(workspacePath(dep.caller.file()), ":",
+						dep.caller.line())] = true
+				}
+				first, last := d.start.Add(dep.first), d.start.Add(dep.lastSeen())
+				if edge.count == 0 || first.Before(edge.first) {
+					edge.first = first
+				}
+				if last.After(edge.last) {
+					edge.last = last
+				}
+				edge.routines[i] = true
+				edge.count += dep.observed()
+				span := edge.spans[i]
+				span.add(dep)
+				edge.spans[i] = span
+			}
+		}
+	}
+
+	res := make([]*lockOrderEdge, 0, len(edges))
+	for _, edge := range edges {
+		edge.concurrent = isConcurrentEdge(edge, uses)
+		indexes := make([]int, 0, len(edge.routines))
+		for index := range edge.routines {
+			indexes = append(indexes, index)
+		}
+		sort.Ints(indexes)
+		edge.goroutines = d.goroutines(indexes)
+		res = append(res, edge)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].from != res[j].from {
+			return res[i].from < res[j].from
+		}
+		return res[i].to < res[j].to
+	})
+	return locks, res
+}
+
+// check if another routine used one of the locks of an edge, while a
+// routine created the edge
+//  Args:
+//   edge (*lockOrderEdge): edge to check
+//   uses (map[string]map[int]lockOrderSpan): spans in which the routines
+//    used the locks, by lock and routine
+//  Returns:
+//   (bool): true if the locks of the edge were observed concurrently
+func isConcurrentEdge(edge *lockOrderEdge, uses map[string]map[int]lockOrderSpan) bool {
+	for index, span := range edge.spans {
+		for _, name := range []string{edge.from, edge.to} {
+			for other, otherSpan := range uses[name] {
+				if other != index && span.overlaps(otherSpan) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// get the sorted keys of a set
+//  Args:
+//   set (map[string]bool): set
+//  Returns:
+//   ([]string): sorted elements of the set
+func sortedKeys(set map[string]bool) []string {
+	res := make([]string, 0, len(set))
+	for key := range set {
+		res = append(res, key)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// get the identities of the goroutines of an edge for the labels of the
+// graph and the tables. Only the first goroutines are shown
+//  Args:
+//   goroutines ([]Goroutine): goroutines of the edge
+//  Returns:
+//   ([]string): identities of the first goroutines
+func goroutineLabels(goroutines []Goroutine) []string {
+	const shown = 3
+	res := make([]string, 0, shown+1)
+	for i, g := range goroutines {
+		if i == shown {
+			res = append(res, fmt.Sprint("and ", len(goroutines)-shown, " more goroutines"))
+			break
+		}
+		res = append(res, g.String())
+	}
+	return res
+}
+
+// write the lock order as a graph in the DOT language
+//  Args:
+//   w (io.Writer): writer to write to
+//   locks (map[string]lockOrderNode): names, resource names and tags of the locks
+//   edges ([]*lockOrderEdge): edges of the lock order
+//  Returns:
+//   (error): error if the graph could not be written
+func writeLockOrderDOT(w io.Writer, locks map[string]lockOrderNode, edges []*lockOrderEdge) error {
+	var b strings.Builder
+	b.WriteString("digraph lockorder {\n")
+	b.WriteString("\tnode [shape=box];\n")
+
+	names := make([]string, 0, len(locks))
+	for name := range locks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		label := locks[name].resource + "\n" + name
+		if locks[name].tags != "" {
+			label += "\n" + locks[name].tags
+		}
+		fmt.Fprintf(&b, "\t%q [label=%q];\n", name, label)
+	}
+
+	for _, edge := range edges {
+		label := append(sortedKeys(edge.sites), fmt.Sprint("observed ", edge.count, "x"),
+			"first "+edge.first.Format(time.RFC3339), "last "+edge.last.Format(time.RFC3339))
+		label = append(label, goroutineLabels(edge.goroutines)...)
+		// edges, whose locks were never used concurrently, are dashed
+		style := "dashed"
+		if edge.concurrent {
+			label = append(label, "concurrent")
+			style = "solid"
+		}
+		fmt.Fprintf(&b, "\t%q -> %q [label=%q, style=%s, concurrent=%t];\n", edge.from,
+			edge.to, strings.Join(label, "\n"), style, edge.concurrent)
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// write the lock order as Markdown tables
+//  Args:
+//   w (io.Writer): writer to write to
+//   locks (map[string]lockOrderNode): names, resource names and tags of the locks
+//   edges ([]*lockOrderEdge): edges of the lock order
+//  Returns:
+//   (error): error if the tables could not be written
+func writeLockOrderMarkdown(w io.Writer, locks map[string]lockOrderNode, edges []*lockOrderEdge) error {
+	var b strings.Builder
+	b.WriteString("# Observed lock order\n\n")
+
+	b.WriteString("## Locks\n\n")
+	b.WriteString("| Lock | Type | Tags |\n|---|---|---|\n")
+	names := make([]string, 0, len(locks))
+	for name := range locks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "| `%s` | %s | %s |\n", name, locks[name].resource,
+			strings.ReplaceAll(locks[name].tags, "|", "\\|"))
+	}
+
+	b.WriteString("\n## Order\n\n")
+	b.WriteString("A lock in the first column was held, while the lock in the " +
+		"second column was acquired.\n\n")
+	b.WriteString("| Held lock | Acquired lock | Acquisition sites | Goroutines | Observed " +
+		"| First seen | Last seen | Concurrent |\n")
+	b.WriteString("|---|---|---|---|---|---|---|---|\n")
+	for _, edge := range edges {
+		sites := sortedKeys(edge.sites)
+		for i := range sites {
+			sites[i] = "`" + sites[i] + "`"
+		}
+		concurrent := "no"
+		if edge.concurrent {
+			concurrent = "yes"
+		}
+		goroutines := goroutineLabels(edge.goroutines)
+		for i := range goroutines {
+			goroutines[i] = strings.ReplaceAll(goroutines[i], "|", "\\|")
+		}
+		fmt.Fprintf(&b, "| `%s` | `%s` | %s | %s | %d | %s | %s | %s |\n", edge.from,
+			edge.to, strings.Join(sites, "<br>"), strings.Join(goroutines, "<br>"),
+			edge.count, edge.first.Format(time.RFC3339), edge.last.Format(time.RFC3339),
+			concurrent)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// lock of the lock order in the JSON format
+type lockOrderJSONLock struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Tags string `json:"tags,omitempty"`
+}
+
+// edge of the lock order in the JSON format
+type lockOrderJSONEdge struct {
+	From       string      `json:"from"`
+	To         string      `json:"to"`
+	Sites      []string    `json:"sites"`
+	Routines   int         `json:"routines"`
+	Goroutines []Goroutine `json:"goroutines,omitempty"`
+	Count      int         `json:"count"`
+	First      time.Time   `json:"first"`
+	Last       time.Time   `json:"last"`
+	Concurrent bool        `json:"concurrent"`
+}
+
+// write the lock order as a JSON object with the locks and the edges
+//  Args:
+//   w (io.Writer): writer to write to
+//   locks (map[string]lockOrderNode): names, resource names and tags of the locks
+//   edges ([]*lockOrderEdge): edges of the lock order
+//  Returns:
+//   (error): error if the object could not be written
+func writeLockOrderJSON(w io.Writer, locks map[string]lockOrderNode, edges []*lockOrderEdge) error {
+	var order struct {
+		Schema int                 `json:"schema"`
+		Locks  []lockOrderJSONLock `json:"locks"`
+		Edges  []lockOrderJSONEdge `json:"edges"`
+	}
+	order.Schema = SchemaVersion
+
+	names := make([]string, 0, len(locks))
+	for name := range locks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	order.Locks = make([]lockOrderJSONLock, 0, len(names))
+	for _, name := range names {
+		order.Locks = append(order.Locks, lockOrderJSONLock{
+			Name: name,
+			Type: locks[name].resource,
+			Tags: locks[name].tags,
+		})
+	}
+
+	order.Edges = make([]lockOrderJSONEdge, 0, len(edges))
+	for _, edge := range edges {
+		order.Edges = append(order.Edges, newLockOrderJSONEdge(edge))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(order)
+}
+
+// convert an edge of the lock order into the JSON format
+//  Args:
+//   edge (*lockOrderEdge): edge of the lock order
+//  Returns:
+//   (lockOrderJSONEdge): the edge in the JSON format
+func newLockOrderJSONEdge(edge *lockOrderEdge) lockOrderJSONEdge {
+	return lockOrderJSONEdge{
+		From:       edge.from,
+		To:         edge.to,
+		Sites:      sortedKeys(edge.sites),
+		Routines:   len(edge.routines),
+		Goroutines: edge.goroutines,
+		Count:      edge.count,
+		First:      edge.first,
+		Last:       edge.last,
+		Concurrent: edge.concurrent,
+	}
+}
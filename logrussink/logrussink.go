@@ -0,0 +1,82 @@
+package logrussink
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: logrussink
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+logrussink.go
+This package implements a report sink on top of logrus. The reports are
+logged with structured fields, so that they can be delivered by the existing
+logging pipeline of a service. The package does not import logrus, the sink
+accepts every entry with the methods of *logrus.Entry used by it, e.g.
+logrus.NewEntry(logger).
+*/
+
+import (
+	"fmt"
+
+	deadlock "github.com/ErikKassubek/Deadlock-Go"
+)
+
+// Entry is the part of *logrus.Entry used by the sink
+type Entry[E any] interface {
+	WithField(key string, value interface{}) E
+	Error(args ...interface{})
+	Warn(args ...interface{})
+	Info(args ...interface{})
+}
+
+// New creates a sink, which logs the reports with entry. Deadlocks are logged
+// as errors, potential deadlocks as warnings and warnings as infos
+//  Args:
+//   entry (E): entry for the reports, e.g. logrus.NewEntry(logger)
+//  Returns:
+//   (deadlock.ReportSink): the created sink
+func New[E Entry[E]](entry E) deadlock.ReportSink {
+	return func(r deadlock.Report) {
+		sites := make([]string, 0, len(r.Sites))
+		for _, site := range r.Sites {
+			sites = append(sites, fmt.Sprint(site.File, ":", site.Line))
+		}
+
+		e := entry.WithField("type", r.Type.String()).
+			WithField("title", r.Title).
+			WithField("sites", sites).
+			WithField("report", r.Text)
+		if r.ID != "" {
+			e = e.WithField("cycle_id", r.ID)
+		}
+		if tags := r.LockTags(); tags != nil {
+			e = e.WithField("lock_tags", tags)
+		}
+
+		switch r.Type {
+		case deadlock.ReportDeadlock:
+			e.Error(r.Title)
+		case deadlock.ReportPotentialDeadlock:
+			e.Warn(r.Title)
+		default:
+			e.Info(r.Title)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+longWait.go
+Implementation of the report of long waits. If a routine waits for a lock
+longer than a given threshold, the wait is reported together with the
+routines which currently hold the lock, the sites where they acquired it,
+how long they hold it and their current call stacks. The holders are also
+added to the reports of starving routines.
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/ErikKassubek/Deadlock-Go/internal/waitfor"
+)
+
+// save the acquisition of a lock by a routine, so that the holder can be
+// shown in the reports of long waits and starvation and the release can be
+// paired with the acquisition
+//  Args:
+//   m (mutexInt): acquired lock
+//   index (int): index of the routine which acquired the lock
+//  Returns:
+//   nil
+func (d *Detector) addHolder(m mutexInt, index int) {
+	if d.opts.LongWaitThreshold <= 0 && !d.opts.StarvationDetection &&
+		!d.opts.CriticalSections {
+		return
+	}
+
+	frame := userFrame()
+	info := newInfo(frame.File, frame.Line, frame.Function, false, false, "")
+	info.timestamp = time.Since(d.start)
+
+	m.getIsLockedRoutineIndexLock().Lock()
+	(*m.getHolders())[index] = info
+	m.getIsLockedRoutineIndexLock().Unlock()
+}
+
+// report all waits for locks, which are longer than the threshold. Each
+// wait is only reported once
+//  Returns:
+//   nil
+func (d *Detector) checkLongWaits() {
+	type longWait struct {
+		index int
+		lock  mutexInt
+		state waitState
+	}
+
+	waits := make([]longWait, 0)
+	d.waitGraph.Range(false, func(index int, w *waitfor.Want) {
+		state := w.Data.(*waitState)
+		m, ok := state.resource.(mutexInt)
+		if !ok || state.reported || time.Since(state.start) < d.opts.LongWaitThreshold {
+			return
+		}
+		state.reported = true
+		waits = append(waits, longWait{index: index, lock: m, state: *state})
+	})
+
+	for _, w := range waits {
+		d.reportLongWait(w.lock, w.index, w.state)
+	}
+}
+
+// get the internal id of a routine
+//  Args:
+//   index (int): index of the routine
+//  Returns:
+//   (int64): internal id of the routine
+//   (bool): false if the routine does not exist
+func (d *Detector) routineID(index int) (int64, bool) {
+	d.createRoutineLock.Lock()
+	defer d.createRoutineLock.Unlock()
+
+	for id, i := range d.mapIndex {
+		if i == index {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// get the current call stack of a routine
+//  Args:
+//   id (int64): internal id of the routine
+//  Returns:
+//   (string): call stack of the routine, empty if the routine does not exist
+func goroutineStack(id int64) string {
+	prefix := fmt.Sprintf("goroutine %d [", id)
+	for _, stack := range strings.Split(allGoroutineStacks(), "\n\n") {
+		if strings.HasPrefix(stack, prefix) {
+			return stack + "\n"
+		}
+	}
+	return ""
+}
+
+// get the current call stacks of all goroutines
+//  Returns:
+//   (string): call stacks of all goroutines, separated by empty lines
+func allGoroutineStacks() string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// write the routines, which currently hold a lock
+//  Args:
+//   out (*bytes.Buffer): buffer to write to
+//   m (mutexInt): lock
+//   exclude (int): index of a routine, which is not written, e.g. the
+//    waiting routine
+//  Returns:
+//   nil
+func (d *Detector) writeHolders(out *bytes.Buffer, m mutexInt, exclude int) {
+	m.getIsLockedRoutineIndexLock().Lock()
+	holders := make(map[int]callerInfo, len(*m.getHolders()))
+	for index, info := range *m.getHolders() {
+		if index != exclude {
+			holders[index] = info
+		}
+	}
+	m.getIsLockedRoutineIndexLock().Unlock()
+
+	fmt.Fprintf(out, purple, "Routines holding the lock:\n\n")
+	if len(holders) == 0 {
+		fmt.Fprintln(out, "unknown")
+		fmt.Fprintln(out, "")
+		return
+	}
+
+	now := time.Since(d.start)
+	for index, info := range holders {
+		fmt.Fprintf(out, blue, fmt.Sprint(d.goroutine(index).sentence(),
+			" holds the lock for ", (now-info.timestamp).Round(time.Millisecond),
+			", acquired at:"))
+		fmt.Fprintf(out, "\n")
+		fmt.Fprintln(out, info.file(), info.line())
+		if id, ok := d.routineID(index); ok {
+			if stack := goroutineStack(id); stack != "" {
+				fmt.Fprintln(out, "")
+				fmt.Fprint(out, stack)
+			}
+		}
+		fmt.Fprintln(out, "")
+	}
+}
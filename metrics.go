@@ -0,0 +1,137 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+metrics.go
+This file implements the quality metrics of the comprehensive detection. The
+search counts the candidates, which it does not extend or report, by the rule
+which rejected them, so that it can be understood why a cycle was or was not
+reported and the options can be tuned accordingly. The detector does not
+record a happens-before relation between routines; the only happens-before
+rule of the search is the program order of a routine, i.e. a chain contains
+at most one dependency of each routine. The metrics are part of the summary
+of each detection and are reported after the detection with VerbosityDebug.
+*/
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Pruning contains the number of candidates, which were rejected by each
+// rule of the comprehensive detection
+type Pruning struct {
+	// candidate chains, which were not extended, because two of their
+	// dependencies hold the same lock (gate lock), and upgrades of the same
+	// rw-lock, which are protected by a gate lock
+	GateLock int `json:"gate_lock"`
+	// candidate chains, which were not extended or closed, because their
+	// links are reader locks of a rw-lock, on which no writer can be
+	// queued or which allows recursive reader locks
+	RWLock int `json:"rw_lock"`
+	// candidate dependencies, which were not added to a chain, because the
+	// chain already contains a dependency of their routine, which is ordered
+	// with them by the program order
+	HappensBefore int `json:"happens_before"`
+	// cycles, which were not reported, because they contain a shorter cycle,
+	// which is reported by itself, and reports, which repeat a report of the
+	// same detection
+	Dedup int `json:"dedup"`
+	// reports, which were not reported, because their cycle is in the
+	// baseline
+	Baseline int `json:"baseline"`
+}
+
+// rule, which rejects a candidate chain
+type pruneRule int
+
+const (
+	// the candidate is valid
+	pruneNone pruneRule = iota
+	// the candidate is not a chain, e.g. because the dependencies are not
+	// linked. Those candidates are not counted
+	pruneNoChain
+	// the candidate contains a gate lock
+	pruneGateLock
+	// the link of the candidate consists of reader locks only
+	pruneRWLock
+	// the candidate contains two dependencies of the same routine
+	pruneHappensBefore
+	// the candidate repeats a report
+	pruneDedup
+)
+
+// count candidates rejected by a rule
+//  Args:
+//   rule (pruneRule): the rule, pruneNone and pruneNoChain are not counted
+//   number (int): number of rejected candidates
+//  Returns:
+//   nil
+func (t *progressTracker) prune(rule pruneRule, number int) {
+	if t == nil {
+		return
+	}
+	switch rule {
+	case pruneGateLock:
+		t.pruning.GateLock += number
+	case pruneRWLock:
+		t.pruning.RWLock += number
+	case pruneHappensBefore:
+		t.pruning.HappensBefore += number
+	case pruneDedup:
+		t.pruning.Dedup += number
+	}
+}
+
+// report the metrics of a detection, if the verbosity is VerbosityDebug
+//  Args:
+//   s (Summary): summary of the detection
+//  Returns:
+//   nil
+func (d *Detector) reportMetrics(s Summary) {
+	if d.opts.Verbosity != VerbosityDebug {
+		return
+	}
+
+	out := &bytes.Buffer{}
+	fmt.Fprintf(out, purple, "Candidates of the detection:\n\n")
+	fmt.Fprintln(out, "Unique dependencies:", s.UniqueDependencies)
+	fmt.Fprintln(out, "Dependencies pruned before the search:", s.DependenciesPruned)
+	fmt.Fprintln(out, "Chains explored:", s.ChainsExplored)
+	fmt.Fprintln(out, "Cycles reported:", s.Cycles)
+	fmt.Fprintf(out, purple, "\nCandidates rejected by rule:\n\n")
+	fmt.Fprintln(out, "Gate lock:", s.Pruning.GateLock)
+	fmt.Fprintln(out, "Reader locks (rw-lock rules):", s.Pruning.RWLock)
+	fmt.Fprintln(out, "Program order (happens-before):", s.Pruning.HappensBefore)
+	fmt.Fprintln(out, "Duplicates and cycles with shorter cycles:", s.Pruning.Dedup)
+	fmt.Fprintln(out, "In the baseline:", s.Pruning.Baseline)
+	fmt.Fprintf(out, "\n")
+
+	d.report(Report{
+		Type:  ReportWarning,
+		Title: "DETECTION METRICS",
+		info:  true,
+	}, out)
+}
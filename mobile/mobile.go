@@ -0,0 +1,99 @@
+package mobile
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: mobile
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+mobile.go
+This package connects the detector to apps built with gomobile. In an app,
+the program can not be terminated and stdout and stderr are not visible,
+therefore the reports are delivered to a Reporter, which is implemented in
+the binding layer (Java, Kotlin, Objective-C or Swift), and the program is
+not terminated after a deadlock. All functions only use types, which are
+supported by gomobile bind. The lifecycle functions are called by the app,
+when it is moved to the background or foreground.
+*/
+
+import (
+	"fmt"
+	"strings"
+
+	deadlock "github.com/ErikKassubek/Deadlock-Go"
+)
+
+// Reporter receives the reports of the detector. It is implemented by the
+// binding layer of the app
+type Reporter interface {
+	// Report is called for every report.
+	// kind is "deadlock", "potential deadlock" or "warning", sites contains
+	// the sites of the report as file:line, separated by newlines, and
+	// cycleID is empty, if the report has no cycle ID
+	Report(kind string, title string, text string, sites string, cycleID string)
+}
+
+// Register delivers all reports of the default detector to r and disables
+// the termination of the program after a deadlock. It must be called before
+// the first lock is used
+//  Args:
+//   r (Reporter): receiver of the reports
+//  Returns:
+//   (bool): true, if the registration was successful, false otherwise
+func Register(r Reporter) bool {
+	if !deadlock.SetExitOnDeadlock(false) {
+		return false
+	}
+	return deadlock.SetReportSink(func(report deadlock.Report) {
+		sites := make([]string, 0, len(report.Sites))
+		for _, site := range report.Sites {
+			sites = append(sites, fmt.Sprint(site.File, ":", site.Line))
+		}
+		r.Report(report.Type.String(), report.Title, report.Text,
+			strings.Join(sites, "\n"), report.ID)
+	})
+}
+
+// OnBackground must be called when the app is moved to the background.
+// The periodical detection is paused, because the app may be suspended
+//  Returns:
+//   nil
+func OnBackground() {
+	deadlock.Pause()
+}
+
+// OnForeground must be called when the app is moved to the foreground again.
+// The periodical detection is resumed without counting the time in the
+// background
+//  Returns:
+//   nil
+func OnForeground() {
+	deadlock.Resume()
+}
+
+// Detect runs the comprehensive detection. Apps are usually not terminated
+// regularly, therefore the detection is not run at the end of the program,
+// but can be run by the app, e.g. when it is moved to the background
+//  Returns:
+//   nil
+func Detect() {
+	deadlock.FindPotentialDeadlocks()
+}
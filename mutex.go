@@ -30,13 +30,16 @@ the lock and unlock operations for these locks.
 */
 
 import (
-	"runtime"
 	"sync"
+	"sync/atomic"
 	"unsafe"
+
+	"github.com/ErikKassubek/Deadlock-Go/internal/waitfor"
 )
 
 // Type to implement a lock
-// It can be used as an drop in replacement
+// It can be used as an drop in replacement. The zero value is an unlocked
+// lock of the default detector
 type Mutex struct {
 	// mutex for the actual locking
 	mu *sync.Mutex
@@ -52,6 +55,38 @@ type Mutex struct {
 	isLockedRoutineIndexLock *sync.Mutex
 	// position of the mutex in memory
 	memoryPosition uintptr
+	// information about the queue of the lock for the convoy detection
+	convoy *convoyInfo
+	// acquisition of the lock by the routines, which currently hold it.
+	// Protected by isLockedRoutineIndexLock
+	holders map[int]callerInfo
+	// most recent releases of the lock. Protected by isLockedRoutineIndexLock
+	unlocks unlockInfo
+	// node of the lock in the wait-for graph, which stores the routines
+	// holding the lock for the periodical detection
+	waitNode *waitfor.Node
+	// detector the lock belongs to
+	detector *Detector
+	// how double locking of the lock is handled
+	doubleLocking DoubleLockingPolicy
+	// metadata of the lock. Protected by isLockedRoutineIndexLock
+	tags map[string]string
+	// name of the lock outside of the program, which is modeled by the lock.
+	// The underlying lock mu of a model is never acquired. Empty for other
+	// locks
+	model string
+	// if true, the lock represents an underlying lock of a LockerProvider.
+	// The underlying lock mu is never acquired
+	provided bool
+	// if true, the lock is excluded from the detection
+	disabled bool
+	// acquisitions of the lock for the detection of hot locks
+	hotLock hotLockInfo
+	// initializes a lock which was not created with NewLock at its first use
+	lazy sync.Once
+	// 1 if the lock is part of a dependency in the lock trees. Accessed
+	// atomically
+	inLockTree int32
 }
 
 // create and return a new lock, which can be used as a drop-in replacement for
@@ -59,30 +94,87 @@ type Mutex struct {
 //  Returns:
 //   (*Mutex): the created lock
 func NewLock() *Mutex {
+	return newLock(defaultDetector, 2)
+}
+
+// create a new lock and save the caller information of the creation
+//  Args:
+//   d (*Detector): detector the lock belongs to
+//   skip (int): number of stack frames between the user code and the
+//    callerSite call
+//  Returns:
+//   (*Mutex): the created lock
+func newLock(d *Detector, skip int) *Mutex {
 	// initialize detector if necessary
-	if !initialized {
-		initialize()
+	if !d.initialized {
+		d.initialize()
 	}
 
-	m := Mutex{
-		mu:                       &sync.Mutex{},
-		in:                       true,
-		isLockedRoutineIndex:     map[int]int{},
-		isLockedRoutineIndexLock: &sync.Mutex{},
-	}
+	m := Mutex{}
 
 	// save the position of the NewLock call
-	_, file, line, _ := runtime.Caller(1)
-	m.context = append(m.context, newInfo(file, line, true, ""))
+	pc, file, line, _ := callerSite(skip)
+	m.init(d, file, line, funcName(pc))
+
+	return &m
+}
+
+// initialize the lock
+//  Args:
+//   d (*Detector): detector the lock belongs to
+//   file (string): file of the creation of the lock
+//   line (int): line of the creation of the lock
+//   function (string): function in which the lock was created
+//  Returns:
+//   nil
+func (m *Mutex) init(d *Detector, file string, line int, function string) {
+	m.mu = &sync.Mutex{}
+	m.isLockedRoutineIndex = map[int]int{}
+	m.isLockedRoutineIndexLock = &sync.Mutex{}
+	m.convoy = newConvoyInfo()
+	m.holders = map[int]callerInfo{}
+	m.unlocks = newUnlockInfo()
+	m.waitNode = waitfor.NewNode()
+	m.detector = d
+	info := newInfo(file, line, function, true, false, "")
+	m.context = append(m.context, info)
 
 	// save the memory position of the mutex
-	m.memoryPosition = uintptr(unsafe.Pointer(&m))
+	m.memoryPosition = uintptr(unsafe.Pointer(m))
 
-	return &m
+	m.in = true
+}
+
+// initialize a lock, which was declared as a zero value instead of being
+// created with NewLock. The first use of the lock is saved as its creation
+//  Returns:
+//   nil
+func (m *Mutex) lazyInit() {
+	m.lazy.Do(func() {
+		if m.in {
+			return
+		}
+
+		if !defaultDetector.initialized {
+			defaultDetector.initialize()
+		}
+
+		frame := userFrame()
+		m.init(defaultDetector, frame.File, frame.Line, frame.Function)
+	})
 }
 
 // ============ GETTER ============
 
+// check if the lock is part of a dependency in the lock trees, i.e. if it
+// was held while another lock was acquired or acquired while another lock
+// was held
+//  Returns:
+//   (bool): true if the lock is part of a dependency
+func (m *Mutex) isInLockTree() bool {
+	return atomic.LoadInt32(&m.inLockTree) != 0
+}
+
 // getter for isLocked
 //  Returns:
 //   (*int): numberLocked
@@ -118,6 +210,52 @@ func (m *Mutex) getMemoryPosition() uintptr {
 	return m.memoryPosition
 }
 
+// getter for convoy
+//  Returns:
+//   (*convoyInfo): information about the queue of the lock
+func (m *Mutex) getConvoyInfo() *convoyInfo {
+	return m.convoy
+}
+
+// getter for holders
+//  Returns:
+//   (*map[int]callerInfo): acquisitions of the routines holding the lock
+func (m *Mutex) getHolders() *map[int]callerInfo {
+	return &m.holders
+}
+
+// getter for unlocks
+//  Returns:
+//   (*unlockInfo): most recent releases of the lock
+func (m *Mutex) getUnlockInfo() *unlockInfo {
+	return &m.unlocks
+}
+
+// getter for waitNode
+//  Returns:
+//   (*waitfor.Node): node of the lock in the wait-for graph
+func (m *Mutex) getWaitNode() *waitfor.Node {
+	return m.waitNode
+}
+
+// getter for the name of the resource type
+//  Returns:
+//   (string): name of the resource type
+func (m *Mutex) getResourceName() string {
+	return "Mutex"
+}
+
+// getter for detector. Locks which were not created with NewLock belong
+// to the default detector
+//  Returns:
+//   (*Detector): detector the lock belongs to
+func (m *Mutex) getDetector() *Detector {
+	if m.detector == nil {
+		return defaultDetector
+	}
+	return m.detector
+}
+
 // getter for in
 //  Returns:
 //   (bool): true if the lock was initialized, false otherwise
@@ -143,20 +281,47 @@ func (m *Mutex) getRLock(routineIndex int) bool {
 
 func (m *Mutex) setRLock(routineIndex int, value bool) {}
 
+// empty getter, needed for mutexInt
+func (m *Mutex) getWriterLock() *sync.Mutex {
+	return nil
+}
+
+// empty getter, needed for mutexInt
+func (m *Mutex) getStarvationInfo() *starvationInfo {
+	return nil
+}
+
+// empty setter, needed for mutexInt
+func (m *Mutex) setWriter(routineIndex int, pending bool) {}
+
+// empty getter, needed for mutexInt
+func (m *Mutex) hasWriter(pending bool, exclude ...int) bool {
+	return false
+}
+
+// empty getter, needed for mutexInt
+func (m *Mutex) getWriterAttempts() *writerAttempts {
+	return nil
+}
+
 // ============ FUNCTIONS ============
 
 // Lock mutex m
 //  Returns:
 //   nil
 func (m *Mutex) Lock() {
+	m.lazyInit()
+
 	// call the lock function with the mutexInt interface
-	lockInt(m, false)
+	lockInt(m, false, false)
 }
 
 // TryLock mutex m
 //  Returns:
 //   (bool): true if locking was successful, false otherwise
 func (m *Mutex) TryLock() bool {
+	m.lazyInit()
+
 	// call the try-lock method for the mutexInt interface
 	return tryLockInt(m, false)
 }
@@ -165,7 +330,9 @@ func (m *Mutex) TryLock() bool {
 //  Returns:
 //   nil
 func (m *Mutex) Unlock() {
-	if opts.activated {
+	m.lazyInit()
+
+	if m.getDetector().opts.Activated {
 		// call the unlock method for the mutexInt interface
 		unlockInt(m)
 	}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"runtime"
 	"sync"
+	"sync/atomic"
+
+	"github.com/ErikKassubek/Deadlock-Go/internal/waitfor"
 )
 
 /*
@@ -57,31 +60,68 @@ type mutexInt interface {
 	getRLock(routineIndex int) bool
 	// setter for rlock
 	setRLock(routineIndex int, value bool)
+	// getter for the lock which is held by writers of rw-mutexes, nil for mutex
+	getWriterLock() *sync.Mutex
+	// getter for the information about waiting routines, nil for mutex
+	getStarvationInfo() *starvationInfo
+	// register that a routine waits for or has acquired the writer lock
+	setWriter(routineIndex int, pending bool)
+	// check if a routine other than the excluded routines is a writer
+	hasWriter(pending bool, exclude ...int) bool
+	// getter for the attempts to acquire the writer lock, nil for mutex
+	getWriterAttempts() *writerAttempts
+	// getter for the information about the queue of the lock
+	getConvoyInfo() *convoyInfo
+	// getter for the acquisitions of the routines holding the lock
+	getHolders() *map[int]callerInfo
+	// getter for the most recent releases of the lock
+	getUnlockInfo() *unlockInfo
+	// getter for the node of the lock in the wait-for graph
+	getWaitNode() *waitfor.Node
+	// getter for the name of the resource type
+	getResourceName() string
+	// getter for the tags of the lock
+	getTags() *map[string]string
+	// getter for the detector the lock belongs to
+	getDetector() *Detector
+	// getter for the double locking policy of the lock
+	getDoubleLockingPolicy() DoubleLockingPolicy
+	// check if recursive reader locks of the lock are not reported
+	allowsRecursiveRLock() bool
+	// check if the lock is excluded from the detection
+	detectionDisabled() bool
+	// getter for the information about the acquisitions for the detection
+	// of hot locks
+	getHotLockInfo() *hotLockInfo
+	// initialize a lock, which was declared as a zero value, at its first use
+	lazyInit()
 }
 
 // lock the mutex or rw-mutex and update the detector data
 //  Args:
 //   m (mutexInt): mutex or rw-mutex to lock
 //   rLock (bool): if set to true, the lock is a reader lock
+//   upgrade (bool): if set to true, the lock is acquired as an upgrade of a
+//    previously held reader lock
 //  Returns:
 //   nil
-func lockInt(m mutexInt, rLock bool) {
-	// do only the operation if detection is completely deactivated
-	if !opts.activated {
-		d, l, t := m.getLock()
-		if d {
-			// lock if m is mutex
-			l.Lock()
-		} else {
-			// lock if m is rw-mutex
-			if rLock {
-				t.RLock()
-			} else {
-				t.Lock()
-			}
-		}
+func lockInt(m mutexInt, rLock bool, upgrade bool) {
+	d := m.getDetector()
+
+	// initialize the detector, if the initialization was postponed because
+	// the lock was created during the init phase
+	if !d.initialized {
+		d.initialize()
+	}
+
+	// do only the operation if detection is completely deactivated or
+	// disabled for the lock, the detector was shut down or the routine emits
+	// a report
+	if !d.opts.Activated || m.detectionDisabled() || d.inReport() || !d.enter() {
+		acquireLock(m, rLock)
 		return
 	}
+	defer d.leave()
 
 	// panic if the lock was not initialized
 	if !*m.getIn() {
@@ -90,51 +130,136 @@ func lockInt(m mutexInt, rLock bool) {
 		panic(errorMessage)
 	}
 
+	// register the wait for the starvation detection
+	var wait *waitInfo
+	if d.opts.StarvationDetection {
+		wait = startWait(m, rLock)
+	}
+
+	// register the wait for the convoy detection
+	var convoyCaller callerInfo
+	if d.opts.ConvoyDetection {
+		convoyCaller = startConvoyWait(m)
+	}
+
+	// index of the routine, -1 if the routine is not registered
+	index := -1
+
+	// true while the recording holds the barrier of the stop-the-world mode
+	inBarrier := false
+
 	// defer the actual locking
 	defer func() {
-		d, l, t := m.getLock()
-		if d {
-			// lock if m is mutex
-			l.Lock()
-		} else {
-			// lock if m is rw-mutex
-			if rLock {
-				t.RLock()
-			} else {
-				t.Lock()
+		// the barrier must not be held while the routine waits for the lock
+		if inBarrier {
+			d.leaveBarrier()
+		}
+
+		d.acquireLockWaiting(m, index, rLock, upgrade)
+
+		if wait != nil {
+			endWait(m, wait)
+		}
+
+		if d.opts.ConvoyDetection {
+			endConvoyWait(m, convoyCaller)
+		}
+
+		if index != -1 {
+			d.addHolder(m, index)
+
+			if d.opts.PeriodicDetection {
+				m.getWaitNode().Hold(index, rLock)
+			}
+
+			if !rLock && d.opts.WriterQueuingDetection {
+				m.setWriter(index, false)
 			}
 		}
 
 		*m.getNumberLocked() += 1
+		d.guardAcquired(m)
 	}()
 
 	// return if detection is disabled
-	if !opts.periodicDetection && !opts.comprehensiveDetection {
+	if !d.opts.PeriodicDetection && !d.opts.ComprehensiveDetection &&
+		len(d.opts.LockOrder) == 0 && d.opts.LockGraphSocket == "" {
 		return
 	}
 
 	// create new routine, if not initialized
-	index := getRoutineIndex()
-	if index == -1 {
-		newRoutine()
+	if d.getRoutineIndex() == -1 {
+		d.newRoutine()
+	}
+	index = d.getRoutineIndex()
+
+	// wait while the world is stopped for a snapshot of the lock trees
+	inBarrier = d.enterBarrier()
+
+	r := &d.routines[index]
+
+	// remember the acquisition for the next call of Done on a wait group
+	if atomic.LoadInt32(&d.waitGroups) != 0 && d.opts.ComprehensiveDetection {
+		r.recordDoneAcquisition(m, rLock)
+	}
+
+	// locks held on the lightweight path are nested with m
+	r.promoteLightLocks()
+
+	// acquire hot locks on the lightweight path, if the routine holds no
+	// other locks
+	if d.acquireHotLock(r, m, rLock, upgrade) {
+		return
 	}
-	index = getRoutineIndex()
 
-	r := &routines[index]
+	// save the event in the history of the routine
+	if upgrade {
+		d.recordEvent(index, m, "Upgrade")
+	} else if rLock {
+		d.recordEvent(index, m, "RLock")
+	} else {
+		d.recordEvent(index, m, "Lock")
+	}
 
 	// check if the locking would lead to double locking
-	if opts.checkDoubleLocking && *m.getNumberLocked() != 0 {
+	if d.opts.CheckDoubleLocking && *m.getNumberLocked() != 0 {
 		r.checkDoubleLocking(m, index, rLock)
 	}
 
+	// without the check for double locking, check if the routine would wait
+	// for itself over a chain of held locks
+	if !d.opts.CheckDoubleLocking && d.opts.SelfDeadlockDetection &&
+		*m.getNumberLocked() != 0 {
+		r.checkSelfDeadlock(m, rLock)
+	}
+
 	m.getIsLockedRoutineIndexLock().Lock()
 	(*m.getIsLockedRoutineIndex())[index] += 1
 	m.getIsLockedRoutineIndexLock().Unlock()
 
-	// update data structures if more than on routine is running
+	// register the routine as a waiting writer, so that cycles which can only
+	// occur with queued writers can be detected
+	if !rLock && d.opts.WriterQueuingDetection {
+		m.setWriter(index, true)
+		if wa := m.getWriterAttempts(); wa != nil {
+			r.recordWriterAttempt(wa)
+		}
+	}
+
+	// update data structures if more than on routine is running or the
+	// packages are still initialized. Locks acquired in the init phase
+	// are recorded, because routines started later can use the same locks.
+	// If a check needs the locks of single routines, every acquisition is
+	// recorded
+	initPhase := inInitPhase()
 	numRoutine := runtime.NumGoroutine()
-	if numRoutine > 1 {
-		(*r).updateLock(m, rLock)
+	if numRoutine > 1 || initPhase || d.recordsSingleRoutine() {
+		// guarded reads of routines, which hold no other locks, are not
+		// added to the holding set
+		if rLock && !upgrade && r.acquireGuardedRead(m, initPhase) {
+			return
+		}
+		(*r).updateLock(m, rLock, upgrade, initPhase)
 	}
 }
 
@@ -146,24 +271,22 @@ func lockInt(m mutexInt, rLock bool) {
 //  Returns:
 //   (bool): true if the acquisition was successful, false otherwise
 func tryLockInt(m mutexInt, rLock bool) bool {
-	// do only the operation if detection is completely deactivated
-	if !opts.activated {
-		d, l, t := m.getLock()
-		var res bool
-		if d {
-			// lock if m is mutex
-			res = l.TryLock()
-		} else {
-			// lock if m is rw-mutex
-			if rLock {
-				res = t.TryRLock()
-			} else {
-				res = t.TryLock()
-			}
-		}
-		return res
+	d := m.getDetector()
+
+	// initialize the detector, if the initialization was postponed because
+	// the lock was created during the init phase
+	if !d.initialized {
+		d.initialize()
 	}
 
+	// do only the operation if detection is completely deactivated or
+	// disabled for the lock, the detector was shut down or the routine emits
+	// a report
+	if !d.opts.Activated || m.detectionDisabled() || d.inReport() || !d.enter() {
+		return tryAcquireLock(m, rLock)
+	}
+	defer d.leave()
+
 	// panic if the lock was not initialized
 	if !*m.getIn() {
 		errorMessage := fmt.Sprint("Lock ", &m, " was not created. Use ",
@@ -172,47 +295,48 @@ func tryLockInt(m mutexInt, rLock bool) bool {
 	}
 
 	// try to lock mu
-	d, l, t := m.getLock()
-	var res bool
-	if d {
-		// lock if m is mutex
-		res = l.TryLock()
-	} else {
-		// lock if m is rw-mutex
-		if rLock {
-			res = t.TryRLock()
-		} else {
-			res = t.TryLock()
-		}
-	}
+	res := tryAcquireLock(m, rLock)
 
 	// if locking was successful increase numberLocked
 	var index int
 	if res {
 		// initialize routine if necessary
-		index := getRoutineIndex()
-		if index == -1 {
+		if d.getRoutineIndex() == -1 {
 			// create new routine, if not initialized
-			newRoutine()
+			d.newRoutine()
+		}
+		index = d.getRoutineIndex()
+
+		if rLock {
+			d.recordEvent(index, m, "TryRLock")
+		} else {
+			d.recordEvent(index, m, "TryLock")
 		}
-		index = getRoutineIndex()
 
 		*m.getNumberLocked() += 1
 		m.getIsLockedRoutineIndexLock().Lock()
 		(*m.getIsLockedRoutineIndex())[index] += 1
 		m.getIsLockedRoutineIndexLock().Unlock()
+
+		d.addHolder(m, index)
+		d.guardAcquired(m)
+
+		if d.opts.PeriodicDetection {
+			m.getWaitNode().Hold(index, rLock)
+		}
 	}
 
 	// return if detection is disabled
-	if !opts.periodicDetection && !opts.comprehensiveDetection {
+	if !d.opts.PeriodicDetection && !d.opts.ComprehensiveDetection &&
+		len(d.opts.LockOrder) == 0 && d.opts.LockGraphSocket == "" {
 		return res
 	}
 
-	// update data structures if more than on routine is running and locking
-	// was successful
-	if runtime.NumGoroutine() > 1 {
+	// update data structures if more than on routine is running or a check
+	// needs the locks of single routines and locking was successful
+	if runtime.NumGoroutine() > 1 || d.recordsSingleRoutine() {
 		if res {
-			r := &routines[index]
+			r := &d.routines[index]
 			(*r).updateTryLock(m, rLock)
 		}
 	}
@@ -226,6 +350,15 @@ func tryLockInt(m mutexInt, rLock bool) bool {
 //  Returns:
 //   nil
 func unlockInt(m mutexInt) {
+	d := m.getDetector()
+
+	// locks are no longer checked after the detector was shut down, if the
+	// detection is disabled for the lock or while the routine emits a report
+	if m.detectionDisabled() || d.inReport() || !d.enter() {
+		return
+	}
+	defer d.leave()
+
 	// panic if the lock was not initialized
 	if !*m.getIn() {
 		errorMessage := fmt.Sprint("Lock ", &m, " was not created. Use ",
@@ -236,26 +369,142 @@ func unlockInt(m mutexInt) {
 	// panic if lock was not locked
 	if *m.getNumberLocked() == 0 {
 		errorMessage := fmt.Sprint("Tried to unLock lock ", &m,
-			" which was not locked.")
+			" which was not locked.", d.lastUnlockHint(m, -1))
 		panic(errorMessage)
 	}
 
 	// defer the actual unlocking
 	defer func() {
+		if d.opts.ConvoyDetection {
+			releaseConvoy(m)
+		}
+
 		// update numberLocked and isLockedRoutineIndex
 		*m.getNumberLocked() -= 1
+		index := d.getRoutineIndex()
+		d.recordUnlock(m, index)
+		d.recordCriticalSection(m, index)
 		m.getIsLockedRoutineIndexLock().Lock()
-		(*m.getIsLockedRoutineIndex())[getRoutineIndex()] -= 1
+		(*m.getIsLockedRoutineIndex())[index] -= 1
+		if (*m.getIsLockedRoutineIndex())[index] <= 0 {
+			delete(*m.getHolders(), index)
+		}
 		m.getIsLockedRoutineIndexLock().Unlock()
+		d.guardReleased(m)
+
+		if d.opts.PeriodicDetection {
+			m.getWaitNode().Release(index)
+		}
 	}()
 
 	// return if detection is disabled
-	if !opts.periodicDetection && !opts.comprehensiveDetection {
+	if !d.opts.PeriodicDetection && !d.opts.ComprehensiveDetection &&
+		len(d.opts.LockOrder) == 0 && d.opts.LockGraphSocket == "" {
+		return
+	}
+
+	// update data structures if more than on routine is running. A routine,
+	// which releases a lock acquired by another routine, does not have to
+	// be registered
+	index := d.getRoutineIndex()
+	if index == -1 {
 		return
 	}
+	r := &d.routines[index]
+
+	// locks acquired on the lightweight path are not in the holding set.
+	// Only the events of guarded reads are recorded
+	if held, ok := r.releaseLightLock(m); ok {
+		if held.recorded {
+			d.recordEvent(index, m, "RUnlock")
+		}
+		return
+	}
+
+	// save the event in the history of the routine
+	if m.getRLock(index) {
+		d.recordEvent(index, m, "RUnlock")
+	} else {
+		d.recordEvent(index, m, "Unlock")
+	}
 
-	// update data structures if more than on routine is running
-	index := getRoutineIndex()
-	r := &routines[index]
 	(*r).updateUnlock(m)
 }
+
+// acquire the underlying lock of the mutex or rw-mutex.
+// Writers of rw-mutexes additionally hold the writer lock of the rw-mutex,
+// so that a downgrade can not be interrupted by another writer
+//  Args:
+//   m (mutexInt): mutex or rw-mutex to lock
+//   rLock (bool): if set to true, the lock is a reader lock
+//  Returns:
+//   nil
+func acquireLock(m mutexInt, rLock bool) {
+	// distributed locks and the locks of lock providers were already
+	// acquired outside of the detector, wait groups and semaphores are never
+	// acquired
+	if isModeled(m) || isProvided(m) || isRepresentation(m) {
+		return
+	}
+
+	d, l, t := m.getLock()
+	if d {
+		// lock if m is mutex
+		l.Lock()
+	} else {
+		// lock if m is rw-mutex
+		if rLock {
+			t.RLock()
+		} else {
+			m.getWriterLock().Lock()
+			t.Lock()
+		}
+	}
+}
+
+// try to acquire the underlying lock of the mutex or rw-mutex
+//  Args:
+//   m (mutexInt): mutex or rw-mutex to lock
+//   rLock (bool): if set to true, the lock is a reader lock
+//  Returns:
+//   (bool): true if the acquisition was successful, false otherwise
+func tryAcquireLock(m mutexInt, rLock bool) bool {
+	// distributed locks and the locks of lock providers were already
+	// acquired outside of the detector, wait groups and semaphores are never
+	// acquired
+	if isModeled(m) || isProvided(m) || isRepresentation(m) {
+		return true
+	}
+
+	d, l, t := m.getLock()
+	if d {
+		// lock if m is mutex
+		return l.TryLock()
+	}
+
+	// lock if m is rw-mutex
+	if rLock {
+		return t.TryRLock()
+	}
+	if !m.getWriterLock().TryLock() {
+		return false
+	}
+	if !t.TryLock() {
+		m.getWriterLock().Unlock()
+		return false
+	}
+	return true
+}
+
+// check if the acquisitions of locks must also be recorded, if only one
+// routine is running. This is necessary for the check of the declared
+// lock order, the analysis of single routines by the comprehensive detection,
+// the detection of self deadlocks and the publication of the dependencies
+// of distributed locks, which can form cycles with other processes
+//  Returns:
+//   (bool): true if the acquisitions must be recorded, false otherwise
+func (d *Detector) recordsSingleRoutine() bool {
+	return len(d.opts.LockOrder) > 0 || d.opts.MinRoutines <= 1 ||
+		(d.opts.SelfDeadlockDetection && !d.opts.CheckDoubleLocking) ||
+		d.opts.LockGraphSocket != ""
+}
@@ -0,0 +1,94 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+once.go
+This file implements a replacement of sync.Once. It uses the same
+implementation as OnceFunc: a routine, which calls Do while another routine
+executes the function, is registered in the wait-for graph, and a call of
+Do on the same Once by the function itself, which would block forever, is
+reported with the call stacks of both calls.
+*/
+
+import "sync"
+
+// type to implement an object, which performs exactly one action
+type Once struct {
+	// implementation of the call
+	call *onceCall
+	// initializes a Once, which was not created with NewOnce, at its first
+	// use
+	lazy sync.Once
+}
+
+// create a new Once
+//  Returns:
+//   (*Once): the created Once
+func NewOnce() *Once {
+	return newOnce(defaultDetector, 2)
+}
+
+// create a new Once and save the caller information of the creation
+//  Args:
+//   d (*Detector): detector the Once belongs to
+//   skip (int): number of stack frames between the user code and the
+//    callerSite call
+//  Returns:
+//   (*Once): the created Once
+func newOnce(d *Detector, skip int) *Once {
+	// save the position of the NewOnce call
+	pc, file, line, _ := callerSite(skip)
+	call := newOnceCallAt(d, "Once", newInfo(file, line, funcName(pc), true, false, ""))
+
+	o := Once{}
+	o.lazy.Do(func() {
+		o.call = call
+	})
+	return &o
+}
+
+// initialize a Once, which was declared as a zero value instead of being
+// created with NewOnce. The first use of the Once is saved as its creation
+//  Returns:
+//   nil
+func (o *Once) lazyInit() {
+	o.lazy.Do(func() {
+		frame := userFrame()
+		o.call = newOnceCallAt(defaultDetector, "Once", newInfo(frame.File,
+			frame.Line, frame.Function, true, false, ""))
+	})
+}
+
+// Do calls f, if Do is called for the first time on this Once. Other calls
+// of Do wait until the first call of f has returned. If f calls Do on the
+// same Once, the deadlock is reported
+//  Args:
+//   f (func()): function to execute
+//  Returns:
+//   nil
+func (o *Once) Do(f func()) {
+	o.lazyInit()
+	o.call.do(f)
+}
@@ -0,0 +1,324 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+onceFunc.go
+This file implements equivalents of OnceFunc, OnceValue and OnceValues of the
+sync package. A routine which calls the returned function, while another
+routine is executing it, is registered in the wait-for graph. If the routine
+executing the function calls the returned function again, it would wait for
+itself. This deadlock is reported with the call stacks of both calls and the
+program is terminated. The reentrant call is recognized by the goroutine id,
+so that it is also reported if the periodical detection is disabled.
+*/
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/ErikKassubek/Deadlock-Go/internal/waitfor"
+	"github.com/petermattis/goid"
+)
+
+// type to implement a function call which is only executed once
+type onceCall struct {
+	// set to 1 if the function has been executed
+	done uint32
+	// lock which is held while the function is executed
+	m sync.Mutex
+	// index of the routine which executes the function, -1 if none or unknown
+	runner int
+	// goroutine id of the routine which executes the function, 0 if none
+	runnerID int64
+	// call stack of the call, which executes the function, nil if none or
+	// reentrant calls are not reported
+	runnerStack []byte
+	// lock to prevent concurrent access to runner, runnerID and runnerStack
+	runnerLock sync.Mutex
+	// info about the creation of the function
+	context []callerInfo
+	// name of the resource type in the reports
+	name string
+	// detector the function belongs to
+	detector *Detector
+}
+
+// create a new onceCall. The creation is the first frame outside of the
+// detector, so that functions created by the sync package are created at its
+// caller
+//  Args:
+//   d (*Detector): detector the function belongs to
+//  Returns:
+//   (*onceCall): the created onceCall
+func newOnceCall(d *Detector) *onceCall {
+	// save the position of the creation
+	frame := userFrame()
+	return newOnceCallAt(d, "OnceFunc", newInfo(frame.File, frame.Line, "", true, false, ""))
+}
+
+// create a new onceCall with a given creation
+//  Args:
+//   d (*Detector): detector the function belongs to
+//   name (string): name of the resource type in the reports
+//   info (callerInfo): info about the creation
+//  Returns:
+//   (*onceCall): the created onceCall
+func newOnceCallAt(d *Detector, name string, info callerInfo) *onceCall {
+	// initialize detector if necessary
+	if !d.initialized {
+		d.initialize()
+	}
+
+	return &onceCall{
+		runner:   -1,
+		context:  []callerInfo{info},
+		name:     name,
+		detector: d,
+	}
+}
+
+// get the routines a routine waiting for the call waits for
+//  Args:
+//   routineIndex (int): index of the waiting routine
+//   read (bool): not used for calls
+//  Returns:
+//   (waitfor.Blockers): the routine executing the function, which is always
+//    known
+func (o *onceCall) getBlockers(routineIndex int, read bool) waitfor.Blockers {
+	o.runnerLock.Lock()
+	defer o.runnerLock.Unlock()
+
+	if o.runner == -1 {
+		return waitfor.Blockers{}
+	}
+	return waitfor.Blockers{Known: []int{o.runner}}
+}
+
+// getter for context
+//  Returns:
+//   (*[]callerInfo): caller info of the function
+func (o *onceCall) getContext() *[]callerInfo {
+	return &o.context
+}
+
+// getter for the name of the resource type
+//  Returns:
+//   (string): name of the resource type
+func (o *onceCall) getResourceName() string {
+	return o.name
+}
+
+// set the routine which executes the function
+//  Args:
+//   index (int): index of the routine, -1 if none or unknown
+//   id (int64): goroutine id of the routine, 0 if none
+//   stack ([]byte): call stack of the call, which executes the function,
+//    nil if none
+//  Returns:
+//   nil
+func (o *onceCall) setRunner(index int, id int64, stack []byte) {
+	o.runnerLock.Lock()
+	o.runner = index
+	o.runnerID = id
+	o.runnerStack = stack
+	o.runnerLock.Unlock()
+}
+
+// execute f if it has not been executed before. If f is currently executed
+// by another routine, wait for it to finish.
+//  Args:
+//   f (func()): function to execute
+//  Returns:
+//   nil
+func (o *onceCall) do(f func()) {
+	if atomic.LoadUint32(&o.done) == 1 {
+		return
+	}
+
+	d := o.detector
+	_, file, line, _ := callerSite(2)
+	caller := newInfo(file, line, "", false, false, "")
+
+	// the routine executing f waits for itself. The call can never return,
+	// so it is checked independently of the check for double locking
+	checkReentrant := d.opts.Activated
+	var id int64
+	if checkReentrant {
+		id = goid.Get()
+		o.runnerLock.Lock()
+		reentrant := o.runnerID == id
+		first := o.runnerStack
+		o.runnerLock.Unlock()
+		if reentrant {
+			d.reportDeadlockReentrant(o, caller, first, currentStack())
+			d.terminate()
+		}
+	}
+
+	index := -1
+	if d.opts.Activated && d.opts.PeriodicDetection {
+		// create new routine, if not initialized
+		index = d.registerRoutine()
+	}
+	if index != -1 {
+		// register the wait in the wait-for graph
+		d.startWaiting(index, o, false, caller)
+	}
+
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	if index != -1 {
+		d.stopWaiting(index)
+	}
+
+	if o.done == 0 {
+		var stack []byte
+		if checkReentrant {
+			stack = currentStack()
+		}
+		o.setRunner(index, id, stack)
+		defer func() {
+			atomic.StoreUint32(&o.done, 1)
+			o.setRunner(-1, 0, nil)
+		}()
+		f()
+	}
+}
+
+// OnceFunc returns a function that invokes f only once. The returned function
+// may be called concurrently. If f panics, the returned function will panic
+// with the same value on every call.
+//  Args:
+//   f (func()): function to execute
+//  Returns:
+//   (func()): function which executes f only once
+func OnceFunc(f func()) func() {
+	o := newOnceCall(defaultDetector)
+
+	var (
+		valid bool
+		p     interface{}
+	)
+
+	// g is only executed once
+	g := func() {
+		defer func() {
+			p = recover()
+			if !valid {
+				// re-panic immediately so on the first call the user gets a
+				// complete stack trace into f
+				panic(p)
+			}
+		}()
+		f()
+		f = nil
+		valid = true
+	}
+
+	return func() {
+		o.do(g)
+		if !valid {
+			panic(p)
+		}
+	}
+}
+
+// OnceValue returns a function that invokes f only once and returns the value
+// returned by f. The returned function may be called concurrently. If f panics,
+// the returned function will panic with the same value on every call.
+//  Args:
+//   f (func() T): function to execute
+//  Returns:
+//   (func() T): function which executes f only once
+func OnceValue[T any](f func() T) func() T {
+	o := newOnceCall(defaultDetector)
+
+	var (
+		valid  bool
+		p      interface{}
+		result T
+	)
+
+	// g is only executed once
+	g := func() {
+		defer func() {
+			p = recover()
+			if !valid {
+				panic(p)
+			}
+		}()
+		result = f()
+		f = nil
+		valid = true
+	}
+
+	return func() T {
+		o.do(g)
+		if !valid {
+			panic(p)
+		}
+		return result
+	}
+}
+
+// OnceValues returns a function that invokes f only once and returns the
+// values returned by f. The returned function may be called concurrently. If
+// f panics, the returned function will panic with the same value on every call.
+//  Args:
+//   f (func() (T1, T2)): function to execute
+//  Returns:
+//   (func() (T1, T2)): function which executes f only once
+func OnceValues[T1, T2 any](f func() (T1, T2)) func() (T1, T2) {
+	o := newOnceCall(defaultDetector)
+
+	var (
+		valid bool
+		p     interface{}
+		r1    T1
+		r2    T2
+	)
+
+	// g is only executed once
+	g := func() {
+		defer func() {
+			p = recover()
+			if !valid {
+				panic(p)
+			}
+		}()
+		r1, r2 = f()
+		f = nil
+		valid = true
+	}
+
+	return func() (T1, T2) {
+		o.do(g)
+		if !valid {
+			panic(p)
+		}
+		return r1, r2
+	}
+}
@@ -28,51 +28,646 @@ options.go
 This file implements options for the deadlock detections such as the
 enabling or disabling of the periodical and/or comprehensive detection as
 well as the periodical detection time and max values for the detection.
+The options can either be set all at once with a versioned Options struct
+or one by one with the Set functions.
 */
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
-// opts controls how the detection behaves
-var opts = struct {
-	// if deactivated is false, there is no detection
-	activated bool
-	// If periodicDetection is set to false, periodic detection is disabled
-	periodicDetection bool
-	// If comprehensiveDetection is set to false, comprehensive detection at
+// OptionsVersion is the version of the Options struct. The fields of a
+// version are never removed or changed in their meaning. New fields are only
+// added together with a new version and get their default value if an older
+// version is configured.
+const OptionsVersion = 29
+
+// ErrInitialized is returned if options are configured after the detector
+// was initialized
+var ErrInitialized = errors.New("deadlock: the options can not be changed " +
+	"after the detector was initialized")
+
+// Options controls how the detection behaves
+// Options should be created with DefaultOptions, so that new fields get their
+// default values
+type Options struct {
+	// Version of the options, must be between 1 and OptionsVersion
+	Version int
+	// If Activated is false, there is no detection
+	Activated bool
+	// If PeriodicDetection is set to false, periodic detection is disabled
+	PeriodicDetection bool
+	// If ComprehensiveDetection is set to false, comprehensive detection at
 	// the end of the program is disabled
-	comprehensiveDetection bool
+	ComprehensiveDetection bool
 	// Set how often the periodic detection is run
-	periodicDetectionTime time.Duration
-	// If collectCallStack is true, the CallStack for lock creation and
+	PeriodicDetectionTime time.Duration
+	// If CollectCallStack is true, the CallStack for lock creation and
 	// acquisition are collected and displayed. Otherwise only file names and
 	// lines are collected
-	collectCallStack bool
-	// If collectSingleLevelLockStack is set to true, stack traces for single
+	CollectCallStack bool
+	// If CollectSingleLevelLockStack is set to true, stack traces for single
 	// level locks are collected. Otherwise not.
-	collectSingleLevelLockStack bool
-	// If checkDoubleLocking is set to true, the detector checks for double
+	CollectSingleLevelLockStack bool
+	// If CheckDoubleLocking is set to true, the detector checks for double
 	// locking
-	checkDoubleLocking bool
+	CheckDoubleLocking bool
 	// maximum number of dependencies
-	maxDependencies int
-	// The maximum number of locks a lock can depend on
-	maxNumberOfDependentLocks int
+	MaxDependencies int
+	// The maximum number of locks a lock can depend on. Since version 22, 0
+	// lets the holding sets of the routines grow dynamically
+	MaxNumberOfDependentLocks int
 	// The maximum number of routines
-	maxRoutines int
+	MaxRoutines int
 	// The maximum byte size for callStacks
-	maxCallStackSize int
-}{
-	activated:                   true,
-	periodicDetection:           true,
-	comprehensiveDetection:      true,
-	periodicDetectionTime:       time.Second * 2,
-	collectCallStack:            false,
-	collectSingleLevelLockStack: true,
-	checkDoubleLocking:          true,
-	maxDependencies:             4096,
-	maxNumberOfDependentLocks:   128,
-	maxRoutines:                 1024,
-	maxCallStackSize:            2048,
+	MaxCallStackSize int
+	// If StarvationDetection is set to true, the detector reports writers and
+	// readers of rw-locks which starve
+	StarvationDetection bool
+	// Time after which a waiting routine is considered as starving
+	StarvationThreshold time.Duration
+	// If WriterQueuingDetection is set to true, cycles in which a routine
+	// waits for a reader lock held by another routine are considered, if a
+	// writer of this lock can be queued by a third routine
+	WriterQueuingDetection bool
+	// If ConvoyDetection is set to true, the detector reports locks with
+	// persistently long queues, which are directly reacquired by the
+	// releasing routine
+	ConvoyDetection bool
+	// Minimum average number of waiting routines for a lock convoy
+	ConvoyMinWaiting int
+
+	// Added in version 2
+
+	// Number of lock events, which are saved for each routine and added
+	// to the reports. If it is 0, no events are saved
+	EventHistorySize int
+
+	// Added in version 3
+
+	// The maximum number of frames of a collected call stack. If it is 0,
+	// the call stacks are only limited by MaxCallStackSize
+	CallStackDepth int
+	// If TrimCallStack is set to true, the frames of the detector, the sync
+	// package and the runtime are removed from the collected call stacks
+	TrimCallStack bool
+	// Frames of functions starting with one of the prefixes, e.g. module
+	// paths of dependencies, are removed from the collected call stacks
+	TrimmedPackages []string
+
+	// Added in version 4
+
+	// How detailed the reports are printed to the console
+	Verbosity Verbosity
+	// Whether the reports printed to the console are colored
+	Color ColorMode
+
+	// Added in version 5
+
+	// Format in which the reports are printed, if the detector has no sink
+	OutputFormat OutputFormat
+
+	// Added in version 6
+
+	// Maximum number of locks a routine should hold at the same time. If a
+	// routine holds more locks, a warning with the acquisition sites is
+	// reported. If it is 0, the lock depth is not checked
+	MaxLockDepth int
+
+	// Added in version 7
+
+	// Time after which a routine waiting for a lock is reported together
+	// with the routines holding the lock. It is checked with the periodic
+	// detection. If it is 0, long waits are not reported
+	LongWaitThreshold time.Duration
+
+	// Added in version 8
+
+	// Declared lock order. Each chain is a list of lock patterns, a lock
+	// matching a pattern must not be acquired while a lock matching a later
+	// pattern of the same chain is held. A pattern is the creation site of
+	// locks as file:line or a file, to match all locks created in it.
+	// Every acquisition, which contradicts the order, is reported
+	LockOrder [][]string
+
+	// Added in version 9
+
+	// Fraction of one CPU, which can be used by the periodical detection,
+	// e.g. 0.01 for 1%. Passes are skipped if the previous passes used more
+	// than the budget. If it is 0, the periodical detection is not limited
+	PeriodicDetectionBudget float64
+
+	// Added in version 10
+
+	// Interval in which the progress of the comprehensive detection is
+	// emitted. If it is 0, no progress is emitted
+	ProgressInterval time.Duration
+	// Function, which receives the progress of the comprehensive detection.
+	// If it is nil, the progress is printed to stderr
+	Progress ProgressFunc
+
+	// Added in version 11
+
+	// Maximum number of dependencies in a chain explored by the
+	// comprehensive detection. Cycles with more dependencies are not found.
+	// If it is 0, the depth is not limited
+	MaxSearchDepth int
+	// Time after which the comprehensive detection is stopped. If it is 0,
+	// the time is not limited
+	MaxSearchTime time.Duration
+
+	// Added in version 12
+
+	// Minimum number of routines, which must have used locks, so that the
+	// comprehensive detection searches for cycles. If it is 1, the lock
+	// trees are also recorded while only one routine is running
+	MinRoutines int
+	// Minimum number of unique dependencies in the lock trees, so that the
+	// comprehensive detection searches for cycles
+	MinDependencies int
+
+	// Added in version 13
+
+	// If SelfDeadlockDetection is set to true and CheckDoubleLocking is
+	// disabled, a routine which acquires a lock again after it has acquired
+	// other locks while holding it, is reported as a deadlock
+	SelfDeadlockDetection bool
+
+	// Added in version 14
+
+	// Path of the unix socket of a broker (cmd/deadlock-broker), to which
+	// the dependencies between distributed locks are published, so that
+	// cycles over multiple processes are found. If it is empty, the
+	// dependencies are not published
+	LockGraphSocket string
+
+	// Added in version 15
+
+	// Minimum number of unique reports of a comprehensive detection, for
+	// which a summary of the reports grouped by subsystem is reported. If it
+	// is 0, no summary is reported
+	SummaryMinReports int
+
+	// Added in version 16
+
+	// Path of a file with the identifiers of known cycles. Reports of these
+	// cycles are suppressed. If the file does not exist, it is created with
+	// the cycles of the first comprehensive detection. If it is empty, no
+	// baseline is used
+	BaselineFile string
+	// If FailOnNewCycles is set to true, the program is terminated with a
+	// nonzero exit code after a comprehensive detection, which found cycles
+	// that are not in the baseline
+	FailOnNewCycles bool
+
+	// Added in version 17
+
+	// If PruneSingleRoutineLocks is set to true, the dependencies of locks,
+	// which only appear in the lock tree of one routine, are removed before
+	// the search for cycles, because they can not be part of a cycle. The
+	// dependencies, which are not part of a cycle of the lock-order graph,
+	// are removed as well
+	PruneSingleRoutineLocks bool
+
+	// Added in version 18
+
+	// If GoroutineDump is set to true, the report of a local deadlock, which
+	// terminates the program, contains the call stacks of all goroutines
+	GoroutineDump bool
+
+	// Added in version 19
+
+	// Maximum time for which the shutdown hooks are run before the program
+	// is terminated because of a deadlock, 0 to wait until they have
+	// finished
+	ShutdownHookTimeout time.Duration
+
+	// Added in version 20
+
+	// Stages, which are run after a deadlock was reported and before the
+	// program is terminated
+	Escalation EscalationPolicy
+
+	// Added in version 21
+
+	// Number of acquisitions per second, from which on a lock, which is never
+	// acquired together with other locks, is acquired on a lightweight path,
+	// until it is nested. If it is 0, hot locks are not detected
+	HotLockRate int
+
+	// Added in version 22
+
+	// Number of locks, which a routine can hold at the same time, before a
+	// warning is reported, because the locks are probably never released.
+	// It is only checked for holding sets, which grow dynamically. If it is
+	// 0, the holding sets are not checked
+	HoldingSetBound int
+
+	// Added in version 23
+
+	// Path of a file, to which the summary of each comprehensive detection
+	// is written. If it is empty, the summary is only returned by
+	// FindPotentialDeadlocks
+	SummaryFile string
+	// Format in which the summary is written to the summary file
+	SummaryFormat SummaryFormat
+
+	// Added in version 24
+
+	// Algorithm of the comprehensive detection to search for cycles
+	CycleSearch CycleSearchAlgorithm
+
+	// Added in version 25
+
+	// Path of a file, to which the new unique dependencies are appended
+	// periodically, so that the lock trees can be analyzed by another
+	// process. If it is empty, the dependencies are not written
+	DeltaFile string
+	// Time between two writes of the new dependencies to the delta file
+	DeltaInterval time.Duration
+
+	// Added in version 26
+
+	// If set, the call stack of every acquisition of a lock, which is held
+	// while another lock is acquired, is saved, so that the reports of
+	// potential deadlocks contain an observed example of each edge of the
+	// cycle with the goroutine, the times and the call stacks of both
+	// acquisitions
+	EdgeExamples bool
+
+	// Added in version 27
+
+	// If set, nothing is printed while the program runs and one summary
+	// block with the number of reports of each severity is printed, when
+	// the detector is shut down or terminates the program. The reports are
+	// passed to the sink or written to ReportFile
+	QuietSummary bool
+	// Path of the file, to which the reports are written as JSON lines in
+	// the quiet summary mode, if no sink is set. If it is empty, the reports
+	// are only counted
+	ReportFile string
+
+	// Added in version 28
+
+	// If set, the lock operations wait at a barrier, while the snapshot of
+	// the lock trees for the comprehensive detection is taken, so that the
+	// snapshot is exactly the state of the lock trees at one point in time.
+	// Every lock operation passes the barrier, which slows down the program
+	StopTheWorld bool
+
+	// Added in version 29
+
+	// If set, each release of a lock is paired with the acquisition of the
+	// lock by the same routine, so that the acquisition and release sites
+	// and the hold durations of the critical sections can be exported with
+	// WriteCriticalSections
+	CriticalSections bool
+}
+
+// DefaultOptions returns the default options of the current version
+//  Returns:
+//   (Options): the default options
+func DefaultOptions() Options {
+	return Options{
+		Version:                     OptionsVersion,
+		Activated:                   true,
+		PeriodicDetection:           true,
+		ComprehensiveDetection:      true,
+		PeriodicDetectionTime:       time.Second * 2,
+		CollectCallStack:            false,
+		CollectSingleLevelLockStack: true,
+		CheckDoubleLocking:          true,
+		MaxDependencies:             defaultMaxDependencies,
+		MaxNumberOfDependentLocks:   defaultMaxNumberOfDependentLocks,
+		MaxRoutines:                 defaultMaxRoutines,
+		MaxCallStackSize:            defaultMaxCallStackSize,
+		StarvationDetection:         false,
+		StarvationThreshold:         time.Second * 5,
+		WriterQueuingDetection:      true,
+		ConvoyDetection:             false,
+		ConvoyMinWaiting:            2,
+		EventHistorySize:            0,
+		CallStackDepth:              32,
+		TrimCallStack:               true,
+		TrimmedPackages:             nil,
+		Verbosity:                   VerbosityFull,
+		Color:                       ColorAuto,
+		OutputFormat:                OutputText,
+		MaxLockDepth:                0,
+		LongWaitThreshold:           0,
+		LockOrder:                   nil,
+		PeriodicDetectionBudget:     0,
+		ProgressInterval:            0,
+		Progress:                    nil,
+		MaxSearchDepth:              0,
+		MaxSearchTime:               0,
+		MinRoutines:                 2,
+		MinDependencies:             2,
+		SelfDeadlockDetection:       true,
+		LockGraphSocket:             "",
+		SummaryMinReports:           2,
+		BaselineFile:                "",
+		FailOnNewCycles:             false,
+		PruneSingleRoutineLocks:     true,
+		GoroutineDump:               true,
+		ShutdownHookTimeout:         5 * time.Second,
+		Escalation:                  EscalationPolicy{},
+		HotLockRate:                 0,
+		HoldingSetBound:             defaultHoldingSetBound,
+		SummaryFile:                 "",
+		SummaryFormat:               SummaryText,
+		CycleSearch:                 CycleSearchChains,
+		DeltaFile:                   "",
+		DeltaInterval:               10 * time.Second,
+		EdgeExamples:                false,
+		QuietSummary:                false,
+		ReportFile:                  "",
+		StopTheWorld:                false,
+		CriticalSections:            false,
+	}
+}
+
+// Validate checks if the options are valid
+//  Returns:
+//   (error): nil if the options are valid, an error describing the first
+//    invalid option otherwise
+func (o Options) Validate() error {
+	if o.Version < 1 || o.Version > OptionsVersion {
+		return fmt.Errorf("deadlock: unsupported options version %d, "+
+			"supported versions are 1 to %d", o.Version, OptionsVersion)
+	}
+	if o.PeriodicDetection && o.PeriodicDetectionTime <= 0 {
+		return fmt.Errorf("deadlock: PeriodicDetectionTime must be positive, got %v",
+			o.PeriodicDetectionTime)
+	}
+	if o.MaxDependencies <= 0 {
+		return fmt.Errorf("deadlock: MaxDependencies must be positive, got %d",
+			o.MaxDependencies)
+	}
+	if o.MaxNumberOfDependentLocks < 0 {
+		return fmt.Errorf("deadlock: MaxNumberOfDependentLocks must not be negative, got %d",
+			o.MaxNumberOfDependentLocks)
+	}
+	if o.MaxRoutines <= 0 {
+		return fmt.Errorf("deadlock: MaxRoutines must be positive, got %d",
+			o.MaxRoutines)
+	}
+	if o.CollectCallStack && o.MaxCallStackSize <= 0 {
+		return fmt.Errorf("deadlock: MaxCallStackSize must be positive, got %d",
+			o.MaxCallStackSize)
+	}
+	if o.StarvationDetection && o.StarvationThreshold <= 0 {
+		return fmt.Errorf("deadlock: StarvationThreshold must be positive, got %v",
+			o.StarvationThreshold)
+	}
+	if o.ConvoyDetection && o.ConvoyMinWaiting <= 0 {
+		return fmt.Errorf("deadlock: ConvoyMinWaiting must be positive, got %d",
+			o.ConvoyMinWaiting)
+	}
+	if o.EventHistorySize < 0 {
+		return fmt.Errorf("deadlock: EventHistorySize must not be negative, got %d",
+			o.EventHistorySize)
+	}
+	if o.CallStackDepth < 0 {
+		return fmt.Errorf("deadlock: CallStackDepth must not be negative, got %d",
+			o.CallStackDepth)
+	}
+	if o.Verbosity < VerbosityQuiet || o.Verbosity > VerbosityDebug {
+		return fmt.Errorf("deadlock: unknown Verbosity %d", o.Verbosity)
+	}
+	if o.Color < ColorAuto || o.Color > ColorNever {
+		return fmt.Errorf("deadlock: unknown Color %d", o.Color)
+	}
+	if o.OutputFormat < OutputText || o.OutputFormat > OutputJSON {
+		return fmt.Errorf("deadlock: unknown OutputFormat %d", o.OutputFormat)
+	}
+	if o.MaxLockDepth < 0 {
+		return fmt.Errorf("deadlock: MaxLockDepth must not be negative, got %d",
+			o.MaxLockDepth)
+	}
+	if o.LongWaitThreshold < 0 {
+		return fmt.Errorf("deadlock: LongWaitThreshold must not be negative, got %v",
+			o.LongWaitThreshold)
+	}
+	for _, chain := range o.LockOrder {
+		if err := validateLockOrderChain(chain); err != nil {
+			return err
+		}
+	}
+	if o.PeriodicDetectionBudget < 0 || o.PeriodicDetectionBudget > 1 {
+		return fmt.Errorf("deadlock: PeriodicDetectionBudget must be between 0 "+
+			"and 1, got %v", o.PeriodicDetectionBudget)
+	}
+	if o.ProgressInterval < 0 {
+		return fmt.Errorf("deadlock: ProgressInterval must not be negative, got %v",
+			o.ProgressInterval)
+	}
+	if o.MaxSearchDepth < 0 {
+		return fmt.Errorf("deadlock: MaxSearchDepth must not be negative, got %d",
+			o.MaxSearchDepth)
+	}
+	if o.MaxSearchTime < 0 {
+		return fmt.Errorf("deadlock: MaxSearchTime must not be negative, got %v",
+			o.MaxSearchTime)
+	}
+	if o.MinRoutines < 1 {
+		return fmt.Errorf("deadlock: MinRoutines must be positive, got %d",
+			o.MinRoutines)
+	}
+	if o.MinDependencies < 0 {
+		return fmt.Errorf("deadlock: MinDependencies must not be negative, got %d",
+			o.MinDependencies)
+	}
+	if o.SummaryMinReports < 0 {
+		return fmt.Errorf("deadlock: SummaryMinReports must not be negative, got %d",
+			o.SummaryMinReports)
+	}
+	if o.ShutdownHookTimeout < 0 {
+		return fmt.Errorf("deadlock: ShutdownHookTimeout must not be negative, got %v",
+			o.ShutdownHookTimeout)
+	}
+	if err := o.Escalation.validate(); err != nil {
+		return err
+	}
+	if o.HotLockRate < 0 {
+		return fmt.Errorf("deadlock: HotLockRate must not be negative, got %d",
+			o.HotLockRate)
+	}
+	if o.HoldingSetBound < 0 {
+		return fmt.Errorf("deadlock: HoldingSetBound must not be negative, got %d",
+			o.HoldingSetBound)
+	}
+	if o.SummaryFormat < SummaryText || o.SummaryFormat > SummaryJSON {
+		return fmt.Errorf("deadlock: unknown SummaryFormat %d", o.SummaryFormat)
+	}
+	if o.CycleSearch < CycleSearchChains || o.CycleSearch > CycleSearchJohnson {
+		return fmt.Errorf("deadlock: unknown CycleSearch %d", o.CycleSearch)
+	}
+	if o.DeltaFile != "" && o.DeltaInterval <= 0 {
+		return fmt.Errorf("deadlock: DeltaInterval must be positive, got %v",
+			o.DeltaInterval)
+	}
+	return nil
+}
+
+// Configure sets the options of the default detector
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   o (Options): options to set
+//  Returns:
+//   (error): nil if the options were set, ErrInitialized or the validation
+//    error otherwise
+func Configure(o Options) error {
+	return defaultDetector.Configure(o)
+}
+
+// CurrentOptions returns the options of the default detector
+//  Returns:
+//   (Options): the current options
+func CurrentOptions() Options {
+	return defaultDetector.Options()
+}
+
+// Configure sets the options of the detector
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   o (Options): options to set
+//  Returns:
+//   (error): nil if the options were set, ErrInitialized or the validation
+//    error otherwise
+func (d *Detector) Configure(o Options) error {
+	o.upgrade()
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	d.initializeLock.Lock()
+	defer d.initializeLock.Unlock()
+	if d.initialized {
+		return ErrInitialized
+	}
+
+	d.opts = o
+	if d.opts.Activated {
+		d.opts.setActivatedAuto()
+	}
+	return nil
+}
+
+// set the fields, which were added after the version of the options, to
+// their default values
+//  Returns:
+//   nil
+func (o *Options) upgrade() {
+	if o.Version < 1 || o.Version >= OptionsVersion {
+		return
+	}
+
+	def := DefaultOptions()
+	if o.Version < 2 {
+		o.EventHistorySize = def.EventHistorySize
+	}
+	if o.Version < 3 {
+		o.CallStackDepth = def.CallStackDepth
+		o.TrimCallStack = def.TrimCallStack
+		o.TrimmedPackages = def.TrimmedPackages
+	}
+	if o.Version < 4 {
+		o.Verbosity = def.Verbosity
+		o.Color = def.Color
+	}
+	if o.Version < 5 {
+		o.OutputFormat = def.OutputFormat
+	}
+	if o.Version < 6 {
+		o.MaxLockDepth = def.MaxLockDepth
+	}
+	if o.Version < 7 {
+		o.LongWaitThreshold = def.LongWaitThreshold
+	}
+	if o.Version < 8 {
+		o.LockOrder = def.LockOrder
+	}
+	if o.Version < 9 {
+		o.PeriodicDetectionBudget = def.PeriodicDetectionBudget
+	}
+	if o.Version < 10 {
+		o.ProgressInterval = def.ProgressInterval
+		o.Progress = def.Progress
+	}
+	if o.Version < 11 {
+		o.MaxSearchDepth = def.MaxSearchDepth
+		o.MaxSearchTime = def.MaxSearchTime
+	}
+	if o.Version < 12 {
+		o.MinRoutines = def.MinRoutines
+		o.MinDependencies = def.MinDependencies
+	}
+	if o.Version < 13 {
+		o.SelfDeadlockDetection = def.SelfDeadlockDetection
+	}
+	if o.Version < 14 {
+		o.LockGraphSocket = def.LockGraphSocket
+	}
+	if o.Version < 15 {
+		o.SummaryMinReports = def.SummaryMinReports
+	}
+	if o.Version < 16 {
+		o.BaselineFile = def.BaselineFile
+		o.FailOnNewCycles = def.FailOnNewCycles
+	}
+	if o.Version < 17 {
+		o.PruneSingleRoutineLocks = def.PruneSingleRoutineLocks
+	}
+	if o.Version < 18 {
+		o.GoroutineDump = def.GoroutineDump
+	}
+	if o.Version < 19 {
+		o.ShutdownHookTimeout = def.ShutdownHookTimeout
+	}
+	if o.Version < 20 {
+		o.Escalation = def.Escalation
+	}
+	if o.Version < 21 {
+		o.HotLockRate = def.HotLockRate
+	}
+	if o.Version < 22 {
+		o.HoldingSetBound = def.HoldingSetBound
+	}
+	if o.Version < 23 {
+		o.SummaryFile = def.SummaryFile
+		o.SummaryFormat = def.SummaryFormat
+	}
+	if o.Version < 24 {
+		o.CycleSearch = def.CycleSearch
+	}
+	if o.Version < 25 {
+		o.DeltaFile = def.DeltaFile
+		o.DeltaInterval = def.DeltaInterval
+	}
+	if o.Version < 26 {
+		o.EdgeExamples = def.EdgeExamples
+	}
+	if o.Version < 27 {
+		o.QuietSummary = def.QuietSummary
+		o.ReportFile = def.ReportFile
+	}
+	if o.Version < 28 {
+		o.StopTheWorld = def.StopTheWorld
+	}
+	if o.Version < 29 {
+		o.CriticalSections = def.CriticalSections
+	}
+	o.Version = OptionsVersion
+}
+
+// Options returns the options of the detector
+//  Returns:
+//   (Options): the current options
+func (d *Detector) Options() Options {
+	return d.opts
 }
 
 // Enable or disable all detections
@@ -82,13 +677,13 @@ var opts = struct {
 //  Returns:
 //   (bool): true, if the set was successful, false otherwise
 func SetActivated(enable bool) bool {
-	if initialized {
+	if defaultDetector.initialized {
 		return false
 	}
-	opts.activated = enable
-	opts.checkDoubleLocking = true
-	opts.periodicDetection = true
-	opts.comprehensiveDetection = true
+	defaultDetector.opts.Activated = enable
+	defaultDetector.opts.CheckDoubleLocking = true
+	defaultDetector.opts.PeriodicDetection = true
+	defaultDetector.opts.ComprehensiveDetection = true
 	return true
 }
 
@@ -99,11 +694,11 @@ func SetActivated(enable bool) bool {
 //  Returns:
 //   (bool): true, if the set was successful, false otherwise
 func SetPeriodicDetection(enable bool) bool {
-	if initialized {
+	if defaultDetector.initialized {
 		return false
 	}
-	opts.periodicDetection = enable
-	setActivatedAuto()
+	defaultDetector.opts.PeriodicDetection = enable
+	defaultDetector.opts.setActivatedAuto()
 	return true
 }
 
@@ -114,11 +709,11 @@ func SetPeriodicDetection(enable bool) bool {
 //  Returns:
 //   (bool): true, if the set was successful, false otherwise
 func SetComprehensiveDetection(enable bool) bool {
-	if initialized {
+	if defaultDetector.initialized {
 		return false
 	}
-	opts.comprehensiveDetection = enable
-	setActivatedAuto()
+	defaultDetector.opts.ComprehensiveDetection = enable
+	defaultDetector.opts.setActivatedAuto()
 	return true
 }
 
@@ -129,10 +724,10 @@ func SetComprehensiveDetection(enable bool) bool {
 //  Returns:
 //   (bool): true, if the set was successful, false otherwise
 func SetPeriodicDetectionTime(seconds int) bool {
-	if initialized {
+	if defaultDetector.initialized {
 		return false
 	}
-	opts.periodicDetectionTime = time.Second * time.Duration(seconds)
+	defaultDetector.opts.PeriodicDetectionTime = time.Second * time.Duration(seconds)
 	return true
 }
 
@@ -144,10 +739,10 @@ func SetPeriodicDetectionTime(seconds int) bool {
 //  Returns:
 //   (bool): true, if the set was successful, false otherwise
 func SetCollectCallStack(enable bool) bool {
-	if initialized {
+	if defaultDetector.initialized {
 		return false
 	}
-	opts.collectCallStack = enable
+	defaultDetector.opts.CollectCallStack = enable
 	return true
 }
 
@@ -159,10 +754,10 @@ func SetCollectCallStack(enable bool) bool {
 //  Returns:
 //   (bool): true, if the set was successful, false otherwise
 func SetCollectSingleLevelLockInformation(enable bool) bool {
-	if initialized {
+	if defaultDetector.initialized {
 		return false
 	}
-	opts.collectSingleLevelLockStack = enable
+	defaultDetector.opts.CollectSingleLevelLockStack = enable
 	return true
 }
 
@@ -173,11 +768,11 @@ func SetCollectSingleLevelLockInformation(enable bool) bool {
 //  Returns:
 //   (bool): true, if the set was successful, false otherwise
 func SetDoubleLockingDetection(enable bool) bool {
-	if initialized {
+	if defaultDetector.initialized {
 		return false
 	}
-	opts.checkDoubleLocking = enable
-	setActivatedAuto()
+	defaultDetector.opts.CheckDoubleLocking = enable
+	defaultDetector.opts.setActivatedAuto()
 	return true
 }
 
@@ -188,24 +783,25 @@ func SetDoubleLockingDetection(enable bool) bool {
 //  Returns:
 //   (bool): true, if the set was successful, false otherwise
 func SetMaxDependencies(number int) bool {
-	if initialized {
+	if defaultDetector.initialized {
 		return false
 	}
-	opts.maxDependencies = number
+	defaultDetector.opts.MaxDependencies = number
 	return true
 }
 
 // Set the max number of locks a lock can depend on
 // It is not possible to set options after the detector was initialized
 //  Args:
-//   number (int): max number of locks a lock can depend on
+//   number (int): max number of locks a lock can depend on, 0 to let the
+//    holding sets grow dynamically
 //  Returns:
 //   (bool): true, if the set was successful, false otherwise
 func SetMaxNumberOfDependentLocks(number int) bool {
-	if initialized {
+	if defaultDetector.initialized {
 		return false
 	}
-	opts.maxNumberOfDependentLocks = number
+	defaultDetector.opts.MaxNumberOfDependentLocks = number
 	return true
 }
 
@@ -216,10 +812,10 @@ func SetMaxNumberOfDependentLocks(number int) bool {
 //  Returns:
 //   (bool): true, if the set was successful, false otherwise
 func SetMaxRoutines(number int) bool {
-	if initialized {
+	if defaultDetector.initialized {
 		return false
 	}
-	opts.maxRoutines = number
+	defaultDetector.opts.MaxRoutines = number
 	return true
 }
 
@@ -230,21 +826,625 @@ func SetMaxRoutines(number int) bool {
 //  Returns:
 //   (bool): true, if the set was successful, false otherwise
 func SetMaxCallStackSize(number int) bool {
-	if initialized {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.MaxCallStackSize = number
+	return true
+}
+
+// Enable or disable the detection of starvation on rw-locks
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   enable (bool): true to enable, false to disable
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetStarvationDetection(enable bool) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.StarvationDetection = enable
+	return true
+}
+
+// Set the time after which a routine waiting for a rw-lock is considered
+// as starving
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   seconds (int): threshold in seconds
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetStarvationThreshold(seconds int) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.StarvationThreshold = time.Second * time.Duration(seconds)
+	return true
+}
+
+// Enable or disable the detection of cycles which can only lead to a deadlock
+// if a writer of a rw-lock is queued
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   enable (bool): true to enable, false to disable
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetWriterQueuingDetection(enable bool) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.WriterQueuingDetection = enable
+	return true
+}
+
+// Enable or disable the detection of lock convoys
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   enable (bool): true to enable, false to disable
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetConvoyDetection(enable bool) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.ConvoyDetection = enable
+	return true
+}
+
+// Set the minimum average number of waiting routines of a lock, such that
+// it can be reported as a lock convoy
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   number (int): minimum average number of waiting routines
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetConvoyMinWaiting(number int) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.ConvoyMinWaiting = number
+	return true
+}
+
+// Set the number of lock events, which are saved for each routine and added
+// to the reports
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   number (int): number of saved events, 0 to disable the history
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetEventHistorySize(number int) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.EventHistorySize = number
+	return true
+}
+
+// Set the maximum number of frames of a collected call stack
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   number (int): maximum number of frames, 0 for no limit
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetCallStackDepth(number int) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.CallStackDepth = number
+	return true
+}
+
+// Enable or disable the removal of the frames of the detector, the sync
+// package and the runtime from the collected call stacks
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   enable (bool): true to enable, false to disable
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetCallStackTrimming(enable bool) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.TrimCallStack = enable
+	return true
+}
+
+// Set the prefixes of the functions, whose frames are removed from the
+// collected call stacks, e.g. the module paths of dependencies
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   prefixes (...string): prefixes of the removed functions
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetTrimmedPackages(prefixes ...string) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.TrimmedPackages = prefixes
+	return true
+}
+
+// Set how detailed the reports are printed to the console
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   verbosity (Verbosity): VerbosityQuiet, VerbositySummary, VerbosityFull or
+//    VerbosityDebug
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetVerbosity(verbosity Verbosity) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.Verbosity = verbosity
+	return true
+}
+
+// Set whether the reports printed to the console are colored
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   mode (ColorMode): ColorAuto, ColorAlways or ColorNever
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetColor(mode ColorMode) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.Color = mode
+	return true
+}
+
+// Set the format in which the reports are printed
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   format (OutputFormat): OutputText, OutputGitHub or OutputJSON
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetOutputFormat(format OutputFormat) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.OutputFormat = format
+	return true
+}
+
+// Set the maximum number of locks a routine should hold at the same time.
+// Deeper nestings are reported as warnings
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   number (int): maximum number of held locks, 0 to disable the check
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetMaxLockDepth(number int) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.MaxLockDepth = number
+	return true
+}
+
+// Set the time after which a routine waiting for a lock is reported together
+// with the routines holding the lock
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   seconds (int): threshold in seconds, 0 to disable the report
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetLongWaitThreshold(seconds int) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.LongWaitThreshold = time.Second * time.Duration(seconds)
+	return true
+}
+
+// Set the declared lock order. Acquisitions, which contradict it, are
+// reported as warnings
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   chains ([][]string): chains of lock patterns in the order, in which the
+//    locks must be acquired
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetLockOrder(chains ...[]string) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	for _, chain := range chains {
+		if validateLockOrderChain(chain) != nil {
+			return false
+		}
+	}
+	defaultDetector.opts.LockOrder = chains
+	return true
+}
+
+// Set the fraction of one CPU, which can be used by the periodical detection.
+// Passes are skipped if the previous passes used more than the budget
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   fraction (float64): fraction of one CPU, e.g. 0.01 for 1%, 0 to not
+//    limit the periodical detection
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetPeriodicDetectionBudget(fraction float64) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.PeriodicDetectionBudget = fraction
+	return true
+}
+
+// Set the interval in which the progress of the comprehensive detection is
+// emitted
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   interval (time.Duration): interval of the progress, 0 to disable it
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetProgressInterval(interval time.Duration) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.ProgressInterval = interval
+	return true
+}
+
+// Set the function, which receives the progress of the comprehensive
+// detection, e.g. to render a progress bar
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   fn (ProgressFunc): function for the progress, nil to print it to stderr
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetProgressFunc(fn ProgressFunc) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.Progress = fn
+	return true
+}
+
+// Set the maximum number of dependencies in a chain explored by the
+// comprehensive detection
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   number (int): maximum depth of the search, 0 to not limit it
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetMaxSearchDepth(number int) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.MaxSearchDepth = number
+	return true
+}
+
+// Set the time after which the comprehensive detection is stopped
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   seconds (int): maximum time of the search in seconds, 0 to not limit it
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetMaxSearchTime(seconds int) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.MaxSearchTime = time.Second * time.Duration(seconds)
+	return true
+}
+
+// Set the thresholds of the comprehensive detection. The search for cycles
+// is only run, if at least minRoutines routines have used locks and the lock
+// trees contain at least minDependencies unique dependencies
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   minRoutines (int): minimum number of routines, at least 1
+//   minDependencies (int): minimum number of unique dependencies
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetComprehensiveDetectionThresholds(minRoutines int, minDependencies int) bool {
+	if defaultDetector.initialized || minRoutines < 1 || minDependencies < 0 {
+		return false
+	}
+	defaultDetector.opts.MinRoutines = minRoutines
+	defaultDetector.opts.MinDependencies = minDependencies
+	return true
+}
+
+// Enable or disable the detection of self deadlocks over a chain of locks,
+// if the check for double locking is disabled
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   enable (bool): true to enable, false to disable
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetSelfDeadlockDetection(enable bool) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.SelfDeadlockDetection = enable
+	return true
+}
+
+// Set the unix socket of the broker, to which the dependencies between
+// distributed locks are published. If it is empty, they are not published
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   socket (string): path of the unix socket of the broker
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetLockGraphSocket(socket string) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.LockGraphSocket = socket
+	return true
+}
+
+// Set the minimum number of unique reports of a comprehensive detection,
+// for which a summary of the reports grouped by subsystem is reported
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   number (int): minimum number of reports, 0 to disable the summary
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetSummaryMinReports(number int) bool {
+	if defaultDetector.initialized || number < 0 {
+		return false
+	}
+	defaultDetector.opts.SummaryMinReports = number
+	return true
+}
+
+// Set the file with the identifiers of the known cycles, which are not
+// reported. If the file does not exist, it is created with the cycles of the
+// first comprehensive detection. If it is empty, no baseline is used
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   path (string): path of the baseline file
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetBaselineFile(path string) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.BaselineFile = path
+	return true
+}
+
+// Enable or disable the termination of the program with a nonzero exit
+// code, if the comprehensive detection found cycles, which are not in the
+// baseline
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   enable (bool): true to enable, false to disable
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetFailOnNewCycles(enable bool) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.FailOnNewCycles = enable
+	return true
+}
+
+// Enable or disable the pruning of the locks, which are only used by one
+// routine, before the search for cycles
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   enable (bool): true to enable, false to disable
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetPruneSingleRoutineLocks(enable bool) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.PruneSingleRoutineLocks = enable
+	return true
+}
+
+// Enable or disable the call stacks of all goroutines in the report of a
+// local deadlock, which terminates the program
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   enable (bool): true to enable, false to disable
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetGoroutineDump(enable bool) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.GoroutineDump = enable
+	return true
+}
+
+// Set the maximum time for which the shutdown hooks are run before the
+// program is terminated because of a deadlock
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   timeout (time.Duration): maximum time, 0 to wait until the hooks have
+//    finished
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetShutdownHookTimeout(timeout time.Duration) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.ShutdownHookTimeout = timeout
+	return true
+}
+
+// Set the stages, which are run after a deadlock was reported and before the
+// program is terminated
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   policy (EscalationPolicy): stages of the escalation
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetEscalationPolicy(policy EscalationPolicy) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.Escalation = policy
+	return true
+}
+
+// Set the number of acquisitions per second, from which on a lock, which is
+// never acquired together with other locks, is acquired on a lightweight path
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   number (int): acquisitions per second, 0 to disable the detection of
+//    hot locks
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetHotLockRate(number int) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.HotLockRate = number
+	return true
+}
+
+// Set the number of locks, which a routine can hold at the same time, before
+// a warning is reported
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   number (int): number of held locks, 0 to disable the check
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetHoldingSetBound(number int) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.HoldingSetBound = number
+	return true
+}
+
+// Set the file, to which the summary of each comprehensive detection is
+// written
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   path (string): path of the file, empty to not write the summary
+//   format (SummaryFormat): SummaryText or SummaryJSON
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetSummaryFile(path string, format SummaryFormat) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.SummaryFile = path
+	defaultDetector.opts.SummaryFormat = format
+	return true
+}
+
+// Set the algorithm of the comprehensive detection to search for cycles
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   algorithm (CycleSearchAlgorithm): CycleSearchChains or CycleSearchJohnson
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetCycleSearch(algorithm CycleSearchAlgorithm) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.CycleSearch = algorithm
+	return true
+}
+
+// Set the file, to which the new unique dependencies are appended
+// periodically
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   path (string): path of the file, empty to not write the dependencies
+//   interval (time.Duration): time between two writes, must be positive
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetDeltaFile(path string, interval time.Duration) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.DeltaFile = path
+	defaultDetector.opts.DeltaInterval = interval
+	return true
+}
+
+// Enable or disable the examples of the edges in the reports of potential
+// deadlocks. The call stack of each acquisition of a lock is saved while the
+// lock is held, which slows down the lock operations
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   enable (bool): true to enable the examples
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetEdgeExamples(enable bool) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.EdgeExamples = enable
+	return true
+}
+
+// Enable or disable the quiet summary mode. In this mode nothing is printed
+// while the program runs and one summary block is printed by the shutdown of
+// the detector or before it terminates the program
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   enable (bool): true to enable the quiet summary mode
+//   reportFile (string): file, to which the reports are written as JSON
+//    lines if no sink is set, empty to only count the reports
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetQuietSummary(enable bool, reportFile string) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.QuietSummary = enable
+	defaultDetector.opts.ReportFile = reportFile
+	return true
+}
+
+// Enable or disable the stop-the-world mode. In this mode the lock operations
+// wait, while the snapshot of the lock trees for the comprehensive detection
+// is taken, which slows down every lock operation
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   enable (bool): true to enable the stop-the-world mode
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetStopTheWorld(enable bool) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.opts.StopTheWorld = enable
+	return true
+}
+
+// Enable or disable the recording of the critical sections of the locks,
+// which can be exported with WriteCriticalSections. The release site is
+// resolved for each release, which slows down the program
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   enable (bool): true to record the critical sections
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetCriticalSections(enable bool) bool {
+	if defaultDetector.initialized {
 		return false
 	}
-	opts.maxCallStackSize = number
+	defaultDetector.opts.CriticalSections = enable
 	return true
 }
 
 // automatically set activated according to the other options
 //  Returns:
 //   nil
-func setActivatedAuto() {
-	if !(opts.periodicDetection || opts.checkDoubleLocking || opts.comprehensiveDetection) {
-		opts.activated = false
+func (o *Options) setActivatedAuto() {
+	if !(o.PeriodicDetection || o.CheckDoubleLocking || o.ComprehensiveDetection ||
+		len(o.LockOrder) > 0) {
+		o.Activated = false
 		return
 	}
-	opts.activated = true
+	o.Activated = true
 
 }
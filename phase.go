@@ -0,0 +1,329 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+phase.go
+Implementation of the differential detection between two phases of the
+program. A runtime transition, e.g. the reload of the configuration, is
+delimited by two marks. The edges of the lock order, which were observed
+for the first time between the marks, only exist since the transition and
+are written separately from the edges of the first phase, so that a
+deadlock introduced by the transition can be isolated. An edge, whose
+opposite edge was already observed in the first phase, is a new lock
+inversion and is marked in all formats. The phases are based on the times
+of the first acquisitions of the dependencies, the lock trees are not
+copied at the marks.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Phase is a mark in the execution of the program, which delimits the
+// phases compared by WritePhaseDiff
+type Phase struct {
+	// detector the mark belongs to
+	detector *Detector
+	// name of the mark, e.g. "before reload"
+	name string
+	// wall clock time of the mark
+	time time.Time
+}
+
+// edge of the lock order, which was first observed in the second phase
+type phaseEdge struct {
+	*lockOrderEdge
+	// true if the opposite edge was observed in the first phase
+	reverses bool
+}
+
+// MarkPhase marks the current point of the execution for the default
+// detector (see Detector.MarkPhase)
+//  Args:
+//   name (string): name of the mark
+//  Returns:
+//   (*Phase): the mark
+func MarkPhase(name string) *Phase {
+	return defaultDetector.MarkPhase(name)
+}
+
+// MarkPhase marks the current point of the execution, e.g. before and after
+// a reload of the configuration. The edges of the lock order between two
+// marks are written by WritePhaseDiff
+//  Args:
+//   name (string): name of the mark
+//  Returns:
+//   (*Phase): the mark
+func (d *Detector) MarkPhase(name string) *Phase {
+	return &Phase{detector: d, name: name, time: time.Now()}
+}
+
+// Name returns the name of the mark
+//  Returns:
+//   (string): name of the mark
+func (p *Phase) Name() string {
+	return p.name
+}
+
+// WritePhaseDiff writes the edges of the lock order, which were observed for
+// the first time after the mark before and not after the mark after. If
+// after is nil, all edges observed since before are written. Both marks
+// must belong to the same detector
+//  Args:
+//   w (io.Writer): writer to write to
+//   before (*Phase): mark at the start of the second phase
+//   after (*Phase): mark at the end of the second phase, nil for now
+//   format (LockOrderFormat): LockOrderDOT, LockOrderMarkdown or LockOrderJSON
+//  Returns:
+//   (error): error if the marks are invalid or the edges could not be
+//    written
+func WritePhaseDiff(w io.Writer, before *Phase, after *Phase, format LockOrderFormat) error {
+	if before == nil {
+		return fmt.Errorf("deadlock: WritePhaseDiff needs the mark before the phase")
+	}
+	if after != nil && after.detector != before.detector {
+		return fmt.Errorf("deadlock: the marks %q and %q belong to different detectors",
+			before.name, after.name)
+	}
+	if after != nil && after.time.Before(before.time) {
+		return fmt.Errorf("deadlock: the mark %q was set before the mark %q",
+			after.name, before.name)
+	}
+
+	locks, edges := before.detector.phaseDiff(before, after)
+	switch format {
+	case LockOrderDOT:
+		return writePhaseDiffDOT(w, locks, edges)
+	case LockOrderMarkdown:
+		return writePhaseDiffMarkdown(w, before, after, edges)
+	case LockOrderJSON:
+		return writePhaseDiffJSON(w, before, after, locks, edges)
+	}
+	return fmt.Errorf("deadlock: unknown lock order format %d", format)
+}
+
+// collect the edges of the lock order, which were first observed between
+// the marks
+//  Args:
+//   before (*Phase): mark at the start of the second phase
+//   after (*Phase): mark at the end of the second phase, nil for now
+//  Returns:
+//   (map[string]lockOrderNode): locks of the new edges
+//   ([]phaseEdge): new edges sorted by their locks
+func (d *Detector) phaseDiff(before *Phase, after *Phase) (map[string]lockOrderNode, []phaseEdge) {
+	allLocks, allEdges := d.lockOrder()
+
+	// edges of the first phase
+	earlier := make(map[[2]string]bool)
+	for _, edge := range allEdges {
+		if edge.first.Before(before.time) {
+			earlier[[2]string{edge.from, edge.to}] = true
+		}
+	}
+
+	locks := make(map[string]lockOrderNode)
+	edges := make([]phaseEdge, 0)
+	for _, edge := range allEdges {
+		if edge.first.Before(before.time) || (after != nil && edge.first.After(after.time)) {
+			continue
+		}
+		locks[edge.from] = allLocks[edge.from]
+		locks[edge.to] = allLocks[edge.to]
+		edges = append(edges, phaseEdge{
+			lockOrderEdge: edge,
+			reverses:      earlier[[2]string{edge.to, edge.from}],
+		})
+	}
+	return locks, edges
+}
+
+// get the description of the end of the second phase
+//  Args:
+//   after (*Phase): mark at the end of the second phase, nil for now
+//  Returns:
+//   (string): name and time of the mark, "now" if after is nil
+func phaseEnd(after *Phase) string {
+	if after == nil {
+		return "now"
+	}
+	return fmt.Sprintf("%s (%s)", after.name, after.time.Format(time.RFC3339))
+}
+
+// write the new edges as a graph in the DOT language. Edges, which reverse
+// an edge of the first phase, are red
+//  Args:
+//   w (io.Writer): writer to write to
+//   locks (map[string]lockOrderNode): names, resource names and tags of the locks
+//   edges ([]phaseEdge): new edges
+//  Returns:
+//   (error): error if the graph could not be written
+func writePhaseDiffDOT(w io.Writer, locks map[string]lockOrderNode, edges []phaseEdge) error {
+	var b strings.Builder
+	b.WriteString("digraph phasediff {\n")
+	b.WriteString("\tnode [shape=box];\n")
+
+	names := make([]string, 0, len(locks))
+	for name := range locks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		label := locks[name].resource + "\n" + name
+		if locks[name].tags != "" {
+			label += "\n" + locks[name].tags
+		}
+		fmt.Fprintf(&b, "\t%q [label=%q];\n", name, label)
+	}
+
+	for _, edge := range edges {
+		label := append(sortedKeys(edge.sites), fmt.Sprint("observed ", edge.count, "x"),
+			"first "+edge.first.Format(time.RFC3339))
+		label = append(label, goroutineLabels(edge.goroutines)...)
+		color := "black"
+		if edge.reverses {
+			label = append(label, "reverses an earlier edge")
+			color = "red"
+		}
+		fmt.Fprintf(&b, "\t%q -> %q [label=%q, color=%s, reverses=%t];\n", edge.from,
+			edge.to, strings.Join(label, "\n"), color, edge.reverses)
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// write the new edges as a Markdown table
+//  Args:
+//   w (io.Writer): writer to write to
+//   before (*Phase): mark at the start of the second phase
+//   after (*Phase): mark at the end of the second phase, nil for now
+//   edges ([]phaseEdge): new edges
+//  Returns:
+//   (error): error if the table could not be written
+func writePhaseDiffMarkdown(w io.Writer, before *Phase, after *Phase, edges []phaseEdge) error {
+	var b strings.Builder
+	b.WriteString("# New lock order edges\n\n")
+	fmt.Fprintf(&b, "Edges first observed between %s (%s) and %s.\n\n", before.name,
+		before.time.Format(time.RFC3339), phaseEnd(after))
+	if len(edges) == 0 {
+		b.WriteString("No new edges.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	b.WriteString("A lock in the first column was held, while the lock in the " +
+		"second column was acquired.\n\n")
+	b.WriteString("| Held lock | Acquired lock | Acquisition sites | Goroutines " +
+		"| Observed | First seen | Reverses an earlier edge |\n")
+	b.WriteString("|---|---|---|---|---|---|---|\n")
+	for _, edge := range edges {
+		sites := sortedKeys(edge.sites)
+		for i := range sites {
+			sites[i] = "`" + sites[i] + "`"
+		}
+		reverses := "no"
+		if edge.reverses {
+			reverses = "yes"
+		}
+		goroutines := goroutineLabels(edge.goroutines)
+		for i := range goroutines {
+			goroutines[i] = strings.ReplaceAll(goroutines[i], "|", "\\|")
+		}
+		fmt.Fprintf(&b, "| `%s` | `%s` | %s | %s | %d | %s | %s |\n", edge.from,
+			edge.to, strings.Join(sites, "<br>"), strings.Join(goroutines, "<br>"),
+			edge.count, edge.first.Format(time.RFC3339), reverses)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// new edge in the JSON format
+type phaseDiffJSONEdge struct {
+	lockOrderJSONEdge
+	Reverses bool `json:"reverses"`
+}
+
+// write the new edges as a JSON object with the marks, the locks and the
+// edges
+//  Args:
+//   w (io.Writer): writer to write to
+//   before (*Phase): mark at the start of the second phase
+//   after (*Phase): mark at the end of the second phase, nil for now
+//   locks (map[string]lockOrderNode): names, resource names and tags of the locks
+//   edges ([]phaseEdge): new edges
+//  Returns:
+//   (error): error if the object could not be written
+func writePhaseDiffJSON(w io.Writer, before *Phase, after *Phase,
+	locks map[string]lockOrderNode, edges []phaseEdge) error {
+	var diff struct {
+		Schema     int                 `json:"schema"`
+		Before     string              `json:"before"`
+		BeforeTime time.Time           `json:"before_time"`
+		After      string              `json:"after,omitempty"`
+		AfterTime  *time.Time          `json:"after_time,omitempty"`
+		Locks      []lockOrderJSONLock `json:"locks"`
+		Edges      []phaseDiffJSONEdge `json:"edges"`
+	}
+	diff.Schema = SchemaVersion
+	diff.Before = before.name
+	diff.BeforeTime = before.time
+	if after != nil {
+		diff.After = after.name
+		diff.AfterTime = &after.time
+	}
+
+	names := make([]string, 0, len(locks))
+	for name := range locks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	diff.Locks = make([]lockOrderJSONLock, 0, len(names))
+	for _, name := range names {
+		diff.Locks = append(diff.Locks, lockOrderJSONLock{
+			Name: name,
+			Type: locks[name].resource,
+			Tags: locks[name].tags,
+		})
+	}
+
+	diff.Edges = make([]phaseDiffJSONEdge, 0, len(edges))
+	for _, edge := range edges {
+		diff.Edges = append(diff.Edges, phaseDiffJSONEdge{
+			lockOrderJSONEdge: newLockOrderJSONEdge(edge.lockOrderEdge),
+			Reverses:          edge.reverses,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(diff)
+}
@@ -0,0 +1,74 @@
+//go:build js
+
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+platformJs.go
+This file implements the parts of the detector, which depend on the platform,
+for js/wasm. All routines share one thread, which also runs the event loop of
+the host. The periodical detection is therefore driven by timers: the next
+pass is only scheduled after the last one has finished, so that no routine
+is parked for the detection and passes never pile up. Exiting the program
+would end the whole wasm instance, therefore the detector only reports
+deadlocks and stops.
+*/
+
+import (
+	"errors"
+	"time"
+)
+
+// schedule the passes of the periodical detection at equal intervals, until
+// the detector is stopped
+//  Returns:
+//   nil
+func (d *Detector) startPeriodicalDetection() {
+	// passes are skipped, if they use more than the budget
+	budget := newDetectionBudget(d.opts.PeriodicDetectionTime,
+		d.opts.PeriodicDetectionBudget)
+
+	var pass func()
+	pass = func() {
+		if d.stopped() {
+			close(d.periodicDone)
+			return
+		}
+
+		d.periodicalPass(budget)
+		time.AfterFunc(d.opts.PeriodicDetectionTime, pass)
+	}
+	time.AfterFunc(d.opts.PeriodicDetectionTime, pass)
+}
+
+// the program is not terminated on js/wasm
+const exitSupported = false
+
+// signals are not supported on js/wasm
+//  Returns:
+//   (error): always an error
+func sendQuitSignal() error {
+	return errors.New("signals are not supported on js/wasm")
+}
@@ -0,0 +1,68 @@
+//go:build !js
+
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+platformNative.go
+This file implements the parts of the detector, which depend on the platform,
+for all platforms except js/wasm. The periodical detection runs in its own
+routine and the program can be terminated after a deadlock was detected.
+*/
+
+import "time"
+
+// start the routine, which runs the periodical detection at equal intervals,
+// until the detector is stopped
+//  Returns:
+//   nil
+func (d *Detector) startPeriodicalDetection() {
+	go func() {
+		defer close(d.periodicDone)
+
+		// timer to send a signals at equal intervals
+		timer := time.NewTicker(d.opts.PeriodicDetectionTime)
+		defer timer.Stop()
+
+		// passes are skipped, if they use more than the budget
+		budget := newDetectionBudget(d.opts.PeriodicDetectionTime,
+			d.opts.PeriodicDetectionBudget)
+
+		// run the periodical detection if a timer signal is received, until
+		// the detector is stopped
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-timer.C:
+			}
+
+			d.periodicalPass(budget)
+		}
+	}()
+}
+
+// the program is terminated after a deadlock was detected
+const exitSupported = true
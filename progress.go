@@ -0,0 +1,347 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+progress.go
+Implementation of the progress of the comprehensive detection. For big
+programs the comprehensive detection at the end of the program can take a
+long time and appear to hang. If a progress interval is set, the progress
+of the detection is passed to the progress function or printed to stderr
+in this interval. The progress contains the current phase, the number of
+processed routines, the number of explored chains, the current depth of the
+search and the elapsed time.
+The tracker of the progress also enforces the limits of the search. If the
+depth or time limit or a cancellation cuts the search short, the result is
+not exhaustive.
+This is recorded as truncation and reported at the end of the detection.
+*/
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Progress describes the progress of the comprehensive detection
+type Progress struct {
+	// current phase of the detection, "init order", "upgrades" or "cycles"
+	Phase string
+	// number of routines, which were completely processed as starting
+	// routines of the cycle search
+	RoutinesProcessed int
+	// number of routines
+	Routines int
+	// number of chains explored by the cycle search
+	ChainsExplored int
+	// number of dependencies in the currently explored chain
+	Depth int
+	// number of dependencies, which were removed from the cycle search,
+	// because their locks are only used by one routine
+	DependenciesPruned int
+	// time since the start of the detection
+	Elapsed time.Duration
+	// true for the last progress, after the detection has finished
+	Done bool
+	// limits, which cut the search short, nil if the search was exhaustive
+	Truncation *Truncation
+}
+
+// Truncation describes how much of the lock trees was searched, if a limit
+// cut the comprehensive detection short
+type Truncation struct {
+	// limits, which were reached, "depth", "time" and/or "canceled"
+	Limits []string `json:"limits"`
+	// number of routines, which were completely processed as starting
+	// routines of the cycle search
+	RoutinesCovered int `json:"routines_covered"`
+	// number of routines
+	Routines int `json:"routines"`
+	// number of chains explored by the cycle search
+	ChainsExplored int `json:"chains_explored"`
+	// number of chains, which were not extended because of the depth limit
+	ChainsCut int `json:"chains_cut"`
+}
+
+// String returns the truncation as a machine-readable line of key=value pairs
+//  Returns:
+//   (string): the truncation
+func (t Truncation) String() string {
+	return fmt.Sprintf("limits=%s routines_covered=%d routines=%d "+
+		"chains_explored=%d chains_cut=%d", strings.Join(t.Limits, ","),
+		t.RoutinesCovered, t.Routines, t.ChainsExplored, t.ChainsCut)
+}
+
+// ProgressFunc receives the progress of the comprehensive detection
+type ProgressFunc func(Progress)
+
+// number of explored chains after which the time is checked
+const progressCheckInterval = 1024
+
+// tracker for the progress of one run of the comprehensive detection
+type progressTracker struct {
+	// current progress
+	progress Progress
+	// start of the detection
+	start time.Time
+	// time of the last emitted progress
+	last time.Time
+	// interval in which the progress is emitted, 0 if it is not emitted
+	interval time.Duration
+	// function to pass the progress to, if nil it is printed to stderr
+	fn ProgressFunc
+	// maximum number of dependencies in an explored chain, 0 if unlimited
+	maxDepth int
+	// time after which the search is stopped, zero if unlimited
+	deadline time.Time
+	// set if the depth limit was reached
+	depthReached bool
+	// set if the time limit was reached
+	timeReached bool
+	// closed to cancel the search, nil if the search can not be canceled
+	cancel <-chan struct{}
+	// set if the search was canceled
+	canceled bool
+	// number of chains, which were not extended because of the depth limit
+	chainsCut int
+	// number of candidates rejected by each rule of the search
+	pruning Pruning
+	// durations of the finished phases
+	phases []PhaseDuration
+	// start of the current phase, zero if no phase is running
+	phaseStart time.Time
+}
+
+// create a new progress tracker
+//  Args:
+//   cancel (<-chan struct{}): closed to cancel the search, nil if the
+//    search can not be canceled
+//  Returns:
+//   (*progressTracker): the tracker
+func (d *Detector) newProgressTracker(cancel <-chan struct{}) *progressTracker {
+	now := time.Now()
+	t := &progressTracker{
+		progress: Progress{Routines: d.snapshot.routines()},
+		start:    now,
+		last:     now,
+		interval: d.opts.ProgressInterval,
+		fn:       d.opts.Progress,
+		maxDepth: d.opts.MaxSearchDepth,
+		cancel:   cancel,
+	}
+	if d.opts.MaxSearchTime > 0 {
+		t.deadline = now.Add(d.opts.MaxSearchTime)
+	}
+	return t
+}
+
+// start a new phase of the detection
+//  Args:
+//   phase (string): name of the phase
+//  Returns:
+//   nil
+func (t *progressTracker) phase(phase string) {
+	if t == nil {
+		return
+	}
+	t.endPhase()
+	t.progress.Phase = phase
+	t.progress.Depth = 0
+	t.phaseStart = time.Now()
+	if t.interval > 0 {
+		t.emit()
+	}
+}
+
+// save the duration of the current phase
+//  Returns:
+//   nil
+func (t *progressTracker) endPhase() {
+	if t.phaseStart.IsZero() {
+		return
+	}
+	t.phases = append(t.phases, PhaseDuration{
+		Phase:    t.progress.Phase,
+		Duration: time.Since(t.phaseStart),
+	})
+	t.phaseStart = time.Time{}
+}
+
+// set the number of dependencies removed from the cycle search
+//  Args:
+//   number (int): number of removed dependencies
+//  Returns:
+//   nil
+func (t *progressTracker) pruned(number int) {
+	if t == nil {
+		return
+	}
+	t.progress.DependenciesPruned = number
+}
+
+// count a routine as processed
+//  Returns:
+//   nil
+func (t *progressTracker) routineDone() {
+	if t == nil {
+		return
+	}
+	t.progress.RoutinesProcessed++
+	t.progress.Depth = 0
+	if t.interval > 0 && time.Since(t.last) >= t.interval {
+		t.emit()
+	}
+}
+
+// count an explored chain and check the time limit and the cancellation
+//  Args:
+//   depth (int): number of dependencies in the chain
+//  Returns:
+//   (bool): false if the search must be stopped, true otherwise
+func (t *progressTracker) explore(depth int) bool {
+	if t == nil {
+		return true
+	}
+	if t.timeReached || t.canceled {
+		return false
+	}
+
+	t.progress.ChainsExplored++
+	t.progress.Depth = depth
+	if t.progress.ChainsExplored%progressCheckInterval != 0 {
+		return true
+	}
+
+	select {
+	case <-t.cancel:
+		t.canceled = true
+		return false
+	default:
+	}
+
+	now := time.Now()
+	if !t.deadline.IsZero() && now.After(t.deadline) {
+		t.timeReached = true
+		return false
+	}
+	if t.interval > 0 && now.Sub(t.last) >= t.interval {
+		t.emit()
+	}
+	return true
+}
+
+// check if a chain can be extended
+//  Args:
+//   depth (int): number of dependencies in the chain
+//  Returns:
+//   (bool): true if the chain can be extended, false if the depth limit
+//    is reached
+func (t *progressTracker) extend(depth int) bool {
+	if t == nil || t.maxDepth <= 0 || depth < t.maxDepth {
+		return true
+	}
+	t.depthReached = true
+	t.chainsCut++
+	return false
+}
+
+// check if the time limit or the cancellation stopped the search
+//  Returns:
+//   (bool): true if the search was stopped, false otherwise
+func (t *progressTracker) stopped() bool {
+	return t != nil && (t.timeReached || t.canceled)
+}
+
+// get the truncation of the search
+//  Returns:
+//   (*Truncation): the truncation, nil if the search was exhaustive
+func (t *progressTracker) truncation() *Truncation {
+	if t == nil || !(t.depthReached || t.timeReached || t.canceled) {
+		return nil
+	}
+
+	limits := make([]string, 0, 3)
+	if t.depthReached {
+		limits = append(limits, "depth")
+	}
+	if t.timeReached {
+		limits = append(limits, "time")
+	}
+	if t.canceled {
+		limits = append(limits, "canceled")
+	}
+	return &Truncation{
+		Limits:          limits,
+		RoutinesCovered: t.progress.RoutinesProcessed,
+		Routines:        t.progress.Routines,
+		ChainsExplored:  t.progress.ChainsExplored,
+		ChainsCut:       t.chainsCut,
+	}
+}
+
+// emit the last progress after the detection has finished
+//  Returns:
+//   nil
+func (t *progressTracker) finish() {
+	if t == nil {
+		return
+	}
+	t.endPhase()
+	if t.interval <= 0 {
+		return
+	}
+	t.progress.Depth = 0
+	t.progress.Done = true
+	t.progress.Truncation = t.truncation()
+	t.emit()
+}
+
+// pass the current progress to the progress function or print it
+//  Returns:
+//   nil
+func (t *progressTracker) emit() {
+	t.last = time.Now()
+	t.progress.Elapsed = t.last.Sub(t.start)
+
+	if t.fn != nil {
+		t.fn(t.progress)
+		return
+	}
+
+	p := t.progress
+	if p.Done {
+		fmt.Fprintf(os.Stderr, "deadlock: detection finished: %d chains explored, "+
+			"%d dependencies pruned, %v elapsed", p.ChainsExplored,
+			p.DependenciesPruned, p.Elapsed.Round(time.Millisecond))
+		if p.Truncation != nil {
+			fmt.Fprintf(os.Stderr, ", truncated: %s", p.Truncation)
+		}
+		fmt.Fprintln(os.Stderr)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "deadlock: %s: %d/%d routines, %d chains explored, "+
+		"depth %d, %v elapsed\n", p.Phase, p.RoutinesProcessed, p.Routines,
+		p.ChainsExplored, p.Depth, p.Elapsed.Round(time.Millisecond))
+}
@@ -0,0 +1,177 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+provider.go
+This file implements the registration of lock types of other packages,
+which consist of multiple underlying locks, e.g. striped locks or maps of
+keyed mutexes. The lock type implements LockerProvider and registers the
+acquisitions and releases of its underlying locks after they were done.
+Each underlying lock is represented by a lock of the detector, whose own
+lock is never acquired, so that the underlying locks are added to the lock
+trees like other locks and cycles between them and the locks of the
+program are found by the detection. The package examples/stripedlock
+contains an example of a striped lock, which implements LockerProvider.
+*/
+
+import (
+	"fmt"
+	"sync"
+)
+
+// LockerProvider is implemented by lock types, which consist of multiple
+// underlying locks. The underlying locks are identified by ids, which are
+// chosen by the lock type, e.g. the index of a stripe. The ids must be
+// comparable
+type LockerProvider interface {
+	// get the name of the lock type, e.g. "StripedMutex"
+	LockerName() string
+	// get the name of an underlying lock, e.g. "stripe 3"
+	LockName(id interface{}) string
+}
+
+// ProvidedLocks registers the acquisitions and releases of the underlying
+// locks of a LockerProvider with a detector
+type ProvidedLocks struct {
+	// the registered lock type
+	provider LockerProvider
+	// detector the locks belong to
+	detector *Detector
+	// locks, which represent the underlying locks, by their ids
+	locks map[interface{}]*Mutex
+	// lock to prevent concurrent access to locks
+	lock sync.Mutex
+}
+
+// RegisterLockerProvider registers a lock type with the default detector.
+// Registering the same lock twice returns the same registration
+//  Args:
+//   p (LockerProvider): the lock type, must be comparable
+//  Returns:
+//   (*ProvidedLocks): the registration, with which the acquisitions and
+//    releases of the underlying locks are registered
+func RegisterLockerProvider(p LockerProvider) *ProvidedLocks {
+	return defaultDetector.RegisterLockerProvider(p)
+}
+
+// RegisterLockerProvider registers a lock type with the detector (see
+// RegisterLockerProvider)
+//  Args:
+//   p (LockerProvider): the lock type, must be comparable
+//  Returns:
+//   (*ProvidedLocks): the registration
+func (d *Detector) RegisterLockerProvider(p LockerProvider) *ProvidedLocks {
+	if p == nil {
+		panic("deadlock: RegisterLockerProvider with nil provider")
+	}
+
+	d.providersLock.Lock()
+	defer d.providersLock.Unlock()
+
+	if l, ok := d.providers[p]; ok {
+		return l
+	}
+	l := &ProvidedLocks{
+		provider: p,
+		detector: d,
+		locks:    make(map[interface{}]*Mutex),
+	}
+	d.providers[p] = l
+	return l
+}
+
+// get the lock, which represents an underlying lock, and create it, if it
+// does not exist yet. The creation of the lock is saved as the first
+// acquisition of the underlying lock
+//  Args:
+//   id (interface{}): id of the underlying lock
+//  Returns:
+//   (*Mutex): the lock
+func (l *ProvidedLocks) get(id interface{}) *Mutex {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if m, ok := l.locks[id]; ok {
+		return m
+	}
+
+	d := l.detector
+	if !d.initialized {
+		d.initialize()
+	}
+
+	m := &Mutex{provided: true}
+	frame := userFrame()
+	m.init(d, frame.File, frame.Line, frame.Function)
+	m.SetTag("resource", fmt.Sprint(l.provider.LockerName(), " ",
+		l.provider.LockName(id)))
+	l.locks[id] = m
+	return m
+}
+
+// Acquired registers that the routine has acquired an underlying lock
+//  Args:
+//   id (interface{}): id of the underlying lock
+//  Returns:
+//   nil
+func (l *ProvidedLocks) Acquired(id interface{}) {
+	lockInt(l.get(id), false, false)
+}
+
+// TryAcquired registers that the routine has acquired an underlying lock
+// with a try-lock. Because the acquisition could not have blocked, it does
+// not create dependencies on the locks held by the routine
+//  Args:
+//   id (interface{}): id of the underlying lock
+//  Returns:
+//   nil
+func (l *ProvidedLocks) TryAcquired(id interface{}) {
+	tryLockInt(l.get(id), false)
+}
+
+// Released registers that the routine has released an underlying lock
+//  Args:
+//   id (interface{}): id of the underlying lock
+//  Returns:
+//   nil
+func (l *ProvidedLocks) Released(id interface{}) {
+	m := l.get(id)
+	if m.getDetector().opts.Activated {
+		unlockInt(m)
+	}
+}
+
+// check if a lock represents an underlying lock of a LockerProvider, whose
+// own lock must not be acquired
+//  Args:
+//   m (mutexInt): lock
+//  Returns:
+//   (bool): true if m represents an underlying lock of a LockerProvider
+func isProvided(m mutexInt) bool {
+	if mu, ok := m.(*Mutex); ok {
+		return mu.provided
+	}
+	return false
+}
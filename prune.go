@@ -0,0 +1,181 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+prune.go
+Implementation of the pruning of the lock trees before the search for
+cycles. Two consecutive dependencies of a cycle always belong to different
+routines and the lock of the first one is held in the second one. A lock,
+which only appears in the dependencies of one routine, can therefore not
+connect two dependencies of a cycle. The dependencies, whose lock is such a
+lock or whose held locks are all such locks, are removed from the search.
+The remaining dependencies are combined into the lock-order graph
+(internal/graph). A dependency can only be part of a cycle, if one of its
+edges is part of a cycle of the graph, i.e. if the held lock and the lock
+of the dependency are in the same strongly connected component
+(internal/analyze). All other dependencies are removed as well.
+The lock trees themselves are not changed, so that the detection of cycles
+in the init order, the verification of reports and the reference check are
+not affected.
+*/
+
+import (
+	"github.com/ErikKassubek/Deadlock-Go/internal/analyze"
+	"github.com/ErikKassubek/Deadlock-Go/internal/graph"
+)
+
+// compute the dependencies of each routine, which are used by the search
+// for cycles
+//  Returns:
+//   ([][]*dependency): dependencies of each routine, which can be part of a
+//    cycle
+//   (int): number of removed dependencies
+func (d *Detector) pruneDependencies() ([][]*dependency, int) {
+	trees := d.snapshot.dependencies
+	if !d.opts.PruneSingleRoutineLocks {
+		return trees, 0
+	}
+	res := make([][]*dependency, len(trees))
+
+	// routine of each lock, -1 if the lock appears in more than one routine
+	routineOfLock := make(map[interface{}]int)
+	use := func(m mutexInt, index int) {
+		key := lockKey(m)
+		if r, ok := routineOfLock[key]; ok && r != index {
+			routineOfLock[key] = -1
+		} else if !ok {
+			routineOfLock[key] = index
+		}
+	}
+	for i, deps := range trees {
+		for _, dep := range deps {
+			use(dep.mu, i)
+			for k := 0; k < dep.holdingCount; k++ {
+				use(dep.holdingSet[k], i)
+			}
+		}
+	}
+	shared := func(m mutexInt) bool {
+		return routineOfLock[lockKey(m)] == -1
+	}
+
+	pruned := 0
+	for i, deps := range trees {
+		res[i] = make([]*dependency, 0, len(deps))
+		for _, dep := range deps {
+			keep := false
+			if shared(dep.mu) {
+				for k := 0; k < dep.holdingCount; k++ {
+					if shared(dep.holdingSet[k]) {
+						keep = true
+						break
+					}
+				}
+			}
+			if keep {
+				res[i] = append(res[i], dep)
+			} else {
+				pruned++
+			}
+		}
+	}
+
+	res, prunedAcyclic := pruneAcyclicDependencies(res, analyze.SCC{})
+	return res, pruned + prunedAcyclic
+}
+
+// remove the dependencies, whose edges in the lock-order graph can not be
+// part of a cycle
+//  Args:
+//   deps ([][]*dependency): dependencies of each routine
+//   a (analyze.Analyzer): analyzer to find the edges of the cycles
+//  Returns:
+//   ([][]*dependency): dependencies of each routine, which can be part of a
+//    cycle
+//   (int): number of removed dependencies
+func pruneAcyclicDependencies(deps [][]*dependency, a analyze.Analyzer) ([][]*dependency, int) {
+	g := newLockOrderGraph(deps)
+	keep := make([][]bool, len(deps))
+	for i := range deps {
+		keep[i] = make([]bool, len(deps[i]))
+	}
+	cycleEdges := a.CycleEdges(g)
+	for i, e := range g.Edges() {
+		if !cycleEdges[i] {
+			continue
+		}
+		for _, dep := range e.Deps {
+			keep[dep.Routine][dep.Index] = true
+		}
+	}
+
+	res := make([][]*dependency, len(deps))
+	pruned := 0
+	for i := range deps {
+		res[i] = make([]*dependency, 0, len(deps[i]))
+		for j, dep := range deps[i] {
+			if keep[i][j] {
+				res[i] = append(res[i], dep)
+			} else {
+				pruned++
+			}
+		}
+	}
+	return res, pruned
+}
+
+// create the lock-order graph of dependencies. Each dependency creates an
+// edge from each of its held locks to its lock
+//  Args:
+//   deps ([][]*dependency): dependencies of each routine
+//  Returns:
+//   (*graph.Graph): the graph, the edges reference the positions of the
+//    dependencies in deps
+func newLockOrderGraph(deps [][]*dependency) *graph.Graph {
+	g := graph.New()
+	for i := range deps {
+		for j, dep := range deps[i] {
+			for k := 0; k < dep.holdingCount; k++ {
+				g.AddEdge(lockKey(dep.holdingSet[k]), lockKey(dep.mu),
+					graph.Dep{Routine: i, Index: j})
+			}
+		}
+	}
+	return g
+}
+
+// get a key, which is equal for two locks, if they have the same
+// underlying lock (see mutexHaveEqualLock)
+//  Args:
+//   m (mutexInt): lock
+//  Returns:
+//   (interface{}): the key of the lock
+func lockKey(m mutexInt) interface{} {
+	isMutex, mutex, rwMutex := m.getLock()
+	if isMutex {
+		return mutex
+	}
+	return rwMutex
+}
@@ -0,0 +1,141 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+quietSummary.go
+Implementation of the quiet summary mode. In this mode, the detector prints
+nothing while the program runs. The reports are passed to the sink, if one
+is set, or appended as JSON lines (see OutputJSON) to the report file, if
+one is set. When the detector is shut down or terminates the program because
+of a deadlock, it prints exactly one block with the number of unique reports
+of each severity and the path of the report file, so that the detector can
+be embedded in tools with their own output discipline.
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// reports collected in the quiet summary mode
+type quietSummary struct {
+	// keys of the reports, which were already counted
+	seen map[string]bool
+	// number of unique reports of each severity
+	counts map[ReportType]int
+	// report file, nil if it was not opened yet
+	file *os.File
+	// true after the summary block was printed
+	printed bool
+}
+
+// count a report in the quiet summary mode and write it to the report file,
+// if no sink is set. Reports, which repeat an earlier report, e.g. of an
+// earlier detection, are neither counted nor written again
+//  Args:
+//   r (Report): report with the text
+//  Returns:
+//   nil
+func (d *Detector) addQuietReport(r Report) {
+	d.quietLock.Lock()
+	defer d.quietLock.Unlock()
+
+	q := d.quiet
+	if q == nil {
+		q = &quietSummary{seen: make(map[string]bool), counts: make(map[ReportType]int)}
+		d.quiet = q
+	}
+
+	sites := make([]string, 0, len(r.Sites))
+	for _, site := range r.Sites {
+		sites = append(sites, fmt.Sprint(site.File, ":", site.Line))
+	}
+	key := r.Title + "\x00" + r.ID + "\x00" + strings.Join(sites, "\x00")
+	if q.seen[key] {
+		return
+	}
+	q.seen[key] = true
+	if !r.info {
+		q.counts[r.Type]++
+	}
+
+	if d.sink != nil || d.opts.ReportFile == "" {
+		return
+	}
+	if q.file == nil {
+		f, err := os.Create(d.opts.ReportFile)
+		if err != nil {
+			panic(fmt.Sprint("Could not create the report file ", d.opts.ReportFile,
+				": ", err))
+		}
+		q.file = f
+	}
+	writeReportJSON(q.file, r)
+}
+
+// print the summary block of the quiet summary mode, if it was not printed
+// yet, and close the report file
+//  Returns:
+//   nil
+func (d *Detector) printQuietSummary() {
+	if !d.opts.QuietSummary {
+		return
+	}
+
+	d.quietLock.Lock()
+	defer d.quietLock.Unlock()
+
+	q := d.quiet
+	if q == nil {
+		q = &quietSummary{counts: make(map[ReportType]int)}
+		d.quiet = q
+	}
+	if q.printed {
+		return
+	}
+	q.printed = true
+
+	var b bytes.Buffer
+	fmt.Fprintln(&b, "Deadlock detection summary:")
+	fmt.Fprintln(&b, "  Deadlocks:", q.counts[ReportDeadlock])
+	fmt.Fprintln(&b, "  Potential deadlocks:", q.counts[ReportPotentialDeadlock])
+	fmt.Fprintln(&b, "  Warnings:", q.counts[ReportWarning])
+	switch {
+	case d.sink != nil:
+		fmt.Fprintln(&b, "  Reports: passed to the report sink")
+	case d.opts.ReportFile != "":
+		fmt.Fprintln(&b, "  Reports:", d.opts.ReportFile)
+	default:
+		fmt.Fprintln(&b, "  Reports: not written (no report file set)")
+	}
+	os.Stderr.Write(b.Bytes())
+
+	if q.file != nil {
+		q.file.Close()
+		q.file = nil
+	}
+}
@@ -0,0 +1,287 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+recursiveRLock.go
+This file implements the global analysis of recursive reader locks. If a
+routine holds the reader lock of a rw-mutex and acquires it again, while a
+writer of another routine waits for the rw-mutex, the second acquisition
+waits for the writer and the writer waits for the release of the first
+acquisition. The attempts to acquire the writer lock of a rw-mutex are
+recorded with the locks held by the writer, so that the pattern is also
+found if the writer is only observed after the recursive reader lock. A
+recursive reader lock is reported immediately, if such a writer is already
+known, otherwise it is checked again by the comprehensive detection. A lock,
+which is held by both routines and is not held as a reader lock by both of
+them, works as gate lock and prevents the writer from waiting between the
+two acquisitions.
+*/
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// attempt of a routine to acquire the writer lock of a rw-mutex
+type writerAttempt struct {
+	// index of the routine
+	routine int
+	// site of the attempt
+	caller callerInfo
+	// locks held by the routine during the attempt
+	held []mutexInt
+	// true for the held locks, which were held as reader locks
+	heldRead []bool
+}
+
+// attempts to acquire the writer lock of a rw-mutex
+type writerAttempts struct {
+	// keys of the recorded attempts, an attempt is only recorded once for
+	// each routine and set of held locks
+	seen map[uint64]bool
+	// recorded attempts
+	attempts []writerAttempt
+	// lock to prevent concurrent access to seen and attempts
+	lock sync.Mutex
+}
+
+// recursive reader lock of a routine, which was not reported yet
+type recursiveRLock struct {
+	// rw-mutex, which was locked recursively
+	lock mutexInt
+	// index of the routine
+	routine int
+	// site of the first acquisition of the reader lock, empty if it is
+	// unknown
+	first callerInfo
+	// site of the recursive acquisition
+	caller callerInfo
+	// other locks held by the routine during the recursive acquisition
+	held []mutexInt
+	// true for the held locks, which were held as reader locks
+	heldRead []bool
+}
+
+// create a new list of writer attempts
+//  Returns:
+//   (*writerAttempts): the list
+func newWriterAttempts() *writerAttempts {
+	return &writerAttempts{seen: make(map[uint64]bool)}
+}
+
+// copy the locks held by a routine, except for a given lock
+//  Args:
+//   r (*routine): the routine
+//   except (mutexInt): lock, which is not copied, nil to copy all locks
+//  Returns:
+//   ([]mutexInt): the held locks
+//   ([]bool): true for the locks held as reader locks
+func heldLocks(r *routine, except mutexInt) ([]mutexInt, []bool) {
+	held := make([]mutexInt, 0, r.holdingCount)
+	heldRead := make([]bool, 0, r.holdingCount)
+	for i := 0; i < r.holdingCount; i++ {
+		if except != nil && r.holdingSet[i] == except {
+			continue
+		}
+		held = append(held, r.holdingSet[i])
+		heldRead = append(heldRead, r.holdingRead[i])
+	}
+	return held, heldRead
+}
+
+// record an attempt of a routine to acquire the writer lock of a rw-mutex.
+// The site of the attempt is only determined for new attempts
+//  Args:
+//   r (*routine): routine, which tries to acquire the writer lock
+//   wa (*writerAttempts): attempts of the rw-mutex
+//  Returns:
+//   nil
+func (r *routine) recordWriterAttempt(wa *writerAttempts) {
+	// the key combines the routine and the memory positions of the held
+	// locks, the lowest bit of a position marks a reader lock
+	key := uint64(14695981039346656037) ^ uint64(r.index)
+	for i := 0; i < r.holdingCount; i++ {
+		pos := uint64(r.holdingSet[i].getMemoryPosition())
+		if r.holdingRead[i] {
+			pos ^= 1
+		}
+		key = (key ^ pos) * 1099511628211
+	}
+
+	wa.lock.Lock()
+	seen := wa.seen[key]
+	wa.seen[key] = true
+	wa.lock.Unlock()
+	if seen {
+		return
+	}
+
+	frame := userFrame()
+	held, heldRead := heldLocks(r, nil)
+	attempt := writerAttempt{
+		routine:  r.index,
+		caller:   newInfo(frame.File, frame.Line, frame.Function, false, false, ""),
+		held:     held,
+		heldRead: heldRead,
+	}
+
+	wa.lock.Lock()
+	wa.attempts = append(wa.attempts, attempt)
+	wa.lock.Unlock()
+}
+
+// check if two sets of held locks contain a gate lock, i.e. a lock which
+// is not held as a reader lock in both sets
+//  Args:
+//   held1 ([]mutexInt): first set
+//   read1 ([]bool): reader status of the locks in the first set
+//   held2 ([]mutexInt): second set
+//   read2 ([]bool): reader status of the locks in the second set
+//  Returns:
+//   (bool): true if the sets contain a gate lock
+func haveHeldGateLock(held1 []mutexInt, read1 []bool, held2 []mutexInt, read2 []bool) bool {
+	for i, lock1 := range held1 {
+		for j, lock2 := range held2 {
+			if mutexHaveEqualLock(lock1, lock2) && !(read1[i] && read2[j]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// search for a writer of another routine, which can wait for the rw-mutex
+// between the two acquisitions of a recursive reader lock
+//  Args:
+//   rec (*recursiveRLock): the recursive reader lock
+//  Returns:
+//   (*writerAttempt): attempt of the writer, nil if no such writer exists
+func queuedWriter(rec *recursiveRLock) *writerAttempt {
+	wa := rec.lock.getWriterAttempts()
+	if wa == nil {
+		return nil
+	}
+
+	wa.lock.Lock()
+	defer wa.lock.Unlock()
+	for i := range wa.attempts {
+		attempt := &wa.attempts[i]
+		if attempt.routine == rec.routine ||
+			haveHeldGateLock(rec.held, rec.heldRead, attempt.held, attempt.heldRead) {
+			continue
+		}
+		res := *attempt
+		return &res
+	}
+	return nil
+}
+
+// check a recursive reader lock of a routine. It is reported, if a writer of
+// another routine can wait between the two acquisitions, otherwise it is
+// saved for the comprehensive detection. Each site of a recursive reader
+// lock is only reported once
+//  Args:
+//   m (mutexInt): rw-mutex, which was locked recursively
+//   r (*routine): routine, which locked m
+//  Returns:
+//   nil
+func (r *routine) checkRecursiveRLock(m mutexInt) {
+	d := r.detector
+
+	frame := userFrame()
+	rec := &recursiveRLock{
+		lock:    m,
+		routine: r.index,
+		caller:  newInfo(frame.File, frame.Line, frame.Function, false, false, ""),
+	}
+	rec.held, rec.heldRead = heldLocks(r, m)
+	m.getIsLockedRoutineIndexLock().Lock()
+	rec.first = (*m.getHolders())[r.index]
+	m.getIsLockedRoutineIndexLock().Unlock()
+
+	key := recursiveRLockKey(rec)
+	d.reportedRecursiveRLocksLock.Lock()
+	_, pending := d.recursiveRLocks[key]
+	reported := d.reportedRecursiveRLocks[key]
+	if reported || pending {
+		d.reportedRecursiveRLocksLock.Unlock()
+		return
+	}
+
+	writer := queuedWriter(rec)
+	if writer == nil {
+		d.recursiveRLocks[key] = rec
+	} else {
+		d.reportedRecursiveRLocks[key] = true
+	}
+	d.reportedRecursiveRLocksLock.Unlock()
+
+	if writer != nil {
+		d.reportRecursiveRLock(rec, writer)
+	}
+}
+
+// get the key of a recursive reader lock, which identifies the lock and the
+// site of the recursive acquisition
+//  Args:
+//   rec (*recursiveRLock): the recursive reader lock
+//  Returns:
+//   (string): the key
+func recursiveRLockKey(rec *recursiveRLock) string {
+	return fmt.Sprint(rec.lock.getMemoryPosition(), ":", rec.caller.file(), ":",
+		rec.caller.line())
+}
+
+// check the saved recursive reader locks against the writers, which were
+// observed after them. Called by the comprehensive detection
+//  Returns:
+//   nil
+func (d *Detector) detectRecursiveRLocks() {
+	type found struct {
+		rec    *recursiveRLock
+		writer *writerAttempt
+	}
+	res := make([]found, 0)
+
+	d.reportedRecursiveRLocksLock.Lock()
+	for key, rec := range d.recursiveRLocks {
+		if writer := queuedWriter(rec); writer != nil {
+			res = append(res, found{rec: rec, writer: writer})
+			d.reportedRecursiveRLocks[key] = true
+			delete(d.recursiveRLocks, key)
+		}
+	}
+	d.reportedRecursiveRLocksLock.Unlock()
+
+	// the reports are ordered by their sites, so that the output does not
+	// depend on the order of the map
+	sort.Slice(res, func(i, j int) bool {
+		return recursiveRLockKey(res[i].rec) < recursiveRLockKey(res[j].rec)
+	})
+	for _, f := range res {
+		d.reportRecursiveRLock(f.rec, f.writer)
+	}
+}
@@ -0,0 +1,81 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+reentrancy.go
+Implementation of the guard against the reentrancy of the detector from the
+sinks. A sink, which acquires locks of the detector, e.g. a logger which is
+protected by a Mutex of the detector, would otherwise update the lock trees
+and the wait-for graph while a report is emitted, possibly in the middle of
+a detection. While a routine emits a report, its lock operations only lock
+and unlock the underlying locks and are not recorded. Locks acquired by the
+sink must therefore also be released by the sink and the sink must not
+release locks it did not acquire.
+*/
+
+import (
+	"sync/atomic"
+
+	"github.com/petermattis/goid"
+)
+
+// register the calling routine as emitting a report
+//  Returns:
+//   nil
+func (d *Detector) startReporting() {
+	id := goid.Get()
+	d.reportingLock.Lock()
+	d.reporting[id]++
+	d.reportingLock.Unlock()
+	atomic.AddInt32(&d.numberReporting, 1)
+}
+
+// unregister the calling routine after the report was emitted
+//  Returns:
+//   nil
+func (d *Detector) stopReporting() {
+	id := goid.Get()
+	atomic.AddInt32(&d.numberReporting, -1)
+	d.reportingLock.Lock()
+	d.reporting[id]--
+	if d.reporting[id] <= 0 {
+		delete(d.reporting, id)
+	}
+	d.reportingLock.Unlock()
+}
+
+// check if the calling routine is currently emitting a report. The check
+// is free while no report is emitted
+//  Returns:
+//   (bool): true if the lock operations of the routine must not be recorded
+func (d *Detector) inReport() bool {
+	if atomic.LoadInt32(&d.numberReporting) == 0 {
+		return false
+	}
+	id := goid.Get()
+	d.reportingLock.Lock()
+	defer d.reportingLock.Unlock()
+	return d.reporting[id] > 0
+}
@@ -0,0 +1,297 @@
+//go:build deadlock_reference
+
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+referenceCheck.go
+This file implements a reference check of the comprehensive detection for
+tests and fuzzing (build tag deadlock_reference). After each search for
+cycles, all chains of dependencies of different routines are enumerated by
+brute force and checked with the definition of a cycle. The result must be
+equal to the cycles found by the optimized depth-first search, otherwise
+the program panics. The links between the dependencies are checked with an
+own implementation of the definition, so that an error in the link check of
+the search is found. The enumeration is exponential, it is therefore only
+run for small lock trees. The check is run for random lock trees by the
+tests of the package:
+
+	go test -tags deadlock_reference ./...
+*/
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maximum number of dependencies in all lock trees, for which the reference
+// check is run
+const referenceMaxDependencies = 12
+
+// save a cycle found by the search
+//  Args:
+//   stack (*depStack): stack which represents the cycle
+//  Returns:
+//   nil
+func (d *Detector) recordFoundCycle(stack *depStack) {
+	if d.foundCycles == nil {
+		d.foundCycles = make(map[string]bool)
+	}
+	cycle := make([]stackElement, 0, stack.size)
+	for cl := stack.stack.next; cl != nil; cl = cl.next {
+		cycle = append(cycle, newStackElement(cl.depEntry, cl.index))
+	}
+	d.foundCycles[referenceKey(cycle)] = true
+}
+
+// compare the cycles found by the search with the cycles of the reference
+// enumeration. The check is skipped, if the search was cut short or the
+// lock trees are too large
+//  Returns:
+//   nil
+func (d *Detector) checkReference() {
+	found := d.foundCycles
+	d.foundCycles = nil
+	if d.progress.truncation() != nil {
+		return
+	}
+
+	all := make([]stackElement, 0)
+	for i, deps := range d.snapshot.dependencies {
+		for _, dep := range deps {
+			all = append(all, newStackElement(dep, i))
+		}
+	}
+	if len(all) > referenceMaxDependencies {
+		return
+	}
+
+	reference := d.referenceCycles(all)
+	for key, cycle := range reference {
+		if !found[key] {
+			panic(fmt.Sprint("deadlock: reference check failed, the cycle ",
+				referenceSites(cycle), " was not found by the search"))
+		}
+	}
+	for key := range found {
+		if _, ok := reference[key]; !ok {
+			panic(fmt.Sprint("deadlock: reference check failed, the search ",
+				"found the cycle ", key, ", which is not a cycle of the reference"))
+		}
+	}
+}
+
+// enumerate all cycles of the lock trees by brute force. Every chain of
+// dependencies of different routines, in which each dependency is linked to
+// the next one, is checked with referenceIsCycle
+//  Args:
+//   all ([]stackElement): all dependencies with the indexes of their routines
+//  Returns:
+//   (map[string][]stackElement): the cycles by their keys
+func (d *Detector) referenceCycles(all []stackElement) map[string][]stackElement {
+	res := make(map[string][]stackElement)
+	path := make([]stackElement, 0)
+	used := make(map[int]bool)
+
+	var extend func()
+	extend = func() {
+		if len(path) >= 2 && d.referenceIsCycle(path) {
+			res[referenceKey(path)] = append([]stackElement{}, path...)
+		}
+		for _, e := range all {
+			if used[e.index] {
+				continue
+			}
+			// a sequence, which is not a chain, can not be extended to a cycle
+			if len(path) > 0 && !d.referenceIsLink(path[len(path)-1], e) {
+				continue
+			}
+			used[e.index] = true
+			path = append(path, e)
+			extend()
+			path = path[:len(path)-1]
+			used[e.index] = false
+		}
+	}
+	extend()
+	return res
+}
+
+// check with the definition, if a sequence of dependencies of different
+// routines is a cycle, which is reported by the detection. The lock of each
+// dependency must be in the holding set of the next dependency and the lock
+// of the last dependency in the holding set of the first dependency, no two
+// holding sets may contain the same lock, except if both hold it as a
+// reader lock (gate lock), and no two dependencies, which are not adjacent,
+// may be linked (the cycle would contain a shorter cycle)
+//  Args:
+//   path ([]stackElement): the dependencies
+//  Returns:
+//   (bool): true if the dependencies are a cycle
+func (d *Detector) referenceIsCycle(path []stackElement) bool {
+	n := len(path)
+	for i := 0; i < n; i++ {
+		if !d.referenceIsLink(path[i], path[(i+1)%n]) {
+			return false
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if !referenceNoGateLock(path[i].depEntry, path[j].depEntry) {
+				return false
+			}
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if j == i || j == (i+1)%n {
+				continue
+			}
+			if d.referenceIsLink(path[i], path[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// check with the definition, if the routine of prev can be forced to wait
+// for the routine of next. The lock of prev must be in the holding set of
+// next. If both hold it as a reader lock, a writer of another routine must
+// be able to queue between them, which is not possible for the recursive
+// reader lock of a rw-lock allowing them
+//  Args:
+//   prev (stackElement): dependency whose lock is acquired
+//   next (stackElement): dependency whose holding set is checked
+//  Returns:
+//   (bool): true if the dependencies are linked
+func (d *Detector) referenceIsLink(prev stackElement, next stackElement) bool {
+	for i := 0; i < next.depEntry.holdingCount; i++ {
+		held := next.depEntry.holdingSet[i]
+		if held.getMemoryPosition() != prev.depEntry.mu.getMemoryPosition() {
+			continue
+		}
+		if !prev.depEntry.read || !next.depEntry.holdingRead[i] {
+			return true
+		}
+		if d.opts.WriterQueuingDetection && !referenceRecursiveRLock(prev.depEntry) &&
+			referenceOtherWriter(held, prev.index, next.index) {
+			return true
+		}
+	}
+	return false
+}
+
+// check if the reader lock of a dependency is acquired recursively on a
+// rw-lock, which allows recursive reader locks
+//  Args:
+//   dep (*dependency): dependency of a reader lock
+//  Returns:
+//   (bool): true if the routine already held the reader lock
+func referenceRecursiveRLock(dep *dependency) bool {
+	if !dep.mu.allowsRecursiveRLock() {
+		return false
+	}
+	for i := 0; i < dep.holdingCount; i++ {
+		if dep.holdingRead[i] &&
+			dep.holdingSet[i].getMemoryPosition() == dep.mu.getMemoryPosition() {
+			return true
+		}
+	}
+	return false
+}
+
+// check if a routine other than the two given routines has acquired the
+// writer lock of a rw-lock
+//  Args:
+//   m (mutexInt): the rw-lock
+//   index1 (int): index of the first routine
+//   index2 (int): index of the second routine
+//  Returns:
+//   (bool): true if another routine has acquired the writer lock
+func referenceOtherWriter(m mutexInt, index1 int, index2 int) bool {
+	rw, ok := m.(*RWMutex)
+	if !ok {
+		return false
+	}
+	rw.isLockedRoutineIndexLock.Lock()
+	defer rw.isLockedRoutineIndexLock.Unlock()
+	for index := range rw.writers {
+		if index != index1 && index != index2 {
+			return true
+		}
+	}
+	return false
+}
+
+// check that two dependencies are not protected by a gate lock
+//  Args:
+//   dep1 (*dependency): first dependency
+//   dep2 (*dependency): second dependency
+//  Returns:
+//   (bool): true if no lock is held in both holding sets, except as a
+//    reader lock in both
+func referenceNoGateLock(dep1 *dependency, dep2 *dependency) bool {
+	for i := 0; i < dep1.holdingCount; i++ {
+		for j := 0; j < dep2.holdingCount; j++ {
+			if mutexHaveEqualLock(dep1.holdingSet[i], dep2.holdingSet[j]) &&
+				!(dep1.holdingRead[i] && dep2.holdingRead[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// get a key of a cycle, which does not depend on its first dependency. A
+// cycle without shorter cycles is identified by its dependencies
+//  Args:
+//   cycle ([]stackElement): dependencies of the cycle
+//  Returns:
+//   (string): key of the cycle
+func referenceKey(cycle []stackElement) string {
+	keys := make([]string, 0, len(cycle))
+	for _, e := range cycle {
+		keys = append(keys, fmt.Sprintf("%d/%p", e.index, e.depEntry))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// get the sites of the dependencies of a cycle
+//  Args:
+//   cycle ([]stackElement): dependencies of the cycle
+//  Returns:
+//   (string): sites of the acquisitions of the dependencies
+func referenceSites(cycle []stackElement) string {
+	sites := make([]string, 0, len(cycle))
+	for _, e := range cycle {
+		sites = append(sites, dependencySite(e.depEntry))
+	}
+	return strings.Join(sites, " -> ")
+}
@@ -0,0 +1,208 @@
+//go:build deadlock_reference
+
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+referenceCheck_test.go
+Tests of the comprehensive detection against the reference check. Random
+lock trees are created by routines, which acquire rw-locks as readers or
+writers in a nested order. The comprehensive detection panics, if the cycles
+found by the search differ from the cycles of the reference enumeration.
+*/
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// maximum number of locks, routines and nested locks of a routine in a
+// random lock tree, so that the reference check is not skipped
+const (
+	referenceTestLocks    = 4
+	referenceTestRoutines = 4
+	referenceTestNesting  = 3
+)
+
+// acquisition of a lock in a random lock tree
+type referenceTestAcquisition struct {
+	// index of the lock
+	lock int
+	// true if the reader lock is acquired
+	read bool
+}
+
+// decode the routines of a random lock tree. A byte with the highest bit set
+// starts a new routine, the other bytes select a lock and whether it is
+// acquired as a reader. A lock, which the routine already holds, is skipped
+//  Args:
+//   data ([]byte): the encoded lock tree
+//  Returns:
+//   ([][]referenceTestAcquisition): nested acquisitions of each routine
+func referenceTestRoutinesOf(data []byte) [][]referenceTestAcquisition {
+	routines := [][]referenceTestAcquisition{{}}
+	for _, b := range data {
+		last := len(routines) - 1
+		if b&0x80 != 0 {
+			if len(routines[last]) != 0 && len(routines) < referenceTestRoutines {
+				routines = append(routines, []referenceTestAcquisition{})
+			}
+			continue
+		}
+		if len(routines[last]) == referenceTestNesting {
+			continue
+		}
+		a := referenceTestAcquisition{lock: int(b) % referenceTestLocks, read: b&0x40 != 0}
+		held := false
+		for _, h := range routines[last] {
+			held = held || h.lock == a.lock
+		}
+		if !held {
+			routines[last] = append(routines[last], a)
+		}
+	}
+	return routines
+}
+
+// run the routines of a random lock tree one after another and run the
+// comprehensive detection, which runs the reference check
+//  Args:
+//   t (*testing.T): the test
+//   data ([]byte): the encoded lock tree
+//   writerQueuing (bool): value of the option WriterQueuingDetection
+//  Returns:
+//   nil
+func referenceTestRun(t *testing.T, data []byte, writerQueuing bool) {
+	d := NewDetector(func(r Report) {})
+	opts := DefaultOptions()
+	opts.PeriodicDetection = false
+	opts.WriterQueuingDetection = writerQueuing
+	if err := d.Configure(opts); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	locks := make([]*RWMutex, referenceTestLocks)
+	for i := range locks {
+		locks[i] = d.NewRWLock()
+	}
+	routines := referenceTestRoutinesOf(data)
+	for _, acquisitions := range routines {
+		done := make(chan struct{})
+		go func(acquisitions []referenceTestAcquisition) {
+			defer close(done)
+			for _, a := range acquisitions {
+				if a.read {
+					locks[a.lock].RLock()
+				} else {
+					locks[a.lock].Lock()
+				}
+			}
+			for i := len(acquisitions) - 1; i >= 0; i-- {
+				if acquisitions[i].read {
+					locks[acquisitions[i].lock].RUnlock()
+				} else {
+					locks[acquisitions[i].lock].Unlock()
+				}
+			}
+		}(acquisitions)
+		<-done
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("routines %v: %v", routines, r)
+		}
+	}()
+	d.FindPotentialDeadlocks()
+}
+
+// check the comprehensive detection of random lock trees
+//  Args:
+//   t (*testing.T): the test
+//  Returns:
+//   nil
+func TestReferenceCheckRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 300; i++ {
+		data := make([]byte, 12)
+		r.Read(data)
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			referenceTestRun(t, data, i%2 == 0)
+		})
+	}
+}
+
+// check that the reference check stays fast for routines, whose
+// dependencies can not be linked
+//  Args:
+//   t (*testing.T): the test
+//  Returns:
+//   nil
+func TestReferenceCheckUnlinked(t *testing.T) {
+	d := NewDetector(func(r Report) {})
+	opts := DefaultOptions()
+	opts.PeriodicDetection = false
+	if err := d.Configure(opts); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	for i := 0; i < referenceMaxDependencies; i++ {
+		x, y := d.NewLock(), d.NewLock()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			x.Lock()
+			y.Lock()
+			y.Unlock()
+			x.Unlock()
+		}()
+		<-done
+	}
+
+	start := time.Now()
+	d.FindPotentialDeadlocks()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("the detection took %v", elapsed)
+	}
+}
+
+// fuzz the comprehensive detection with lock trees decoded from the input
+//  Args:
+//   f (*testing.F): the fuzz test
+//  Returns:
+//   nil
+func FuzzReferenceCheck(f *testing.F) {
+	// two-lock inversion, reader inversion, three-lock ring
+	f.Add([]byte{0, 1, 0x80, 1, 0}, true)
+	f.Add([]byte{0x40, 0x41, 0x80, 0x41, 0x40, 0x80, 0}, true)
+	f.Add([]byte{0, 1, 0x80, 1, 2, 0x80, 2, 0}, false)
+	f.Fuzz(func(t *testing.T, data []byte, writerQueuing bool) {
+		referenceTestRun(t, data, writerQueuing)
+	})
+}
@@ -1,9 +1,11 @@
 package deadlock
 
 import (
+	"bytes"
 	"fmt"
-	"os"
-	"runtime"
+	"sort"
+	"strings"
+	"time"
 )
 
 /*
@@ -40,31 +42,176 @@ const (
 	purple = "\033[1;35m%s\033[0m"
 	red    = "\033[1;31m%s\033[0m"
 	blue   = "\033[0;36m%s\033[0m"
+	yellow = "\033[1;33m%s\033[0m"
 )
 
 // report if double locking is detected
 //  Args:
 //   m (mutexInt): mutex on which double locking was detected
+//   index (int): index of the routine which locked m twice
+//   reportType (ReportType): ReportDeadlock, or ReportWarning if the
+//    double locking policy of the lock downgrades the report
 //  Returns:
 //   nil
-func reportDeadlockDoubleLocking(m mutexInt) {
-	fmt.Fprintf(os.Stderr, red, "DEADLOCK (DOUBLE LOCKING)\n\n")
+func (d *Detector) reportDoubleLocking(m mutexInt, index int, reportType ReportType) {
+	out := &bytes.Buffer{}
+
+	title := "DEADLOCK (DOUBLE LOCKING)"
+	involved := "deadlock"
+	if reportType == ReportWarning {
+		title = "DOUBLE LOCKING"
+		involved = "double locking"
+	}
 
 	// print information about the involved lock
-	fmt.Fprintf(os.Stderr, purple, "Initialization of lock involved in deadlock:\n\n")
+	fmt.Fprintf(out, purple, "Initialization of lock involved in "+involved+":\n\n")
 	context := *m.getContext()
-	fmt.Fprintln(os.Stderr, context[0].file, context[0].line)
-	fmt.Fprintln(os.Stderr, "")
-	fmt.Fprintf(os.Stderr, purple, "Calls of lock involved in deadlock:\n\n")
+	fmt.Fprintln(out, context[0].file(), context[0].line())
+	fmt.Fprintln(out, "")
+	fmt.Fprintf(out, purple, "Calls of lock involved in "+involved+":\n\n")
+	sites := make([]Site, 0)
 	for i, call := range context {
 		if i == 0 {
 			continue
 		}
-		fmt.Fprintln(os.Stderr, call.file, call.line)
+		fmt.Fprintln(out, call.file(), call.line())
+		sites = append(sites, newSite(call))
+	}
+	_, file, line, _ := callerSite(4)
+	fmt.Fprintln(out, file, line)
+	sites = append(sites, Site{File: file, Line: line})
+	fmt.Fprintln(out, "")
+	goroutines := d.goroutines([]int{index})
+	fmt.Fprintln(out, "Locked by", goroutines[0])
+
+	// the missing release is usually placed after the last release
+	var released *Site
+	if info, ok := lastUnlock(m, index); ok {
+		fmt.Fprintf(out, purple, "\nLast release of the lock by the routine:\n\n")
+		fmt.Fprintln(out, info.file(), info.line())
+		site := newSite(info)
+		released = &site
+	}
+	d.writeHistory(out, []int{index})
+	fmt.Fprintf(out, "\n\n")
+
+	locks := lockInfos(m)
+	d.report(Report{
+		Type:       reportType,
+		Title:      title,
+		Sites:      sites,
+		Locks:      locks,
+		Goroutines: goroutines,
+		Released:   released,
+		err: &DoubleLockError{
+			Lock:     locks[0],
+			Sites:    sites,
+			Released: released,
+			Deadlock: reportType == ReportDeadlock,
+		},
+	}, out)
+}
+
+// report a recursive reader lock of a rw-mutex, between whose acquisitions
+// a writer of another routine can wait for the rw-mutex
+//  Args:
+//   rec (*recursiveRLock): the recursive reader lock
+//   writer (*writerAttempt): attempt of the writer
+//  Returns:
+//   nil
+func (d *Detector) reportRecursiveRLock(rec *recursiveRLock, writer *writerAttempt) {
+	m := rec.lock
+	out := &bytes.Buffer{}
+
+	// print information about the involved lock
+	fmt.Fprintf(out, purple, "Initialization of lock involved in potential deadlock:\n\n")
+	context := *m.getContext()
+	fmt.Fprintln(out, context[0].file(), context[0].line())
+	fmt.Fprintln(out, "")
+	fmt.Fprintf(out, purple, "Recursive reader lock:\n\n")
+	if rec.first.file() != "" {
+		fmt.Fprintln(out, rec.first.file(), rec.first.line(), "(first reader lock)")
+	}
+	fmt.Fprintln(out, rec.caller.file(), rec.caller.line())
+	fmt.Fprintln(out, "")
+	fmt.Fprintf(out, purple, "Writer lock:\n\n")
+	fmt.Fprintln(out, writer.caller.file(), writer.caller.line())
+	fmt.Fprintln(out, "")
+	goroutines := d.goroutines([]int{rec.routine, writer.routine})
+	fmt.Fprintln(out, d.goroutine(rec.routine).sentence(),
+		"acquires the reader lock while already holding it.")
+	fmt.Fprintln(out, "If", d.goroutine(writer.routine),
+		"starts to wait for the writer lock between the")
+	fmt.Fprintln(out, "two acquisitions, both routines wait for each other. Use")
+	fmt.Fprintln(out, "AllowRecursiveRLock() if this can not happen.")
+	fmt.Fprintf(out, "\n\n")
+
+	d.report(Report{
+		Type:       ReportPotentialDeadlock,
+		Title:      "POTENTIAL DEADLOCK (RECURSIVE READER LOCK)",
+		Sites:      []Site{newSite(rec.caller), newSite(writer.caller)},
+		Locks:      lockInfos(m),
+		Goroutines: goroutines,
+	}, out)
+}
+
+// report if an upgrade of a reader lock waits for a routine, which itself
+// waits for the release of the reader lock
+//  Args:
+//   m (mutexInt): rw-mutex which was upgraded
+//  Returns:
+//   nil
+func (d *Detector) reportDeadlockUpgrade(m mutexInt) {
+	out := &bytes.Buffer{}
+
+	// print information about the involved lock
+	fmt.Fprintf(out, purple, "Initialization of lock involved in deadlock:\n\n")
+	context := *m.getContext()
+	fmt.Fprintln(out, context[0].file(), context[0].line())
+	fmt.Fprintln(out, "")
+	fmt.Fprintf(out, purple, "Upgrade involved in deadlock:\n\n")
+	_, file, line, _ := callerSite(3)
+	fmt.Fprintln(out, file, line)
+	fmt.Fprintf(out, "\n\n")
+
+	d.report(Report{
+		Type:  ReportDeadlock,
+		Title: "DEADLOCK (CONCURRENT UPGRADE)",
+		Sites: []Site{{File: file, Line: line}},
+		Locks: lockInfos(m),
+	}, out)
+}
+
+// report if the reader lock of a rw-mutex was upgraded in different routines
+// without being protected by a gate lock
+//  Args:
+//   m (mutexInt): rw-mutex which was upgraded
+//  Returns:
+//   nil
+func (d *Detector) reportPotentialDeadlockUpgrade(m mutexInt) {
+	out := &bytes.Buffer{}
+
+	// print information about the involved lock
+	fmt.Fprintf(out, purple, "Initialization of lock involved in potential deadlock:\n\n")
+	context := *m.getContext()
+	fmt.Fprintln(out, context[0].file(), context[0].line())
+	fmt.Fprintln(out, "")
+	fmt.Fprintf(out, purple, "Upgrades of lock involved in potential deadlock:\n\n")
+	sites := make([]Site, 0)
+	for _, call := range context {
+		if call.upgrade {
+			fmt.Fprintln(out, call.file(), call.line())
+			sites = append(sites, newSite(call))
+		}
 	}
-	_, file, line, _ := runtime.Caller(4)
-	fmt.Fprintln(os.Stderr, file, line)
-	fmt.Fprintf(os.Stderr, "\n\n")
+	fmt.Fprintf(out, "\n\n")
+
+	d.report(Report{
+		Type:  ReportPotentialDeadlock,
+		Title: "POTENTIAL DEADLOCK (CONCURRENT UPGRADE)",
+		Sites: sites,
+		Locks: lockInfos(m),
+	}, out)
 }
 
 // report a found deadlock
@@ -72,59 +219,754 @@ func reportDeadlockDoubleLocking(m mutexInt) {
 //   stack (*depStack) stack which represents the found cycle
 //  Returns:
 //   nil
-func reportDeadlock(stack *depStack) {
-	fmt.Fprintf(os.Stderr, red, "POTENTIAL DEADLOCK\n\n")
+func (d *Detector) reportDeadlock(stack *depStack) {
+	out := &bytes.Buffer{}
+
+	// print the identifier of the cycle
+	deps := make([]*dependency, 0)
+	for cl := stack.stack.next; cl != nil; cl = cl.next {
+		deps = append(deps, cl.depEntry)
+	}
+	id := dependencyCycleID(deps)
+	fmt.Fprintln(out, "Cycle ID:", id)
+	fmt.Fprintln(out, "")
+
+	// save the chain, so that the report can be verified
+	d.saveReportedCycle(id, stack)
 
 	// print information about the locks in the circle
-	fmt.Fprintf(os.Stderr, purple, "Initialization of locks involved in potential deadlock:\n\n")
+	fmt.Fprintf(out, purple, "Initialization of locks involved in potential deadlock:\n\n")
 	for cl := stack.stack.next; cl != nil; cl = cl.next {
 		for _, c := range *cl.depEntry.mu.getContext() {
 			if c.create {
-				fmt.Fprintln(os.Stderr, c.file, c.line)
+				fmt.Fprintln(out, c.file(), c.line())
 			}
 		}
 	}
 
+	// print information about rw-locks, on which a writer must be queued
+	queued := d.queuedWriterLocks(stack)
+	if len(queued) != 0 {
+		fmt.Fprintf(out, purple, "\nThe deadlock requires a queued writer on the locks created at:\n\n")
+		for _, m := range queued {
+			context := *m.getContext()
+			fmt.Fprintln(out, context[0].file(), context[0].line())
+		}
+	}
+
 	// print information if call stacks were collected
-	if opts.collectCallStack {
-		fmt.Fprintf(os.Stderr, purple, "\nCallStacks of Locks involved in potential deadlock:\n\n")
+	if d.opts.CollectCallStack {
+		fmt.Fprintf(out, purple, "\nCallStacks of Locks involved in potential deadlock:\n\n")
 		for cl := stack.stack.next; cl != nil; cl = cl.next {
 			cont := *cl.depEntry.mu.getContext()
-			fmt.Fprintf(os.Stderr, blue, "CallStacks for lock created at: ")
-			fmt.Fprintf(os.Stderr, blue, cont[0].file)
-			fmt.Fprintf(os.Stderr, blue, ":")
-			fmt.Fprintf(os.Stderr, blue, fmt.Sprint(cont[0].line))
-			fmt.Fprintf(os.Stderr, "\n\n")
+			fmt.Fprintf(out, blue, "CallStacks for lock created at: ")
+			fmt.Fprintf(out, blue, cont[0].file())
+			fmt.Fprintf(out, blue, ":")
+			fmt.Fprintf(out, blue, fmt.Sprint(cont[0].line()))
+			fmt.Fprintf(out, "\n\n")
 			for i, c := range cont {
 				if i != 0 {
-					fmt.Fprint(os.Stderr, c.callStacks)
+					fmt.Fprint(out, c.callStacks())
 				}
 			}
 		}
 	} else {
 		// print information if only caller information were selected
-		fmt.Fprintf(os.Stderr, purple, "\nCalls of locks involved in potential deadlock:\n\n")
+		fmt.Fprintf(out, purple, "\nCalls of locks involved in potential deadlock:\n\n")
 		for cl := stack.stack.next; cl != nil; cl = cl.next {
 			for i, c := range *cl.depEntry.mu.getContext() {
 				if i == 0 {
-					fmt.Fprintf(os.Stderr, blue, "Calls for lock created at: ")
-					fmt.Fprintf(os.Stderr, blue, c.file)
-					fmt.Fprintf(os.Stderr, blue, ":")
-					fmt.Fprintf(os.Stderr, blue, fmt.Sprint(c.line))
-					fmt.Fprintf(os.Stderr, "\n")
+					fmt.Fprintf(out, blue, "Calls for lock created at: ")
+					fmt.Fprintf(out, blue, c.file())
+					fmt.Fprintf(out, blue, ":")
+					fmt.Fprintf(out, blue, fmt.Sprint(c.line()))
+					fmt.Fprintf(out, "\n")
+				} else if c.upgrade {
+					fmt.Fprintln(out, c.file(), c.line(), "(upgrade)")
+				} else if c.initPhase {
+					fmt.Fprintln(out, c.file(), c.line(), "(init)")
+				} else if c.condWait {
+					fmt.Fprintln(out, c.file(), c.line(), "(reacquired by Cond.Wait)")
 				} else {
-					fmt.Fprintln(os.Stderr, c.file, c.line)
+					fmt.Fprintln(out, c.file(), c.line())
 				}
 			}
-			fmt.Fprintln(os.Stderr, "")
+			fmt.Fprintln(out, "")
+		}
+	}
+
+	// print the relative timing of the acquisitions in the circle
+	fmt.Fprintf(out, purple, "\nTiming of the acquisitions involved in potential deadlock:\n\n")
+	writeTiming(out, stack)
+
+	// print how often and when the dependencies of the circle were observed
+	fmt.Fprintf(out, purple, "\nFrequency of the acquisitions involved in potential deadlock:\n\n")
+	d.writeFrequency(out, deps)
+
+	// print the routines, which created the dependencies of the circle
+	indexes := make([]int, 0)
+	for cl := stack.stack.next; cl != nil; cl = cl.next {
+		indexes = append(indexes, cl.index)
+	}
+	goroutines := d.goroutines(indexes)
+	fmt.Fprintf(out, purple, "\nGoroutines involved in potential deadlock:\n\n")
+	for _, g := range goroutines {
+		fmt.Fprintln(out, g)
+	}
+
+	// print the last lock events of the routines in the circle
+	d.writeHistory(out, indexes)
+	fmt.Fprintf(out, "\n\n")
+
+	// print an observed example of each edge of the circle
+	examples := d.edgeExamples(deps)
+	writeEdgeExamples(out, examples)
+
+	// the acquisitions, which create the edges of the cycle
+	sites := make([]Site, 0, len(deps))
+	for _, dep := range deps {
+		sites = append(sites, newSite(dep.caller))
+	}
+
+	locks := lockInfos(dependencyLocks(deps)...)
+	d.report(Report{
+		Type:       ReportPotentialDeadlock,
+		Title:      "POTENTIAL DEADLOCK",
+		ID:         id,
+		Sites:      sites,
+		Locks:      locks,
+		Goroutines: goroutines,
+		Examples:   examples,
+		err:        &CycleError{ID: id, Sites: sites, Locks: locks, Potential: true},
+	}, out)
+}
+
+// write the times of the last acquisitions of the dependencies in a cycle,
+// ordered by time. Cycles whose dependencies were created far apart from
+// each other are less likely to result in an actual deadlock
+//  Args:
+//   out (*bytes.Buffer): buffer to write to
+//   stack (*depStack): stack which represents the found cycle
+//  Returns:
+//   nil
+func writeTiming(out *bytes.Buffer, stack *depStack) {
+	// the times are read once, because the routines can renew them while
+	// the report is written
+	deps := make([]*dependency, 0)
+	last := make(map[*dependency]time.Duration)
+	for cl := stack.stack.next; cl != nil; cl = cl.next {
+		deps = append(deps, cl.depEntry)
+		last[cl.depEntry] = cl.depEntry.lastSeen()
+	}
+	sort.SliceStable(deps, func(i, j int) bool {
+		return last[deps[i]] < last[deps[j]]
+	})
+
+	name := func(dep *dependency) string {
+		return fmt.Sprint(dep.caller.file(), ":", dep.caller.line())
+	}
+
+	for i, dep := range deps {
+		context := *dep.mu.getContext()
+		fmt.Fprintln(out, name(dep), "(lock created at", fmt.Sprint(context[0].file(),
+			":", context[0].line(), ")"), "acquired",
+			last[dep].Round(time.Millisecond), "after the start of the detector")
+		if i > 0 {
+			fmt.Fprintln(out, " ", name(deps[i-1]), "acquired",
+				(last[dep] - last[deps[i-1]]).Round(time.Millisecond), "before",
+				name(dep))
+		}
+	}
+}
+
+// write how often and when the dependencies in a cycle were observed.
+// A cycle, which was observed on every request, is more urgent than a cycle
+// with a dependency which was observed only once. The wall clock times of
+// the first and last observation help to find the deploy, which introduced
+// a dependency
+//  Args:
+//   out (*bytes.Buffer): buffer to write to
+//   deps ([]*dependency): dependencies of the cycle
+//  Returns:
+//   nil
+func (d *Detector) writeFrequency(out *bytes.Buffer, deps []*dependency) {
+	for _, dep := range deps {
+		context := *dep.mu.getContext()
+		count := dep.observed()
+		times := "times"
+		if count == 1 {
+			times = "time"
+		}
+		fmt.Fprintln(out, fmt.Sprint(dep.caller.file(), ":", dep.caller.line()),
+			"(lock created at", fmt.Sprint(context[0].file(), ":", context[0].line(), ")"),
+			"observed", count, times)
+		fmt.Fprintln(out, "  first seen", d.wallClock(dep.first), "last seen",
+			d.wallClock(dep.lastSeen()))
+	}
+}
+
+// convert a time relative to the start of the detector into the wall clock
+// time
+//  Args:
+//   t (time.Duration): time after the start of the detector
+//  Returns:
+//   (string): wall clock time in RFC 3339 format
+func (d *Detector) wallClock(t time.Duration) string {
+	return d.start.Add(t).Format(time.RFC3339)
+}
+
+// report a starving reader or writer of a rw-lock
+//  Args:
+//   m (mutexInt): rw-lock on which the starvation was detected
+//   w (*waitInfo): information about the starving wait
+//  Returns:
+//   nil
+func (d *Detector) reportStarvation(m mutexInt, w *waitInfo) {
+	out := &bytes.Buffer{}
+	title := "STARVATION (WRITER)"
+	if w.read {
+		title = "STARVATION (READER)"
+	}
+
+	// print information about the involved lock
+	fmt.Fprintf(out, purple, "Initialization of lock involved in starvation:\n\n")
+	context := *m.getContext()
+	fmt.Fprintln(out, context[0].file(), context[0].line())
+	fmt.Fprintln(out, "")
+	fmt.Fprintf(out, purple, "Starving call:\n\n")
+	fmt.Fprintln(out, w.caller.file(), w.caller.line())
+	fmt.Fprintln(out, "")
+	if w.read {
+		fmt.Fprintln(out, "Waiting for", time.Since(w.start).Round(time.Millisecond),
+			"while", w.arrivals, "writers acquired the lock")
+	} else {
+		fmt.Fprintln(out, "Waiting for", time.Since(w.start).Round(time.Millisecond),
+			"while", w.arrivals, "readers arrived")
+	}
+	fmt.Fprintln(out, "")
+	d.writeHolders(out, m, -1)
+	fmt.Fprintf(out, "\n")
+
+	d.report(Report{
+		Type:  ReportWarning,
+		Title: title,
+		Sites: []Site{newSite(w.caller)},
+		Locks: lockInfos(m),
+	}, out)
+}
+
+// report a routine, which holds more locks than allowed
+//  Args:
+//   locks ([]mutexInt): locks held by the routine
+//   callers ([]callerInfo): acquisition sites of the held locks
+//  Returns:
+//   nil
+func (d *Detector) reportLockDepth(locks []mutexInt, callers []callerInfo) {
+	out := &bytes.Buffer{}
+
+	fmt.Fprintf(out, purple, fmt.Sprint("Routine holds ", len(locks),
+		" locks at the same time, the maximum is ", d.opts.MaxLockDepth, ":\n\n"))
+	sites := make([]Site, 0, len(callers))
+	for i, m := range locks {
+		context := *m.getContext()
+		fmt.Fprintln(out, callers[i].file(), callers[i].line(),
+			fmt.Sprint("(lock created at ", context[0].file(), ":", context[0].line(), ")"))
+		sites = append(sites, newSite(callers[i]))
+	}
+	fmt.Fprintf(out, "\n\n")
+
+	d.report(Report{
+		Type:  ReportWarning,
+		Title: "LOCK DEPTH",
+		Sites: sites,
+		Locks: lockInfos(locks...),
+	}, out)
+}
+
+// report a routine, which holds more locks at the same time than the bound
+// of the holding sets
+//  Args:
+//   locks ([]mutexInt): locks held by the routine
+//   index (int): index of the routine
+//  Returns:
+//   nil
+func (d *Detector) reportHoldingSetBound(locks []mutexInt, index int) {
+	out := &bytes.Buffer{}
+
+	fmt.Fprintf(out, purple, fmt.Sprint(d.goroutine(index).sentence(), " holds ",
+		len(locks)+1, " locks at the same time, more than the bound of ",
+		d.opts.HoldingSetBound, ".\n\n"))
+	fmt.Fprintln(out, "The locks are probably never released. All acquisitions are still")
+	fmt.Fprintln(out, "recorded, but the holding set of the routine keeps growing.")
+	fmt.Fprintln(out, "")
+
+	// the number of held locks can be large, only the first locks are shown
+	shown := locks
+	if len(shown) > 10 {
+		shown = shown[:10]
+	}
+	fmt.Fprintf(out, purple, "First held locks:\n\n")
+	for _, m := range shown {
+		context := *m.getContext()
+		fmt.Fprintln(out, fmt.Sprint("lock created at ", context[0].file(), ":", context[0].line()))
+	}
+	if len(shown) < len(locks) {
+		fmt.Fprintln(out, "...")
+	}
+	fmt.Fprintf(out, "\n\n")
+
+	d.report(Report{
+		Type:  ReportWarning,
+		Title: "HOLDING SET BOUND",
+		Locks: lockInfos(shown...),
+	}, out)
+}
+
+// report a lock, which is still held when the guarded function, which
+// acquired it, returns
+//  Args:
+//   g (*Guard): guard of the function
+//   l (guardedLock): acquisition of the lock
+//  Returns:
+//   nil
+func (d *Detector) reportAbandonedLock(g *Guard, l guardedLock) {
+	out := &bytes.Buffer{}
+
+	context := *l.mu.getContext()
+	fmt.Fprintf(out, purple, "Acquisition of the abandoned lock:\n\n")
+	fmt.Fprintln(out, l.site.File, l.site.Line,
+		fmt.Sprint("(lock created at ", context[0].file(), ":", context[0].line(), ")"))
+	fmt.Fprintln(out, "")
+	if g.request != "" {
+		fmt.Fprintf(out, purple, "Handler:\n\n")
+		fmt.Fprintln(out, g.site.File, g.site.Line, g.site.Function)
+		fmt.Fprintln(out, "serving", g.request)
+		fmt.Fprintln(out, "")
+		fmt.Fprintln(out, "The lock is still held when the handler returns. Locks acquired")
+		fmt.Fprintln(out, "in a handler must be released before it returns, not by a")
+		fmt.Fprintln(out, "handler of the chain, which called it, or by a later request.")
+	} else {
+		fmt.Fprintf(out, purple, "Guarded function:\n\n")
+		fmt.Fprintln(out, g.site.File, g.site.Line, g.site.Function)
+		fmt.Fprintln(out, "")
+		fmt.Fprintln(out, "The lock is still held when the function returns. Release it")
+		fmt.Fprintln(out, "with defer or use Transfer of the guard if the release is")
+		fmt.Fprintln(out, "intentionally left to the caller.")
+	}
+	fmt.Fprintf(out, "\n\n")
+
+	locks := lockInfos(l.mu)
+	d.report(Report{
+		Type:  ReportWarning,
+		Title: "ABANDONED LOCK",
+		Sites: []Site{l.site},
+		Locks: locks,
+		err: &AbandonedLockError{
+			Lock:    locks[0],
+			Site:    l.site,
+			Routine: d.getRoutineIndex(),
+		},
+	}, out)
+}
+
+// report an acquisition, which contradicts the declared lock order
+//  Args:
+//   chain ([]string): chain of the declared lock order
+//   held (mutexInt): lock, which was held
+//   heldCaller (callerInfo): acquisition of the held lock
+//   acquired (mutexInt): lock, which was acquired
+//   acquiredCaller (callerInfo): acquisition of the acquired lock
+//  Returns:
+//   nil
+func (d *Detector) reportLockOrderViolation(chain []string, held mutexInt,
+	heldCaller callerInfo, acquired mutexInt, acquiredCaller callerInfo) {
+	out := &bytes.Buffer{}
+
+	fmt.Fprintf(out, purple, "Acquisition contradicts the declared lock order:\n\n")
+	fmt.Fprintln(out, strings.Join(chain, " -> "))
+	fmt.Fprintln(out, "")
+
+	heldContext := *held.getContext()
+	fmt.Fprintf(out, purple, "Held lock:\n\n")
+	fmt.Fprintln(out, heldCaller.file(), heldCaller.line(),
+		fmt.Sprint("(lock created at ", heldContext[0].file(), ":", heldContext[0].line(), ")"))
+	fmt.Fprintln(out, "")
+
+	acquiredContext := *acquired.getContext()
+	fmt.Fprintf(out, purple, "Acquired lock:\n\n")
+	fmt.Fprintln(out, acquiredCaller.file(), acquiredCaller.line(),
+		fmt.Sprint("(lock created at ", acquiredContext[0].file(), ":",
+			acquiredContext[0].line(), ")"))
+	fmt.Fprintf(out, "\n\n")
+
+	d.report(Report{
+		Type:  ReportWarning,
+		Title: "LOCK ORDER VIOLATION",
+		Sites: []Site{newSite(heldCaller), newSite(acquiredCaller)},
+		Locks: lockInfos(held, acquired),
+	}, out)
+}
+
+// report a self deadlock of a routine, which acquires a lock again, after it
+// has acquired other locks while holding it
+//  Args:
+//   r (*routine): routine which acquires the lock
+//   pos (int): position of the lock in the holding set of r
+//   m (mutexInt): lock which is acquired again
+//  Returns:
+//   nil
+func (d *Detector) reportSelfDeadlock(r *routine, pos int, m mutexInt) {
+	out := &bytes.Buffer{}
+	sites := make([]Site, 0)
+
+	// print the chain of the held locks, starting with m
+	fmt.Fprintf(out, purple, "Locks held by the routine:\n\n")
+	for i := pos; i < r.holdingCount; i++ {
+		held := r.holdingSet[i]
+		context := *held.getContext()
+		if r.holdingCallers != nil {
+			caller := r.holdingCallers[i]
+			fmt.Fprintln(out, caller.file(), caller.line(),
+				fmt.Sprint("(lock created at ", context[0].file(), ":", context[0].line(), ")"))
+			sites = append(sites, newSite(caller))
+		} else {
+			fmt.Fprintln(out, "lock created at", context[0].file(), context[0].line())
+		}
+	}
+	fmt.Fprintln(out, "")
+
+	frame := userFrame()
+	fmt.Fprintf(out, purple, "Acquisition of the first lock again:\n\n")
+	fmt.Fprintln(out, frame.File, frame.Line)
+	sites = append(sites, Site{File: frame.File, Line: frame.Line})
+	fmt.Fprintln(out, "")
+	fmt.Fprintln(out, d.goroutine(r.index).sentence(),
+		"waits for a lock which it holds itself.")
+	d.writeHistory(out, []int{r.index})
+	fmt.Fprintf(out, "\n\n")
+
+	locks := lockInfos(r.holdingSet[pos:r.holdingCount]...)
+	d.report(Report{
+		Type:       ReportDeadlock,
+		Title:      "DEADLOCK (SELF DEADLOCK)",
+		Sites:      sites,
+		Locks:      locks,
+		Goroutines: d.goroutines([]int{r.index}),
+		err:        &DoubleLockError{Lock: locks[0], Sites: sites, Deadlock: true},
+	}, out)
+}
+
+// report a comprehensive detection, which was cut short by a limit
+//  Args:
+//   t (Truncation): limits and coverage of the detection
+//  Returns:
+//   nil
+func (d *Detector) reportTruncation(t Truncation) {
+	out := &bytes.Buffer{}
+
+	reasons := make([]string, 0, len(t.Limits))
+	for _, limit := range t.Limits {
+		if limit == "canceled" {
+			reasons = append(reasons, "the cancellation")
+		} else {
+			reasons = append(reasons, "the "+limit+" limit")
+		}
+	}
+	fmt.Fprintf(out, purple, "The search for potential deadlocks was cut short by "+
+		strings.Join(reasons, " and ")+". Not all potential deadlocks may have "+
+		"been reported.\n\n")
+	fmt.Fprintln(out, "Routines covered:", t.RoutinesCovered, "of", t.Routines)
+	fmt.Fprintln(out, "Chains explored:", t.ChainsExplored)
+	fmt.Fprintln(out, "Chains cut by the depth limit:", t.ChainsCut)
+	fmt.Fprintln(out, "")
+	fmt.Fprintln(out, "truncated:", t)
+	fmt.Fprintf(out, "\n")
+
+	d.report(Report{
+		Type:       ReportWarning,
+		Title:      "INCOMPLETE DETECTION",
+		Truncation: &t,
+	}, out)
+}
+
+// report a routine, which waits for a lock longer than the threshold
+//  Args:
+//   m (mutexInt): lock the routine waits for
+//   index (int): index of the waiting routine
+//   state (waitState): wait of the routine
+//  Returns:
+//   nil
+func (d *Detector) reportLongWait(m mutexInt, index int, state waitState) {
+	out := &bytes.Buffer{}
+
+	// print information about the involved lock
+	fmt.Fprintf(out, purple, "Initialization of lock involved in long wait:\n\n")
+	context := *m.getContext()
+	fmt.Fprintln(out, context[0].file(), context[0].line())
+	fmt.Fprintln(out, "")
+	fmt.Fprintf(out, purple, "Waiting call:\n\n")
+	fmt.Fprintln(out, state.caller.file(), state.caller.line())
+	fmt.Fprintln(out, "")
+	fmt.Fprintln(out, d.goroutine(index).sentence(), "is waiting for",
+		time.Since(state.start).Round(time.Millisecond))
+	fmt.Fprintln(out, "")
+	d.writeHolders(out, m, index)
+	fmt.Fprintf(out, "\n")
+
+	d.report(Report{
+		Type:       ReportWarning,
+		Title:      "LONG WAIT",
+		Sites:      []Site{newSite(state.caller)},
+		Locks:      lockInfos(m),
+		Goroutines: d.goroutines([]int{index}),
+	}, out)
+}
+
+// report a lock convoy
+//  Args:
+//   m (mutexInt): lock on which the convoy was detected
+//   c (*convoyInfo): information about the queue of the lock
+//  Returns:
+//   nil
+func (d *Detector) reportConvoy(m mutexInt, c *convoyInfo) {
+	out := &bytes.Buffer{}
+
+	// print information about the involved lock
+	fmt.Fprintf(out, purple, "Initialization of lock involved in convoy:\n\n")
+	context := *m.getContext()
+	fmt.Fprintln(out, context[0].file(), context[0].line())
+	fmt.Fprintln(out, "")
+	fmt.Fprintln(out, "Average number of waiting routines:",
+		float64(c.waitingSum)/float64(c.acquisitions))
+	fmt.Fprintln(out, "Immediate reacquisitions:", c.reacquisitions,
+		"of", c.acquisitions, "acquisitions")
+	fmt.Fprintln(out, "")
+	fmt.Fprintf(out, purple, "Immediate reacquisitions by the releasing routine:\n\n")
+	sites := make([]Site, 0)
+	for _, call := range c.reacquisitionSites {
+		fmt.Fprintln(out, call.file(), call.line())
+		sites = append(sites, newSite(call))
+	}
+	fmt.Fprintln(out, "")
+	fmt.Fprintf(out, purple, "Calls of waiting routines:\n\n")
+	for _, call := range c.waitingSites {
+		fmt.Fprintln(out, call.file(), call.line())
+		sites = append(sites, newSite(call))
+	}
+	fmt.Fprintf(out, "\n\n")
+
+	d.report(Report{
+		Type:  ReportWarning,
+		Title: "LOCK CONVOY",
+		Sites: sites,
+		Locks: lockInfos(m),
+	}, out)
+}
+
+// report if a routine waits for a resource, which can only be released
+// by the routine itself
+//  Args:
+//   resource (waitResource): resource the routine waits for
+//   caller (callerInfo): caller info of the wait
+//   first ([]byte): call stack of the call, which holds the resource, nil if
+//    unknown
+//   reentrant ([]byte): call stack of the reentrant call, nil if unknown
+//  Returns:
+//   nil
+func (d *Detector) reportDeadlockReentrant(resource waitResource, caller callerInfo,
+	first []byte, reentrant []byte) {
+	out := &bytes.Buffer{}
+
+	// print information about the involved resource
+	fmt.Fprintf(out, purple, fmt.Sprint("Initialization of ",
+		resource.getResourceName(), " involved in deadlock:\n\n"))
+	context := *resource.getContext()
+	fmt.Fprintln(out, context[0].file(), context[0].line())
+	fmt.Fprintln(out, "")
+	fmt.Fprintf(out, purple, "Reentrant call involved in deadlock:\n\n")
+	fmt.Fprintln(out, caller.file(), caller.line())
+	if first != nil {
+		fmt.Fprintln(out, "")
+		fmt.Fprintf(out, purple, "Call stack of the first call:\n\n")
+		fmt.Fprintln(out, strings.TrimRight(string(first), "\n"))
+	}
+	if reentrant != nil {
+		fmt.Fprintln(out, "")
+		fmt.Fprintf(out, purple, "Call stack of the reentrant call:\n\n")
+		fmt.Fprintln(out, strings.TrimRight(string(reentrant), "\n"))
+	}
+	fmt.Fprintf(out, "\n\n")
+
+	d.report(Report{
+		Type:  ReportDeadlock,
+		Title: "DEADLOCK (REENTRANT CALL)",
+		Sites: []Site{newSite(caller)},
+		Locks: lockInfos(resourceLocks(resource)...),
+	}, out)
+}
+
+// report a deadlock found in the wait-for graph
+//  Args:
+//   cycle ([]int): indexes of the routines in the cycle
+//   states ([]waitState): waits of the routines in the cycle
+//  Returns:
+//   nil
+func (d *Detector) reportDeadlockWaitFor(cycle []int, states []waitState) {
+	out := &bytes.Buffer{}
+	id, sites := d.writeWaitForCycle(out, "Resources involved in deadlock", cycle, states)
+	d.writeHistory(out, cycle)
+	fmt.Fprintf(out, "\n")
+	d.writeGoroutineDump(out)
+
+	locks := lockInfos(resourceLocks(waitResources(states)...)...)
+	d.report(Report{
+		Type:       ReportDeadlock,
+		Title:      "DEADLOCK (WAIT-FOR CYCLE)",
+		ID:         id,
+		Sites:      sites,
+		Locks:      locks,
+		Goroutines: d.goroutines(cycle),
+		err:        &CycleError{ID: id, Sites: sites, Locks: locks},
+	}, out)
+}
+
+// report a cycle in the wait-for graph, which contains a wait whose blockers
+// are only inferred, e.g. from earlier uses of the resource. Another routine
+// can release the wait, the cycle is therefore reported as a warning
+//  Args:
+//   cycle ([]int): indexes of the routines in the cycle
+//   states ([]waitState): waits of the routines in the cycle
+//  Returns:
+//   nil
+func (d *Detector) reportInferredWaitFor(cycle []int, states []waitState) {
+	out := &bytes.Buffer{}
+	id, sites := d.writeWaitForCycle(out, "Resources involved in the cycle", cycle, states)
+	fmt.Fprintln(out, "The routines are blocked in a cycle, but the routines releasing some of")
+	fmt.Fprintln(out, "the waits are only inferred, e.g. from earlier uses of the resource.")
+	fmt.Fprintln(out, "A routine outside of the cycle can still release these waits.")
+	fmt.Fprintln(out, "")
+	d.writeHistory(out, cycle)
+	fmt.Fprintf(out, "\n")
+
+	d.report(Report{
+		Type:       ReportWarning,
+		Title:      "INFERRED WAIT-FOR CYCLE",
+		ID:         id,
+		Sites:      sites,
+		Locks:      lockInfos(resourceLocks(waitResources(states)...)...),
+		Goroutines: d.goroutines(cycle),
+	}, out)
+}
+
+// write the identifier and the waits of a cycle in the wait-for graph
+//  Args:
+//   out (*bytes.Buffer): buffer to write to
+//   heading (string): heading of the waits
+//   cycle ([]int): indexes of the routines in the cycle
+//   states ([]waitState): waits of the routines in the cycle
+//  Returns:
+//   (string): identifier of the cycle
+//   ([]Site): sites of the waits
+func (d *Detector) writeWaitForCycle(out *bytes.Buffer, heading string, cycle []int,
+	states []waitState) (string, []Site) {
+	// print the identifier of the cycle
+	id := waitForCycleID(states)
+	fmt.Fprintln(out, "Cycle ID:", id)
+	fmt.Fprintln(out, "")
+
+	fmt.Fprintf(out, purple, heading+":\n\n")
+	sites := make([]Site, 0)
+	for i, state := range states {
+		context := *state.resource.getContext()
+		fmt.Fprintf(out, blue, fmt.Sprint(d.goroutine(cycle[i]).sentence(),
+			" waits for ", state.resource.getResourceName(), " created at: ",
+			context[0].file(), ":", context[0].line()))
+		fmt.Fprintf(out, "\n")
+		if state.caller.file() != "" {
+			fmt.Fprintln(out, state.caller.file(), state.caller.line())
+			sites = append(sites, newSite(state.caller))
+		}
+		if c, ok := state.resource.(*Cond); ok {
+			c.writeMissedSignal(out)
+		}
+		fmt.Fprintln(out, "")
+	}
+
+	// routines waiting for a condition variable, which can only be signaled
+	// by the routines in the deadlock
+	waiters, waits := d.condWaitersBlockedBy(cycle)
+	if len(waiters) != 0 {
+		fmt.Fprintf(out, purple, "Routines waiting for a Cond, which is only signaled by blocked routines:\n\n")
+		for i, state := range waits {
+			context := *state.resource.getContext()
+			fmt.Fprintf(out, blue, fmt.Sprint(d.goroutine(waiters[i]).sentence(),
+				" waits for Cond created at: ", context[0].file(), ":", context[0].line()))
+			fmt.Fprintf(out, "\n")
+			if state.caller.file() != "" {
+				fmt.Fprintln(out, state.caller.file(), state.caller.line())
+			}
+			state.resource.(*Cond).writeMissedSignal(out)
+			fmt.Fprintln(out, "")
+		}
+	}
+	return id, sites
+}
+
+// report a cycle in the lock order of the init functions of different packages
+//  Args:
+//   cycle ([]*dependency): dependencies which create the edges of the cycle
+//  Returns:
+//   nil
+func (d *Detector) reportPotentialDeadlockInitOrder(cycle []*dependency) {
+	out := &bytes.Buffer{}
+
+	// print the identifier of the cycle
+	id := dependencyCycleID(cycle)
+	fmt.Fprintln(out, "Cycle ID:", id)
+	fmt.Fprintln(out, "")
+
+	// print information about the locks in the circle
+	fmt.Fprintf(out, purple, "Initialization of locks involved in potential deadlock:\n\n")
+	for _, dep := range cycle {
+		context := *dep.mu.getContext()
+		fmt.Fprintln(out, context[0].file(), context[0].line())
+	}
+
+	// print the acquisitions in the init functions, which create the cycle
+	fmt.Fprintf(out, purple, "\nCalls in init functions involved in potential deadlock:\n\n")
+	sites := make([]Site, 0, len(cycle))
+	for _, dep := range cycle {
+		fmt.Fprintln(out, dep.caller.file(), dep.caller.line(), "(init)")
+		sites = append(sites, newSite(dep.caller))
+		if d.opts.CollectCallStack {
+			fmt.Fprint(out, dep.caller.callStacks())
 		}
 	}
-	fmt.Fprintf(os.Stderr, "\n\n")
+	fmt.Fprintf(out, "\n\n")
+
+	locks := lockInfos(dependencyLocks(cycle)...)
+	d.report(Report{
+		Type:  ReportPotentialDeadlock,
+		Title: "POTENTIAL DEADLOCK (INIT ORDER)",
+		ID:    id,
+		Sites: sites,
+		Locks: locks,
+		err:   &CycleError{ID: id, Sites: sites, Locks: locks, Potential: true},
+	}, out)
 }
 
-// print a message, that the program was terminated because of a detected local deadlock
-// Returns:
-//  nil
-func reportDeadlockPeriodical() {
-	fmt.Fprintf(os.Stderr, red, "THE PROGRAM WAS TERMINATED BECAUSE IT DETECTED A LOCAL DEADLOCK\n\n")
+// write the call stacks of all goroutines, if the program is terminated
+// because of a confirmed local deadlock. The states of the other goroutines
+// are usually needed to fix the deadlock
+//  Args:
+//   out (*bytes.Buffer): buffer to write to
+//  Returns:
+//   nil
+func (d *Detector) writeGoroutineDump(out *bytes.Buffer) {
+	if !d.opts.GoroutineDump || !d.exit || !exitSupported {
+		return
+	}
+	fmt.Fprintf(out, purple, "Goroutines:\n\n")
+	fmt.Fprintln(out, allGoroutineStacks())
+	fmt.Fprintln(out, "")
 }
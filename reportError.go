@@ -0,0 +1,172 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+reportError.go
+Implementation of the error interface for the reports. Each report wraps an
+error, which describes the kind of the finding, so that sinks and tests can
+use errors.Is and errors.As instead of comparing the titles, e.g.
+
+	var cycle *deadlock.CycleError
+	if errors.As(r, &cycle) {
+		...
+	}
+	if errors.Is(r, deadlock.ReportDeadlock) {
+		...
+	}
+*/
+
+import "fmt"
+
+// CycleError describes a report of a cycle in the lock trees or of a
+// cycle of waiting routines
+type CycleError struct {
+	// stable identifier of the cycle
+	ID string
+	// acquisitions, which create the edges of the cycle
+	Sites []Site
+	// locks in the cycle
+	Locks []LockInfo
+	// true if the cycle is a potential deadlock, false if the routines in
+	// the cycle are actually blocked
+	Potential bool
+}
+
+// Error returns the description of the cycle
+//  Returns:
+//   (string): description of the cycle
+func (e *CycleError) Error() string {
+	if e.Potential {
+		return fmt.Sprintf("potential deadlock: cycle %s of %d locks", e.ID, len(e.Locks))
+	}
+	return fmt.Sprintf("deadlock: cycle %s of %d locks", e.ID, len(e.Locks))
+}
+
+// DoubleLockError describes a report of a routine, which acquires a lock,
+// which it already holds
+type DoubleLockError struct {
+	// lock which was acquired again
+	Lock LockInfo
+	// acquisitions of the lock, the last one is the acquisition which
+	// locked it again
+	Sites []Site
+	// most recent release of the lock by the routine, nil if the routine
+	// never released the lock
+	Released *Site
+	// true if the routine blocks, false if the double locking policy of the
+	// lock downgraded the report to a warning
+	Deadlock bool
+}
+
+// Error returns the description of the double locking
+//  Returns:
+//   (string): description of the double locking
+func (e *DoubleLockError) Error() string {
+	return fmt.Sprintf("double locking of lock created at %s:%d",
+		e.Lock.Site.File, e.Lock.Site.Line)
+}
+
+// AbandonedLockError describes a report of a lock, which is still held,
+// when the code which acquired it is no longer able to release it
+type AbandonedLockError struct {
+	// lock which was abandoned
+	Lock LockInfo
+	// acquisition of the lock
+	Site Site
+	// index of the routine, which holds the lock, -1 if the routine is not
+	// registered in the detector
+	Routine int
+}
+
+// Error returns the description of the abandoned lock
+//  Returns:
+//   (string): description of the abandoned lock
+func (e *AbandonedLockError) Error() string {
+	return fmt.Sprintf("lock acquired at %s:%d was never released",
+		e.Site.File, e.Site.Line)
+}
+
+// ExpectationError describes a report of a violated expectation about a
+// held lock (see Expect)
+type ExpectationError struct {
+	// lock of the expectation
+	Lock LockInfo
+	// site where the expectation was added to the context
+	Expectation Site
+	// site where the expectation was checked
+	Check Site
+	// true if the lock had to be held, false if it must not be held
+	Held bool
+}
+
+// Error returns the description of the violated expectation
+//  Returns:
+//   (string): description of the violated expectation
+func (e *ExpectationError) Error() string {
+	if e.Held {
+		return fmt.Sprintf("lock created at %s:%d is not held at %s:%d",
+			e.Lock.Site.File, e.Lock.Site.Line, e.Check.File, e.Check.Line)
+	}
+	return fmt.Sprintf("lock created at %s:%d is held at %s:%d",
+		e.Lock.Site.File, e.Lock.Site.Line, e.Check.File, e.Check.Line)
+}
+
+// Error returns the title of the report, so that a report can be used as
+// an error
+//  Returns:
+//   (string): title of the report
+func (r Report) Error() string {
+	if r.ID != "" {
+		return r.Title + " (Cycle ID: " + r.ID + ")"
+	}
+	return r.Title
+}
+
+// Unwrap returns the error, which describes the kind of the finding
+//  Returns:
+//   (error): *CycleError, *DoubleLockError, *AbandonedLockError or
+//    *ExpectationError, nil for other reports
+func (r Report) Unwrap() error {
+	return r.err
+}
+
+// Is reports whether the report has the type target, so that
+// errors.Is(r, ReportDeadlock) is true for all deadlocks
+//  Args:
+//   target (error): error to compare with
+//  Returns:
+//   (bool): true if target is the type of the report
+func (r Report) Is(target error) bool {
+	t, ok := target.(ReportType)
+	return ok && t == r.Type
+}
+
+// Error returns the name of the report type, so that a report type can be
+// used as the target of errors.Is
+//  Returns:
+//   (string): name of the report type
+func (t ReportType) Error() string {
+	return t.String()
+}
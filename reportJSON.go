@@ -0,0 +1,122 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+reportJSON.go
+Implementation of the reports as JSON lines. With OutputJSON each report is
+printed as one JSON object per line instead of the text, so that the program
+only emits the cheap machine format and the reports are rendered offline,
+e.g. with cmd/undead-report. ReadReports reads the reports back.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// MarshalText returns the name of the report type, so that the type is
+// readable in the JSON form of a report
+//  Returns:
+//   ([]byte): name of the report type
+//   (error): error if the report type is unknown
+func (t ReportType) MarshalText() ([]byte, error) {
+	if t < ReportDeadlock || t > ReportWarning {
+		return nil, fmt.Errorf("deadlock: unknown report type %d", int(t))
+	}
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText sets the report type from its name
+//  Args:
+//   text ([]byte): name of the report type
+//  Returns:
+//   (error): error if the name is unknown
+func (t *ReportType) UnmarshalText(text []byte) error {
+	for _, reportType := range []ReportType{ReportDeadlock,
+		ReportPotentialDeadlock, ReportWarning} {
+		if string(text) == reportType.String() {
+			*t = reportType
+			return nil
+		}
+	}
+	return fmt.Errorf("deadlock: unknown report type %q", string(text))
+}
+
+// JSON form of a report
+type reportRecord struct {
+	// schema version of the record
+	Schema int `json:"schema"`
+	Report
+}
+
+// write a report as one line of JSON
+//  Args:
+//   w (io.Writer): writer to write to
+//   r (Report): the report
+//  Returns:
+//   nil
+func writeReportJSON(w io.Writer, r Report) {
+	// the report is encoded completely before it is written, so that the
+	// lines of concurrent reports are not mixed
+	line, err := json.Marshal(reportRecord{Schema: SchemaVersion, Report: r})
+	if err != nil {
+		fmt.Fprintln(w, "deadlock: could not encode the report:", err)
+		return
+	}
+	w.Write(append(line, '\n'))
+}
+
+// ReadReports reads the reports printed with OutputJSON. Lines, which are
+// not JSON objects, e.g. output of the program on stderr, are skipped. The
+// read reports do not wrap the error describing the kind of the finding
+//  Args:
+//   r (io.Reader): reader of the reports
+//  Returns:
+//   ([]Report): the reports in the order of the input
+//   (error): error if the input could not be read, a report is invalid or
+//    was written with an unsupported schema version
+func ReadReports(r io.Reader) ([]Report, error) {
+	res := make([]Report, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		var record reportRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return res, err
+		}
+		if err := CheckSchemaVersion(record.Schema); err != nil {
+			return res, err
+		}
+		res = append(res, record.Report)
+	}
+	return res, scanner.Err()
+}
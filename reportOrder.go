@@ -0,0 +1,136 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+reportOrder.go
+This file implements the deterministic order of the reports of the
+comprehensive detection. The order in which the search finds the cycles
+depends on the indexes of the routines, which depend on the scheduling, and
+on the iteration of maps. The reports created by the routine running the
+detection are therefore held back and emitted sorted by their cycle ID,
+their sites and their title at the end of the detection, so that the
+outputs of two runs only differ if the found cycles differ. Reports of other
+routines, e.g. of the periodical detection, are emitted immediately.
+*/
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/petermattis/goid"
+)
+
+// report, which is held back until the end of a detection
+type heldReport struct {
+	// the report without the text
+	report Report
+	// colored text of the report without the title
+	out *bytes.Buffer
+}
+
+// reports held back by a running comprehensive detection
+type reportOrder struct {
+	// id of the goroutine running the detection
+	goroutine int64
+	// held back reports in the order of their creation
+	reports []heldReport
+}
+
+// start to hold back the reports of the current routine
+//  Returns:
+//   nil
+func (d *Detector) startReportOrder() {
+	d.reportOrderLock.Lock()
+	d.reportOrder = &reportOrder{goroutine: goid.Get()}
+	d.reportOrderLock.Unlock()
+}
+
+// hold back a report, if it is created by the routine running the detection
+//  Args:
+//   r (Report): report without the text
+//   out (*bytes.Buffer): colored text of the report without the title
+//  Returns:
+//   (bool): true if the report was held back, false if it must be emitted
+func (d *Detector) holdBackReport(r Report, out *bytes.Buffer) bool {
+	d.reportOrderLock.Lock()
+	defer d.reportOrderLock.Unlock()
+
+	o := d.reportOrder
+	if o == nil || o.goroutine != goid.Get() {
+		return false
+	}
+	o.reports = append(o.reports, heldReport{report: r, out: out})
+	return true
+}
+
+// stop to hold back reports and emit the held back reports sorted by their
+// cycle ID, their sites and their title. Reports without a cycle ID are
+// emitted after the cycles
+//  Returns:
+//   nil
+func (d *Detector) finishReportOrder() {
+	d.reportOrderLock.Lock()
+	o := d.reportOrder
+	d.reportOrder = nil
+	d.reportOrderLock.Unlock()
+
+	if o == nil {
+		return
+	}
+
+	sort.SliceStable(o.reports, func(i, j int) bool {
+		return reportLess(o.reports[i].report, o.reports[j].report)
+	})
+	for _, h := range o.reports {
+		d.report(h.report, h.out)
+	}
+}
+
+// compare two reports by their cycle ID, their sites and their title
+//  Args:
+//   a (Report): first report
+//   b (Report): second report
+//  Returns:
+//   (bool): true if a is emitted before b
+func reportLess(a, b Report) bool {
+	if (a.ID == "") != (b.ID == "") {
+		return a.ID != ""
+	}
+	if a.ID != b.ID {
+		return a.ID < b.ID
+	}
+	for k := 0; k < len(a.Sites) && k < len(b.Sites); k++ {
+		if a.Sites[k].File != b.Sites[k].File {
+			return a.Sites[k].File < b.Sites[k].File
+		}
+		if a.Sites[k].Line != b.Sites[k].Line {
+			return a.Sites[k].Line < b.Sites[k].Line
+		}
+	}
+	if len(a.Sites) != len(b.Sites) {
+		return len(a.Sites) < len(b.Sites)
+	}
+	return a.Title < b.Title
+}
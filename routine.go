@@ -31,91 +31,132 @@ to update these trees.
 */
 
 import (
-	"os"
-	"runtime"
-	"strings"
-	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/ErikKassubek/Deadlock-Go/internal/record"
 	"github.com/petermattis/goid"
 )
 
-// map to map the internal routine id to index in routines
-var mapIndex = make(map[int64]int)
-
-// lock for the creation of a new routine
-var createRoutineLock sync.Mutex
-
-// list of routines
-var routines = make([]routine, opts.maxRoutines)
-
-// number of routines in routines
-var numberRoutines = 0
+// initial size of holding sets, which grow dynamically
+const initialHoldingSetSize = 8
 
 // type to implement structures for lock logging
 type routine struct {
+	// detector the routine belongs to
+	detector *Detector
 	// index of the routine
 	index int
+	// identity of the goroutine of the routine without its name
+	identity Goroutine
 	// number of currently hold locks
 	holdingCount int
 	// set of currently hold locks
 	holdingSet []mutexInt
+	// true for the locks in holdingSet which are held as reader locks
+	holdingRead []bool
 	// map of the dependencies
 	dependencyMap map[uintptr]*[]*dependency
 	// list of dependencies, implements the lock tree
 	dependencies [](*dependency)
-	// last inserted dependency
-	curDep *dependency
 	// number of dependencies in dependency map
 	depCount int
+	// number of complete dependencies, which can be read by the analysis.
+	// Must be accessed atomically (see snapshot.go)
+	published int32
 	// map to save information about collected single level
 	collectedSingleLevelLocks map[string][]int
+	// last lock events of the routine, nil if the history is disabled
+	history record.Recorder
+	// acquisition sites of the locks in holdingSet, nil if neither the lock
+	// depth nor the declared lock order is checked
+	holdingCallers []callerInfo
+	// call stacks of the acquisitions of the locks in holdingSet, nil if
+	// no edge examples are collected
+	holdingStacks [][]uintptr
+	// locks held by the routine, which were acquired on the lightweight
+	// path of hot locks or guarded reads and are not in holdingSet
+	lightHolding []lightHeldLock
+	// call stacks of guarded reads, whose single level site was already
+	// collected
+	guardedReadSites map[guardedReadSite]struct{}
+	// true if the routine exceeded the bound of the holding set
+	holdingSetBoundReported bool
+	// locks acquired by the routine since its start or its last call of
+	// Done on a wait group, nil if no wait group was created
+	doneAcquisitions map[doneAcquisition]callerInfo
 }
 
 // Initialize a go routine
 // Returns:
 //  nil
-func newRoutine() {
+func (d *Detector) newRoutine() {
 	// return if detection is disabled
-	if !opts.periodicDetection && !opts.comprehensiveDetection {
+	if !d.opts.PeriodicDetection && !d.opts.ComprehensiveDetection &&
+		len(d.opts.LockOrder) == 0 {
 		return
 	}
 
+	// the creator of the goroutine is read from its call stack before the
+	// routine list is locked
+	identity := currentGoroutine()
+
 	// lock the routine list
-	createRoutineLock.Lock()
+	d.createRoutineLock.Lock()
+
+	// the holding set is preallocated with its maximum size or grows
+	// dynamically
+	holdingSize := d.opts.MaxNumberOfDependentLocks
+	if holdingSize == 0 {
+		holdingSize = initialHoldingSetSize
+	}
 
 	// create the routine
 	r := routine{
-		index:                     numberRoutines,
+		detector:                  d,
+		index:                     d.numberRoutines,
+		identity:                  identity,
 		holdingCount:              0,
-		holdingSet:                make([]mutexInt, opts.maxNumberOfDependentLocks),
+		holdingSet:                make([]mutexInt, holdingSize),
+		holdingRead:               make([]bool, holdingSize),
 		dependencyMap:             make(map[uintptr]*[]*dependency),
-		dependencies:              make([]*dependency, opts.maxDependencies),
-		curDep:                    nil,
+		dependencies:              make([]*dependency, d.opts.MaxDependencies),
 		depCount:                  0,
 		collectedSingleLevelLocks: make(map[string][]int),
+		guardedReadSites:          make(map[guardedReadSite]struct{}),
+	}
+	if d.opts.EventHistorySize > 0 {
+		r.history = record.NewRing(d.opts.EventHistorySize)
+	}
+	if d.opts.MaxLockDepth > 0 || len(d.opts.LockOrder) > 0 || d.opts.EdgeExamples {
+		r.holdingCallers = make([]callerInfo, holdingSize)
+	}
+	if d.opts.EdgeExamples {
+		r.holdingStacks = make([][]uintptr, holdingSize)
 	}
 
 	// the routine list can only contain a fixed amount of routines
 	// panic if it already full
-	if numberRoutines >= opts.maxRoutines {
+	if d.numberRoutines >= len(d.routines) {
 		panic(`Number of routines is greater than max number of routines. 
 			Increase Opts.MaxRoutines.`)
 	}
 
 	// set the routine
-	routines[numberRoutines] = r
+	d.routines[d.numberRoutines] = r
 
 	// save the link from internal go id to index of routine
-	mapIndex[goid.Get()] = numberRoutines
+	d.mapIndex[identity.ID] = d.numberRoutines
 
 	// increase number of routines in routine
-	numberRoutines++
+	d.numberRoutines++
+	d.advanceEpoch()
 
 	// release list lock
-	createRoutineLock.Unlock()
+	d.createRoutineLock.Unlock()
 
 	// allocate the dependency list
-	// for i := 0; i < opts.maxDependencies; i++ {
+	// for i := 0; i < opts.MaxDependencies; i++ {
 	// 	dep := newDependency(nil, nil, 0)
 	// 	r.dependencies[i] = &dep
 	// }
@@ -124,42 +165,71 @@ func newRoutine() {
 // Update the routine structure if a mutex is locked
 // Args:
 //  m (mutexInt): mutex to lock
+//  rLock (bool): true if the lock is a reader lock
+//  upgrade (bool): true if the lock is acquired as an upgrade of a reader lock
+//  initPhase (bool): true if the lock is acquired during the initialization
+//   of the packages
 // Returns:
 //  nil
-func (r *routine) updateLock(m mutexInt, rLock bool) {
+func (r *routine) updateLock(m mutexInt, rLock bool, upgrade bool, initPhase bool) {
 	hc := r.holdingCount
 
+	// time of the acquisition, the monotonic clock of time.Since is not
+	// influenced by changes of the wall clock
+	now := time.Since(r.detector.start)
+
 	m.setRLock(r.index, rLock)
 
 	isNew := false
 
+	// dependency which was added to the lock tree by this call
+	var newDep *dependency
+
 	// if lock is not a single level lock -> found nested lock
-	if hc > 0 {
+	// upgrades are always recorded as dependencies, so that concurrent upgrades
+	// can be detected, even if no other lock is held
+	if hc > 0 || upgrade {
 		// calculate the key corresponding to the dependency from the memory addresses
 		// of m and the last mutex which was added to the list of mutexes which
 		// are currently held by r
-		key := m.getMemoryPosition() ^ r.holdingSet[hc-1].getMemoryPosition()
+		key := m.getMemoryPosition()
+		if hc > 0 {
+			key ^= r.holdingSet[hc-1].getMemoryPosition()
+		}
 
 		depMap := r.dependencyMap
 
 		// check if the key already exists in depMap
 		d, ok := depMap[key]
 
+		var existing *dependency
+		if ok {
+			existing = r.findDependency(m, d, rLock, upgrade)
+		}
+
 		panicMassage := `Number of dependencies is greater than max number of 
 			dependencies. Increase Opts.MaxDependencies.`
 
 		// Check if the key does not exists or if it exists, that the current
 		// dependency, created by locking m is not already in the list of
 		// dependencies associated with that key. In this case the dependency
-		// will be added to the lock tree
-		if !(ok && r.dependencyAlreadyExists(m, d)) {
+		// will be added to the lock tree. Otherwise only the time of the last
+		// acquisition of the existing dependency is renewed
+		if existing != nil {
+			existing.observe(now)
+		} else {
 			// panic if the number of number of dependencies in the lock tree exceeds
 			// it maximum
-			if r.depCount >= opts.maxDependencies {
+			if r.depCount >= r.detector.opts.MaxDependencies {
 				panic(panicMassage)
 			}
 			// add the new dependency to the lock tree
-			dep := newDependency(m, r.holdingSet, hc)
+			dep := newDependency(m, rLock, r.holdingSet, r.holdingRead, hc, upgrade)
+			dep.initPhase = initPhase
+			dep.first = now
+			dep.last = int64(now)
+			dep.count = 1
+			dep.example = r.newEdgeExample(hc)
 			r.dependencies[r.depCount] = &dep
 			dep.update(m, &r.holdingSet, hc)
 			r.depCount++
@@ -172,8 +242,11 @@ func (r *routine) updateLock(m mutexInt, rLock bool) {
 			}
 			r.dependencyMap[key] = d
 
-			// set the last added dependency pf the tree
-			r.curDep = &dep
+			newDep = &dep
+			markInLockTree(m)
+			for i := 0; i < hc; i++ {
+				markInLockTree(r.holdingSet[i])
+			}
 
 			isNew = true
 		}
@@ -181,99 +254,130 @@ func (r *routine) updateLock(m mutexInt, rLock bool) {
 	} else {
 		// save information on single level locks if enabled in the options
 		// to avoid creating the caller info multiple times
-		if opts.collectSingleLevelLockStack {
-			// get caller information
-			_, file, line, _ := runtime.Caller(3)
-
-			// check if a lock of a single level lock was already called in the same file
-			if lines, ok := r.collectedSingleLevelLocks[file]; ok {
-				isNew = true
-
-				for _, l := range lines {
-					// the call (from this file and line) was already called before
-					if l == line {
-						isNew = false
-						break
-					}
-				}
-
-				if isNew {
-					// add new information
-					r.collectedSingleLevelLocks[file] = append(
-						r.collectedSingleLevelLocks[file], line)
-				}
-			} else {
-				// add new information if no lock was locked from this file before
-				isNew = true
-				r.collectedSingleLevelLocks[file] = []int{line}
-			}
-		}
+		isNew = r.isNewSingleLevelLock()
 	}
 
 	// save caller information or call stacks if the dependency situation was
 	// added for the first time
-	if isNew && (hc > 0 || opts.collectSingleLevelLockStack) {
-		var file string
-		var line int
-		var bufStringCleaned string
-
-		// get the call stack if call stack collection is enabled
-		if opts.collectCallStack {
-			var bufString string
-			buf := make([]byte, opts.maxCallStackSize)
-			n := runtime.Stack(buf[:], false)
-			bufString = string(buf[:n])
-			bufStringSplit := strings.Split(bufString, "\n")
-			bufStringCleaned = bufStringSplit[0] + "\n"
-			for i := 7; i < len(bufStringSplit); i++ {
-				bufStringCleaned += bufStringSplit[i] + "\n"
-			}
+	if isNew && (hc > 0 || upgrade || r.detector.opts.CollectSingleLevelLockStack) {
+		info := r.saveLockCaller(m, upgrade, initPhase, now)
+
+		if newDep != nil {
+			newDep.caller = info
+			atomic.StoreInt32(&r.published, int32(r.depCount))
+			r.detector.advanceEpoch()
+			r.detector.publishDependency(newDep)
+			r.detector.recordDelta(newDep, r.index)
 		}
-
-		// get the file and line from which the locking was initiated
-		_, file, line, _ = runtime.Caller(3)
-
-		// add the new caller information
-		context := m.getContext()
-		*context = append(*context, newInfo(file, line, false, bufStringCleaned))
 	}
 
-	// panic if the holding depth exceeds its maximum
-	if hc >= opts.maxNumberOfDependentLocks {
-		panic(`Holding Count is grater than maximum number of dependent locks. 
-		Increase Opts.maxNumberOfDependentLocks.`)
-	}
+	// make room for the lock in the holding set of the routine
+	r.growHoldingSet(hc)
 
 	// add the lock to the holding set of the routine
 	r.holdingSet[hc] = m
+	r.holdingRead[hc] = rLock
+	r.saveHoldingCaller(hc)
+	r.checkLockOrder(hc)
+	r.checkLockDepth(hc)
 	r.holdingCount++
 }
 
-// check if the dependency which results from locking m already exists in list
+// check if a single level lock is acquired from a site, from which no single
+// level lock was acquired before, and remember the site
+//  Returns:
+//   (bool): true if the site is new, false if it is known or single level
+//    locks are not collected
+func (r *routine) isNewSingleLevelLock() bool {
+	if !r.detector.opts.CollectSingleLevelLockStack {
+		return false
+	}
+
+	// get caller information
+	file, line := userCaller()
+
+	// check if a lock of a single level lock was already called in the same file
+	lines, ok := r.collectedSingleLevelLocks[file]
+	if !ok {
+		// add new information if no lock was locked from this file before
+		r.collectedSingleLevelLocks[file] = []int{line}
+		return true
+	}
+
+	for _, l := range lines {
+		// the call (from this file and line) was already called before
+		if l == line {
+			return false
+		}
+	}
+
+	// add new information
+	r.collectedSingleLevelLocks[file] = append(lines, line)
+	return true
+}
+
+// add the caller information of an acquisition to the context of the lock
+//  Args:
+//   m (mutexInt): acquired lock
+//   upgrade (bool): true if m is acquired as an upgrade of a reader lock
+//   initPhase (bool): true if m is acquired during the initialization of
+//    the packages
+//   now (time.Duration): time of the acquisition
+//  Returns:
+//   (callerInfo): the saved caller information
+func (r *routine) saveLockCaller(m mutexInt, upgrade bool, initPhase bool,
+	now time.Duration) callerInfo {
+	var bufStringCleaned string
+
+	// get the call stack if call stack collection is enabled
+	if r.detector.opts.CollectCallStack {
+		bufStringCleaned = r.detector.callStack()
+	}
+
+	// get the file, line and function from which the locking was initiated
+	frame, condWait := userFrameCond()
+
+	// add the new caller information
+	info := newInfo(frame.File, frame.Line, frame.Function, false, upgrade, bufStringCleaned)
+	info.initPhase = initPhase
+	info.condWait = condWait
+	info.timestamp = now
+	context := m.getContext()
+	*context = append(*context, info)
+
+	return info
+}
+
+// find the dependency which results from locking m in list
 //  Args:
 //   m (mutexInt): mutex which gets locked
 //   depList (*([]*dependency)): list to check in
+//   rLock (bool): true if m is acquired as a reader lock
+//   upgrade (bool): true if m is acquired as an upgrade of a reader lock
 //  Returns:
-//   true if dependency already exist
-func (r *routine) dependencyAlreadyExists(m mutexInt, depList *([]*dependency)) bool {
+//   (*dependency): the existing dependency, nil if it does not exist
+func (r *routine) findDependency(m mutexInt, depList *([]*dependency),
+	rLock bool, upgrade bool) *dependency {
 	// traverse depList
 	for _, d := range *depList {
 		hc := r.holdingCount
 
 		// check if dependency with same lock and holding count exists
-		if d.mu == m && d.holdingCount == hc {
+		if d.mu == m && d.holdingCount == hc && d.read == rLock &&
+			d.upgrade == upgrade {
 			// check if the holdingSets in the dependency and the routine are equal
 			i := 0
-			for d.holdingSet[i] == r.holdingSet[i] && i < hc {
+			for i < hc && d.holdingSet[i] == r.holdingSet[i] &&
+				d.holdingRead[i] == r.holdingRead[i] {
 				i++
 			}
 			if i == hc {
-				return true
+				return d
 			}
 		}
 	}
 
-	return false
+	return nil
 }
 
 // update the routine data structure if tryLock is successfully
@@ -283,20 +387,77 @@ func (r *routine) dependencyAlreadyExists(m mutexInt, depList *([]*dependency))
 //  Returns:
 //   nil
 func (r *routine) updateTryLock(m mutexInt, rLock bool) {
-	// panic if the number of locks in the holding set exceeds its maximum
 	hc := r.holdingCount
-	if hc >= opts.maxNumberOfDependentLocks {
-		panic(`Holding Count is grater than maximum holding depth. Increase 
-			Opts.MaxHoldingDepth.`)
-	}
+	r.growHoldingSet(hc)
 
 	m.setRLock(r.index, rLock)
 
 	// add the lock to the holding set
 	r.holdingSet[hc] = m
+	r.holdingRead[hc] = rLock
+	r.saveHoldingCaller(hc)
+	r.checkLockOrder(hc)
+	r.checkLockDepth(hc)
 	r.holdingCount++
 }
 
+// make room for a new lock at position hc of the holding set. Holding sets
+// without a maximum size grow dynamically
+//  Args:
+//   hc (int): position of the new lock in the holding set
+//  Returns:
+//   nil
+func (r *routine) growHoldingSet(hc int) {
+	// panic if the holding depth exceeds its maximum
+	max := r.detector.opts.MaxNumberOfDependentLocks
+	if max > 0 && hc >= max {
+		panic(`Holding Count is grater than maximum number of dependent locks. 
+		Increase Opts.MaxNumberOfDependentLocks or set it to 0.`)
+	}
+
+	if hc >= len(r.holdingSet) {
+		r.holdingSet = append(r.holdingSet, nil)
+		r.holdingRead = append(r.holdingRead, false)
+		if r.holdingCallers != nil {
+			r.holdingCallers = append(r.holdingCallers, callerInfo{})
+		}
+		if r.holdingStacks != nil {
+			r.holdingStacks = append(r.holdingStacks, nil)
+		}
+	}
+
+	// report a routine, which holds more locks than the bound, once
+	bound := r.detector.opts.HoldingSetBound
+	if max == 0 && bound > 0 && hc >= bound && !r.holdingSetBoundReported {
+		r.holdingSetBoundReported = true
+		r.detector.reportHoldingSetBound(r.holdingSet[:hc], r.index)
+	}
+}
+
+// Update the routine data structure if the writer lock of a rw-mutex is
+// downgraded into a reader lock. The lock stays in the holding set, but
+// is from now on treated as a reader lock
+//  Args:
+//   m (mutexInt): rw-mutex which was downgraded
+//  Returns:
+//   nil
+func (r *routine) updateDowngrade(m mutexInt) {
+	m.setRLock(r.index, true)
+
+	for i := range r.lightHolding {
+		if r.lightHolding[i].mu == m {
+			r.lightHolding[i].read = true
+		}
+	}
+
+	for i := r.holdingCount - 1; i >= 0; i-- {
+		if r.holdingSet[i] == m {
+			r.holdingRead[i] = true
+			break
+		}
+	}
+}
+
 // Update the routine data structure is a mutex is unlocked
 //  Args:
 //   m (mutexInt): mutex which was released
@@ -308,6 +469,16 @@ func (r *routine) updateUnlock(m mutexInt) {
 		if r.holdingSet[i] == m {
 			r.holdingSet = append(r.holdingSet[:i], r.holdingSet[i+1:]...)
 			r.holdingSet = append(r.holdingSet, nil)
+			r.holdingRead = append(r.holdingRead[:i], r.holdingRead[i+1:]...)
+			r.holdingRead = append(r.holdingRead, false)
+			if r.holdingCallers != nil {
+				r.holdingCallers = append(r.holdingCallers[:i], r.holdingCallers[i+1:]...)
+				r.holdingCallers = append(r.holdingCallers, callerInfo{})
+			}
+			if r.holdingStacks != nil {
+				r.holdingStacks = append(r.holdingStacks[:i], r.holdingStacks[i+1:]...)
+				r.holdingStacks = append(r.holdingStacks, nil)
+			}
 			r.holdingCount--
 			break
 		}
@@ -317,15 +488,15 @@ func (r *routine) updateUnlock(m mutexInt) {
 // Get the index of the routine which calls getRoutineIndex in routines
 //  Returns:
 //   (int): index of the routine in routines which called getRoutineIndex
-func getRoutineIndex() int {
+func (d *Detector) getRoutineIndex() int {
 	// get an unique internal routine
 	// uses "github.com/petermattis/goid"
 	id := goid.Get()
 
 	// get the index corresponding to this id
-	createRoutineLock.Lock()
-	index, ok := mapIndex[id]
-	createRoutineLock.Unlock()
+	d.createRoutineLock.Lock()
+	index, ok := d.mapIndex[id]
+	d.createRoutineLock.Unlock()
 
 	// return -1 if the routine does not exist
 	if !ok {
@@ -343,18 +514,45 @@ func getRoutineIndex() int {
 //  Returns:
 //   nil
 func (r *routine) checkDoubleLocking(m mutexInt, routineIndex int, rLock bool) {
+	policy := m.getDoubleLockingPolicy()
+	if policy == DoubleLockingIgnore {
+		return
+	}
+
 	// it can only be double locking, if the routine already holds the lock
 	if (*m.getIsLockedRoutineIndex())[routineIndex] == 0 {
 		return
 	}
 
-	// no double locking of two reader
+	// no double locking of two reader, but a writer of another routine can
+	// be queued between the reader locks
 	if rLock && m.getRLock(routineIndex) {
+		if !m.allowsRecursiveRLock() && r.detector.opts.WriterQueuingDetection {
+			r.checkRecursiveRLock(m)
+		}
+		return
+	}
+
+	// report double locking as warning, the program is not terminated
+	if policy == DoubleLockingWarn {
+		r.detector.reportDoubleLocking(m, routineIndex, ReportWarning)
 		return
 	}
 
 	// report double locking and terminate the program
-	reportDeadlockDoubleLocking(m)
-	FindPotentialDeadlocks()
-	os.Exit(2)
+	r.detector.reportDoubleLocking(m, routineIndex, ReportDeadlock)
+	r.detector.terminate()
+}
+
+// mark a lock as part of a dependency in the lock trees. Only locks of the
+// type Mutex are marked, the mark is used to decide, whether the lock of
+// a key of a KeyedMutex can be removed
+//  Args:
+//   m (mutexInt): the lock
+//  Returns:
+//   nil
+func markInLockTree(m mutexInt) {
+	if mu, ok := m.(*Mutex); ok && atomic.LoadInt32(&mu.inLockTree) == 0 {
+		atomic.StoreInt32(&mu.inLockTree, 1)
+	}
 }
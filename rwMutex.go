@@ -31,12 +31,16 @@ the lock, r-lock and unlock operations for these locks.
 */
 
 import (
-	"runtime"
+	"fmt"
 	"sync"
 	"unsafe"
+
+	"github.com/ErikKassubek/Deadlock-Go/internal/waitfor"
 )
 
 // type to implement a lock
+// It can be used as an drop in replacement. The zero value is an unlocked
+// lock of the default detector
 type RWMutex struct {
 	// rw-mutex for the actual locking
 	mu *sync.RWMutex
@@ -52,36 +56,133 @@ type RWMutex struct {
 	isLockedRoutineIndexLock *sync.Mutex
 	// position of the mutex in memory
 	memoryPosition uintptr
+	// information about the queue of the lock for the convoy detection
+	convoy *convoyInfo
+	// acquisition of the lock by the routines, which currently hold it.
+	// Protected by isLockedRoutineIndexLock
+	holders map[int]callerInfo
+	// most recent releases of the lock. Protected by isLockedRoutineIndexLock
+	unlocks unlockInfo
 	// save for the routine index if the lock was locked by rLock
 	isRLock map[int]bool
 	// lock to prevent concurrent writes to isRLock
 	isRLockLock *sync.Mutex
+	// lock which is held by the writer of the rw-mutex. It prevents other
+	// writers from acquiring the rw-mutex during a downgrade
+	writerLock *sync.Mutex
+	// information about waiting routines for the starvation detection
+	starvation *starvationInfo
+	// indexes of the routines, which have acquired the writer lock. The value
+	// is true if the routine is currently waiting for the writer lock
+	writers map[int]bool
+	// attempts to acquire the writer lock with the locks held by the writers
+	writerAttempts *writerAttempts
+	// node of the lock in the wait-for graph, which stores the routines
+	// holding the lock for the periodical detection
+	waitNode *waitfor.Node
+	// detector the lock belongs to
+	detector *Detector
+	// how double locking of the lock is handled
+	doubleLocking DoubleLockingPolicy
+	// metadata of the lock. Protected by isLockedRoutineIndexLock
+	tags map[string]string
+	// if true, recursive reader locks are not reported
+	recursiveRLock bool
+	// if true, the lock is excluded from the detection
+	disabled bool
+	// acquisitions of the lock for the detection of hot locks
+	hotLock hotLockInfo
+	// initializes a lock which was not created with NewRWLock at its first use
+	lazy sync.Once
+	// name of the resource type, if the lock represents a wait group or a
+	// semaphore in the lock trees, empty for a lock. The underlying lock of
+	// the representation is never acquired
+	represents string
 }
 
 // create a new rw-lock
+//  Returns:
+//   (*RWMutex): the created rw-lock
 func NewRWLock() *RWMutex {
+	return newRWLock(defaultDetector, 2)
+}
+
+// create a new rw-lock and save the caller information of the creation
+//  Args:
+//   d (*Detector): detector the lock belongs to
+//   skip (int): number of stack frames between the user code and the
+//    callerSite call
+//  Returns:
+//   (*RWMutex): the created rw-lock
+func newRWLock(d *Detector, skip int) *RWMutex {
 	// initialize detector if necessary
-	if !initialized {
-		initialize()
+	if !d.initialized {
+		d.initialize()
 	}
 
-	m := RWMutex{
-		mu:                       &sync.RWMutex{},
-		in:                       true,
-		isLockedRoutineIndex:     map[int]int{},
-		isLockedRoutineIndexLock: &sync.Mutex{},
-		isRLock:                  map[int]bool{},
-		isRLockLock:              &sync.Mutex{},
-	}
+	m := RWMutex{}
 
 	// save the position of the NewLock call
-	_, file, line, _ := runtime.Caller(1)
-	m.context = append(m.context, newInfo(file, line, true, ""))
+	pc, file, line, _ := callerSite(skip)
+	m.init(d, file, line, funcName(pc))
+
+	return &m
+}
+
+// initialize the rw-lock
+//  Args:
+//   d (*Detector): detector the lock belongs to
+//   file (string): file of the creation of the lock
+//   line (int): line of the creation of the lock
+//   function (string): function in which the lock was created
+//  Returns:
+//   nil
+func (m *RWMutex) init(d *Detector, file string, line int, function string) {
+	m.mu = &sync.RWMutex{}
+	m.isLockedRoutineIndex = map[int]int{}
+	m.isLockedRoutineIndexLock = &sync.Mutex{}
+	m.convoy = newConvoyInfo()
+	m.holders = map[int]callerInfo{}
+	m.unlocks = newUnlockInfo()
+	m.waitNode = waitfor.NewNode()
+	m.isRLock = map[int]bool{}
+	m.isRLockLock = &sync.Mutex{}
+	m.writerLock = &sync.Mutex{}
+	m.starvation = newStarvationInfo()
+	m.writers = map[int]bool{}
+	m.writerAttempts = newWriterAttempts()
+	m.detector = d
+	info := newInfo(file, line, function, true, false, "")
+	m.context = append(m.context, info)
 
 	// save the memory position of the mutex
-	m.memoryPosition = uintptr(unsafe.Pointer(&m))
+	m.memoryPosition = uintptr(unsafe.Pointer(m))
 
-	return &m
+	m.in = true
+}
+
+// initialize a rw-lock, which was declared as a zero value instead of being
+// created with NewRWLock. The first use of the lock is saved as its creation
+//  Returns:
+//   nil
+func (m *RWMutex) lazyInit() {
+	// the rw-lock of an UpgradableRWMutex, which was declared as a zero value
+	if m == nil {
+		panic("UpgradableRWMutex was not created. Use x := NewUpgradableRWLock(policy).")
+	}
+
+	m.lazy.Do(func() {
+		if m.in {
+			return
+		}
+
+		if !defaultDetector.initialized {
+			defaultDetector.initialize()
+		}
+
+		frame := userFrame()
+		m.init(defaultDetector, frame.File, frame.Line, frame.Function)
+	})
 }
 
 // ====== GETTER ===============================================================
@@ -121,6 +222,68 @@ func (m *RWMutex) getMemoryPosition() uintptr {
 	return m.memoryPosition
 }
 
+// getter for convoy
+//  Returns:
+//   (*convoyInfo): information about the queue of the lock
+func (m *RWMutex) getConvoyInfo() *convoyInfo {
+	return m.convoy
+}
+
+// getter for holders
+//  Returns:
+//   (*map[int]callerInfo): acquisitions of the routines holding the lock
+func (m *RWMutex) getHolders() *map[int]callerInfo {
+	return &m.holders
+}
+
+// getter for unlocks
+//  Returns:
+//   (*unlockInfo): most recent releases of the lock
+func (m *RWMutex) getUnlockInfo() *unlockInfo {
+	return &m.unlocks
+}
+
+// getter for waitNode
+//  Returns:
+//   (*waitfor.Node): node of the lock in the wait-for graph
+func (m *RWMutex) getWaitNode() *waitfor.Node {
+	return m.waitNode
+}
+
+// getter for the name of the resource type
+//  Returns:
+//   (string): name of the resource type
+func (m *RWMutex) getResourceName() string {
+	if m.represents != "" {
+		return m.represents
+	}
+	return "RWMutex"
+}
+
+// check if a lock represents a wait group or a semaphore, whose own lock
+// must not be acquired
+//  Args:
+//   m (mutexInt): lock
+//  Returns:
+//   (bool): true if m represents a wait group or a semaphore
+func isRepresentation(m mutexInt) bool {
+	if mu, ok := m.(*RWMutex); ok {
+		return mu.represents != ""
+	}
+	return false
+}
+
+// getter for detector. Locks which were not created with NewRWLock belong
+// to the default detector
+//  Returns:
+//   (*Detector): detector the lock belongs to
+func (m *RWMutex) getDetector() *Detector {
+	if m.detector == nil {
+		return defaultDetector
+	}
+	return m.detector
+}
+
 // getter for in
 //  Returns:
 //   (bool): true if the lock was initialized, false otherwise
@@ -162,57 +325,211 @@ func (m *RWMutex) setRLock(routineIndex int, value bool) {
 	m.isLockedRoutineIndexLock.Unlock()
 }
 
+// getter for writerLock
+//  Returns:
+//   (*sync.Mutex): lock held by the writer of the rw-mutex
+func (m *RWMutex) getWriterLock() *sync.Mutex {
+	return m.writerLock
+}
+
+// getter for starvation
+//  Returns:
+//   (*starvationInfo): information about waiting routines
+func (m *RWMutex) getStarvationInfo() *starvationInfo {
+	return m.starvation
+}
+
+// register that a routine waits for or has acquired the writer lock
+//  Args:
+//   routineIndex (int): index of the routine
+//   pending (bool): true if the routine is waiting for the writer lock, false
+//    if it has acquired it
+//  Returns:
+//   nil
+func (m *RWMutex) setWriter(routineIndex int, pending bool) {
+	m.isLockedRoutineIndexLock.Lock()
+	m.writers[routineIndex] = pending
+	m.isLockedRoutineIndexLock.Unlock()
+}
+
+// getter for writerAttempts
+//  Returns:
+//   (*writerAttempts): attempts to acquire the writer lock
+func (m *RWMutex) getWriterAttempts() *writerAttempts {
+	return m.writerAttempts
+}
+
+// check if a routine other than the excluded routines has acquired or is
+// waiting for the writer lock
+//  Args:
+//   pending (bool): if true, only routines which are currently waiting for the
+//    writer lock are considered
+//   exclude (...int): indexes of the routines which are not considered
+//  Returns:
+//   (bool): true if such a writer exists, false otherwise
+func (m *RWMutex) hasWriter(pending bool, exclude ...int) bool {
+	m.isLockedRoutineIndexLock.Lock()
+	defer m.isLockedRoutineIndexLock.Unlock()
+
+writers:
+	for index, isPending := range m.writers {
+		if pending && !isPending {
+			continue
+		}
+		for _, e := range exclude {
+			if index == e {
+				continue writers
+			}
+		}
+		return true
+	}
+	return false
+}
+
 // ====== FUNCTIONS ============================================================
 
 // Lock rw-mutex m
 //  Returns:
 //   nil
 func (m *RWMutex) Lock() {
+	m.lazyInit()
+
 	// call the lock method for the mutexInt interface
-	lockInt(m, false)
+	lockInt(m, false, false)
 }
 
 // R-Lock rw-mutex m
 //  Returns:
 //   nil
 func (m *RWMutex) RLock() {
+	m.lazyInit()
+
 	// call the lock method for the mutexInt interface
-	lockInt(m, true)
+	lockInt(m, true, false)
 }
 
 // TryLock rw-mutex m
 //  Returns:
 //   (bool): true if locking was successful, false otherwise
 func (m *RWMutex) TryLock() bool {
+	m.lazyInit()
+
 	// call the try-lock method for the mutexInt interface
 	res := tryLockInt(m, false)
 	return res
 }
 
-// TryLock rw-mutex m
+// TryRLock rw-mutex m
+//  Returns:
+//   (bool): true if locking was successful, false otherwise
+func (m *RWMutex) TryRLock() bool {
+	m.lazyInit()
+
+	// call the try-lock method for the mutexInt interface
+	res := tryLockInt(m, true)
+	return res
+}
+
+// RTryLock is the same as TryRLock. It is kept for the code written before
+// TryRLock was added
 //  Returns:
 //   (bool): true if locking was successful, false otherwise
 func (m *RWMutex) RTryLock() bool {
+	m.lazyInit()
+
 	// call the try-lock method for the mutexInt interface
-	res := tryLockInt(m, false)
+	res := tryLockInt(m, true)
 	return res
 }
 
+// RLocker returns a sync.Locker, whose Lock and Unlock call RLock and
+// RUnlock of m, e.g. for a sync.Cond on the reader lock
+//  Returns:
+//   (sync.Locker): reader locker of m
+func (m *RWMutex) RLocker() sync.Locker {
+	return (*rlocker)(m)
+}
+
+// type to implement the reader locker of a rw-mutex
+type rlocker RWMutex
+
+// Lock the reader lock
+//  Returns:
+//   nil
+func (r *rlocker) Lock() {
+	(*RWMutex)(r).RLock()
+}
+
+// Unlock the reader lock
+//  Returns:
+//   nil
+func (r *rlocker) Unlock() {
+	(*RWMutex)(r).RUnlock()
+}
+
 // Unlock rw-mutex m
 //  Returns:
 //   nil
 func (m *RWMutex) Unlock() {
-	if opts.activated {
+	m.lazyInit()
+
+	if m.getDetector().opts.Activated {
 		unlockInt(m)
 	}
 	m.mu.Unlock()
+	m.writerLock.Unlock()
 }
 
 // Unlock rw-mutex m
 //  Returns: nil
 func (m *RWMutex) RUnlock() {
-	if opts.activated {
+	m.lazyInit()
+
+	if m.getDetector().opts.Activated {
 		unlockInt(m)
 	}
 	m.mu.RUnlock()
 }
+
+// Downgrade the writer lock of rw-mutex m, which is held by the calling
+// routine, into a reader lock. No other writer can acquire m between the
+// release of the writer lock and the acquisition of the reader lock.
+// After the downgrade, the lock must be released with RUnlock.
+//  Returns:
+//   nil
+func (m *RWMutex) DowngradeLock() {
+	m.lazyInit()
+
+	d := m.getDetector()
+	if d.opts.Activated && d.enter() {
+		defer d.leave()
+
+		// panic if the routine does not hold the writer lock
+		index := d.getRoutineIndex()
+		m.isLockedRoutineIndexLock.Lock()
+		holdsLock := index != -1 && m.isLockedRoutineIndex[index] > 0 &&
+			!m.isRLock[index]
+		m.isLockedRoutineIndexLock.Unlock()
+		if !holdsLock {
+			errorMessage := fmt.Sprint("Tried to downgrade lock ", &m,
+				" which was not locked by the routine.", d.lastUnlockHint(m, index))
+			panic(errorMessage)
+		}
+
+		// update the holding set of the routine
+		if d.opts.PeriodicDetection || d.opts.ComprehensiveDetection {
+			r := &d.routines[index]
+			(*r).updateDowngrade(m)
+		}
+
+		// the routine holds the lock shared in the wait-for graph
+		if d.opts.PeriodicDetection {
+			m.waitNode.Downgrade(index)
+		}
+	}
+
+	// writers are blocked by writerLock until the reader lock is acquired
+	m.mu.Unlock()
+	m.mu.RLock()
+	m.writerLock.Unlock()
+}
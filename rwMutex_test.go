@@ -0,0 +1,98 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+rwMutex_test.go
+Tests of the rw-lock. Locks acquired with TryLock or TryRLock are held by
+the routine, which acquired them, so that the locks acquired while they
+are held depend on them.
+*/
+
+import "testing"
+
+// run functions one after another, each in its own routine
+//  Args:
+//   routines (...func()): the functions
+//  Returns:
+//   nil
+func rwMutexTestSequential(routines ...func()) {
+	for _, f := range routines {
+		done := make(chan struct{})
+		go func(f func()) {
+			defer close(done)
+			f()
+		}(f)
+		<-done
+	}
+}
+
+// check that crossed acquisitions with TryLock and TryRLock in two routines
+// are found as a cycle
+//  Args:
+//   t (*testing.T): the test
+//  Returns:
+//   nil
+func TestRWMutexTryLockCycle(t *testing.T) {
+	reports := 0
+	d := NewDetector(func(r Report) {
+		if r.Type != ReportWarning {
+			reports++
+		}
+	})
+	opts := DefaultOptions()
+	opts.PeriodicDetection = false
+	if err := d.Configure(opts); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	x, y := d.NewRWLock(), d.NewRWLock()
+	rwMutexTestSequential(func() {
+		if !x.TryLock() {
+			t.Error("TryLock of x failed")
+			return
+		}
+		y.Lock()
+		y.Unlock()
+		x.Unlock()
+	}, func() {
+		if !y.TryRLock() {
+			t.Error("TryRLock of y failed")
+			return
+		}
+		x.Lock()
+		x.Unlock()
+		y.RUnlock()
+	})
+
+	summary := d.FindPotentialDeadlocks()
+	if summary.UniqueDependencies != 2 {
+		t.Errorf("expected 2 unique dependencies, got %d", summary.UniqueDependencies)
+	}
+	if summary.Cycles != 1 || reports != 1 {
+		t.Errorf("expected 1 cycle and 1 report, got %d cycles and %d reports",
+			summary.Cycles, reports)
+	}
+}
@@ -0,0 +1,118 @@
+package scenarios
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: scenarios
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+run.go
+Run a scenario with a new detector and compare the reports of the detector
+with the expected result of the scenario.
+*/
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	deadlock "github.com/ErikKassubek/Deadlock-Go"
+)
+
+// Result contains the result of the detection of a scenario
+type Result struct {
+	// the scenario
+	Scenario Scenario
+	// outcome of the reports of the detector
+	Got Outcome
+	// deadlocks and potential deadlocks reported by the detector
+	Reports []deadlock.Report
+}
+
+// Passed checks if the detector found the expected outcome
+//  Returns:
+//   (bool): true if the outcome is the expected outcome of the scenario
+func (r Result) Passed() bool {
+	return r.Got == r.Scenario.Expect
+}
+
+// Run runs a scenario with a new detector and gets the outcome of its
+// reports. Warnings are ignored. The scenario is run until all its routines
+// have finished, a deadlock is reported or the timeout has passed. If all
+// routines have finished, the detector is shut down, which runs the
+// comprehensive detection. The routines of an actual deadlock are never
+// released.
+//  Args:
+//   s (Scenario): the scenario
+//   opts (deadlock.Options): options of the detector. For scenarios with an
+//    actual deadlock, PeriodicDetectionTime must be shorter than the timeout
+//   timeout (time.Duration): maximum duration of the scenario and of the
+//    shutdown
+//  Returns:
+//   (Result): the result of the scenario
+//   (error): error if the options are invalid
+func Run(s Scenario, opts deadlock.Options, timeout time.Duration) (Result, error) {
+	res := Result{Scenario: s}
+	var resLock sync.Mutex
+	deadlocked := make(chan struct{})
+	d := deadlock.NewDetector(func(r deadlock.Report) {
+		if r.Type == deadlock.ReportWarning {
+			return
+		}
+
+		resLock.Lock()
+		defer resLock.Unlock()
+		res.Reports = append(res.Reports, r)
+		if r.Type == deadlock.ReportDeadlock {
+			if res.Got != Deadlock {
+				close(deadlocked)
+			}
+			res.Got = Deadlock
+		} else if res.Got == Clean {
+			res.Got = PotentialDeadlock
+		}
+	})
+	if err := d.Configure(opts); err != nil {
+		return Result{}, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(d)
+	}()
+	select {
+	case <-done:
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		d.Shutdown(ctx)
+	case <-deadlocked:
+		// the comprehensive detection has already run after the report of
+		// the deadlock. Shutdown would wait for the blocked routines
+		d.Stop()
+	case <-time.After(timeout):
+		d.Stop()
+	}
+
+	resLock.Lock()
+	defer resLock.Unlock()
+	return res, nil
+}
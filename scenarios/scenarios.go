@@ -0,0 +1,520 @@
+package scenarios
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: scenarios
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+scenarios.go
+This package contains synthetic scenarios with known results of the
+detection, so that the detector can be checked with a custom configuration,
+build tags or version before a clean result on the own code is trusted. Each
+scenario uses the locks of a detector in a known pattern and states, whether
+the detector must report a deadlock, a potential deadlock or nothing. Except
+for the actual deadlock, the channel-mutex mix and the scenarios with a late
+routine, the routines of a scenario run one after another, so that a
+potential deadlock can not occur while the scenario runs. The command cmd/undead-selftest runs all scenarios
+from the command line.
+*/
+
+import (
+	"sync"
+	"time"
+
+	deadlock "github.com/ErikKassubek/Deadlock-Go"
+)
+
+// Outcome is the result of the detection of a scenario
+type Outcome int
+
+const (
+	// Clean means, that no deadlock or potential deadlock is reported
+	Clean Outcome = iota
+	// PotentialDeadlock means, that at least one potential deadlock and no
+	// deadlock is reported
+	PotentialDeadlock
+	// Deadlock means, that at least one deadlock is reported
+	Deadlock
+)
+
+// String returns the description of the outcome
+//  Returns:
+//   (string): description of the outcome
+func (o Outcome) String() string {
+	switch o {
+	case PotentialDeadlock:
+		return "potential deadlock"
+	case Deadlock:
+		return "deadlock"
+	}
+	return "clean"
+}
+
+// Scenario is a pattern of lock operations with a known result of the
+// detection
+type Scenario struct {
+	// name of the scenario, e.g. "two-lock-inversion"
+	Name string
+	// description of the pattern
+	Description string
+	// expected result of the detection with the default options
+	Expect Outcome
+	// true if the expected report is a false positive, i.e. the reported
+	// deadlock can not occur, because the routines are ordered by an
+	// operation the detector does not observe
+	FalsePositive bool
+	// use the locks of the detector in the pattern of the scenario. The
+	// function returns after all routines of the scenario have finished,
+	// except for scenarios with an actual deadlock, which never return
+	Run func(d *deadlock.Detector)
+}
+
+// All returns all scenarios
+//  Returns:
+//   ([]Scenario): the scenarios
+func All() []Scenario {
+	return []Scenario{
+		{
+			Name:        "two-lock-inversion",
+			Description: "two routines acquire two locks in opposite orders",
+			Expect:      PotentialDeadlock,
+			Run:         twoLockInversion,
+		},
+		{
+			Name:        "consistent-order",
+			Description: "two routines acquire two locks in the same order",
+			Expect:      Clean,
+			Run:         consistentOrder,
+		},
+		{
+			Name:        "three-lock-ring",
+			Description: "three routines acquire three locks, so that the dependencies form a ring",
+			Expect:      PotentialDeadlock,
+			Run:         threeLockRing,
+		},
+		{
+			Name:        "gate-locked-pair",
+			Description: "two routines acquire two locks in opposite orders while holding the same gate lock",
+			Expect:      Clean,
+			Run:         gateLockedPair,
+		},
+		{
+			Name:        "rw-readers",
+			Description: "two routines acquire the reader locks of two rw-locks in opposite orders",
+			Expect:      Clean,
+			Run:         rwReaders,
+		},
+		{
+			Name:        "rw-writer",
+			Description: "a reader and a writer acquire a rw-lock and a lock in opposite orders",
+			Expect:      PotentialDeadlock,
+			Run:         rwWriter,
+		},
+		{
+			Name:        "rw-upgrade",
+			Description: "a routine upgrades its reader lock and acquires a lock, which another routine holds while it acquires the reader lock",
+			Expect:      PotentialDeadlock,
+			Run:         rwUpgrade,
+		},
+		{
+			Name:        "waitgroup-done",
+			Description: "a routine acquires a lock before it calls Done, and the waiting routine holds the lock while it waits",
+			Expect:      PotentialDeadlock,
+			Run:         waitGroupDone,
+		},
+		{
+			Name:          "channel-mutex-mix",
+			Description:   "two routines acquire two locks in opposite orders, but the second routine waits for a message of the first one",
+			Expect:        PotentialDeadlock,
+			FalsePositive: true,
+			Run:           channelMutexMix,
+		},
+		{
+			Name:        "barrier-late-partner",
+			Description: "a routine waits at a barrier while holding a lock, which another routine waits for, until the second participant arrives late",
+			Expect:      Clean,
+			Run:         barrierLatePartner,
+		},
+		{
+			Name:        "cond-late-signaler",
+			Description: "a routine waits for a condition variable while it holds a lock, which the routine that signaled before waits for, until another routine signals late",
+			Expect:      Clean,
+			Run:         condLateSignaler,
+		},
+		{
+			Name:        "chan-late-sender",
+			Description: "a routine receives from a channel while it holds a lock, which the routine that sent before waits for, until another routine sends late",
+			Expect:      Clean,
+			Run:         chanLateSender,
+		},
+		{
+			Name:        "actual-deadlock",
+			Description: "two routines acquire two locks in opposite orders at the same time and block each other",
+			Expect:      Deadlock,
+			Run:         actualDeadlock,
+		},
+	}
+}
+
+// Get returns the scenario with the given name
+//  Args:
+//   name (string): name of the scenario
+//  Returns:
+//   (Scenario): the scenario
+//   (bool): false if no scenario has the name
+func Get(name string) (Scenario, bool) {
+	for _, s := range All() {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Scenario{}, false
+}
+
+// run functions one after another, each in its own routine
+//  Args:
+//   routines (...func()): the functions
+//  Returns:
+//   nil
+func sequential(routines ...func()) {
+	for _, f := range routines {
+		done := make(chan struct{})
+		go func(f func()) {
+			defer close(done)
+			f()
+		}(f)
+		<-done
+	}
+}
+
+// reader lock of a rw-lock as a sync.Locker
+type readLocker struct {
+	m *deadlock.RWMutex
+}
+
+// acquire the reader lock
+//  Returns:
+//   nil
+func (r readLocker) Lock() {
+	r.m.RLock()
+}
+
+// release the reader lock
+//  Returns:
+//   nil
+func (r readLocker) Unlock() {
+	r.m.RUnlock()
+}
+
+// get the reader lock of a rw-lock as a sync.Locker
+//  Args:
+//   m (*deadlock.RWMutex): the rw-lock
+//  Returns:
+//   (sync.Locker): the reader lock of m
+func reader(m *deadlock.RWMutex) sync.Locker {
+	return readLocker{m: m}
+}
+
+// acquire locks in the given order and release them in reverse order
+//  Args:
+//   locks (...sync.Locker): the locks
+//  Returns:
+//   (func()): function which acquires and releases the locks
+func nested(locks ...sync.Locker) func() {
+	return func() {
+		for _, l := range locks {
+			l.Lock()
+		}
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].Unlock()
+		}
+	}
+}
+
+// two routines acquire two locks in opposite orders
+//  Args:
+//   d (*deadlock.Detector): detector of the locks
+//  Returns:
+//   nil
+func twoLockInversion(d *deadlock.Detector) {
+	x, y := d.NewLock(), d.NewLock()
+	sequential(nested(x, y), nested(y, x))
+}
+
+// two routines acquire two locks in the same order
+//  Args:
+//   d (*deadlock.Detector): detector of the locks
+//  Returns:
+//   nil
+func consistentOrder(d *deadlock.Detector) {
+	x, y := d.NewLock(), d.NewLock()
+	sequential(nested(x, y), nested(x, y))
+}
+
+// three routines acquire three locks, so that the dependencies form a ring
+//  Args:
+//   d (*deadlock.Detector): detector of the locks
+//  Returns:
+//   nil
+func threeLockRing(d *deadlock.Detector) {
+	x, y, z := d.NewLock(), d.NewLock(), d.NewLock()
+	sequential(nested(x, y), nested(y, z), nested(z, x))
+}
+
+// two routines acquire two locks in opposite orders, but both hold the same
+// gate lock, so the cycle can never block
+//  Args:
+//   d (*deadlock.Detector): detector of the locks
+//  Returns:
+//   nil
+func gateLockedPair(d *deadlock.Detector) {
+	g, x, y := d.NewLock(), d.NewLock(), d.NewLock()
+	sequential(nested(g, x, y), nested(g, y, x))
+}
+
+// two routines acquire the reader locks of two rw-locks in opposite orders.
+// Readers do not block each other
+//  Args:
+//   d (*deadlock.Detector): detector of the locks
+//  Returns:
+//   nil
+func rwReaders(d *deadlock.Detector) {
+	x, y := d.NewRWLock(), d.NewRWLock()
+	sequential(nested(reader(x), reader(y)), nested(reader(y), reader(x)))
+}
+
+// one routine holds a reader lock while it acquires a lock, which another
+// routine holds while it acquires the writer lock
+//  Args:
+//   d (*deadlock.Detector): detector of the locks
+//  Returns:
+//   nil
+func rwWriter(d *deadlock.Detector) {
+	x, y := d.NewRWLock(), d.NewLock()
+	sequential(nested(reader(x), y), nested(y, x))
+}
+
+// one routine upgrades its reader lock and acquires another lock, which
+// another routine holds while it acquires the reader lock
+//  Args:
+//   d (*deadlock.Detector): detector of the locks
+//  Returns:
+//   nil
+func rwUpgrade(d *deadlock.Detector) {
+	x, y := d.NewUpgradableRWLock(deadlock.UpgradeQueue), d.NewLock()
+	sequential(func() {
+		x.RLock()
+		x.UpgradeLock()
+		y.Lock()
+		y.Unlock()
+		x.Unlock()
+	}, nested(y, reader(x.RWMutex)))
+}
+
+// a routine of a wait group acquires a lock and calls Done, afterwards
+// another routine waits for the wait group while it holds the lock. If the
+// waiting routine acquires the lock first, the routine of the wait group can
+// never call Done
+//  Args:
+//   d (*deadlock.Detector): detector of the locks
+//  Returns:
+//   nil
+func waitGroupDone(d *deadlock.Detector) {
+	m, wg := d.NewLock(), d.NewWaitGroup()
+	wg.Add(1)
+	sequential(func() {
+		m.Lock()
+		m.Unlock()
+		wg.Done()
+	}, func() {
+		m.Lock()
+		wg.Wait()
+		m.Unlock()
+	})
+}
+
+// two routines acquire two locks in opposite orders. The second routine
+// starts after it received a message, which the first routine sends after it
+// has released the locks, so the cycle can never block. The detector does not
+// observe the channel and reports the cycle
+//  Args:
+//   d (*deadlock.Detector): detector of the locks
+//  Returns:
+//   nil
+func channelMutexMix(d *deadlock.Detector) {
+	x, y := d.NewLock(), d.NewLock()
+	ch := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		nested(x, y)()
+		ch <- struct{}{}
+	}()
+	go func() {
+		defer close(done)
+		<-ch
+		nested(y, x)()
+	}()
+	<-done
+}
+
+// a routine waits at a barrier for two routines while it holds a lock,
+// which another routine waits for. The second participant of the barrier
+// arrives late and releases the barrier, so the routines are only blocked
+// until it arrives. The participant has not waited at the barrier before,
+// so the detector can not know it while the routines are blocked
+//  Args:
+//   d (*deadlock.Detector): detector of the locks
+//  Returns:
+//   nil
+func barrierLatePartner(d *deadlock.Detector) {
+	m, b := d.NewLock(), d.NewBarrier(2)
+	held := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		m.Lock()
+		close(held)
+		b.Wait()
+		m.Unlock()
+	}()
+	go func() {
+		defer wg.Done()
+		<-held
+		m.Lock()
+		m.Unlock()
+	}()
+	go func() {
+		defer wg.Done()
+		time.Sleep(500 * time.Millisecond)
+		b.Wait()
+	}()
+	wg.Wait()
+}
+
+// a routine waits for a condition variable while it holds a lock, which the
+// routine that signaled the condition variable before waits for. Another
+// routine signals late and releases the waiting routine, so the routines are
+// only blocked until the signal. The detector can only infer the earlier
+// signaler as the routine the waiting routine waits for
+//  Args:
+//   d (*deadlock.Detector): detector of the locks
+//  Returns:
+//   nil
+func condLateSignaler(d *deadlock.Detector) {
+	var l sync.Mutex
+	m, c := d.NewLock(), d.NewCond(&l)
+	ready := false
+	signaled, held := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		l.Lock()
+		c.Signal()
+		l.Unlock()
+		close(signaled)
+		<-held
+		m.Lock()
+		m.Unlock()
+	}()
+	go func() {
+		defer wg.Done()
+		<-signaled
+		m.Lock()
+		l.Lock()
+		close(held)
+		for !ready {
+			c.Wait()
+		}
+		l.Unlock()
+		m.Unlock()
+	}()
+	go func() {
+		defer wg.Done()
+		time.Sleep(500 * time.Millisecond)
+		l.Lock()
+		ready = true
+		c.Signal()
+		l.Unlock()
+	}()
+	wg.Wait()
+}
+
+// a routine receives from a channel while it holds a lock, which the routine
+// that sent on the channel before waits for. Another routine sends late and
+// releases the receiving routine, so the routines are only blocked until the
+// send. The detector can only infer the earlier sender as the routine the
+// receiving routine waits for
+//  Args:
+//   d (*deadlock.Detector): detector of the locks
+//  Returns:
+//   nil
+func chanLateSender(d *deadlock.Detector) {
+	m, c := d.NewLock(), deadlock.NewChanOf[int](d, 0)
+	held := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		c.Send(1)
+		<-held
+		m.Lock()
+		m.Unlock()
+	}()
+	go func() {
+		defer wg.Done()
+		c.Recv()
+		m.Lock()
+		close(held)
+		c.Recv()
+		m.Unlock()
+	}()
+	go func() {
+		defer wg.Done()
+		time.Sleep(500 * time.Millisecond)
+		c.Send(2)
+	}()
+	wg.Wait()
+}
+
+// two routines acquire two locks in opposite orders at the same time and
+// block each other. The deadlock must be found by the periodical detection.
+// The routines are never released
+//  Args:
+//   d (*deadlock.Detector): detector of the locks
+//  Returns:
+//   nil
+func actualDeadlock(d *deadlock.Detector) {
+	x, y := d.NewLock(), d.NewLock()
+	var held sync.WaitGroup
+	held.Add(2)
+	lock := func(first, second *deadlock.Mutex) {
+		first.Lock()
+		held.Done()
+		held.Wait()
+		second.Lock()
+	}
+	go lock(x, y)
+	go lock(y, x)
+	select {}
+}
@@ -0,0 +1,70 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+schema.go
+Version of the schema of the machine outputs: the reports printed with
+OutputJSON, the lock order and the summary in the JSON format, the records
+of the delta file, the baseline file and the dependencies published to the
+broker. Each output contains the version it was written with, so that saved
+outputs and baselines can still be read after an upgrade of the package.
+The readers accept the current and the previous versions. Outputs without
+a version were written before the version was added and have version 1.
+*/
+
+import "fmt"
+
+const (
+	// SchemaVersion is the version of the schema of the machine outputs.
+	// It is increased with every incompatible change of an output
+	SchemaVersion = 3
+	// MinSchemaVersion is the oldest version of the machine outputs, which
+	// can still be read
+	MinSchemaVersion = 1
+)
+
+// CheckSchemaVersion checks if an output with the given schema version can
+// be read by this version of the package
+//  Args:
+//   version (int): schema version of the output, 0 if the output contains
+//    no version
+//  Returns:
+//   (error): error if the output was written by a newer version of the
+//    package or is too old to be read
+func CheckSchemaVersion(version int) error {
+	if version == 0 {
+		version = 1
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("deadlock: the output has schema version %d, which is "+
+			"newer than the supported version %d, upgrade the detector", version,
+			SchemaVersion)
+	}
+	if version < MinSchemaVersion {
+		return fmt.Errorf("deadlock: the output has schema version %d, which is "+
+			"older than the oldest supported version %d", version, MinSchemaVersion)
+	}
+	return nil
+}
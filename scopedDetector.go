@@ -0,0 +1,632 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+scopedDetector.go
+This file implements the detector type. All locks are checked by the detector,
+which created them. Locks created by the package level functions (e.g. NewLock)
+belong to the default detector, which is configured by the package level
+options and terminates the program if a deadlock is detected.
+Libraries can create their own detector, which only checks the locks created
+by it. Such a detector is not influenced by the package level options, does
+not terminate the program and passes its reports to a sink, so that the
+library can choose how to surface them.
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ErikKassubek/Deadlock-Go/internal/waitfor"
+)
+
+// ReportType describes the kind of a report
+type ReportType int
+
+const (
+	// ReportDeadlock is a deadlock, which has actually occurred
+	ReportDeadlock ReportType = iota
+	// ReportPotentialDeadlock is a deadlock, which can occur in another
+	// execution of the program
+	ReportPotentialDeadlock
+	// ReportWarning is a situation which is no deadlock, but can look like
+	// one, e.g. starvation or lock convoys
+	ReportWarning
+)
+
+// String returns the name of the report type
+//  Returns:
+//   (string): name of the report type
+func (t ReportType) String() string {
+	switch t {
+	case ReportDeadlock:
+		return "deadlock"
+	case ReportPotentialDeadlock:
+		return "potential deadlock"
+	case ReportWarning:
+		return "warning"
+	}
+	return fmt.Sprintf("ReportType(%d)", int(t))
+}
+
+// Report contains a report of the detector
+type Report struct {
+	// kind of the report
+	Type ReportType `json:"type"`
+	// headline of the report, e.g. "DEADLOCK (DOUBLE LOCKING)"
+	Title string `json:"title"`
+	// complete text of the report without colors
+	Text string `json:"text"`
+	// stable identifier of the cycle of the report, empty if the report
+	// does not describe a cycle
+	ID string `json:"id,omitempty"`
+	// acquisition sites involved in the report
+	Sites []Site `json:"sites"`
+	// locks involved in the report with their tags
+	Locks []LockInfo `json:"locks"`
+	// goroutines involved in the report, nil if the report is not about
+	// specific goroutines
+	Goroutines []Goroutine `json:"goroutines,omitempty"`
+	// most recent release of the lock by the routine, which locks it again,
+	// nil if the report is not about double locking or the routine never
+	// released the lock
+	Released *Site `json:"released,omitempty"`
+	// limits, which cut the detection short, nil if the detection was
+	// exhaustive or the report is not about the result of a detection
+	Truncation *Truncation `json:"truncation,omitempty"`
+	// observed example of each edge of a potential deadlock, nil if the
+	// examples are not collected (see EdgeExamples)
+	Examples []EdgeExample `json:"examples,omitempty"`
+	// kind of the finding, returned by Unwrap
+	err error
+	// true if the report describes the detection instead of a finding, e.g.
+	// its summary. Those reports are not counted in the quiet summary mode
+	info bool
+}
+
+// Site is a position in the source code
+type Site struct {
+	// name of the file with full path
+	File string `json:"file"`
+	// number of the line
+	Line int `json:"line"`
+	// full name of the function, empty if it is unknown
+	Function string `json:"function,omitempty"`
+}
+
+// LockInfo describes a lock involved in a report
+type LockInfo struct {
+	// creation site of the lock
+	Site Site `json:"site"`
+	// metadata of the lock, set with SetTag, nil if the lock has no tags
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// create a site from a caller info
+//  Args:
+//   c (callerInfo): caller info of the site
+//  Returns:
+//   (Site): the site
+func newSite(c callerInfo) Site {
+	return Site{File: c.file(), Line: c.line(), Function: c.function()}
+}
+
+// ReportSink receives the reports of a detector
+type ReportSink func(Report)
+
+// type to implement a deadlock detector
+type Detector struct {
+	// options of the detector
+	opts Options
+	// set to true after the detector was initialized
+	initialized bool
+	// lock to prevent multiple concurrent initializations, if the
+	// initialization was postponed by the init phase
+	initializeLock sync.Mutex
+	// list of routines
+	routines []routine
+	// number of routines in routines
+	numberRoutines int
+	// map to map the internal routine id to index in routines
+	mapIndex map[int64]int
+	// lock for the creation of a new routine
+	createRoutineLock sync.Mutex
+	// wait-for graph with the current wait of each routine, the data of
+	// the wants are of type *waitState
+	waitGraph *waitfor.Graph
+	// number of created wait groups. The locks acquired by the routines are
+	// only recorded for Done after a wait group was created. Accessed
+	// atomically
+	waitGroups int32
+	// rw-locks, which currently have waiting routines
+	waitingRWLocks map[*starvationInfo]mutexInt
+	// lock to prevent concurrent access to waitingRWLocks
+	waitingRWLocksLock sync.Mutex
+	// sink for the reports, if nil the reports are printed to stderr
+	sink ReportSink
+	// if true, the program is terminated after a deadlock was detected
+	exit bool
+	// closed to stop the periodical detection
+	stop chan struct{}
+	// lock to prevent concurrent access to stop
+	stopLock sync.Mutex
+	// creation time of the detector, the timestamps of the acquisitions are
+	// relative to it
+	start time.Time
+	// acquisition sites of the nestings, which exceeded the lock depth and
+	// were already reported
+	reportedLockDepths map[string]bool
+	// lock to prevent concurrent access to reportedLockDepths
+	reportedLockDepthsLock sync.Mutex
+	// positions of the creation sites of locks in the chains of the declared
+	// lock order
+	lockOrderLevels map[lockSiteKey][]int
+	// acquisitions, which contradict the declared lock order and were
+	// already reported
+	reportedLockOrders map[string]bool
+	// lock to prevent concurrent access to lockOrderLevels and
+	// reportedLockOrders
+	lockOrderLock sync.Mutex
+	// violated expectations about held locks, which were already reported
+	reportedExpectations map[string]bool
+	// lock to prevent concurrent access to reportedExpectations
+	reportedExpectationsLock sync.Mutex
+	// progress and limits of the running comprehensive detection, nil if no
+	// comprehensive detection is running
+	progress *progressTracker
+	// closed when the periodical detection has finished, nil if it was
+	// not started. Protected by initializeLock
+	periodicDone chan struct{}
+	// number of lock operations, which are updating the data of the
+	// detector. Accessed atomically
+	running int32
+	// set to 1 when the detector is shut down. Accessed atomically
+	shutdown int32
+	// functions, which are run by the shutdown
+	shutdownFuncs []func() error
+	// functions, which are run before the program is terminated because of
+	// a deadlock
+	shutdownHooks []func()
+	// lock to prevent concurrent access to shutdownFuncs and shutdownHooks
+	shutdownLock sync.Mutex
+	// last reported deadlock, passed to the callback of the escalation
+	deadlockReport Report
+	// lock to prevent concurrent access to deadlockReport
+	deadlockReportLock sync.Mutex
+	// sites of recursive reader locks, which were already reported
+	reportedRecursiveRLocks map[string]bool
+	// recursive reader locks, which were not reported yet, because no
+	// writer of another routine was known (see recursiveRLock.go)
+	recursiveRLocks map[string]*recursiveRLock
+	// lock to prevent concurrent access to reportedRecursiveRLocks and
+	// recursiveRLocks
+	reportedRecursiveRLocksLock sync.Mutex
+	// set to 1 while the periodical detection is paused. Accessed atomically
+	paused int32
+	// locks acquired in C code, which were registered, by their handles
+	externalLocks map[uintptr]*externalLock
+	// lock to prevent concurrent access to externalLocks and the holders
+	// and waiters of the external locks
+	externalLocksLock sync.Mutex
+	// models of the distributed locks by their names
+	distributedLocks map[string]*DistributedLock
+	// lock to prevent concurrent access to distributedLocks
+	distributedLocksLock sync.Mutex
+	// writer of the new dependencies to the delta file, nil if the deltas
+	// are not written
+	deltas *deltaWriter
+	// registrations of the lock providers
+	providers map[LockerProvider]*ProvidedLocks
+	// lock to prevent concurrent access to providers
+	providersLock sync.Mutex
+	// publisher of the dependencies between distributed locks, nil if they
+	// are not published
+	publisher *lockGraphPublisher
+	// chains of the reported cycles by their identifiers
+	reportedCycles map[string][]stackElement
+	// lock to prevent concurrent access to reportedCycles
+	reportedCyclesLock sync.Mutex
+	// keys of the cycles found by the last search, only used by the reference
+	// check (build tag deadlock_reference)
+	foundCycles map[string]bool
+	// reports of the running comprehensive detection, nil if no summary is
+	// collected
+	summary *reportSummary
+	// lock to prevent concurrent access to summary
+	summaryLock sync.Mutex
+	// reports held back by the running comprehensive detection, nil if no
+	// detection is running
+	reportOrder *reportOrder
+	// lock to prevent concurrent access to reportOrder
+	reportOrderLock sync.Mutex
+	// reports of the quiet summary mode, nil before the first report
+	quiet *quietSummary
+	// lock to prevent concurrent access to quiet
+	quietLock sync.Mutex
+	// known cycles and new cycles of the baseline, nil if the baseline file
+	// was not read yet
+	baseline *baseline
+	// lock to prevent concurrent access to baseline
+	baselineLock sync.Mutex
+	// goroutine ids of the routines, which currently emit a report, with
+	// the number of nested reports
+	reporting map[int64]int
+	// lock to prevent concurrent access to reporting
+	reportingLock sync.Mutex
+	// number of reports, which are currently emitted. Must be accessed
+	// atomically
+	numberReporting int32
+	// innermost guard of each routine by goroutine id
+	guards map[int64]*Guard
+	// acquisition sites in guarded functions, which were already reported
+	// as abandoned locks
+	reportedAbandonedLocks map[string]bool
+	// lock to prevent concurrent access to guards, the acquisitions of the
+	// guards and reportedAbandonedLocks
+	guardsLock sync.Mutex
+	// number of guards, which were not checked yet. Must be accessed
+	// atomically
+	numberGuards int32
+	// snapshot of the lock trees, which is analysed by the running
+	// comprehensive detection, nil if no detection is running
+	snapshot *lockTreeSnapshot
+	// version of the lock trees, increased with every new dependency and
+	// routine. Must be accessed atomically (see snapshot.go)
+	epoch uint32
+	// last exact snapshot of the lock trees, nil if no snapshot was taken
+	// since the lock trees were released
+	lastSnapshot *lockTreeSnapshot
+	// lock to prevent concurrent access to lastSnapshot
+	snapshotLock sync.Mutex
+	// barrier of the stop-the-world mode, held as a reader while a lock
+	// operation is recorded and as a writer while a snapshot is taken
+	barrier sync.RWMutex
+	// dependencies of each routine, which are used by the running search
+	// for cycles, nil if no search is running
+	searchDependencies [][]*dependency
+	// running comprehensive detection, nil if no detection is running
+	detectionRun *detectionRun
+	// lock to prevent concurrent access to detectionRun
+	detectionRunLock sync.Mutex
+	// critical sections of the locks by lock, acquisition and release site
+	criticalSections map[criticalSectionKey]*criticalSection
+	// lock to prevent concurrent access to criticalSections
+	criticalSectionsLock sync.Mutex
+}
+
+// default detector, which is used by the package level functions
+var defaultDetector = newDetector(nil, true)
+
+// create a new detector with the default options
+//  Args:
+//   sink (ReportSink): sink for the reports, nil to print them to stderr
+//   exit (bool): if true, the program is terminated after a deadlock was
+//    detected
+//  Returns:
+//   (*Detector): the created detector
+func newDetector(sink ReportSink, exit bool) *Detector {
+	d := Detector{
+		opts:           DefaultOptions(),
+		mapIndex:       make(map[int64]int),
+		waitGraph:      waitfor.New(),
+		waitingRWLocks: make(map[*starvationInfo]mutexInt),
+		sink:           sink,
+		exit:           exit,
+		stop:           make(chan struct{}),
+		start:          time.Now(),
+
+		reportedLockDepths: make(map[string]bool),
+		lockOrderLevels:    make(map[lockSiteKey][]int),
+		reportedLockOrders: make(map[string]bool),
+
+		reportedExpectations: make(map[string]bool),
+
+		reportedRecursiveRLocks: make(map[string]bool),
+		recursiveRLocks:         make(map[string]*recursiveRLock),
+
+		externalLocks:    make(map[uintptr]*externalLock),
+		distributedLocks: make(map[string]*DistributedLock),
+		providers:        make(map[LockerProvider]*ProvidedLocks),
+		reportedCycles:   make(map[string][]stackElement),
+		reporting:        make(map[int64]int),
+		guards:           make(map[int64]*Guard),
+
+		reportedAbandonedLocks: make(map[string]bool),
+		criticalSections:       make(map[criticalSectionKey]*criticalSection),
+	}
+	d.routines = make([]routine, d.opts.MaxRoutines)
+	return &d
+}
+
+// NewDetector creates a new detector, which only checks the locks created
+// by it. The detector does not terminate the program, if a deadlock is
+// detected, but passes all reports to sink.
+//  Args:
+//   sink (ReportSink): sink for the reports, if nil the reports are
+//    printed to stderr
+//  Returns:
+//   (*Detector): the created detector
+func NewDetector(sink ReportSink) *Detector {
+	return newDetector(sink, false)
+}
+
+// Set the sink for the reports of the default detector. The default detector
+// still terminates the program if a deadlock is detected, unless this is
+// disabled with SetExitOnDeadlock
+// It is not possible to set the sink after the detector was initialized
+//  Args:
+//   sink (ReportSink): sink for the reports, nil to print them to stderr
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetReportSink(sink ReportSink) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.sink = sink
+	return true
+}
+
+// Enable or disable the termination of the program by the default detector,
+// after a deadlock was detected. If it is disabled, the deadlock is reported
+// and the periodical detection is stopped, e.g. in apps, in which the
+// program can not be terminated
+// It is not possible to set options after the detector was initialized
+//  Args:
+//   enable (bool): true to enable, false to disable
+//  Returns:
+//   (bool): true, if the set was successful, false otherwise
+func SetExitOnDeadlock(enable bool) bool {
+	if defaultDetector.initialized {
+		return false
+	}
+	defaultDetector.exit = enable
+	return true
+}
+
+// create a new lock, which is checked by the detector
+//  Returns:
+//   (*Mutex): the created lock
+func (d *Detector) NewLock() *Mutex {
+	return newLock(d, 2)
+}
+
+// create a new rw-lock, which is checked by the detector
+//  Returns:
+//   (*RWMutex): the created rw-lock
+func (d *Detector) NewRWLock() *RWMutex {
+	return newRWLock(d, 2)
+}
+
+// create a new upgradable rw-lock, which is checked by the detector
+//  Args:
+//   policy (UpgradePolicy): behavior of UpgradeLock, if the upgrade is not
+//    directly possible
+//  Returns:
+//   (*UpgradableRWMutex): the created lock
+func (d *Detector) NewUpgradableRWLock(policy UpgradePolicy) *UpgradableRWMutex {
+	return newUpgradableRWLock(d, policy, 3)
+}
+
+// create a new barrier for n routines, which is checked by the detector
+//  Args:
+//   n (int): number of routines which have to arrive, before the barrier is released
+//  Returns:
+//   (*Barrier): the created barrier
+func (d *Detector) NewBarrier(n int) *Barrier {
+	return newBarrier(d, n, 2)
+}
+
+// create a new group, which is checked by the detector
+//  Returns:
+//   (*Group): the created group
+func (d *Detector) NewGroup() *Group {
+	return newGroup(d, 2)
+}
+
+// create a new striped lock, which is checked by the detector
+//  Args:
+//   n (int): number of stripes, must be positive
+//  Returns:
+//   (*StripedMutex): the created lock
+func (d *Detector) NewStripedMutex(n int) *StripedMutex {
+	return newStripedMutex(d, n, 3)
+}
+
+// get the model of a distributed lock, which is checked by the detector.
+// The same name always returns the same lock
+//  Args:
+//   name (string): name of the lock
+//  Returns:
+//   (*DistributedLock): the lock
+func (d *Detector) NewDistributedLock(name string) *DistributedLock {
+	return newDistributedLock(d, name, 2)
+}
+
+// create a guard for the calling function, which checks that the function
+// releases the locks of the detector it acquires
+//  Returns:
+//   (*Guard): the created guard
+func (d *Detector) NewGuard() *Guard {
+	return newGuard(d)
+}
+
+// Stop stops the periodical detection of the detector. The locks of the
+// detector can still be used afterwards.
+//  Returns:
+//   nil
+func (d *Detector) Stop() {
+	d.stopLock.Lock()
+	defer d.stopLock.Unlock()
+
+	select {
+	case <-d.stop:
+	default:
+		close(d.stop)
+	}
+}
+
+// check if the detector was stopped
+//  Returns:
+//   (bool): true if the periodical detection was stopped, false otherwise
+func (d *Detector) stopped() bool {
+	select {
+	case <-d.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// pass a report to the sink of the detector or print it to stderr
+//  Args:
+//   r (Report): report without the text
+//   out (*bytes.Buffer): colored text of the report without the title
+//  Returns:
+//   nil
+func (d *Detector) report(r Report, out *bytes.Buffer) {
+	// the reports of the comprehensive detection are emitted sorted at its
+	// end
+	if d.holdBackReport(r, out) {
+		return
+	}
+
+	if r.Type == ReportDeadlock {
+		d.saveDeadlockReport(r, out.String())
+	}
+
+	// cycles in the baseline are not reported
+	if d.inBaseline(r) {
+		d.addBaselineToSummary()
+		return
+	}
+
+	writeTags(out, r.Locks)
+	d.addToSummary(r)
+
+	// in the quiet summary mode, the reports are only passed to the sink or
+	// written to the report file
+	if d.opts.QuietSummary {
+		r.Text = removeColors(r.Title + "\n\n" + out.String())
+		d.addQuietReport(r)
+		if d.sink != nil {
+			d.startReporting()
+			defer d.stopReporting()
+			d.sink(r)
+		}
+		return
+	}
+
+	if d.sink == nil && d.opts.OutputFormat == OutputJSON {
+		r.Text = removeColors(r.Title + "\n\n" + out.String())
+		writeReportJSON(os.Stderr, r)
+		return
+	}
+
+	if d.sink == nil {
+		d.printReport(os.Stderr, r, out.String())
+		if d.opts.OutputFormat == OutputGitHub {
+			writeAnnotations(os.Stdout, r)
+		}
+		return
+	}
+
+	r.Text = removeColors(r.Title + "\n\n" + out.String())
+
+	// the lock operations of the sink are not recorded
+	d.startReporting()
+	defer d.stopReporting()
+	d.sink(r)
+}
+
+// run the comprehensive detection after a deadlock was detected and
+// terminate the program. Detectors, which do not terminate the program,
+// and all detectors on js/wasm stop the periodical detection instead, so
+// that the deadlock is only reported once
+//  Returns:
+//   nil
+func (d *Detector) terminate() {
+	d.FindPotentialDeadlocks()
+	if d.exit && exitSupported {
+		d.escalate()
+	}
+	d.Stop()
+}
+
+// remove the color codes from the text of a report
+//  Args:
+//   text (string): colored text
+//  Returns:
+//   (string): text without colors
+func removeColors(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		// color codes start with ESC[ and end with m
+		if text[i] == '\033' && i+1 < len(text) && text[i+1] == '[' {
+			if end := strings.IndexByte(text[i:], 'm'); end != -1 {
+				i += end
+				continue
+			}
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+// create a new condition variable with locker l, which is checked by the
+// detector
+//  Args:
+//   l (sync.Locker): locker of the condition variable
+//  Returns:
+//   (*Cond): the created condition variable
+func (d *Detector) NewCond(l sync.Locker) *Cond {
+	return newCond(d, l, 2)
+}
+
+// create a new wait group, which is checked by the detector
+//  Returns:
+//   (*WaitGroup): the created wait group
+func (d *Detector) NewWaitGroup() *WaitGroup {
+	return newWaitGroup(d, 2)
+}
+
+// create a new Once, which is checked by the detector
+//  Returns:
+//   (*Once): the created Once
+func (d *Detector) NewOnce() *Once {
+	return newOnce(d, 2)
+}
+
+// create a new weighted semaphore, which is checked by the detector
+//  Args:
+//   n (int64): number of permits
+//  Returns:
+//   (*Semaphore): the created semaphore
+func (d *Detector) NewSemaphore(n int64) *Semaphore {
+	return newSemaphore(d, n)
+}
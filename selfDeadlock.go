@@ -0,0 +1,72 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+selfDeadlock.go
+This file implements the detection of self deadlocks of a single routine over
+a chain of locks. If a routine acquires a lock A, then a lock B and then A
+again, it waits for itself and can never continue. If the check for double
+locking is disabled, these re-acquisitions are still found with the locks
+held by the routine.
+*/
+
+// check if the acquisition of m by the routine can never succeed, because
+// the routine already holds m and has acquired other locks after it.
+// In this case the deadlock is reported and the program is terminated.
+// Re-acquisitions without locks in between are double locking and are only
+// reported by the check for double locking.
+//  Args:
+//   m (mutexInt): lock which is acquired
+//   rLock (bool): true if m is acquired as a reader lock
+//  Returns:
+//   nil
+func (r *routine) checkSelfDeadlock(m mutexInt, rLock bool) {
+	if m.getDoubleLockingPolicy() == DoubleLockingIgnore {
+		return
+	}
+
+	// search m in the locks held by the routine
+	pos := -1
+	for i := 0; i < r.holdingCount; i++ {
+		if r.holdingSet[i] == m {
+			pos = i
+			break
+		}
+	}
+
+	// no self deadlock if the routine does not hold m or no lock was acquired
+	// after m
+	if pos == -1 || pos == r.holdingCount-1 {
+		return
+	}
+
+	// a reader lock can be acquired again while the routine holds a reader lock
+	if rLock && r.holdingRead[pos] {
+		return
+	}
+
+	r.detector.reportSelfDeadlock(r, pos, m)
+	r.detector.terminate()
+}
@@ -0,0 +1,327 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+semaphore.go
+This file implements a weighted semaphore with the methods of the semaphore
+of golang.org/x/sync. The semaphore is represented by a rw-mutex in the lock
+trees, so that cycles of semaphores and locks are found by the comprehensive
+detection. A semaphore with n permits can be held by multiple routines at
+the same time. An acquisition of at most half of the permits is therefore
+recorded as a reader lock, because another acquisition of the same weight
+does not have to wait for it, and an acquisition of more than half of the
+permits as a writer lock, because two of them can never hold the semaphore
+at the same time. The acquisitions are registered after the permits were
+acquired, like distributed locks. Acquisitions with a deadline and
+TryAcquire can not block forever and are registered like try-locks, which
+do not create dependencies on the locks held by the routine. Each
+acquisition is released in the lock trees, when all of its permits were
+released. A routine releases its own acquisitions first, permits released
+by a routine without acquisitions are released from the acquisitions of
+another routine.
+*/
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/petermattis/goid"
+)
+
+// type to implement a weighted semaphore
+type Semaphore struct {
+	// number of permits of the semaphore
+	size int64
+	// number of acquired permits
+	cur int64
+	// routines waiting for permits in the order of their calls
+	waiters list.List
+	// lock to prevent concurrent access to cur and waiters
+	lock sync.Mutex
+	// representation of the semaphore in the lock trees
+	m *RWMutex
+	// weights of the registered acquisitions by the goroutine ids of the
+	// routines, which acquired them
+	holds map[int64][]int64
+	// lock to prevent concurrent access to holds
+	holdsLock sync.Mutex
+}
+
+// routine waiting for permits of a semaphore
+type semaphoreWaiter struct {
+	// number of requested permits
+	n int64
+	// closed when the permits were acquired
+	ready chan struct{}
+}
+
+// create a new weighted semaphore of the default detector
+//  Args:
+//   n (int64): number of permits
+//  Returns:
+//   (*Semaphore): the created semaphore
+func NewSemaphore(n int64) *Semaphore {
+	return newSemaphore(defaultDetector, n)
+}
+
+// create a new weighted semaphore and save the caller information of the
+// creation. The creation is the first frame outside of the detector, so that
+// semaphores created by the semaphore package are created at its caller
+//  Args:
+//   d (*Detector): detector the semaphore belongs to
+//   n (int64): number of permits
+//  Returns:
+//   (*Semaphore): the created semaphore
+func newSemaphore(d *Detector, n int64) *Semaphore {
+	// initialize detector if necessary
+	if !d.initialized {
+		d.initialize()
+	}
+
+	m := &RWMutex{represents: "Semaphore"}
+	frame := userFrame()
+	m.init(d, frame.File, frame.Line, frame.Function)
+	m.SetDoubleLockingPolicy(DoubleLockingIgnore)
+	m.AllowRecursiveRLock()
+	m.SetTag("permits", strconv.FormatInt(n, 10))
+
+	return &Semaphore{
+		size:  n,
+		m:     m,
+		holds: make(map[int64][]int64),
+	}
+}
+
+// Acquire acquires n permits of the semaphore, blocking until they are
+// available or ctx is done. On success, it returns nil. On failure, it
+// returns ctx.Err() and leaves the semaphore unchanged
+//  Args:
+//   ctx (context.Context): context of the acquisition
+//   n (int64): number of permits
+//  Returns:
+//   (error): nil if the permits were acquired, ctx.Err() otherwise
+func (s *Semaphore) Acquire(ctx context.Context, n int64) error {
+	if err := s.acquire(ctx, n); err != nil {
+		return err
+	}
+	_, deadline := ctx.Deadline()
+	s.register(n, deadline)
+	return nil
+}
+
+// TryAcquire acquires n permits of the semaphore without blocking
+//  Args:
+//   n (int64): number of permits
+//  Returns:
+//   (bool): true if the permits were acquired, false otherwise
+func (s *Semaphore) TryAcquire(n int64) bool {
+	s.lock.Lock()
+	success := s.size-s.cur >= n && s.waiters.Len() == 0
+	if success {
+		s.cur += n
+	}
+	s.lock.Unlock()
+
+	if success {
+		s.register(n, true)
+	}
+	return success
+}
+
+// Release releases n permits of the semaphore
+//  Args:
+//   n (int64): number of permits
+//  Returns:
+//   nil
+func (s *Semaphore) Release(n int64) {
+	s.lock.Lock()
+	s.cur -= n
+	if s.cur < 0 {
+		s.lock.Unlock()
+		panic("semaphore: released more than held")
+	}
+	s.notifyWaiters()
+	s.lock.Unlock()
+
+	s.unregister(n)
+}
+
+// acquire n permits of the semaphore. The waiting routines are served in
+// the order of their calls, so that a large request is not starved by
+// smaller ones
+//  Args:
+//   ctx (context.Context): context of the acquisition
+//   n (int64): number of permits
+//  Returns:
+//   (error): nil if the permits were acquired, ctx.Err() otherwise
+func (s *Semaphore) acquire(ctx context.Context, n int64) error {
+	done := ctx.Done()
+
+	s.lock.Lock()
+	select {
+	case <-done:
+		s.lock.Unlock()
+		return ctx.Err()
+	default:
+	}
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.lock.Unlock()
+		return nil
+	}
+
+	// the request can never be satisfied
+	if n > s.size {
+		s.lock.Unlock()
+		<-done
+		return ctx.Err()
+	}
+
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(semaphoreWaiter{n: n, ready: ready})
+	s.lock.Unlock()
+
+	select {
+	case <-done:
+		s.lock.Lock()
+		select {
+		case <-ready:
+			// the permits were acquired after ctx was done, they are
+			// returned to leave the semaphore unchanged
+			s.cur -= n
+			s.notifyWaiters()
+		default:
+			front := s.waiters.Front() == elem
+			s.waiters.Remove(elem)
+			// the following waiters may be able to proceed
+			if front && s.size > s.cur {
+				s.notifyWaiters()
+			}
+		}
+		s.lock.Unlock()
+		return ctx.Err()
+
+	case <-ready:
+		return nil
+	}
+}
+
+// wake the waiting routines, whose permits are available, in the order of
+// their calls. Must be called with s.lock held
+//  Returns:
+//   nil
+func (s *Semaphore) notifyWaiters() {
+	for {
+		next := s.waiters.Front()
+		if next == nil {
+			return
+		}
+		w := next.Value.(semaphoreWaiter)
+		if s.size-s.cur < w.n {
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(next)
+		close(w.ready)
+	}
+}
+
+// register an acquisition of permits in the lock trees
+//  Args:
+//   n (int64): number of acquired permits
+//   try (bool): true if the acquisition could not have blocked forever
+//  Returns:
+//   nil
+func (s *Semaphore) register(n int64, try bool) {
+	// an acquisition of no permits does not hold the semaphore
+	if !s.m.getDetector().opts.Activated || n <= 0 {
+		return
+	}
+
+	// acquisitions of at most half of the permits can hold the semaphore
+	// at the same time
+	read := 2*n <= s.size
+	if try {
+		tryLockInt(s.m, read)
+	} else {
+		lockInt(s.m, read, false)
+	}
+
+	id := goid.Get()
+	s.holdsLock.Lock()
+	s.holds[id] = append(s.holds[id], n)
+	s.holdsLock.Unlock()
+}
+
+// release the acquisitions, whose permits were released, in the lock trees.
+// The latest acquisitions of the calling routine are released first
+//  Args:
+//   n (int64): number of released permits
+//  Returns:
+//   nil
+func (s *Semaphore) unregister(n int64) {
+	if !s.m.getDetector().opts.Activated {
+		return
+	}
+
+	released := 0
+	id := goid.Get()
+	s.holdsLock.Lock()
+	for n > 0 && len(s.holds) > 0 {
+		// permits of another routine are released, if the routine holds none
+		owner := id
+		if len(s.holds[owner]) == 0 {
+			for other := range s.holds {
+				owner = other
+				break
+			}
+		}
+
+		holds := s.holds[owner]
+		for n > 0 && len(holds) > 0 {
+			last := holds[len(holds)-1]
+			if last > n {
+				holds[len(holds)-1] = last - n
+				n = 0
+				break
+			}
+			n -= last
+			holds = holds[:len(holds)-1]
+			released++
+		}
+		if len(holds) == 0 {
+			delete(s.holds, owner)
+		} else {
+			s.holds[owner] = holds
+		}
+	}
+	s.holdsLock.Unlock()
+
+	for i := 0; i < released; i++ {
+		unlockInt(s.m)
+	}
+}
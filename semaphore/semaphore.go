@@ -0,0 +1,50 @@
+package semaphore
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: semaphore
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+semaphore.go
+This package mirrors the exported surface of the package
+golang.org/x/sync/semaphore. The weighted semaphore is replaced by the
+semaphore of the deadlock detector, which is checked by the default
+detector. Adopting the detector is therefore only a change of the import
+path from "golang.org/x/sync/semaphore" to
+"github.com/ErikKassubek/Deadlock-Go/semaphore".
+*/
+
+import deadlock "github.com/ErikKassubek/Deadlock-Go"
+
+// Weighted provides a way to bound concurrent access to a resource. It is
+// checked by the default detector
+type Weighted = deadlock.Semaphore
+
+// NewWeighted creates a new weighted semaphore with the given maximum
+// combined weight for concurrent access
+//  Args:
+//   n (int64): maximum combined weight
+//  Returns:
+//   (*Weighted): the created semaphore
+func NewWeighted(n int64) *Weighted {
+	return deadlock.NewSemaphore(n)
+}
@@ -0,0 +1,235 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+sentry.go
+Implementation of a report sink, which sends the reports as events to
+Sentry. The events are sent with the envelope endpoint of the HTTP API of
+Sentry, so that no client library is needed. The events are sent
+synchronously, because the program may be terminated directly after a
+deadlock was reported.
+*/
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// event of the Sentry API
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger"`
+	Message     string            `json:"message"`
+	Fingerprint []string          `json:"fingerprint"`
+	Tags        map[string]string `json:"tags"`
+	Extra       map[string]string `json:"extra"`
+	Exception   sentryExceptions  `json:"exception"`
+}
+
+// exceptions of an event of the Sentry API
+type sentryExceptions struct {
+	Values []sentryException `json:"values"`
+}
+
+// exception of an event of the Sentry API
+type sentryException struct {
+	Type       string           `json:"type"`
+	Value      string           `json:"value"`
+	Stacktrace sentryStacktrace `json:"stacktrace"`
+}
+
+// stack trace of an exception of the Sentry API
+type sentryStacktrace struct {
+	Frames []sentryFrame `json:"frames"`
+}
+
+// frame of a stack trace of the Sentry API
+type sentryFrame struct {
+	Function string `json:"function,omitempty"`
+	AbsPath  string `json:"abs_path"`
+	Filename string `json:"filename"`
+	Lineno   int    `json:"lineno"`
+}
+
+// NewSentrySink creates a sink, which sends the reports as events to Sentry.
+// Reports of the same cycle are grouped into one issue by their cycle ID
+//  Args:
+//   dsn (string): DSN of the Sentry project,
+//    e.g. https://<key>@o0.ingest.sentry.io/<project>
+//  Returns:
+//   (ReportSink): the created sink
+//   (error): error if the dsn is invalid
+func NewSentrySink(dsn string) (ReportSink, error) {
+	endpoint, auth, err := parseSentryDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	return func(r Report) {
+		body, err := sentryEnvelope(dsn, NewErrorEvent(r))
+		if err == nil {
+			err = sendSentryEnvelope(client, endpoint, auth, body)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "deadlock: could not send report to Sentry:", err)
+			fmt.Fprint(os.Stderr, r.Text)
+		}
+	}, nil
+}
+
+// get the envelope endpoint and the authentication header from a DSN
+//  Args:
+//   dsn (string): DSN of the Sentry project
+//  Returns:
+//   (string): url of the envelope endpoint
+//   (string): value of the X-Sentry-Auth header
+//   (error): error if the dsn is invalid
+func parseSentryDSN(dsn string) (string, string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", "", fmt.Errorf("deadlock: invalid Sentry DSN: %w", err)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return "", "", fmt.Errorf("deadlock: invalid Sentry DSN: missing public key")
+	}
+
+	// the project id is the last element of the path
+	path := strings.TrimSuffix(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	if i == -1 || path[i+1:] == "" {
+		return "", "", fmt.Errorf("deadlock: invalid Sentry DSN: missing project id")
+	}
+
+	endpoint := fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host,
+		path[:i], path[i+1:])
+	auth := fmt.Sprintf("Sentry sentry_version=7, sentry_client=deadlock-go/1.0, "+
+		"sentry_key=%s", u.User.Username())
+	return endpoint, auth, nil
+}
+
+// create an envelope with an event for the Sentry API
+//  Args:
+//   dsn (string): DSN of the Sentry project
+//   e (ErrorEvent): event to send
+//  Returns:
+//   ([]byte): the envelope
+//   (error): error if the event could not be encoded
+func sentryEnvelope(dsn string, e ErrorEvent) ([]byte, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	eventID := hex.EncodeToString(id)
+
+	// every site is an exception with the site as its only frame
+	exceptions := make([]sentryException, 0, len(e.Frames))
+	for i, site := range e.Frames {
+		exceptions = append(exceptions, sentryException{
+			Type:  e.Message,
+			Value: fmt.Sprint("involved site ", i+1, " of ", len(e.Frames)),
+			Stacktrace: sentryStacktrace{Frames: []sentryFrame{{
+				Function: site.Function,
+				AbsPath:  site.File,
+				Filename: site.File,
+				Lineno:   site.Line,
+			}}},
+		})
+	}
+
+	event, err := json.Marshal(sentryEvent{
+		EventID:     eventID,
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Platform:    "go",
+		Level:       e.Level,
+		Logger:      "deadlock",
+		Message:     e.Message,
+		Fingerprint: e.Fingerprint,
+		Tags:        e.Tags,
+		Extra:       map[string]string{"report": e.Text},
+		Exception:   sentryExceptions{Values: exceptions},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := json.Marshal(map[string]string{"event_id": eventID, "dsn": dsn})
+	if err != nil {
+		return nil, err
+	}
+	item, err := json.Marshal(map[string]interface{}{"type": "event",
+		"length": len(event)})
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	b.Write(header)
+	b.WriteByte('\n')
+	b.Write(item)
+	b.WriteByte('\n')
+	b.Write(event)
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// send an envelope to Sentry
+//  Args:
+//   client (*http.Client): client to send the envelope with
+//   endpoint (string): url of the envelope endpoint
+//   auth (string): value of the X-Sentry-Auth header
+//   body ([]byte): the envelope
+//  Returns:
+//   (error): error if the envelope could not be sent
+func sendSentryEnvelope(client *http.Client, endpoint string, auth string,
+	body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", auth)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
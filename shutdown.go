@@ -0,0 +1,237 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+shutdown.go
+Implementation of the shutdown of a detector. The shutdown stops the
+periodical detection and the checking of the locks, runs a final
+comprehensive detection, runs the registered shutdown functions, e.g. to
+flush and close sinks, and releases the lock trees and wait states of the
+detector. The locks of the detector can still be used afterwards, but are no
+longer checked. Lock operations register themselves while they update the
+data of the detector, so that the shutdown can wait until the lock trees
+are no longer changed, before it analyzes and releases them.
+*/
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Shutdown shuts down the default detector (see Detector.Shutdown)
+//  Args:
+//   ctx (context.Context): context to cancel the shutdown
+//  Returns:
+//   (error): first error of the shutdown functions, or the error of the
+//    context if the shutdown was canceled
+func Shutdown(ctx context.Context) error {
+	return defaultDetector.Shutdown(ctx)
+}
+
+// OnShutdown registers a function, which is run by the shutdown of the
+// default detector (see Detector.OnShutdown)
+//  Args:
+//   fn (func() error): function to run
+//  Returns:
+//   nil
+func OnShutdown(fn func() error) {
+	defaultDetector.OnShutdown(fn)
+}
+
+// OnShutdown registers a function, which is run by the shutdown after the
+// final detection, e.g. to flush or close a sink. The functions are run in
+// the order in which they were registered
+//  Args:
+//   fn (func() error): function to run
+//  Returns:
+//   nil
+func (d *Detector) OnShutdown(fn func() error) {
+	d.shutdownLock.Lock()
+	defer d.shutdownLock.Unlock()
+	d.shutdownFuncs = append(d.shutdownFuncs, fn)
+}
+
+// Shutdown stops the periodical detection and waits until it has finished.
+// From then on, the locks of the detector can still be used, but are no
+// longer checked. If the comprehensive detection is enabled, a final
+// comprehensive detection is run on the recorded lock trees. Afterwards the
+// functions registered with OnShutdown are run and the memory of the
+// detector is released.
+// If ctx is done before the shutdown has finished, the final detection is
+// cut short and the shutdown is aborted before the shutdown functions are
+// run and the memory is released.
+//  Args:
+//   ctx (context.Context): context to cancel the shutdown
+//  Returns:
+//   (error): first error of the shutdown functions, or the error of the
+//    context if the shutdown was canceled
+func (d *Detector) Shutdown(ctx context.Context) error {
+	// prevent a later start of the periodical detection
+	d.initializeLock.Lock()
+	d.initialized = true
+	done := d.periodicDone
+	d.initializeLock.Unlock()
+
+	// stop the periodical detection and wait until the running pass has
+	// finished
+	d.Stop()
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	// wait until no lock operation updates the data of the detector, so
+	// that the final detection works on the complete lock trees
+	atomic.StoreInt32(&d.shutdown, 1)
+	for atomic.LoadInt32(&d.running) != 0 {
+		select {
+		case <-time.After(time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	// run the final detection
+	d.findPotentialDeadlocks(ctx.Done())
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d.printQuietSummary()
+
+	// run the shutdown functions
+	d.shutdownLock.Lock()
+	funcs := d.shutdownFuncs
+	d.shutdownFuncs = nil
+	d.shutdownLock.Unlock()
+
+	var err error
+	for _, fn := range funcs {
+		if fnErr := fn(); fnErr != nil && err == nil {
+			err = fnErr
+		}
+	}
+
+	d.release()
+	return err
+}
+
+// register a lock operation, which updates the data of the detector
+//  Returns:
+//   (bool): true if the operation can update the data, false if the
+//    detector was shut down
+func (d *Detector) enter() bool {
+	atomic.AddInt32(&d.running, 1)
+	if atomic.LoadInt32(&d.shutdown) != 0 {
+		atomic.AddInt32(&d.running, -1)
+		return false
+	}
+	return true
+}
+
+// unregister a lock operation, which was registered with enter
+//  Returns:
+//   nil
+func (d *Detector) leave() {
+	atomic.AddInt32(&d.running, -1)
+}
+
+// get the index of the calling routine and create the routine, if it does
+// not exist yet
+//  Returns:
+//   (int): index of the routine, -1 if the detector was shut down
+func (d *Detector) registerRoutine() int {
+	if !d.enter() {
+		return -1
+	}
+	defer d.leave()
+
+	if d.getRoutineIndex() == -1 {
+		d.newRoutine()
+	}
+	return d.getRoutineIndex()
+}
+
+// check if the detector was shut down
+//  Returns:
+//   (bool): true if the detector was shut down, false otherwise
+func (d *Detector) isShutdown() bool {
+	return atomic.LoadInt32(&d.shutdown) != 0
+}
+
+// release the lock trees, wait states and reported findings of the detector
+//  Returns:
+//   nil
+func (d *Detector) release() {
+	d.createRoutineLock.Lock()
+	d.routines = nil
+	d.numberRoutines = 0
+	d.mapIndex = make(map[int64]int)
+	d.advanceEpoch()
+	d.createRoutineLock.Unlock()
+	d.resetSnapshot()
+
+	d.waitGraph.Reset()
+
+	d.waitingRWLocksLock.Lock()
+	d.waitingRWLocks = make(map[*starvationInfo]mutexInt)
+	d.waitingRWLocksLock.Unlock()
+
+	d.reportedLockDepthsLock.Lock()
+	d.reportedLockDepths = make(map[string]bool)
+	d.reportedLockDepthsLock.Unlock()
+
+	d.reportedRecursiveRLocksLock.Lock()
+	d.reportedRecursiveRLocks = make(map[string]bool)
+	d.recursiveRLocks = make(map[string]*recursiveRLock)
+	d.reportedRecursiveRLocksLock.Unlock()
+
+	d.lockOrderLock.Lock()
+	d.lockOrderLevels = make(map[lockSiteKey][]int)
+	d.reportedLockOrders = make(map[string]bool)
+	d.lockOrderLock.Unlock()
+
+	d.reportedExpectationsLock.Lock()
+	d.reportedExpectations = make(map[string]bool)
+	d.reportedExpectationsLock.Unlock()
+
+	d.externalLocksLock.Lock()
+	d.externalLocks = make(map[uintptr]*externalLock)
+	d.externalLocksLock.Unlock()
+
+	d.reportedCyclesLock.Lock()
+	d.reportedCycles = make(map[string][]stackElement)
+	d.reportedCyclesLock.Unlock()
+
+	d.guardsLock.Lock()
+	d.reportedAbandonedLocks = make(map[string]bool)
+	d.guardsLock.Unlock()
+	d.criticalSectionsLock.Lock()
+	d.criticalSections = make(map[criticalSectionKey]*criticalSection)
+	d.criticalSectionsLock.Unlock()
+}
@@ -0,0 +1,128 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+shutdownHook.go
+Implementation of the hooks, which are run between the confirmation of a
+local deadlock and the termination of the program, so that applications can
+flush buffers, close files or notify peers before the forced termination.
+The hooks run with a timeout, because they can themselves wait for a lock
+held by one of the deadlocked routines. Their lock operations are not
+recorded, like the lock operations of the sinks.
+*/
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// RegisterShutdownHook registers a function, which is run before the default
+// detector terminates the program because of a deadlock
+//  Args:
+//   fn (func()): function to run
+//  Returns:
+//   nil
+func RegisterShutdownHook(fn func()) {
+	defaultDetector.RegisterShutdownHook(fn)
+}
+
+// RegisterShutdownHook registers a function, which is run before the
+// detector terminates the program because of a deadlock. The hooks are run
+// in the order in which they were registered. Detectors, which do not
+// terminate the program, never run the hooks
+//  Args:
+//   fn (func()): function to run
+//  Returns:
+//   nil
+func (d *Detector) RegisterShutdownHook(fn func()) {
+	d.shutdownLock.Lock()
+	defer d.shutdownLock.Unlock()
+	d.shutdownHooks = append(d.shutdownHooks, fn)
+}
+
+// run the shutdown hooks and wait until they have finished or the timeout
+// is reached
+//  Returns:
+//   nil
+func (d *Detector) runShutdownHooks() {
+	d.shutdownLock.Lock()
+	hooks := d.shutdownHooks
+	d.shutdownHooks = nil
+	d.shutdownLock.Unlock()
+
+	d.runHooks("shutdown hook", hooks)
+}
+
+// run functions before the termination of the program in a new routine and
+// wait until they have finished or the timeout of the shutdown hooks is
+// reached. A panic of a function does not prevent the other functions and
+// the termination
+//  Args:
+//   name (string): name of the functions for the messages
+//   hooks ([]func()): functions to run
+//  Returns:
+//   nil
+func (d *Detector) runHooks(name string, hooks []func()) {
+	if len(hooks) == 0 {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.startReporting()
+		defer d.stopReporting()
+		for _, hook := range hooks {
+			runHook(name, hook)
+		}
+	}()
+
+	if d.opts.ShutdownHookTimeout <= 0 {
+		<-done
+		return
+	}
+	select {
+	case <-done:
+	case <-time.After(d.opts.ShutdownHookTimeout):
+		fmt.Fprintf(os.Stderr, "deadlock: %s did not finish within %v\n", name,
+			d.opts.ShutdownHookTimeout)
+	}
+}
+
+// run a function and recover from its panic
+//  Args:
+//   name (string): name of the function for the message
+//   hook (func()): function to run
+//  Returns:
+//   nil
+func runHook(name string, hook func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "deadlock: %s panicked: %v\n", name, r)
+		}
+	}()
+	hook()
+}
@@ -0,0 +1,220 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+singleflight.go
+This file implements a group, in which only one call of a function per key
+is executed at a time. Duplicate callers wait for the first caller (leader)
+and receive its results. The waiting callers are registered in the wait-for
+graph, so that deadlocks in which the leader waits for a lock held by one of
+the waiting callers are found by the periodical detection.
+*/
+
+import (
+	"sync"
+
+	"github.com/ErikKassubek/Deadlock-Go/internal/waitfor"
+)
+
+// type to implement a call, which is in progress or completed
+type call struct {
+	// wait group to wait for the completion of the call
+	wg sync.WaitGroup
+	// results of the call
+	val interface{}
+	err error
+	// number of duplicate callers
+	dups int
+	// index of the routine executing the call, -1 if unknown
+	leader int
+	// group of the call
+	group *Group
+}
+
+// get the routines a routine waiting for the call waits for
+//  Args:
+//   routineIndex (int): index of the waiting routine
+//   read (bool): not used for calls
+//  Returns:
+//   (waitfor.Blockers): the leader, unknown if the leader is not known
+func (c *call) getBlockers(routineIndex int, read bool) waitfor.Blockers {
+	if c.leader == -1 {
+		return waitfor.Blockers{Unknown: true}
+	}
+	return waitfor.Blockers{Known: []int{c.leader}}
+}
+
+// getter for context
+//  Returns:
+//   (*[]callerInfo): caller info of the group of the call
+func (c *call) getContext() *[]callerInfo {
+	return &c.group.context
+}
+
+// getter for the name of the resource type
+//  Returns:
+//   (string): name of the resource type
+func (c *call) getResourceName() string {
+	return "Group"
+}
+
+// type to implement a group of calls with duplicate suppression
+type Group struct {
+	// lock to prevent concurrent access to m
+	mu *sync.Mutex
+	// calls which are currently in progress
+	m map[string]*call
+	// info about the creation of the group
+	context []callerInfo
+	// detector the group belongs to
+	detector *Detector
+}
+
+// create a new group
+//  Returns:
+//   (*Group): the created group
+func NewGroup() *Group {
+	return newGroup(defaultDetector, 2)
+}
+
+// create a new group and save the caller information of the creation
+//  Args:
+//   d (*Detector): detector the group belongs to
+//   skip (int): number of stack frames between the user code and the
+//    callerSite call
+//  Returns:
+//   (*Group): the created group
+func newGroup(d *Detector, skip int) *Group {
+	// initialize detector if necessary
+	if !d.initialized {
+		d.initialize()
+	}
+
+	g := Group{
+		mu:       &sync.Mutex{},
+		m:        make(map[string]*call),
+		detector: d,
+	}
+
+	// save the position of the NewGroup call
+	_, file, line, _ := callerSite(skip)
+	g.context = append(g.context, newInfo(file, line, "", true, false, ""))
+
+	return &g
+}
+
+// Do executes and returns the results of fn, making sure that only one
+// execution is in progress for a given key at a time. If a duplicate comes
+// in, the duplicate caller waits for the original to complete and receives
+// the same results.
+//  If the routine executing fn calls Do with the same key again, it would
+//  wait for itself. This deadlock is reported and the program is terminated.
+//  Args:
+//   key (string): key of the call
+//   fn (func() (interface{}, error)): function to execute
+//  Returns:
+//   (interface{}): value returned by fn
+//   (error): error returned by fn
+//   (bool): true if the results were given to multiple callers
+func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error, bool) {
+	d := g.detector
+	index := -1
+	if d.opts.Activated && d.opts.PeriodicDetection {
+		// create new routine, if not initialized
+		index = d.registerRoutine()
+	}
+
+	g.mu.Lock()
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		g.mu.Unlock()
+
+		if index != -1 {
+			_, file, line, _ := callerSite(1)
+			caller := newInfo(file, line, "", false, false, "")
+
+			// the leader waits for itself
+			if c.leader == index && d.opts.CheckDoubleLocking {
+				d.reportDeadlockReentrant(c, caller, nil, nil)
+				d.terminate()
+			}
+
+			// register the wait in the wait-for graph
+			d.startWaiting(index, c, false, caller)
+		}
+
+		c.wg.Wait()
+
+		if index != -1 {
+			d.stopWaiting(index)
+		}
+		return c.val, c.err, true
+	}
+
+	c := &call{
+		leader: index,
+		group:  g,
+	}
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	g.doCall(c, key, fn)
+	return c.val, c.err, c.dups > 0
+}
+
+// execute the call and release the waiting callers, even if fn panics
+//  Args:
+//   c (*call): call to execute
+//   key (string): key of the call
+//   fn (func() (interface{}, error)): function to execute
+//  Returns:
+//   nil
+func (g *Group) doCall(c *call, key string, fn func() (interface{}, error)) {
+	defer func() {
+		c.wg.Done()
+
+		g.mu.Lock()
+		if g.m[key] == c {
+			delete(g.m, key)
+		}
+		g.mu.Unlock()
+	}()
+
+	c.val, c.err = fn()
+}
+
+// Forget tells the group to forget about a key. Future calls to Do for this
+// key will call the function rather than waiting for an earlier call to
+// complete.
+//  Args:
+//   key (string): key to forget
+//  Returns:
+//   nil
+func (g *Group) Forget(key string) {
+	g.mu.Lock()
+	delete(g.m, key)
+	g.mu.Unlock()
+}
@@ -0,0 +1,170 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+snapshot.go
+Implementation of the snapshot of the lock trees for the analyses. The
+comprehensive detection and the outputs like the lock order can run while
+the program still acquires locks, e.g. if the detection is started by a
+signal handler or before the shutdown. Reading the lock trees while the
+routines add new dependencies would give each phase of the detection a
+different state and make it contend with the lock operations.
+The dependencies of a routine are only appended to its preallocated list and
+never changed in the fields used by the analysis, except for the time of
+the last acquisition and the number of acquisitions, which are renewed for
+an existing dependency and are therefore only accessed atomically. Each
+routine therefore
+publishes the number of its complete dependencies atomically, after a new
+dependency was fully created, and increases the epoch of the detector. The
+epoch is the version of the lock trees: it changes with every new dependency
+and every new routine. A snapshot copies the lists of all routines up to
+their published number, without blocking the lock operations. If the epoch
+did not change while the lists were copied, the snapshot is exactly the
+version of the lock trees with this epoch, otherwise the copy is repeated.
+The last exact snapshot is shared by all analyses, until the epoch changes,
+so that concurrent analyses neither copy the lock trees again nor see
+different states of the same version. All phases of the comprehensive
+detection read the snapshot taken at its start, the dependencies added later
+are checked by the next detection.
+*/
+
+import "sync/atomic"
+
+// number of times the lock trees are copied again, if new dependencies were
+// added while they were copied
+const snapshotAttempts = 3
+
+// state of the lock trees
+type lockTreeSnapshot struct {
+	// complete dependencies of each routine at the time of the snapshot,
+	// by the index of the routine
+	dependencies [][]*dependency
+	// epoch of the lock trees, when the snapshot was started
+	epoch uint32
+	// true if no dependency was added while the snapshot was taken, i.e.
+	// the snapshot is exactly the version of epoch
+	exact bool
+}
+
+// Epoch returns the version of the lock trees of the default detector
+// (see Detector.Epoch)
+//  Returns:
+//   (uint32): the epoch
+func Epoch() uint32 {
+	return defaultDetector.Epoch()
+}
+
+// Epoch returns the version of the lock trees of the detector. It changes
+// with every new dependency and every new routine, so that a caller, which
+// polls the detector, e.g. a dashboard, only needs to analyse the lock trees
+// again, if the epoch changed since its last call
+//  Returns:
+//   (uint32): the epoch
+func (d *Detector) Epoch() uint32 {
+	return atomic.LoadUint32(&d.epoch)
+}
+
+// increase the epoch after the lock trees were changed
+//  Returns:
+//   nil
+func (d *Detector) advanceEpoch() {
+	atomic.AddUint32(&d.epoch, 1)
+}
+
+// get a snapshot of the lock trees of all routines. The lock operations are
+// not blocked, only the creation of new routines while the lists are copied
+//  Returns:
+//   (*lockTreeSnapshot): the snapshot, shared with other analyses if the
+//    lock trees did not change since the last snapshot
+func (d *Detector) takeSnapshot() *lockTreeSnapshot {
+	// the world is stopped before snapshotLock is acquired, because a sink
+	// can take a snapshot while its routine holds the barrier
+	stopped := d.stopWorld()
+	if stopped {
+		defer d.startWorld()
+	}
+
+	d.snapshotLock.Lock()
+	defer d.snapshotLock.Unlock()
+
+	if s := d.lastSnapshot; s != nil && s.epoch == d.Epoch() {
+		return s
+	}
+
+	// no dependency can be added while the world is stopped
+	if stopped {
+		d.lastSnapshot = d.copyLockTrees()
+		return d.lastSnapshot
+	}
+
+	var s *lockTreeSnapshot
+	for i := 0; i < snapshotAttempts; i++ {
+		s = d.copyLockTrees()
+		if s.exact {
+			d.lastSnapshot = s
+			break
+		}
+	}
+	return s
+}
+
+// copy the published dependencies of all routines
+//  Returns:
+//   (*lockTreeSnapshot): the copy
+func (d *Detector) copyLockTrees() *lockTreeSnapshot {
+	d.createRoutineLock.Lock()
+	defer d.createRoutineLock.Unlock()
+
+	epoch := d.Epoch()
+	s := &lockTreeSnapshot{
+		dependencies: make([][]*dependency, d.numberRoutines),
+		epoch:        epoch,
+	}
+	for i := 0; i < d.numberRoutines; i++ {
+		r := &d.routines[i]
+		n := int(atomic.LoadInt32(&r.published))
+		// limit the capacity, so that the snapshot can not be extended into
+		// the live list
+		s.dependencies[i] = r.dependencies[:n:n]
+	}
+	s.exact = d.Epoch() == epoch
+	return s
+}
+
+// forget the last snapshot, e.g. after the lock trees were released
+//  Returns:
+//   nil
+func (d *Detector) resetSnapshot() {
+	d.snapshotLock.Lock()
+	d.lastSnapshot = nil
+	d.snapshotLock.Unlock()
+}
+
+// get the number of routines in the snapshot
+//  Returns:
+//   (int): number of routines
+func (s *lockTreeSnapshot) routines() int {
+	return len(s.dependencies)
+}
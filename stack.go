@@ -68,6 +68,8 @@ type depStack struct {
 	stack *stackElement
 	// pointer to the top element of the stack
 	top *stackElement
+	// number of elements on the stack
+	size int
 }
 
 // create a new stack
@@ -101,6 +103,7 @@ func (s *depStack) push(dep *dependency, index int) {
 	// reset the pointers of the previous element and the pointer to the top element
 	cl.prev = s.top
 	s.top = &cl
+	s.size++
 }
 
 // remove the top element from stack
@@ -115,4 +118,5 @@ func (s *depStack) pop() {
 	// reroute the pointer to remove the top stack element
 	s.top.prev.next = s.top.next
 	s.top = s.top.prev
+	s.size--
 }
@@ -0,0 +1,197 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+starvation.go
+This file implements the detection of writer- and reader-starvation on
+rw-locks. A writer starves, if it waits for the lock longer than a given
+threshold, while new readers keep arriving. A reader starves, if it waits
+for the lock longer than the threshold, while writers keep acquiring the lock.
+Starvation does not stop the program, but can look like a deadlock.
+*/
+
+import (
+	"sync"
+	"time"
+)
+
+// type to save information about a routine which waits for a rw-lock
+type waitInfo struct {
+	// true if the routine waits for a reader lock
+	read bool
+	// time when the routine started to wait
+	start time.Time
+	// number of acquisitions of the other kind (reader for a waiting writer,
+	// writer for a waiting reader) which arrived while the routine was waiting
+	arrivals int
+	// true if the starvation of this wait was already reported
+	reported bool
+	// caller info of the waiting acquisition
+	caller callerInfo
+}
+
+// type to save the waiting routines of a rw-lock
+type starvationInfo struct {
+	// lock to prevent concurrent access to the waiting lists
+	lock sync.Mutex
+	// currently waiting routines
+	waiting map[*waitInfo]struct{}
+}
+
+// create a new starvationInfo
+//  Returns:
+//   (*starvationInfo): the created starvationInfo
+func newStarvationInfo() *starvationInfo {
+	return &starvationInfo{
+		waiting: make(map[*waitInfo]struct{}),
+	}
+}
+
+// register that a routine starts to wait for a rw-lock. If a waiting routine
+// of the other kind waits longer than the threshold, the starvation is reported
+//  Args:
+//   m (mutexInt): lock the routine waits for
+//   rLock (bool): true if the routine waits for a reader lock
+//  Returns:
+//   (*waitInfo): information about the wait, nil if m is not a rw-lock
+func startWait(m mutexInt, rLock bool) *waitInfo {
+	s := m.getStarvationInfo()
+	if s == nil {
+		return nil
+	}
+	d := m.getDetector()
+
+	_, file, line, _ := callerSite(3)
+	w := &waitInfo{
+		read:   rLock,
+		start:  time.Now(),
+		caller: newInfo(file, line, "", false, false, ""),
+	}
+
+	s.lock.Lock()
+	s.waiting[w] = struct{}{}
+
+	if len(s.waiting) == 1 {
+		d.waitingRWLocksLock.Lock()
+		d.waitingRWLocks[s] = m
+		d.waitingRWLocksLock.Unlock()
+	}
+
+	// new readers can starve waiting writers
+	if rLock {
+		s.countArrival(m, false)
+	}
+	s.lock.Unlock()
+
+	return w
+}
+
+// register that a routine has stopped waiting and acquired the rw-lock.
+// If the routine acquired a writer lock, waiting readers are checked for
+// starvation
+//  Args:
+//   m (mutexInt): lock the routine waited for
+//   w (*waitInfo): information about the wait
+//  Returns:
+//   nil
+func endWait(m mutexInt, w *waitInfo) {
+	s := m.getStarvationInfo()
+	d := m.getDetector()
+
+	s.lock.Lock()
+	delete(s.waiting, w)
+
+	if len(s.waiting) == 0 {
+		d.waitingRWLocksLock.Lock()
+		delete(d.waitingRWLocks, s)
+		d.waitingRWLocksLock.Unlock()
+	}
+
+	// acquiring writers can starve waiting readers
+	if !w.read {
+		s.countArrival(m, true)
+	}
+	s.lock.Unlock()
+}
+
+// count an arrival in all waiting routines of the given kind and report
+// the starvation of routines, which have waited longer than the threshold.
+// Must be called while s.lock is held.
+//  Args:
+//   m (mutexInt): rw-lock
+//   read (bool): kind of the waiting routines, which are affected by the arrival
+//  Returns:
+//   nil
+func (s *starvationInfo) countArrival(m mutexInt, read bool) {
+	for w := range s.waiting {
+		if w.read != read {
+			continue
+		}
+
+		w.arrivals++
+		s.checkWait(m, w)
+	}
+}
+
+// report the starvation of a waiting routine, if it has waited longer than
+// the threshold while at least one acquisition of the other kind arrived.
+// Must be called while s.lock is held.
+//  Args:
+//   m (mutexInt): rw-lock
+//   w (*waitInfo): wait to check
+//  Returns:
+//   nil
+func (s *starvationInfo) checkWait(m mutexInt, w *waitInfo) {
+	d := m.getDetector()
+	if !w.reported && w.arrivals > 0 &&
+		time.Since(w.start) >= d.opts.StarvationThreshold {
+		w.reported = true
+		d.reportStarvation(m, w)
+	}
+}
+
+// check all waiting routines for starvation. This is run periodically,
+// so that a starvation is also reported, if nothing happens on the lock
+// after the threshold was exceeded
+//  Returns:
+//   nil
+func (d *Detector) checkStarvation() {
+	// copy the locks, so that waitingRWLocksLock is never held while s.lock
+	// is acquired
+	d.waitingRWLocksLock.Lock()
+	locks := make(map[*starvationInfo]mutexInt, len(d.waitingRWLocks))
+	for s, m := range d.waitingRWLocks {
+		locks[s] = m
+	}
+	d.waitingRWLocksLock.Unlock()
+
+	for s, m := range locks {
+		s.lock.Lock()
+		for w := range s.waiting {
+			s.checkWait(m, w)
+		}
+		s.lock.Unlock()
+	}
+}
@@ -0,0 +1,83 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+stopTheWorld.go
+Implementation of the stop-the-world mode. Without it, the snapshot of the
+lock trees (see snapshot.go) is copied while the routines still add new
+dependencies, so that it can contain dependencies, which were added after
+other dependencies had already been copied. In the stop-the-world mode, the
+recording of each lock operation holds the barrier of the detector as a
+reader, and the snapshot is taken while the barrier is held as a writer.
+The lock operations therefore wait at the barrier, while the lock trees are
+copied, and the snapshot is exactly the state of the lock trees at one
+point in time. The barrier is released before the lock operation waits for
+the lock itself, so that a routine, which waits for a lock, does not hold
+up the snapshot. Lock operations of sinks, which are not recorded (see
+reentrancy.go), do not pass the barrier, and a snapshot taken while the
+calling routine emits a report does not stop the world, because the routine
+may itself hold the barrier.
+*/
+
+// pass the barrier of the stop-the-world mode before the lock operation is
+// recorded. The recording waits, while the world is stopped
+//  Returns:
+//   (bool): true if the barrier is held and must be released with
+//    leaveBarrier, false if the stop-the-world mode is disabled
+func (d *Detector) enterBarrier() bool {
+	if !d.opts.StopTheWorld {
+		return false
+	}
+	d.barrier.RLock()
+	return true
+}
+
+// release the barrier after the lock operation was recorded
+//  Returns:
+//   nil
+func (d *Detector) leaveBarrier() {
+	d.barrier.RUnlock()
+}
+
+// stop the world: wait until all running recordings have finished and let
+// new recordings wait at the barrier
+//  Returns:
+//   (bool): true if the world was stopped and must be started again with
+//    startWorld, false if the stop-the-world mode is disabled or the calling
+//    routine emits a report
+func (d *Detector) stopWorld() bool {
+	if !d.opts.StopTheWorld || d.inReport() {
+		return false
+	}
+	d.barrier.Lock()
+	return true
+}
+
+// start the world again after it was stopped with stopWorld
+//  Returns:
+//   nil
+func (d *Detector) startWorld() {
+	d.barrier.Unlock()
+}
@@ -0,0 +1,136 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+stripedMutex.go
+Implementation of a striped lock, which protects many keys with a fixed
+number of locks (stripes). The stripe of a key is selected by the hash of
+the key. Each stripe is a lock of the detector, so that the stripes are
+added to the lock trees as individual locks. Two routines, which lock the
+stripes of two keys in key-dependent orders, are therefore reported as a
+potential deadlock, and a routine, which locks two keys of the same
+stripe, is reported as double locking. The index of a stripe is added as
+the tag stripe of its lock.
+*/
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+)
+
+// Type to implement a striped lock
+type StripedMutex struct {
+	// the stripes
+	stripes []*Mutex
+}
+
+// create a new striped lock of the default detector
+//  Args:
+//   n (int): number of stripes, must be positive
+//  Returns:
+//   (*StripedMutex): the created lock
+func NewStripedMutex(n int) *StripedMutex {
+	return newStripedMutex(defaultDetector, n, 3)
+}
+
+// create a new striped lock. The creation is saved as the creation of all
+// stripes
+//  Args:
+//   d (*Detector): detector the lock belongs to
+//   n (int): number of stripes, must be positive
+//   skip (int): number of stack frames between the user code and the
+//    callerSite call
+//  Returns:
+//   (*StripedMutex): the created lock
+func newStripedMutex(d *Detector, n int, skip int) *StripedMutex {
+	if n <= 0 {
+		panic(fmt.Sprint("deadlock: number of stripes must be positive, got ", n))
+	}
+
+	s := &StripedMutex{stripes: make([]*Mutex, n)}
+	for i := range s.stripes {
+		s.stripes[i] = newLock(d, skip)
+		s.stripes[i].SetTag("stripe", strconv.Itoa(i))
+	}
+	return s
+}
+
+// get the stripe of a key
+//  Args:
+//   key (string): the key
+//  Returns:
+//   (int): index of the stripe
+func (s *StripedMutex) Stripe(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(s.stripes)))
+}
+
+// getter for the number of stripes
+//  Returns:
+//   (int): number of stripes
+func (s *StripedMutex) Len() int {
+	return len(s.stripes)
+}
+
+// SetTag sets the tag key of all stripes to value
+//  Args:
+//   key (string): key of the tag
+//   value (string): value of the tag
+//  Returns:
+//   nil
+func (s *StripedMutex) SetTag(key string, value string) {
+	for _, m := range s.stripes {
+		m.SetTag(key, value)
+	}
+}
+
+// Lock locks the stripe of a key
+//  Args:
+//   key (string): the key
+//  Returns:
+//   nil
+func (s *StripedMutex) Lock(key string) {
+	s.stripes[s.Stripe(key)].Lock()
+}
+
+// TryLock tries to lock the stripe of a key
+//  Args:
+//   key (string): the key
+//  Returns:
+//   (bool): true if locking was successful, false otherwise
+func (s *StripedMutex) TryLock(key string) bool {
+	return s.stripes[s.Stripe(key)].TryLock()
+}
+
+// Unlock unlocks the stripe of a key
+//  Args:
+//   key (string): the key
+//  Returns:
+//   nil
+func (s *StripedMutex) Unlock(key string) {
+	s.stripes[s.Stripe(key)].Unlock()
+}
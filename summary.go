@@ -0,0 +1,440 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+summary.go
+This file implements the summary of the comprehensive detection. The reports
+of a detection are grouped by the subsystem of the involved locks, so that a
+run with many reports is navigable. The subsystem of a report is the tag
+"domain" of its locks, or, if the locks have no domain, the packages of the
+involved acquisition sites. Reports with the same cycle ID, sites and locks
+are only counted once.
+Each detection also returns a Summary with the size of the analysed lock
+trees, the number of found cycles, the truncation and the durations of the
+phases, so that wrapper scripts can decide if a run passed. The summary can
+be written as text or JSON, and is written to the summary file after each
+detection, if one is set.
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary describes the result of a comprehensive detection
+type Summary struct {
+	// number of routines, whose lock trees were analysed
+	Routines int `json:"routines"`
+	// epoch of the analysed lock trees (see Detector.Epoch)
+	Epoch uint32 `json:"epoch"`
+	// number of unique dependencies in the lock trees
+	UniqueDependencies int `json:"unique_dependencies"`
+	// number of dependencies, which were removed from the cycle search,
+	// because their locks are only used by one routine
+	DependenciesPruned int `json:"dependencies_pruned"`
+	// number of chains explored by the cycle search
+	ChainsExplored int `json:"chains_explored"`
+	// number of candidates rejected by each rule of the detection
+	Pruning Pruning `json:"pruning"`
+	// number of unique reports of deadlocks and potential deadlocks. Cycles
+	// in the baseline are not counted
+	Cycles int `json:"cycles"`
+	// number of unique reports of the detection, including the warnings
+	Reports int `json:"reports"`
+	// limits, which cut the detection short, nil if the detection was
+	// exhaustive
+	Truncation *Truncation `json:"truncation"`
+	// duration of the detection
+	Duration time.Duration `json:"duration_ns"`
+	// durations of the phases of the detection in the order in which they
+	// were run
+	Phases []PhaseDuration `json:"phases"`
+}
+
+// PhaseDuration is the duration of a phase of the comprehensive detection
+type PhaseDuration struct {
+	// name of the phase, "init order", "upgrades" or "cycles"
+	Phase string `json:"phase"`
+	// duration of the phase
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// SummaryFormat is the format in which a summary is written
+type SummaryFormat int
+
+const (
+	// SummaryText writes the summary as text with one value per line
+	SummaryText SummaryFormat = iota
+	// SummaryJSON writes the summary as a JSON object, durations are given
+	// in nanoseconds
+	SummaryJSON
+)
+
+// tag of the locks, which sets the subsystem of the reports
+const domainTag = "domain"
+
+// type to collect the reports of a comprehensive detection
+type reportSummary struct {
+	// keys of the reports, which were already counted
+	seen map[string]bool
+	// number of reports by subsystem and title
+	groups map[string]map[string]int
+	// number of unique reports
+	total int
+	// number of unique reports of deadlocks and potential deadlocks
+	cycles int
+	// number of reports, which repeat a counted report
+	duplicates int
+	// number of reports, whose cycle is in the baseline
+	baseline int
+}
+
+// start to collect the reports for the summary
+//  Returns:
+//   nil
+func (d *Detector) startSummary() {
+	d.summaryLock.Lock()
+	d.summary = &reportSummary{
+		seen:   make(map[string]bool),
+		groups: make(map[string]map[string]int),
+	}
+	d.summaryLock.Unlock()
+}
+
+// add a report to the summary, if a summary is collected. Reports about
+// the truncation of the detection are not counted
+//  Args:
+//   r (Report): the report
+//  Returns:
+//   nil
+func (d *Detector) addToSummary(r Report) {
+	d.summaryLock.Lock()
+	defer d.summaryLock.Unlock()
+
+	s := d.summary
+	if s == nil || r.Truncation != nil {
+		return
+	}
+
+	// the cycle ID only contains the functions of the sites, reports of
+	// the same functions are distinguished by the lines of the sites and
+	// the creations of the locks
+	sites := make([]string, 0, len(r.Sites)+len(r.Locks))
+	for _, site := range r.Sites {
+		sites = append(sites, fmt.Sprint(site.File, ":", site.Line))
+	}
+	for _, lock := range r.Locks {
+		sites = append(sites, fmt.Sprint(lock.Site.File, ":", lock.Site.Line))
+	}
+	key := r.Title + "\x00" + r.ID + "\x00" + strings.Join(sites, "\x00")
+	if s.seen[key] {
+		s.duplicates++
+		return
+	}
+	s.seen[key] = true
+	s.total++
+	if r.Type != ReportWarning {
+		s.cycles++
+	}
+
+	group := reportGroup(r)
+	if s.groups[group] == nil {
+		s.groups[group] = make(map[string]int)
+	}
+	s.groups[group][r.Title]++
+}
+
+// count a report, whose cycle is in the baseline, if a summary is collected
+//  Returns:
+//   nil
+func (d *Detector) addBaselineToSummary() {
+	d.summaryLock.Lock()
+	if d.summary != nil {
+		d.summary.baseline++
+	}
+	d.summaryLock.Unlock()
+}
+
+// stop to collect the reports and report the summary, if the detection
+// created at least SummaryMinReports unique reports
+//  Returns:
+//   (*reportSummary): the collected reports, nil if no reports were
+//    collected
+func (d *Detector) reportSummary() *reportSummary {
+	d.summaryLock.Lock()
+	s := d.summary
+	d.summary = nil
+	d.summaryLock.Unlock()
+
+	// the quiet summary mode prints its own summary at the end of the program
+	if s == nil || d.opts.SummaryMinReports == 0 || s.total < d.opts.SummaryMinReports ||
+		d.opts.QuietSummary {
+		return s
+	}
+
+	// sort the subsystems by their number of reports
+	groups := make([]string, 0, len(s.groups))
+	counts := make(map[string]int, len(s.groups))
+	for group, titles := range s.groups {
+		groups = append(groups, group)
+		for _, n := range titles {
+			counts[group] += n
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if counts[groups[i]] != counts[groups[j]] {
+			return counts[groups[i]] > counts[groups[j]]
+		}
+		return groups[i] < groups[j]
+	})
+
+	out := &bytes.Buffer{}
+	fmt.Fprintf(out, purple, fmt.Sprint("Reports of the detection by subsystem (",
+		s.total, " unique reports):\n\n"))
+	for _, group := range groups {
+		reports := "reports"
+		if counts[group] == 1 {
+			reports = "report"
+		}
+		fmt.Fprintf(out, blue, fmt.Sprint(group, ": ", counts[group], " unique ", reports))
+		fmt.Fprintf(out, "\n")
+
+		titles := make([]string, 0, len(s.groups[group]))
+		for title := range s.groups[group] {
+			titles = append(titles, title)
+		}
+		sort.Strings(titles)
+		for _, title := range titles {
+			fmt.Fprintln(out, " ", title+":", s.groups[group][title])
+		}
+	}
+	fmt.Fprintf(out, "\n")
+
+	d.report(Report{
+		Type:  ReportWarning,
+		Title: "SUMMARY",
+		info:  true,
+	}, out)
+	return s
+}
+
+// create the summary of a finished detection
+//  Args:
+//   t (*progressTracker): tracker of the detection
+//   reports (*reportSummary): collected reports of the detection, nil if
+//    no reports were collected
+//   truncation (*Truncation): limits, which cut the detection short, nil
+//    if the detection was exhaustive
+//  Returns:
+//   (Summary): the summary
+func (d *Detector) newSummary(t *progressTracker, reports *reportSummary,
+	truncation *Truncation) Summary {
+	s := Summary{
+		Routines:           d.snapshot.routines(),
+		Epoch:              d.snapshot.epoch,
+		UniqueDependencies: d.countUniqueDependencies(0),
+		DependenciesPruned: t.progress.DependenciesPruned,
+		ChainsExplored:     t.progress.ChainsExplored,
+		Pruning:            t.pruning,
+		Truncation:         truncation,
+		Duration:           time.Since(t.start),
+		Phases:             t.phases,
+	}
+	if reports != nil {
+		s.Cycles = reports.cycles
+		s.Reports = reports.total
+		s.Pruning.Dedup += reports.duplicates
+		s.Pruning.Baseline = reports.baseline
+	}
+	return s
+}
+
+// String returns the summary as a machine-readable line of key=value pairs
+//  Returns:
+//   (string): the summary
+func (s Summary) String() string {
+	res := fmt.Sprintf("routines=%d epoch=%d unique_dependencies=%d dependencies_pruned=%d "+
+		"chains_explored=%d cycles=%d reports=%d truncated=%t duration=%v",
+		s.Routines, s.Epoch, s.UniqueDependencies, s.DependenciesPruned, s.ChainsExplored,
+		s.Cycles, s.Reports, s.Truncation != nil, s.Duration)
+	for _, p := range s.Phases {
+		res += fmt.Sprintf(" phase_%s=%v", strings.ReplaceAll(p.Phase, " ", "_"),
+			p.Duration)
+	}
+	return res
+}
+
+// Write writes the summary
+//  Args:
+//   w (io.Writer): writer to write to
+//   format (SummaryFormat): SummaryText or SummaryJSON
+//  Returns:
+//   (error): error if the summary could not be written
+func (s Summary) Write(w io.Writer, format SummaryFormat) error {
+	switch format {
+	case SummaryText:
+		return s.writeText(w)
+	case SummaryJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(summaryRecord{Schema: SchemaVersion, Summary: s})
+	}
+	return fmt.Errorf("deadlock: unknown summary format %d", format)
+}
+
+// JSON form of a summary
+type summaryRecord struct {
+	// schema version of the summary
+	Schema int `json:"schema"`
+	Summary
+}
+
+// ReadSummary reads a summary written in the JSON format, e.g. the summary
+// file
+//  Args:
+//   r (io.Reader): reader of the summary
+//  Returns:
+//   (Summary): the summary
+//   (error): error if the summary could not be read or was written with an
+//    unsupported schema version
+func ReadSummary(r io.Reader) (Summary, error) {
+	var record summaryRecord
+	if err := json.NewDecoder(r).Decode(&record); err != nil {
+		return Summary{}, err
+	}
+	if err := CheckSchemaVersion(record.Schema); err != nil {
+		return Summary{}, err
+	}
+	return record.Summary, nil
+}
+
+// write the summary as text with one value per line
+//  Args:
+//   w (io.Writer): writer to write to
+//  Returns:
+//   (error): error if the summary could not be written
+func (s Summary) writeText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintln(&b, "Routines analysed:", s.Routines)
+	fmt.Fprintln(&b, "Lock tree epoch:", s.Epoch)
+	fmt.Fprintln(&b, "Unique dependencies:", s.UniqueDependencies)
+	fmt.Fprintln(&b, "Dependencies pruned:", s.DependenciesPruned)
+	fmt.Fprintln(&b, "Chains explored:", s.ChainsExplored)
+	fmt.Fprintln(&b, "Pruned by gate locks:", s.Pruning.GateLock)
+	fmt.Fprintln(&b, "Pruned by rw-lock rules:", s.Pruning.RWLock)
+	fmt.Fprintln(&b, "Pruned by program order:", s.Pruning.HappensBefore)
+	fmt.Fprintln(&b, "Pruned as duplicates:", s.Pruning.Dedup)
+	fmt.Fprintln(&b, "Pruned by the baseline:", s.Pruning.Baseline)
+	fmt.Fprintln(&b, "Cycles found:", s.Cycles)
+	fmt.Fprintln(&b, "Unique reports:", s.Reports)
+	if s.Truncation != nil {
+		fmt.Fprintln(&b, "Truncated:", *s.Truncation)
+	} else {
+		fmt.Fprintln(&b, "Truncated: no")
+	}
+	fmt.Fprintln(&b, "Duration:", s.Duration)
+	for _, p := range s.Phases {
+		fmt.Fprintf(&b, "Duration of %s: %v\n", p.Phase, p.Duration)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// write the summary of a detection to the summary file, if it is set. The
+// file is overwritten by each detection
+//  Args:
+//   s (Summary): the summary
+//  Returns:
+//   nil
+func (d *Detector) writeSummaryFile(s Summary) {
+	if d.opts.SummaryFile == "" {
+		return
+	}
+
+	var out bytes.Buffer
+	if err := s.Write(&out, d.opts.SummaryFormat); err != nil {
+		panic(fmt.Sprint("Could not write the summary file ", d.opts.SummaryFile,
+			": ", err))
+	}
+	if err := os.WriteFile(d.opts.SummaryFile, out.Bytes(), 0644); err != nil {
+		panic(fmt.Sprint("Could not write the summary file ", d.opts.SummaryFile,
+			": ", err))
+	}
+}
+
+// get the subsystem of a report
+//  Args:
+//   r (Report): the report
+//  Returns:
+//   (string): the domain of the locks of the report, or the packages of
+//    its sites
+func reportGroup(r Report) string {
+	if domain := r.LockTags()[domainTag]; domain != "" {
+		return domain
+	}
+
+	packages := make(map[string]bool)
+	for _, site := range r.Sites {
+		if p := sitePackage(site); p != "" {
+			packages[p] = true
+		}
+	}
+	if len(packages) == 0 {
+		return "unknown"
+	}
+
+	res := make([]string, 0, len(packages))
+	for p := range packages {
+		res = append(res, p)
+	}
+	sort.Strings(res)
+	return strings.Join(res, " + ")
+}
+
+// get the package of a site from its function, or the directory of its
+// file, if the function is unknown
+//  Args:
+//   site (Site): the site
+//  Returns:
+//   (string): import path of the package or directory, empty if unknown
+func sitePackage(site Site) string {
+	if name := site.Function; name != "" {
+		slash := strings.LastIndex(name, "/")
+		if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+			return name[:slash+1+dot]
+		}
+	}
+	if site.File != "" {
+		return filepath.Dir(site.File)
+	}
+	return ""
+}
@@ -0,0 +1,192 @@
+package sync
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: sync
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+sync.go
+This package mirrors the exported surface of the sync package of the standard
+library. The locks, the condition variable, the wait group and the Once
+functions are replaced by the types and functions of the deadlock detector,
+all other types are the types of the sync package. The locks only forward
+the methods of the sync package to the locks of the detector, so that code
+written against this package still compiles with the sync package. The zero
+values of the types can be used as in the sync package. Adopting the detector is therefore only a change
+of the import path from "sync" to "github.com/ErikKassubek/Deadlock-Go/sync".
+*/
+
+import (
+	"sync"
+
+	deadlock "github.com/ErikKassubek/Deadlock-Go"
+)
+
+// A Locker represents an object that can be locked and unlocked
+type Locker = sync.Locker
+
+// A WaitGroup waits for a collection of routines to finish. It is checked by
+// the default detector
+type WaitGroup = deadlock.WaitGroup
+
+// Once is an object that will perform exactly one action. It is checked by
+// the default detector
+type Once = deadlock.Once
+
+// Cond implements a condition variable, which is checked by the default
+// detector
+type Cond = deadlock.Cond
+
+// Map is a map which is safe for concurrent use
+type Map = sync.Map
+
+// A Pool is a set of temporary objects
+type Pool = sync.Pool
+
+// NewCond returns a new Cond with Locker l
+//  Args:
+//   l (Locker): locker of the condition variable
+//  Returns:
+//   (*Cond): the created condition variable
+func NewCond(l Locker) *Cond {
+	// the first use is saved as the creation, as for the zero value
+	return &Cond{L: l}
+}
+
+// OnceFunc returns a function that invokes f only once. The returned function
+// may be called concurrently. It is checked by the default detector
+//  Args:
+//   f (func()): function to execute
+//  Returns:
+//   (func()): function which executes f only once
+func OnceFunc(f func()) func() {
+	return deadlock.OnceFunc(f)
+}
+
+// OnceValue returns a function that invokes f only once and returns the value
+// returned by f. It is checked by the default detector
+//  Args:
+//   f (func() T): function to execute
+//  Returns:
+//   (func() T): function which executes f only once
+func OnceValue[T any](f func() T) func() T {
+	return deadlock.OnceValue(f)
+}
+
+// OnceValues returns a function that invokes f only once and returns the
+// values returned by f. It is checked by the default detector
+//  Args:
+//   f (func() (T1, T2)): function to execute
+//  Returns:
+//   (func() (T1, T2)): function which executes f only once
+func OnceValues[T1, T2 any](f func() (T1, T2)) func() (T1, T2) {
+	return deadlock.OnceValues(f)
+}
+
+// Mutex is a mutual exclusion lock, which is checked by the default detector.
+// The zero value is an unlocked mutex.
+// A Mutex must not be copied after first use.
+type Mutex struct {
+	// lock of the detector, its methods are not exported, so that the
+	// method set is the method set of sync.Mutex
+	m deadlock.Mutex
+}
+
+// Lock locks m
+//  Returns:
+//   nil
+func (m *Mutex) Lock() {
+	m.m.Lock()
+}
+
+// TryLock tries to lock m
+//  Returns:
+//   (bool): true if locking was successful, false otherwise
+func (m *Mutex) TryLock() bool {
+	return m.m.TryLock()
+}
+
+// Unlock unlocks m
+//  Returns:
+//   nil
+func (m *Mutex) Unlock() {
+	m.m.Unlock()
+}
+
+// RWMutex is a reader/writer mutual exclusion lock, which is checked by the
+// default detector. The zero value is an unlocked mutex.
+// A RWMutex must not be copied after first use.
+type RWMutex struct {
+	// lock of the detector, its methods are not exported, so that the
+	// method set is the method set of sync.RWMutex
+	rw deadlock.RWMutex
+}
+
+// Lock locks rw for writing
+//  Returns:
+//   nil
+func (rw *RWMutex) Lock() {
+	rw.rw.Lock()
+}
+
+// TryLock tries to lock rw for writing
+//  Returns:
+//   (bool): true if locking was successful, false otherwise
+func (rw *RWMutex) TryLock() bool {
+	return rw.rw.TryLock()
+}
+
+// Unlock unlocks rw for writing
+//  Returns:
+//   nil
+func (rw *RWMutex) Unlock() {
+	rw.rw.Unlock()
+}
+
+// RLock locks rw for reading
+//  Returns:
+//   nil
+func (rw *RWMutex) RLock() {
+	rw.rw.RLock()
+}
+
+// TryRLock tries to lock rw for reading
+//  Returns:
+//   (bool): true if locking was successful, false otherwise
+func (rw *RWMutex) TryRLock() bool {
+	return rw.rw.TryRLock()
+}
+
+// RUnlock undoes a single RLock call
+//  Returns:
+//   nil
+func (rw *RWMutex) RUnlock() {
+	rw.rw.RUnlock()
+}
+
+// RLocker returns a Locker interface that implements the Lock and Unlock
+// methods by calling rw.RLock and rw.RUnlock
+//  Returns:
+//   (Locker): reader lock of rw
+func (rw *RWMutex) RLocker() Locker {
+	return rw.rw.RLocker()
+}
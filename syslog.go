@@ -0,0 +1,151 @@
+//go:build !windows && !plan9 && !js
+
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+syslog.go
+Implementation of a report sink, which writes the reports to the system log.
+If journald is running, the reports are sent to the journal with the
+structured fields DEADLOCK_TYPE, DEADLOCK_TITLE, DEADLOCK_CYCLE_ID and
+CODE_FILE and CODE_LINE of the first involved site. Otherwise the reports
+are written to syslog.
+*/
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/syslog"
+	"net"
+	"os"
+	"strings"
+)
+
+// socket of the native protocol of journald
+var journalSocket = "/run/systemd/journal/socket"
+
+// NewSyslogSink creates a sink, which writes the reports to the journal or,
+// if journald is not running, to syslog
+//  Args:
+//   tag (string): identifier of the program in the log, the name of
+//    the program if empty
+//  Returns:
+//   (ReportSink): the created sink
+//   (error): error if neither the journal nor syslog are available
+func NewSyslogSink(tag string) (ReportSink, error) {
+	if tag == "" {
+		tag = os.Args[0]
+	}
+
+	if conn, err := net.Dial("unixgram", journalSocket); err == nil {
+		return func(r Report) {
+			if _, err := conn.Write(journalMessage(tag, r)); err != nil {
+				fmt.Fprint(os.Stderr, r.Text)
+			}
+		}, nil
+	}
+
+	w, err := syslog.New(syslog.LOG_USER|syslog.LOG_ERR, tag)
+	if err != nil {
+		return nil, err
+	}
+	return func(r Report) {
+		text := strings.TrimSpace(r.Text)
+		var err error
+		switch syslogPriority(r.Type) {
+		case syslog.LOG_ERR:
+			err = w.Err(text)
+		case syslog.LOG_WARNING:
+			err = w.Warning(text)
+		default:
+			err = w.Notice(text)
+		}
+		if err != nil {
+			fmt.Fprint(os.Stderr, r.Text)
+		}
+	}, nil
+}
+
+// get the syslog priority of a report
+//  Args:
+//   reportType (ReportType): kind of the report
+//  Returns:
+//   (syslog.Priority): error for deadlocks, warning for potential deadlocks
+//    and notice for warnings
+func syslogPriority(reportType ReportType) syslog.Priority {
+	switch reportType {
+	case ReportDeadlock:
+		return syslog.LOG_ERR
+	case ReportPotentialDeadlock:
+		return syslog.LOG_WARNING
+	default:
+		return syslog.LOG_NOTICE
+	}
+}
+
+// create a message of the native protocol of journald
+//  Args:
+//   tag (string): identifier of the program
+//   r (Report): report to send
+//  Returns:
+//   ([]byte): the message
+func journalMessage(tag string, r Report) []byte {
+	var b bytes.Buffer
+	writeJournalField(&b, "MESSAGE", strings.TrimSpace(r.Text))
+	writeJournalField(&b, "PRIORITY", fmt.Sprint(int(syslogPriority(r.Type))))
+	writeJournalField(&b, "SYSLOG_IDENTIFIER", tag)
+	writeJournalField(&b, "DEADLOCK_TYPE", r.Type.String())
+	writeJournalField(&b, "DEADLOCK_TITLE", r.Title)
+	if r.ID != "" {
+		writeJournalField(&b, "DEADLOCK_CYCLE_ID", r.ID)
+	}
+	if len(r.Sites) != 0 {
+		writeJournalField(&b, "CODE_FILE", r.Sites[0].File)
+		writeJournalField(&b, "CODE_LINE", fmt.Sprint(r.Sites[0].Line))
+	}
+	return b.Bytes()
+}
+
+// write a field of a message of the native protocol of journald. Values
+// with newlines are written with their length in front of them
+//  Args:
+//   b (*bytes.Buffer): buffer of the message
+//   name (string): name of the field
+//   value (string): value of the field
+//  Returns:
+//   nil
+func writeJournalField(b *bytes.Buffer, name string, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(b, "%s=%s\n", name, value)
+		return
+	}
+
+	b.WriteString(name)
+	b.WriteByte('\n')
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
@@ -0,0 +1,252 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+tags.go
+Implementation of the metadata tags of locks. A tag is an arbitrary key-value
+pair, e.g. the tenant, the component or the name of the struct field of a
+lock. The tags of the locks involved in a report are added to the text of the
+report, to Report.Locks for the sinks and to the exported lock order, so that
+reports can be filtered downstream.
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SetTag sets the tag key of m to value. If m was not created with NewLock,
+// the call of SetTag is saved as its creation
+//  Args:
+//   key (string): key of the tag
+//   value (string): value of the tag
+//  Returns:
+//   nil
+func (m *Mutex) SetTag(key string, value string) {
+	m.lazyInit()
+	setTag(m, key, value)
+}
+
+// SetTag sets the tag key of m to value. If m was not created with NewRWLock,
+// the call of SetTag is saved as its creation
+//  Args:
+//   key (string): key of the tag
+//   value (string): value of the tag
+//  Returns:
+//   nil
+func (m *RWMutex) SetTag(key string, value string) {
+	m.lazyInit()
+	setTag(m, key, value)
+}
+
+// getter for tags
+//  Returns:
+//   (*map[string]string): tags of the lock
+func (m *Mutex) getTags() *map[string]string {
+	return &m.tags
+}
+
+// getter for tags
+//  Returns:
+//   (*map[string]string): tags of the lock
+func (m *RWMutex) getTags() *map[string]string {
+	return &m.tags
+}
+
+// set a tag of a lock
+//  Args:
+//   m (mutexInt): lock
+//   key (string): key of the tag
+//   value (string): value of the tag
+//  Returns:
+//   nil
+func setTag(m mutexInt, key string, value string) {
+	m.getIsLockedRoutineIndexLock().Lock()
+	defer m.getIsLockedRoutineIndexLock().Unlock()
+
+	tags := m.getTags()
+	if *tags == nil {
+		*tags = make(map[string]string)
+	}
+	(*tags)[key] = value
+}
+
+// get a copy of the tags of a lock
+//  Args:
+//   m (mutexInt): lock
+//  Returns:
+//   (map[string]string): tags of the lock, nil if it has no tags
+func copyTags(m mutexInt) map[string]string {
+	m.getIsLockedRoutineIndexLock().Lock()
+	defer m.getIsLockedRoutineIndexLock().Unlock()
+
+	tags := *m.getTags()
+	if len(tags) == 0 {
+		return nil
+	}
+	res := make(map[string]string, len(tags))
+	for key, value := range tags {
+		res[key] = value
+	}
+	return res
+}
+
+// format tags as key=value pairs, sorted by their keys
+//  Args:
+//   tags (map[string]string): tags
+//  Returns:
+//   (string): the formatted tags, empty if there are no tags
+func formatTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+tags[key])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// create the information about locks for a report. Each lock is only
+// contained once
+//  Args:
+//   locks (...mutexInt): locks involved in the report
+//  Returns:
+//   ([]LockInfo): information about the locks
+func lockInfos(locks ...mutexInt) []LockInfo {
+	res := make([]LockInfo, 0, len(locks))
+	seen := make(map[mutexInt]bool, len(locks))
+	for _, m := range locks {
+		if m == nil || seen[m] {
+			continue
+		}
+		seen[m] = true
+		context := *m.getContext()
+		res = append(res, LockInfo{Site: newSite(context[0]), Tags: copyTags(m)})
+	}
+	return res
+}
+
+// LockTags merges the tags of the locks involved in the report. If locks have
+// different values for the same key, the values are joined with commas
+//  Returns:
+//   (map[string]string): the merged tags, nil if no lock has tags
+func (r Report) LockTags() map[string]string {
+	values := make(map[string][]string)
+	for _, lock := range r.Locks {
+		for key, value := range lock.Tags {
+			values[key] = append(values[key], value)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	res := make(map[string]string, len(values))
+	for key, list := range values {
+		sort.Strings(list)
+		unique := make([]string, 0, len(list))
+		for _, value := range list {
+			if len(unique) == 0 || unique[len(unique)-1] != value {
+				unique = append(unique, value)
+			}
+		}
+		res[key] = strings.Join(unique, ",")
+	}
+	return res
+}
+
+// get the locks of dependencies
+//  Args:
+//   deps ([]*dependency): dependencies
+//  Returns:
+//   ([]mutexInt): locks of the dependencies
+func dependencyLocks(deps []*dependency) []mutexInt {
+	res := make([]mutexInt, 0, len(deps))
+	for _, dep := range deps {
+		res = append(res, dep.mu)
+	}
+	return res
+}
+
+// get the locks in a list of resources
+//  Args:
+//   resources (...waitResource): resources
+//  Returns:
+//   ([]mutexInt): resources which are locks
+func resourceLocks(resources ...waitResource) []mutexInt {
+	res := make([]mutexInt, 0, len(resources))
+	for _, resource := range resources {
+		if m, ok := resource.(mutexInt); ok {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+// get the resources of waits
+//  Args:
+//   states ([]waitState): waits
+//  Returns:
+//   ([]waitResource): resources of the waits
+func waitResources(states []waitState) []waitResource {
+	res := make([]waitResource, 0, len(states))
+	for _, state := range states {
+		res = append(res, state.resource)
+	}
+	return res
+}
+
+// write the tags of the locks involved in a report. Nothing is written, if
+// none of the locks has tags
+//  Args:
+//   out (*bytes.Buffer): text of the report
+//   locks ([]LockInfo): locks involved in the report
+//  Returns:
+//   nil
+func writeTags(out *bytes.Buffer, locks []LockInfo) {
+	tagged := false
+	for _, lock := range locks {
+		if len(lock.Tags) > 0 {
+			tagged = true
+			break
+		}
+	}
+	if !tagged {
+		return
+	}
+
+	fmt.Fprintf(out, purple, "Tags of locks involved:\n\n")
+	for _, lock := range locks {
+		if len(lock.Tags) > 0 {
+			fmt.Fprintln(out, lock.Site.File, lock.Site.Line, formatTags(lock.Tags))
+		}
+	}
+	fmt.Fprintf(out, "\n\n")
+}
@@ -0,0 +1,133 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+unlockSite.go
+Implementation of the recording of the release sites of the locks. The
+context of a lock only contains its creation and its acquisitions, but for a
+double locking the missing or misplaced release is usually the bug, e.g. an
+Unlock in a branch, which is not taken. Each lock therefore stores the most
+recent release by each routine and by any routine. The double locking
+reports show the last release of the lock by the routine, which locks it
+again, and the panics for releases of locks, which are not held, show the
+last release of the lock. The sites are only recorded if double locking is
+checked, because resolving the site slows down each release.
+*/
+
+import (
+	"fmt"
+	"time"
+)
+
+// most recent releases of a lock. Protected by the isLockedRoutineIndexLock
+// of the lock
+type unlockInfo struct {
+	// most recent release of the lock by each routine
+	byRoutine map[int]callerInfo
+	// most recent release of the lock by any routine, the zero value if the
+	// lock was never released
+	last callerInfo
+	// index of the routine of last, -1 if the routine is not registered in
+	// the detector
+	lastRoutine int
+}
+
+// create the release information of a new lock
+//  Returns:
+//   (unlockInfo): information without releases
+func newUnlockInfo() unlockInfo {
+	return unlockInfo{
+		byRoutine:   make(map[int]callerInfo),
+		lastRoutine: -1,
+	}
+}
+
+// save the site of the release of a lock by the calling routine
+//  Args:
+//   m (mutexInt): the released lock
+//   index (int): index of the routine, -1 if the routine is not registered
+//  Returns:
+//   nil
+func (d *Detector) recordUnlock(m mutexInt, index int) {
+	if !d.opts.CheckDoubleLocking {
+		return
+	}
+
+	frame := userFrame()
+	info := newInfo(frame.File, frame.Line, frame.Function, false, false, "")
+	info.timestamp = time.Since(d.start)
+
+	m.getIsLockedRoutineIndexLock().Lock()
+	unlocks := m.getUnlockInfo()
+	if index != -1 {
+		unlocks.byRoutine[index] = info
+	}
+	unlocks.last = info
+	unlocks.lastRoutine = index
+	m.getIsLockedRoutineIndexLock().Unlock()
+}
+
+// get the most recent release of a lock by a routine
+//  Args:
+//   m (mutexInt): the lock
+//   index (int): index of the routine
+//  Returns:
+//   (callerInfo): the release
+//   (bool): false if the routine never released the lock or the sites are
+//    not recorded
+func lastUnlock(m mutexInt, index int) (callerInfo, bool) {
+	m.getIsLockedRoutineIndexLock().Lock()
+	defer m.getIsLockedRoutineIndexLock().Unlock()
+	info, ok := m.getUnlockInfo().byRoutine[index]
+	return info, ok
+}
+
+// get the description of the most recent release of a lock for the panic
+// about an operation on a lock, which is not held
+//  Args:
+//   m (mutexInt): the lock
+//   index (int): index of the routine, whose release is described, -1 for
+//    the release by any routine
+//  Returns:
+//   (string): the description, starting with a space, empty if the lock was
+//    not released or the sites are not recorded
+func (d *Detector) lastUnlockHint(m mutexInt, index int) string {
+	m.getIsLockedRoutineIndexLock().Lock()
+	info, routine := m.getUnlockInfo().last, m.getUnlockInfo().lastRoutine
+	if index != -1 {
+		info, routine = m.getUnlockInfo().byRoutine[index], index
+	}
+	m.getIsLockedRoutineIndexLock().Unlock()
+
+	if info.file() == "" {
+		return ""
+	}
+	by := ""
+	if routine != -1 {
+		by = fmt.Sprint(" by ", d.goroutine(routine))
+	}
+	return fmt.Sprint(" It was last released at ", info.file(), ":",
+		info.line(), by, ".")
+}
@@ -0,0 +1,246 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+upgradableRWMutex.go
+This file implements a rw-lock, where a reader lock can be upgraded into a
+writer lock, without another writer being able to acquire the lock between the
+release of the reader lock and the acquisition of the writer lock.
+*/
+
+import (
+	"fmt"
+	"sync"
+)
+
+// UpgradePolicy defines the behavior of UpgradeLock, if another routine
+// is currently upgrading or write-locking the lock
+type UpgradePolicy int
+
+const (
+	// UpgradeQueue lets UpgradeLock wait until the upgrade is possible.
+	// If the upgrade can never succeed, because the routine which is upgrading
+	// or write-locking the lock waits for the release of the reader lock,
+	// the deadlock is reported.
+	UpgradeQueue UpgradePolicy = iota
+	// UpgradeFail lets UpgradeLock return false, if another routine is
+	// currently upgrading or write-locking the lock. The reader lock is still
+	// held in this case.
+	UpgradeFail
+)
+
+// type to implement a rw-lock with the possibility to upgrade reader locks.
+// Unlike the other locks, the zero value can not be used, the lock must be
+// created with NewUpgradableRWLock
+type UpgradableRWMutex struct {
+	// underlying rw-mutex for the actual locking and the detection
+	*RWMutex
+	// behavior of UpgradeLock if the upgrade is not directly possible
+	policy UpgradePolicy
+	// set to true, if a routine is currently upgrading or write-locking the
+	// lock. Only one routine can hold this gate at a time, which guaranties
+	// that no other writer can acquire the lock during an upgrade
+	gateHeld bool
+	// lock to prevent concurrent access to gateHeld
+	gateLock *sync.Mutex
+	// condition to wait for the release of the gate
+	gateCond *sync.Cond
+}
+
+// create a new upgradable rw-lock
+//  Args:
+//   policy (UpgradePolicy): behavior of UpgradeLock, if the upgrade is not
+//    directly possible
+//  Returns:
+//   (*UpgradableRWMutex): the created lock
+func NewUpgradableRWLock(policy UpgradePolicy) *UpgradableRWMutex {
+	return newUpgradableRWLock(defaultDetector, policy, 3)
+}
+
+// create a new upgradable rw-lock and save the caller information of the
+// creation
+//  Args:
+//   d (*Detector): detector the lock belongs to
+//   policy (UpgradePolicy): behavior of UpgradeLock, if the upgrade is not
+//    directly possible
+//   skip (int): number of stack frames between the user code and the
+//    runtime.Caller call
+//  Returns:
+//   (*UpgradableRWMutex): the created lock
+func newUpgradableRWLock(d *Detector, policy UpgradePolicy, skip int) *UpgradableRWMutex {
+	m := UpgradableRWMutex{
+		RWMutex:  newRWLock(d, skip),
+		policy:   policy,
+		gateLock: &sync.Mutex{},
+	}
+	m.gateCond = sync.NewCond(m.gateLock)
+
+	return &m
+}
+
+// ====== GATE =================================================================
+
+// acquire the gate of the lock
+//  Args:
+//   upgrade (bool): true if the gate is acquired for an upgrade, false if it
+//    is acquired for a writer lock
+//  Returns:
+//   (bool): true if the gate was acquired, false if the policy is UpgradeFail
+//    and the gate is held by another routine
+func (m *UpgradableRWMutex) acquireGate(upgrade bool) bool {
+	m.checkCreated()
+
+	m.gateLock.Lock()
+
+	// true if the deadlock of the upgrade was already reported
+	reported := false
+	for m.gateHeld {
+		if upgrade && m.policy == UpgradeFail {
+			m.gateLock.Unlock()
+			return false
+		}
+
+		// The routine holding the gate waits for the writer lock and can therefore
+		// only continue, if the reader lock of the upgrading routine is released.
+		// The upgrading routine on the other hand can only release the reader
+		// lock after getting the gate.
+		d := m.getDetector()
+		if upgrade && !reported && d.opts.Activated && d.opts.CheckDoubleLocking &&
+			!d.isShutdown() {
+			reported = true
+
+			// the termination runs callbacks and hooks, which may use the
+			// lock, gateLock must therefore not be held
+			m.gateLock.Unlock()
+			d.reportDeadlockUpgrade(m.RWMutex)
+			d.terminate()
+			m.gateLock.Lock()
+			continue
+		}
+
+		m.gateCond.Wait()
+	}
+
+	m.gateHeld = true
+	m.gateLock.Unlock()
+	return true
+}
+
+// panic with a description, if the lock was declared as a zero value instead
+// of being created with NewUpgradableRWLock
+//  Returns:
+//   nil
+func (m *UpgradableRWMutex) checkCreated() {
+	if m.RWMutex == nil {
+		panic("UpgradableRWMutex was not created. Use x := NewUpgradableRWLock(policy).")
+	}
+}
+
+// release the gate of the lock
+//  Returns:
+//   nil
+func (m *UpgradableRWMutex) releaseGate() {
+	m.gateLock.Lock()
+	m.gateHeld = false
+	m.gateLock.Unlock()
+	m.gateCond.Signal()
+}
+
+// ====== FUNCTIONS ============================================================
+
+// Lock upgradable rw-mutex m
+//  Returns:
+//   nil
+func (m *UpgradableRWMutex) Lock() {
+	m.acquireGate(false)
+	// call the lock method for the mutexInt interface
+	lockInt(m.RWMutex, false, false)
+	m.releaseGate()
+}
+
+// TryLock upgradable rw-mutex m
+//  Returns:
+//   (bool): true if locking was successful, false otherwise
+func (m *UpgradableRWMutex) TryLock() bool {
+	m.checkCreated()
+
+	// the gate is not acquired, because tryLockInt does not wait. Holding
+	// gateLock is therefore sufficient to prevent an upgrade in between
+	m.gateLock.Lock()
+	defer m.gateLock.Unlock()
+	if m.gateHeld {
+		return false
+	}
+
+	// call the try-lock method for the mutexInt interface
+	return tryLockInt(m.RWMutex, false)
+}
+
+// UpgradeLock upgrades the reader lock of the calling routine on m into a
+// writer lock. No other writer can acquire m between the release of the reader
+// lock and the acquisition of the writer lock. If the upgrade is successful,
+// the lock must be released with Unlock.
+//  If another routine is currently upgrading or write-locking m and the policy
+//  of m is UpgradeFail, false is returned and the reader lock is still held.
+//  If the policy is UpgradeQueue, the upgrade would wait for a routine which
+//  itself waits for the release of the reader lock. This deadlock is reported
+//  and the program is terminated.
+//  Returns:
+//   (bool): true if the upgrade was successful, false otherwise
+func (m *UpgradableRWMutex) UpgradeLock() bool {
+	m.checkCreated()
+	d := m.getDetector()
+
+	// panic if the routine does not hold the reader lock
+	if d.opts.Activated && d.enter() {
+		index := d.getRoutineIndex()
+		m.isLockedRoutineIndexLock.Lock()
+		holdsRLock := index != -1 && m.isLockedRoutineIndex[index] > 0 &&
+			m.isRLock[index]
+		m.isLockedRoutineIndexLock.Unlock()
+		d.leave()
+		if !holdsRLock {
+			errorMessage := fmt.Sprint("Tried to upgrade lock ", &m,
+				" which was not r-locked by the routine.",
+				d.lastUnlockHint(m.RWMutex, index))
+			panic(errorMessage)
+		}
+	}
+
+	if !m.acquireGate(true) {
+		return false
+	}
+
+	// release the reader lock and acquire the writer lock. The acquisition
+	// is recorded as an upgrade
+	if d.opts.Activated {
+		unlockInt(m.RWMutex)
+	}
+	m.mu.RUnlock()
+	lockInt(m.RWMutex, false, true)
+
+	m.releaseGate()
+	return true
+}
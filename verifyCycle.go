@@ -0,0 +1,155 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+verifyCycle.go
+This file implements the verification of a reported potential deadlock. The
+chains of dependencies of the reported cycles are saved with the identifier
+of the cycle. The verification replays the chain of a report against the
+current lock trees and checks again, that every dependency extends the
+chain (isChain), that the last dependency closes the cycle (isCycleChain),
+including the rules for rw-locks, and that the cycle does not contain a
+shorter cycle.
+*/
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownCycle is returned by VerifyCycle, if the report was not created
+// for a cycle in the lock trees of the detector
+var ErrUnknownCycle = errors.New("deadlock: the report does not belong to a " +
+	"cycle in the lock trees of the detector")
+
+// VerifyCycle verifies a potential deadlock reported by the default
+// detector (see Detector.VerifyCycle)
+//  Args:
+//   r (Report): report of the potential deadlock
+//  Returns:
+//   (error): nil if the cycle is valid, an error describing the first
+//    violated rule otherwise
+func VerifyCycle(r Report) error {
+	return defaultDetector.VerifyCycle(r)
+}
+
+// VerifyCycle replays the chain of dependencies of a reported potential
+// deadlock against the lock trees of the detector and checks it again with
+// the rules of the comprehensive detection
+//  Args:
+//   r (Report): report of the potential deadlock
+//  Returns:
+//   (error): nil if the cycle is valid, ErrUnknownCycle if the report does
+//    not belong to a cycle of the detector, or an error describing the
+//    first violated rule
+func (d *Detector) VerifyCycle(r Report) error {
+	d.reportedCyclesLock.Lock()
+	cycle, ok := d.reportedCycles[r.ID]
+	d.reportedCyclesLock.Unlock()
+	if r.Type != ReportPotentialDeadlock || !ok {
+		return ErrUnknownCycle
+	}
+
+	// the dependencies must still be in the lock trees of different routines
+	routines := make(map[int]int)
+	for i, e := range cycle {
+		if !d.inLockTree(e.depEntry, e.index) {
+			return fmt.Errorf("deadlock: dependency %d of cycle %s (%s) is not "+
+				"in the lock tree of routine %d", i+1, r.ID, dependencySite(e.depEntry),
+				e.index)
+		}
+		if j, ok := routines[e.index]; ok {
+			return fmt.Errorf("deadlock: dependencies %d and %d of cycle %s "+
+				"belong to the same routine %d", j+1, i+1, r.ID, e.index)
+		}
+		routines[e.index] = i
+	}
+
+	// replay the chain
+	stack := newDepStack()
+	stack.push(cycle[0].depEntry, cycle[0].index)
+	for i := 1; i < len(cycle); i++ {
+		e := cycle[i]
+		if !d.isChain(&stack, e.depEntry, e.index, false) {
+			return fmt.Errorf("deadlock: dependency %d of cycle %s (%s) does not "+
+				"extend the chain", i+1, r.ID, dependencySite(e.depEntry))
+		}
+		if i == len(cycle)-1 && !d.isCycleChain(&stack, e.depEntry, e.index, false) {
+			return fmt.Errorf("deadlock: dependency %d of cycle %s (%s) does not "+
+				"close the cycle", i+1, r.ID, dependencySite(e.depEntry))
+		}
+		stack.push(e.depEntry, e.index)
+	}
+
+	if d.hasChord(&stack, false) {
+		return fmt.Errorf("deadlock: cycle %s contains a shorter cycle", r.ID)
+	}
+	return nil
+}
+
+// save the chain of a reported cycle
+//  Args:
+//   id (string): identifier of the cycle
+//   stack (*depStack): stack which represents the cycle
+//  Returns:
+//   nil
+func (d *Detector) saveReportedCycle(id string, stack *depStack) {
+	cycle := make([]stackElement, 0, stack.size)
+	for cl := stack.stack.next; cl != nil; cl = cl.next {
+		cycle = append(cycle, newStackElement(cl.depEntry, cl.index))
+	}
+
+	d.reportedCyclesLock.Lock()
+	d.reportedCycles[id] = cycle
+	d.reportedCyclesLock.Unlock()
+}
+
+// check if a dependency is in the lock tree of a routine
+//  Args:
+//   dep (*dependency): the dependency
+//   index (int): index of the routine
+//  Returns:
+//   (bool): true if dep is in the lock tree of the routine
+func (d *Detector) inLockTree(dep *dependency, index int) bool {
+	trees := d.takeSnapshot().dependencies
+	if index < 0 || index >= len(trees) {
+		return false
+	}
+	for _, other := range trees[index] {
+		if other == dep {
+			return true
+		}
+	}
+	return false
+}
+
+// get the site of the acquisition, which created a dependency
+//  Args:
+//   dep (*dependency): the dependency
+//  Returns:
+//   (string): file and line of the acquisition
+func dependencySite(dep *dependency) string {
+	return fmt.Sprint(dep.caller.file(), ":", dep.caller.line())
+}
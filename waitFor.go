@@ -0,0 +1,168 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+waitFor.go
+This file connects the detector with the wait-for graph of the periodical
+detection (internal/waitfor). The locks hold a node of the graph, which
+stores the routines holding the lock. Each routine, which is currently
+blocked by a lock or another synchronization primitive, has a want edge to
+this resource. Resources without a node provide the routines they are
+blocked by themselves. A cycle in the graph, which does not change between
+two periodical detections, is a deadlock. A lock operation first tries to
+acquire the lock. Only if this fails, the routine is actually blocked and its
+wait for the lock is registered with the site of the acquisition, so that the
+graph contains the actual waits instead of the last acquisitions of the
+routines.
+*/
+
+import (
+	"time"
+
+	"github.com/ErikKassubek/Deadlock-Go/internal/waitfor"
+)
+
+// interface for resources on which a routine can wait
+type waitResource interface {
+	// getter for context
+	getContext() *[]callerInfo
+	// getter for the name of the resource type
+	getResourceName() string
+}
+
+// interface for resources on which a routine can wait, which do not have a
+// node in the wait-for graph
+type blockingResource interface {
+	waitResource
+	// get the routines a routine waiting for the resource waits for.
+	// Routines, which are only inferred, e.g. from earlier uses of the
+	// resource, are returned as inferred blockers. If the resource can also
+	// be released by routines, which are not known yet, all other blocked
+	// routines are considered as inferred blockers
+	getBlockers(routineIndex int, read bool) waitfor.Blockers
+}
+
+// type to save on which resource a routine waits
+type waitState struct {
+	// resource the routine waits for
+	resource waitResource
+	// true if the routine waits for a reader lock
+	read bool
+	// caller info of the wait
+	caller callerInfo
+	// time when the routine started to wait
+	start time.Time
+	// true if the wait was already reported as a long wait
+	reported bool
+}
+
+// register that a routine starts to wait for a resource. Locks are waited
+// for on their node, the blockers of other resources are requested from
+// the resource
+//  Args:
+//   index (int): index of the routine
+//   resource (waitResource): resource the routine waits for
+//   read (bool): true if the routine waits for a reader lock
+//   caller (callerInfo): caller info of the wait
+//  Returns:
+//   nil
+func (d *Detector) startWaiting(index int, resource waitResource, read bool, caller callerInfo) {
+	var node *waitfor.Node
+	if m, ok := resource.(mutexInt); ok {
+		node = m.getWaitNode()
+	}
+	d.waitGraph.Want(index, resource, node, read, &waitState{
+		resource: resource,
+		read:     read,
+		caller:   caller,
+		start:    time.Now(),
+	})
+}
+
+// acquire the underlying lock of a mutex or rw-mutex. If the lock is not
+// available, the wait of the routine is registered in the wait-for graph
+// until the lock is acquired
+//  Args:
+//   m (mutexInt): mutex or rw-mutex to lock
+//   index (int): index of the routine, -1 if the routine is not registered
+//   rLock (bool): true if the lock is a reader lock
+//   upgrade (bool): true if the lock is acquired as an upgrade of a reader
+//    lock
+//  Returns:
+//   nil
+func (d *Detector) acquireLockWaiting(m mutexInt, index int, rLock bool, upgrade bool) {
+	if index == -1 || !d.opts.PeriodicDetection {
+		acquireLock(m, rLock)
+		return
+	}
+
+	// the routine is not blocked, if the lock is available
+	if tryAcquireLock(m, rLock) {
+		return
+	}
+
+	frame := userFrame()
+	d.startWaiting(index, m, rLock, newInfo(frame.File, frame.Line, frame.Function,
+		false, upgrade, ""))
+	acquireLock(m, rLock)
+	d.stopWaiting(index)
+}
+
+// register that a routine has stopped waiting
+//  Args:
+//   index (int): index of the routine
+//  Returns:
+//   nil
+func (d *Detector) stopWaiting(index int) {
+	d.waitGraph.Done(index)
+}
+
+// search for a cycle in the wait-for graph. If the same cycle, with the same
+// waits, was already found in the last periodical detection, the program
+// is in a deadlock. The deadlock is reported and the program is terminated.
+// A cycle over an inferred blocker can be broken by another routine. It is
+// only reported as a warning and the program is not terminated.
+//  Returns:
+//   nil
+func (d *Detector) periodicalWaitForDetection() {
+	cycle, wants, confirmed, inferred := d.waitGraph.Check(func(index int, w *waitfor.Want) waitfor.Blockers {
+		return w.Resource.(blockingResource).getBlockers(index, w.Shared)
+	})
+	if !confirmed {
+		return
+	}
+
+	// copy the waits for the report
+	states := make([]waitState, 0, len(wants))
+	for _, w := range wants {
+		states = append(states, *w.Data.(*waitState))
+	}
+	if inferred {
+		d.reportInferredWaitFor(cycle, states)
+		return
+	}
+	d.reportDeadlockWaitFor(cycle, states)
+	d.terminate()
+}
@@ -0,0 +1,327 @@
+package deadlock
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: deadlock
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+waitGroup.go
+This file implements a wait group, which can be used as a drop-in
+replacement for sync.WaitGroup. The wait group is represented in the lock
+trees by a rw-lock, whose underlying lock is never acquired. A routine
+started with Go holds the representation as a reader lock until its
+function returns, because Wait can not return before. Wait acquires the
+representation as a writer lock and releases it immediately, so that the
+locks held by the waiting routine are recorded as dependencies on the wait
+group. If a routine holds a lock m while it waits, and a routine of the wait
+group acquires m, the dependencies build a cycle, which is found by the
+comprehensive detection. The waiting routine is also registered in the
+wait-for graph as a writer on the representation, so that it is blocked by
+the routines of the wait group, which are still running. Routines, which
+only call Done, can not be known before they call it. After a wait group
+was created, the locks acquired by a routine since its start or its last
+call of Done are therefore remembered, and Done records them as acquired
+while the routine held the representation as a reader lock. A routine
+holding a lock while it waits, and a routine which acquired the lock before
+it called Done, build the same cycle as with Go. Routines, which only call
+Done, are not blockers in the wait-for graph.
+*/
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// type to implement a wait group
+type WaitGroup struct {
+	// wait group for the actual waiting
+	wg sync.WaitGroup
+	// representation of the wait group in the lock trees
+	m *RWMutex
+	// initializes a wait group, which was not created with NewWaitGroup, at
+	// its first use
+	lazy sync.Once
+}
+
+// lock acquired by a routine before it calls Done
+type doneAcquisition struct {
+	// the acquired lock
+	mu mutexInt
+	// true if mu was acquired as a reader lock
+	read bool
+}
+
+// create a new wait group
+//  Returns:
+//   (*WaitGroup): the created wait group
+func NewWaitGroup() *WaitGroup {
+	return newWaitGroup(defaultDetector, 2)
+}
+
+// create a new wait group and save the caller information of the creation
+//  Args:
+//   d (*Detector): detector the wait group belongs to
+//   skip (int): number of stack frames between the user code and the
+//    callerSite call
+//  Returns:
+//   (*WaitGroup): the created wait group
+func newWaitGroup(d *Detector, skip int) *WaitGroup {
+	// initialize detector if necessary
+	if !d.initialized {
+		d.initialize()
+	}
+
+	m := &RWMutex{represents: "WaitGroup"}
+
+	// save the position of the NewWaitGroup call
+	pc, file, line, _ := callerSite(skip)
+	m.init(d, file, line, funcName(pc))
+	atomic.AddInt32(&d.waitGroups, 1)
+
+	wg := WaitGroup{}
+	wg.lazy.Do(func() {
+		wg.m = m
+	})
+	return &wg
+}
+
+// initialize a wait group, which was declared as a zero value instead of
+// being created with NewWaitGroup. The first use of the wait group is saved
+// as its creation
+//  Returns:
+//   nil
+func (wg *WaitGroup) lazyInit() {
+	wg.lazy.Do(func() {
+		wg.m = &RWMutex{represents: "WaitGroup"}
+		wg.m.lazyInit()
+		atomic.AddInt32(&wg.m.getDetector().waitGroups, 1)
+	})
+}
+
+// Add adds delta, which may be negative, to the counter of the wait group.
+// If the counter becomes zero, all routines blocked in Wait are released
+//  Args:
+//   delta (int): value to add to the counter
+//  Returns:
+//   nil
+func (wg *WaitGroup) Add(delta int) {
+	wg.lazyInit()
+	wg.wg.Add(delta)
+}
+
+// Done decrements the counter of the wait group by one. The locks acquired
+// by the routine since its start or its last call of Done are recorded as
+// acquired while the routine held the wait group
+//  Returns:
+//   nil
+func (wg *WaitGroup) Done() {
+	wg.lazyInit()
+	wg.recordDone()
+	wg.wg.Done()
+}
+
+// Go calls f in a new routine and adds the routine to the wait group. The
+// routine holds the wait group in the lock trees, while f runs, so that
+// cycles between the locks acquired by f and the locks held by the waiting
+// routines are found
+//  Args:
+//   f (func()): function to run
+//  Returns:
+//   nil
+func (wg *WaitGroup) Go(f func()) {
+	wg.lazyInit()
+	wg.wg.Add(1)
+
+	// the go statement below is in the detector, the routine is therefore
+	// shown as created by the call of Go
+	frame := userFrame()
+	created := Site{File: frame.File, Line: frame.Line, Function: frame.Function}
+
+	go func() {
+		defer wg.wg.Done()
+
+		d := wg.m.getDetector()
+		if !d.opts.Activated {
+			f()
+			return
+		}
+		if index := d.registerRoutine(); index != -1 {
+			d.setGoroutineCreated(index, created)
+		}
+		lockInt(wg.m, true, false)
+		defer unlockInt(wg.m)
+		f()
+	}()
+}
+
+// Wait blocks until the counter of the wait group is zero. The locks held by
+// the routine are recorded as dependencies on the wait group
+//  Returns:
+//   nil
+func (wg *WaitGroup) Wait() {
+	wg.lazyInit()
+
+	d := wg.m.getDetector()
+	if !d.opts.Activated {
+		wg.wg.Wait()
+		return
+	}
+
+	lockInt(wg.m, false, false)
+	unlockInt(wg.m)
+
+	// register the wait in the wait-for graph. The routines started with Go
+	// hold the wait group as readers of its node
+	index := -1
+	if d.opts.PeriodicDetection {
+		index = d.registerRoutine()
+	}
+	if index != -1 {
+		frame := userFrame()
+		d.startWaiting(index, wg.m, false, newInfo(frame.File, frame.Line,
+			frame.Function, false, false, ""))
+	}
+
+	wg.wg.Wait()
+
+	if index != -1 {
+		d.stopWaiting(index)
+	}
+}
+
+// record the locks acquired by the calling routine since its start or its
+// last call of Done as dependencies on the representation of the wait group
+//  Returns:
+//   nil
+func (wg *WaitGroup) recordDone() {
+	d := wg.m.getDetector()
+	if !d.opts.Activated || !d.opts.ComprehensiveDetection || !d.enter() {
+		return
+	}
+	defer d.leave()
+
+	index := d.getRoutineIndex()
+	if index == -1 {
+		return
+	}
+	r := &d.routines[index]
+	if len(r.doneAcquisitions) == 0 {
+		return
+	}
+
+	// wait while the world is stopped for a snapshot of the lock trees
+	if d.enterBarrier() {
+		defer d.leaveBarrier()
+	}
+
+	for a, info := range r.doneAcquisitions {
+		r.addDoneDependency(wg.m, a, info)
+	}
+	r.doneAcquisitions = make(map[doneAcquisition]callerInfo)
+}
+
+// remember the acquisition of a lock for the next call of Done. Only the
+// first acquisition of each lock is remembered. The representations of wait
+// groups are not remembered
+//  Args:
+//   m (mutexInt): acquired lock
+//   rLock (bool): true if m is acquired as a reader lock
+//  Returns:
+//   nil
+func (r *routine) recordDoneAcquisition(m mutexInt, rLock bool) {
+	if mu, ok := m.(*RWMutex); ok && mu.represents == "WaitGroup" {
+		return
+	}
+
+	a := doneAcquisition{mu: m, read: rLock}
+	if _, ok := r.doneAcquisitions[a]; ok {
+		return
+	}
+	if r.doneAcquisitions == nil {
+		r.doneAcquisitions = make(map[doneAcquisition]callerInfo)
+	}
+
+	frame, condWait := userFrameCond()
+	info := newInfo(frame.File, frame.Line, frame.Function, false, false, "")
+	info.condWait = condWait
+	info.timestamp = time.Since(r.detector.start)
+	r.doneAcquisitions[a] = info
+}
+
+// add the dependency of a lock acquired before Done on the representation of
+// a wait group to the lock tree of the routine, as if the routine had held
+// the representation as a reader lock while it acquired the lock. If the
+// dependency already exists, only the time of its last acquisition is
+// renewed
+//  Args:
+//   wgm (*RWMutex): representation of the wait group
+//   a (doneAcquisition): lock acquired before Done
+//   info (callerInfo): caller information of the acquisition
+//  Returns:
+//   nil
+func (r *routine) addDoneDependency(wgm *RWMutex, a doneAcquisition, info callerInfo) {
+	key := a.mu.getMemoryPosition() ^ wgm.getMemoryPosition()
+	list, ok := r.dependencyMap[key]
+	if ok {
+		for _, dep := range *list {
+			if dep.mu == a.mu && dep.read == a.read && !dep.upgrade &&
+				dep.holdingCount == 1 && dep.holdingSet[0] == mutexInt(wgm) &&
+				dep.holdingRead[0] {
+				dep.observe(info.timestamp)
+				return
+			}
+		}
+	}
+
+	if r.depCount >= r.detector.opts.MaxDependencies {
+		panic(`Number of dependencies is greater than max number of 
+			dependencies. Increase Opts.MaxDependencies.`)
+	}
+
+	holding := []mutexInt{wgm}
+	dep := newDependency(a.mu, a.read, holding, []bool{true}, 1, false)
+	dep.first = info.timestamp
+	dep.last = int64(info.timestamp)
+	dep.count = 1
+	dep.caller = info
+	dep.update(a.mu, &holding, 1)
+	r.dependencies[r.depCount] = &dep
+	r.depCount++
+
+	if ok {
+		*list = append(*list, &dep)
+	} else {
+		list = &[]*dependency{&dep}
+	}
+	r.dependencyMap[key] = list
+
+	markInLockTree(a.mu)
+	markInLockTree(wgm)
+	context := a.mu.getContext()
+	*context = append(*context, info)
+
+	atomic.StoreInt32(&r.published, int32(r.depCount))
+	r.detector.advanceEpoch()
+	r.detector.publishDependency(&dep)
+	r.detector.recordDelta(&dep, r.index)
+}
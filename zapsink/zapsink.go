@@ -0,0 +1,83 @@
+package zapsink
+
+/*
+Copyright (c) 2022, Erik Kassubek
+All rights reserved.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+/*
+Author: Erik Kassubek <erik-kassubek@t-online.de>
+Package: zapsink
+Project: Bachelor Project at the Albert-Ludwigs-University Freiburg,
+	Institute of Computer Science: Dynamic Deadlock Detection in Go
+*/
+
+/*
+zapsink.go
+This package implements a report sink on top of the sugared logger of zap.
+The reports are logged with structured fields, so that they can be delivered
+by the existing logging pipeline of a service. The package does not import
+zap, the sink accepts every logger with the methods of *zap.SugaredLogger
+used by it, e.g. logger.Sugar().
+*/
+
+import (
+	"fmt"
+
+	deadlock "github.com/ErikKassubek/Deadlock-Go"
+)
+
+// Logger is the part of *zap.SugaredLogger used by the sink
+type Logger interface {
+	Errorw(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+}
+
+// New creates a sink, which logs the reports with logger. Deadlocks are logged
+// as errors, potential deadlocks as warnings and warnings as infos
+//  Args:
+//   logger (Logger): logger for the reports, e.g. logger.Sugar()
+//  Returns:
+//   (deadlock.ReportSink): the created sink
+func New(logger Logger) deadlock.ReportSink {
+	return func(r deadlock.Report) {
+		sites := make([]string, 0, len(r.Sites))
+		for _, site := range r.Sites {
+			sites = append(sites, fmt.Sprint(site.File, ":", site.Line))
+		}
+
+		keysAndValues := []interface{}{
+			"type", r.Type.String(),
+			"title", r.Title,
+			"sites", sites,
+			"report", r.Text,
+		}
+		if r.ID != "" {
+			keysAndValues = append(keysAndValues, "cycle_id", r.ID)
+		}
+		if tags := r.LockTags(); tags != nil {
+			keysAndValues = append(keysAndValues, "lock_tags", tags)
+		}
+
+		switch r.Type {
+		case deadlock.ReportDeadlock:
+			logger.Errorw(r.Title, keysAndValues...)
+		case deadlock.ReportPotentialDeadlock:
+			logger.Warnw(r.Title, keysAndValues...)
+		default:
+			logger.Infow(r.Title, keysAndValues...)
+		}
+	}
+}